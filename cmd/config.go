@@ -8,13 +8,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/tght/lan-proxy-gateway/internal/app"
 	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
 var configCmd = &cobra.Command{
@@ -25,6 +28,7 @@ var configCmd = &cobra.Command{
 例：
   gateway config show --json
   gateway config source --type subscription --url https://example.com/sub
+  gateway config source --type multi --sub airA=https://a.example/sub --sub self=https://b.example/nodes
   gateway config mode rule
   gateway config tun on
   gateway config adblock off
@@ -35,12 +39,13 @@ var configCmd = &cobra.Command{
 // configView 是 config show 的机器可读快照（不含敏感的脚本路径细节）。
 type configView struct {
 	Source struct {
-		Type   string `json:"type"`
-		URL    string `json:"url,omitempty"`
-		Path   string `json:"path,omitempty"`
-		Server string `json:"server,omitempty"`
-		Port   int    `json:"port,omitempty"`
-		Kind   string `json:"kind,omitempty"`
+		Type   string   `json:"type"`
+		URL    string   `json:"url,omitempty"`
+		URLs   []string `json:"urls,omitempty"`
+		Path   string   `json:"path,omitempty"`
+		Server string   `json:"server,omitempty"`
+		Port   int      `json:"port,omitempty"`
+		Kind   string   `json:"kind,omitempty"`
 	} `json:"source"`
 	Mode        string `json:"mode"`
 	TUN         bool   `json:"tun"`
@@ -65,6 +70,10 @@ func buildConfigView(a *app.App) configView {
 	v.Source.URL = c.Source.Subscription.URL
 	v.Source.Path = c.Source.File.Path
 	switch c.Source.Type {
+	case config.SourceTypeMulti:
+		for _, s := range c.Source.Subscriptions {
+			v.Source.URLs = append(v.Source.URLs, s.URL)
+		}
 	case config.SourceTypeExternal:
 		v.Source.Server = c.Source.External.Server
 		v.Source.Port = c.Source.External.Port
@@ -112,6 +121,9 @@ var configShowCmd = &cobra.Command{
 		if v.Source.URL != "" {
 			fmt.Printf("  url:    %s\n", v.Source.URL)
 		}
+		for _, u := range v.Source.URLs {
+			fmt.Printf("  url:    %s\n", u)
+		}
 		if v.Source.Path != "" {
 			fmt.Printf("  path:   %s\n", v.Source.Path)
 		}
@@ -132,39 +144,27 @@ var configShowCmd = &cobra.Command{
 
 // ---- config source ----
 
-var (
-	srcType, srcURL, srcPath, srcServer, srcKind, srcUser, srcPass string
-	srcPort                                                        int
-)
+// sourceFlags 是 --type/--url/--path/... 这组源 flag 的取值。`config source` 和
+// `profile add` 各持一份，用 bindSourceFlags 注册，sourceFromFlags 组装。
+type sourceFlags struct {
+	typ, url, path, server, kind, user, pass string
+	port                                     int
+	subs                                     []string // type=multi：每项 name=url
+}
+
+var srcFlags sourceFlags
 
 var configSourceCmd = &cobra.Command{
 	Use:   "source",
-	Short: "设置代理源（--type subscription|file|external|remote|none）",
+	Short: "设置代理源（--type subscription|multi|file|external|remote|none）",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		a, err := app.New()
 		if err != nil {
 			return err
 		}
-		src := config.SourceConfig{Type: srcType}
-		switch srcType {
-		case config.SourceTypeSubscription:
-			if srcURL == "" {
-				return fmt.Errorf("--type subscription 需要 --url")
-			}
-			src.Subscription = config.SubscriptionSource{URL: srcURL, Name: "subscription"}
-		case config.SourceTypeFile:
-			if srcPath == "" {
-				return fmt.Errorf("--type file 需要 --path")
-			}
-			src.File = config.FileSource{Path: srcPath}
-		case config.SourceTypeExternal:
-			src.External = config.ExternalProxy{Name: "外部代理", Server: srcServer, Port: srcPort, Kind: srcKind}
-		case config.SourceTypeRemote:
-			src.Remote = config.RemoteProxy{Name: "远程代理", Server: srcServer, Port: srcPort, Kind: srcKind, Username: srcUser, Password: srcPass}
-		case config.SourceTypeNone, "":
-			src.Type = config.SourceTypeNone
-		default:
-			return fmt.Errorf("不支持的源类型: %s", srcType)
+		src, err := sourceFromFlags(srcFlags)
+		if err != nil {
+			return err
 		}
 		if err := a.SetSource(context.Background(), src); err != nil {
 			return err
@@ -174,6 +174,59 @@ var configSourceCmd = &cobra.Command{
 	},
 }
 
+// sourceFromFlags 把 --type/--url/--path/... 这组 flag 组装成 SourceConfig。
+// `config source` 和 `profile add` 共用，保证两边对同一组 flag 的理解一致。
+func sourceFromFlags(f sourceFlags) (config.SourceConfig, error) {
+	src := config.SourceConfig{Type: f.typ}
+	switch f.typ {
+	case config.SourceTypeSubscription:
+		if f.url == "" {
+			return src, fmt.Errorf("--type subscription 需要 --url")
+		}
+		src.Subscription = config.SubscriptionSource{URL: f.url, Name: "subscription"}
+	case config.SourceTypeMulti:
+		if len(f.subs) == 0 {
+			return src, fmt.Errorf("--type multi 需要至少一个 --sub name=url")
+		}
+		for _, raw := range f.subs {
+			name, u, ok := strings.Cut(raw, "=")
+			if !ok || strings.TrimSpace(u) == "" {
+				return src, fmt.Errorf("--sub 格式应为 name=url，当前: %q", raw)
+			}
+			src.Subscriptions = append(src.Subscriptions, config.SubscriptionSource{
+				Name: strings.TrimSpace(name), URL: strings.TrimSpace(u),
+			})
+		}
+	case config.SourceTypeFile:
+		if f.path == "" {
+			return src, fmt.Errorf("--type file 需要 --path")
+		}
+		src.File = config.FileSource{Path: f.path}
+	case config.SourceTypeExternal:
+		src.External = config.ExternalProxy{Name: "外部代理", Server: f.server, Port: f.port, Kind: f.kind}
+	case config.SourceTypeRemote:
+		src.Remote = config.RemoteProxy{Name: "远程代理", Server: f.server, Port: f.port, Kind: f.kind, Username: f.user, Password: f.pass}
+	case config.SourceTypeNone, "":
+		src.Type = config.SourceTypeNone
+	default:
+		return src, fmt.Errorf("不支持的源类型: %s", f.typ)
+	}
+	return src, nil
+}
+
+// bindSourceFlags 给 cmd 注册一整套源 flag，写进 f。
+func bindSourceFlags(cmd *cobra.Command, f *sourceFlags) {
+	cmd.Flags().StringVar(&f.typ, "type", "", "subscription|multi|file|external|remote|none")
+	cmd.Flags().StringVar(&f.url, "url", "", "订阅 URL（type=subscription）")
+	cmd.Flags().StringArrayVar(&f.subs, "sub", nil, "name=url，可重复（type=multi，多订阅合并）")
+	cmd.Flags().StringVar(&f.path, "path", "", "本地 clash yaml 路径（type=file）")
+	cmd.Flags().StringVar(&f.server, "server", "", "主机（type=external/remote）")
+	cmd.Flags().IntVar(&f.port, "port", 0, "端口（type=external/remote）")
+	cmd.Flags().StringVar(&f.kind, "kind", "http", "http|socks5")
+	cmd.Flags().StringVar(&f.user, "user", "", "用户名（type=remote，可选）")
+	cmd.Flags().StringVar(&f.pass, "pass", "", "密码（type=remote，可选）")
+}
+
 // ---- config mode / tun / adblock / gateway-mode ----
 
 var configModeCmd = &cobra.Command{
@@ -274,6 +327,9 @@ var configRuleAddCmd = &cobra.Command{
 			return err
 		}
 		fmt.Printf("✓ 已加规则 [%s] %s\n", args[0], rule)
+		for _, is := range app.RuleIssues(a.LintRules(cmd.Context()), rule) {
+			color.New(color.FgYellow).Printf("  ⚠ %s: %s\n", is.Where, is.Message)
+		}
 		return nil
 	},
 }
@@ -330,6 +386,95 @@ var configRuleRmCmd = &cobra.Command{
 	},
 }
 
+// ---- config rule import ----
+
+var (
+	configRuleImportFormat string
+	configRuleImportApply  bool
+)
+
+var configRuleImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "从 Surge / Quantumult X / Shadowrocket 配置导入规则（默认只预览，--apply 写入）",
+	Long: `把 Surge / Shadowrocket 的 [Rule] 段、Quantumult X 的 [filter_local] 段转成 mihomo 规则，
+按策略分进自定义规则的 direct / proxy / reject / 指定策略组。已有的规则跳过；
+转不了的行（USER-AGENT、URL-REGEX、外部 RULE-SET、FINAL…）列在报告里；分桶后会被
+提到一条范围重叠的规则前面、命中结果会变的行也不导入，一并列出。
+默认是 dry-run：只打印会新增哪些规则，确认无误后加 --apply 再跑一次写入。
+
+例：
+  gateway config rule import ~/Downloads/surge.conf --format surge
+  gateway config rule import qx.conf --format qx --apply`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", args[0], err)
+		}
+		res, err := traffic.ParseImport(data, strings.ToLower(configRuleImportFormat))
+		if err != nil {
+			return err
+		}
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		targets := a.PolicyTargets(cmd.Context())
+		res = traffic.DropUnknownTargets(res, targets)
+		merged, added := traffic.MergeImport(a.Cfg.Traffic.Extras, res.Rules)
+
+		dim := color.New(color.Faint)
+		green := color.New(color.FgGreen)
+		for _, r := range added {
+			bucket := r.Bucket
+			if r.Bucket == "group" {
+				bucket = "group:" + r.Target
+			}
+			green.Printf("+ [%s] %s\n", bucket, r.Rule)
+		}
+		fmt.Printf("\n共 %d 条规则：新增 %d，已存在或重复 %d，无法转换 %d\n",
+			len(res.Rules)+len(res.Skipped), len(added), len(res.Rules)-len(added), len(res.Skipped))
+		if len(res.Skipped) > 0 {
+			fmt.Println("\n无法转换：")
+			for _, sk := range res.Skipped {
+				dim.Printf("  第 %d 行  %s\n", sk.Line, sk.Text)
+				fmt.Printf("          %s\n", sk.Reason)
+			}
+		}
+		// 导入后的整体检查：语法错误、指向不存在的策略组、被已有规则挡住的都提前说。
+		// 前两种是 SeverityError，写进去 mihomo 会拒绝启动，网关直接断网，所以挡住 --apply。
+		issues := traffic.Lint(merged, targets)
+		blocking := 0
+		if len(issues) > 0 {
+			fmt.Println("\n导入后的提醒：")
+			for _, is := range issues {
+				c := color.New(color.FgYellow)
+				if is.Severity == traffic.SeverityError {
+					c = color.New(color.FgRed)
+					blocking++
+				}
+				c.Printf("  ⚠ %s  %s: %s\n", is.Where, is.Rule, is.Message)
+			}
+		}
+
+		if len(added) == 0 {
+			return nil
+		}
+		if !configRuleImportApply {
+			dim.Println("\n（dry-run，配置未改动；确认后加 --apply 写入）")
+			return nil
+		}
+		if blocking > 0 {
+			return fmt.Errorf("有 %d 个问题会让 mihomo 拒绝启动（规则错误或策略组不存在），先修好再 --apply", blocking)
+		}
+		if _, err := a.ImportRules(cmd.Context(), res.Rules); err != nil {
+			return err
+		}
+		fmt.Printf("✓ 已导入 %d 条规则\n", len(added))
+		return nil
+	},
+}
+
 func parseOnOff(s string) (bool, error) {
 	switch strings.ToLower(strings.TrimSpace(s)) {
 	case "on", "true", "1", "yes", "enable", "enabled":
@@ -343,18 +488,13 @@ func parseOnOff(s string) (bool, error) {
 func init() {
 	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "机器可读 JSON 输出")
 
-	configSourceCmd.Flags().StringVar(&srcType, "type", "", "subscription|file|external|remote|none")
-	configSourceCmd.Flags().StringVar(&srcURL, "url", "", "订阅 URL（type=subscription）")
-	configSourceCmd.Flags().StringVar(&srcPath, "path", "", "本地 clash yaml 路径（type=file）")
-	configSourceCmd.Flags().StringVar(&srcServer, "server", "", "主机（type=external/remote）")
-	configSourceCmd.Flags().IntVar(&srcPort, "port", 0, "端口（type=external/remote）")
-	configSourceCmd.Flags().StringVar(&srcKind, "kind", "http", "http|socks5")
-	configSourceCmd.Flags().StringVar(&srcUser, "user", "", "用户名（type=remote，可选）")
-	configSourceCmd.Flags().StringVar(&srcPass, "pass", "", "密码（type=remote，可选）")
+	bindSourceFlags(configSourceCmd, &srcFlags)
 	_ = configSourceCmd.MarkFlagRequired("type")
 
 	configRuleListCmd.Flags().BoolVar(&configRuleListJSON, "json", false, "机器可读 JSON 输出")
-	configRuleCmd.AddCommand(configRuleAddCmd, configRuleListCmd, configRuleRmCmd)
+	configRuleImportCmd.Flags().StringVar(&configRuleImportFormat, "format", traffic.ImportSurge, "来源格式：surge / qx / shadowrocket")
+	configRuleImportCmd.Flags().BoolVar(&configRuleImportApply, "apply", false, "写入配置（不加只预览）")
+	configRuleCmd.AddCommand(configRuleAddCmd, configRuleListCmd, configRuleRmCmd, configRuleImportCmd)
 
 	configCmd.AddCommand(
 		configShowCmd, configSourceCmd, configModeCmd,
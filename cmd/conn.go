@@ -0,0 +1,149 @@
+package cmd
+
+// conn.go 查看和关闭 mihomo 当前的活跃连接：某台设备卡在一条坏连接上、
+// 切了节点旧连接还挂在老节点上时，不用重启整个网关。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+var connCmd = &cobra.Command{
+	Use:   "conn",
+	Short: "活跃连接：列出 / 关闭",
+}
+
+var (
+	connDevice string
+	connHost   string
+	connJSON   bool
+)
+
+// runningApp 和 runningClient 一样要求网关在跑，但返回 App，连接筛选要用设备名。
+func runningApp() (*app.App, error) {
+	a, err := app.New()
+	if err != nil {
+		return nil, err
+	}
+	if a.Engine == nil || !a.Engine.Running() {
+		return nil, fmt.Errorf("网关未运行，先 `gateway start`")
+	}
+	return a, nil
+}
+
+func connFilter() app.ConnFilter {
+	return app.ConnFilter{Device: connDevice, Host: connHost}
+}
+
+var connListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出活跃连接（--device / --host 过滤，--json 机器可读）",
+	Long: `列出 mihomo 当前的活跃连接，最新的在前。
+
+--device 填来源 IP 或 gateway.device_labels 里的名字；--host 含 * / ? 时按通配符
+匹配（*.google.com），否则按子串匹配。
+
+例：
+  gateway conn list
+  gateway conn list --device 192.168.1.20
+  gateway conn list --device iPad --host '*.youtube.com' --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := runningApp()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
+		defer cancel()
+		list, err := a.Connections(ctx, connFilter())
+		if err != nil {
+			return err
+		}
+		if connJSON {
+			b, _ := json.MarshalIndent(list, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		printConnections(list)
+		return nil
+	},
+}
+
+func printConnections(list []app.ConnInfo) {
+	if len(list) == 0 {
+		color.New(color.Faint).Println("  没有匹配的活跃连接")
+		return
+	}
+	dim := color.New(color.Faint)
+	for _, c := range list {
+		src := c.Source
+		if c.Name != "" {
+			src = c.Name + "(" + c.Source + ")"
+		}
+		fmt.Printf("  %-8s %-22s %-4s %s\n", c.ID[:min(8, len(c.ID))], src, c.Network, c.Host)
+		dim.Printf("           %s  ·  %s  ·  %s  ↑%s ↓%s\n", c.Rule, c.Chain, c.Age,
+			stats.FormatBytes(c.Upload), stats.FormatBytes(c.Download))
+	}
+	dim.Printf("  共 %d 条\n", len(list))
+}
+
+var connCloseCmd = &cobra.Command{
+	Use:   "close [id...]",
+	Short: "关闭连接：按 ID，或按 --device / --host 批量关",
+	Long: `关闭指定连接。客户端会立刻看到断开并重连，重连后按当前规则 / 节点重新选路。
+
+ID 可以只写 conn list 里显示的前 8 位；写短了对应到不止一条连接时会报错并列出
+候选，不会一起关掉。不给 ID 时必须给 --device 或 --host，防止一不小心把所有
+连接都断了。
+
+例：
+  gateway conn close 3f2a9c1e
+  gateway conn close --device iPad
+  gateway conn close --host '*.netflix.com'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && connFilter().Empty() {
+			return fmt.Errorf("要给连接 ID，或者用 --device / --host 指定要关哪些")
+		}
+		a, err := runningApp()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+		defer cancel()
+		list, err := a.Connections(ctx, connFilter())
+		if err != nil {
+			return err
+		}
+		ids, missing, err := app.MatchConnIDs(list, args)
+		if err != nil {
+			return err
+		}
+		for _, id := range missing {
+			color.New(color.FgYellow).Printf("  ⚠ 没有找到连接 %s（可能已经结束）\n", id)
+		}
+		if len(ids) == 0 {
+			color.New(color.Faint).Println("  没有要关闭的连接")
+			return nil
+		}
+		n, err := a.CloseConnections(ctx, ids)
+		fmt.Printf("✓ 已关闭 %d / %d 条连接\n", n, len(ids))
+		return err
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{connListCmd, connCloseCmd} {
+		c.Flags().StringVar(&connDevice, "device", "", "只看这台设备（IP 或 device_labels 里的名字）")
+		c.Flags().StringVar(&connHost, "host", "", "只看匹配的目标域名（子串，或 *.example.com 通配符）")
+	}
+	connListCmd.Flags().BoolVar(&connJSON, "json", false, "机器可读 JSON 输出")
+	connCmd.AddCommand(connListCmd, connCloseCmd)
+}
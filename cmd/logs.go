@@ -0,0 +1,203 @@
+package cmd
+
+// logs.go 看 mihomo 日志：默认打印日志文件末尾几行；-f 订阅 mihomo 的 /logs
+// websocket 推送持续输出，--json 每行一个 JSON 对象，方便脚本 / jq 处理。
+// --crashes 看 watchdog 存下来的崩溃现场（每次意外退出时的日志末尾）。
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+var (
+	logsFollow  bool
+	logsLevel   string
+	logsLines   int
+	logsJSON    bool
+	logsCrashes bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "查看 mihomo 日志（-f 实时跟随，--json 机器可读）",
+	Long: `不带 -f 时打印日志文件末尾 -n 行；-f 订阅 mihomo 的实时日志推送（断线自动重连），
+Ctrl+C 退出。--level 只看该级别及以上：debug / info / warning / error。
+--crashes 列出最近 -n 次 mihomo 意外退出，每次带当时的日志末尾（重启会清空日志）。
+
+例：
+  gateway logs -n 100
+  gateway logs --crashes -n 5
+  gateway logs -f --level warning
+  gateway logs -f --level warning --json | jq -r .payload`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level := strings.ToLower(strings.TrimSpace(logsLevel))
+		if level != "" && !engine.ValidLogLevel(level) {
+			return fmt.Errorf("--level 只能是 %s", strings.Join(engine.LogLevels, " / "))
+		}
+		if logsCrashes {
+			if logsFollow {
+				return fmt.Errorf("--crashes 不能和 -f 一起用")
+			}
+			a, err := app.New()
+			if err != nil {
+				return err
+			}
+			return printCrashes(a, logsLines, logsJSON)
+		}
+		if logsJSON && !logsFollow {
+			return fmt.Errorf("--json 需要配合 -f 或 --crashes 使用（日志文件是 mihomo 的原始文本格式）")
+		}
+		if !logsFollow {
+			a, err := app.New()
+			if err != nil {
+				return err
+			}
+			return printLogTail(a.Engine.LogPath(), logsLines, level)
+		}
+
+		cli, err := runningClient()
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		enc := json.NewEncoder(os.Stdout)
+		for e := range cli.StreamLogs(ctx, level) {
+			if logsJSON {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Printf("%s %s %s\n", e.Time.Format("15:04:05"), levelTag(e.Type), e.Payload)
+		}
+		return nil
+	},
+}
+
+// printCrashes 打印最近 n 次崩溃记录，旧的在前。
+func printCrashes(a *app.App, n int, asJSON bool) error {
+	list, err := a.Stats().EngineCrashes(n)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if list == nil {
+			list = []stats.EngineCrash{}
+		}
+		b, _ := json.MarshalIndent(list, "", "  ")
+		fmt.Println(string(b))
+		return nil
+	}
+	if len(list) == 0 {
+		fmt.Println("没有 mihomo 意外退出的记录")
+		return nil
+	}
+	for _, c := range list {
+		color.New(color.FgYellow).Printf("== %s  %s\n", c.At.Format("2006-01-02 15:04:05"), crashOutcome(c))
+		if c.LogTail != "" {
+			fmt.Println(strings.TrimRight(c.LogTail, "\n"))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// crashOutcome 一句话说明 watchdog 怎么处理的这次崩溃。
+func crashOutcome(c stats.EngineCrash) string {
+	switch {
+	case c.Restarted && c.LastGood:
+		return fmt.Sprintf("第 %d 次重启成功，用的是上次稳定运行的配置", c.Attempts)
+	case c.Restarted:
+		return fmt.Sprintf("第 %d 次重启成功", c.Attempts)
+	case c.Error != "":
+		return fmt.Sprintf("重启 %d 次都失败: %s", c.Attempts, c.Error)
+	}
+	return "没有自动重启"
+}
+
+func levelTag(level string) string {
+	tag := fmt.Sprintf("%-7s", strings.ToUpper(level))
+	switch level {
+	case "error":
+		return color.New(color.FgRed).Sprint(tag)
+	case "warning":
+		return color.New(color.FgYellow).Sprint(tag)
+	case "debug":
+		return color.New(color.Faint).Sprint(tag)
+	}
+	return tag
+}
+
+// printLogTail 打印日志文件末尾 n 行；level 非空时只留该级别及以上的行
+// （行里找不到 level= 的原样保留）。
+func printLogTail(path string, n int, level string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("暂无日志 (%s)", path)
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if level != "" && !logLineAtLeast(line, level) {
+			continue
+		}
+		lines = append(lines, line)
+		if n > 0 && len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	return nil
+}
+
+// logLineAtLeast 报告日志文件里的一行是否不低于 level。
+func logLineAtLeast(line, level string) bool {
+	i := strings.Index(line, "level=")
+	if i < 0 {
+		return true
+	}
+	got, _, _ := strings.Cut(line[i+len("level="):], " ")
+	return logLevelRank(got) >= logLevelRank(level)
+}
+
+func logLevelRank(level string) int {
+	if level == "warn" {
+		level = "warning"
+	}
+	for i, l := range engine.LogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return len(engine.LogLevels) // fatal / panic 之类，比 error 还高
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "实时跟随（订阅 mihomo 日志推送）")
+	logsCmd.Flags().StringVar(&logsLevel, "level", "", "只看该级别及以上：debug / info / warning / error（-f 时默认 info）")
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "不带 -f 时打印末尾多少行（--crashes 时是多少次）")
+	logsCmd.Flags().BoolVar(&logsJSON, "json", false, "-f 时每行输出一个 JSON 对象；--crashes 时输出 JSON 数组")
+	logsCmd.Flags().BoolVar(&logsCrashes, "crashes", false, "看 mihomo 意外退出时存下的日志")
+}
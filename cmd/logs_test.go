@@ -0,0 +1,22 @@
+package cmd
+
+import "testing"
+
+func TestLogLineAtLeast(t *testing.T) {
+	cases := []struct {
+		line, level string
+		want        bool
+	}{
+		{`time="x" level=warning msg="a"`, "warning", true},
+		{`time="x" level=info msg="a"`, "warning", false},
+		{`time="x" level=error msg="a"`, "info", true},
+		{`time="x" level=warn msg="a"`, "warning", true},
+		{`time="x" level=fatal msg="a"`, "error", true},
+		{`plain startup banner`, "error", true},
+	}
+	for _, tc := range cases {
+		if got := logLineAtLeast(tc.line, tc.level); got != tc.want {
+			t.Errorf("logLineAtLeast(%q, %q) = %v, want %v", tc.line, tc.level, got, tc.want)
+		}
+	}
+}
@@ -7,11 +7,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/config"
 	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
 )
 
 var nodeCmd = &cobra.Command{
@@ -81,7 +86,200 @@ var nodeSwitchCmd = &cobra.Command{
 	},
 }
 
+var nodeAutoPilotJSON bool
+
+var nodeAutoPilotCmd = &cobra.Command{
+	Use:   "autopilot",
+	Short: "查看自动选节点：各节点滚动延迟 / 丢包、最近的自动切换记录",
+	Long: `自动选节点在 gateway.yaml 的 traffic.auto_pilot 里开启，由菜单或 start --foreground
+里的 supervisor 定时测速；当前节点连续几轮变差、且有节点同样几轮都稳定更快时才切。`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		st := a.AutoPilot()
+		switches, err := a.Stats().NodeSwitches(10)
+		if err != nil {
+			return err
+		}
+		if nodeAutoPilotJSON {
+			b, _ := json.MarshalIndent(struct {
+				app.AutoPilotStatus
+				Switches []stats.NodeSwitch `json:"switches,omitempty"`
+			}{st, switches}, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		dim := color.New(color.Faint)
+		if !st.Enabled {
+			color.New(color.FgYellow).Println("  自动选节点没开（gateway.yaml → traffic.auto_pilot.enabled）")
+		}
+		fmt.Printf("  组 %s  ·  当前 %s", st.Group, st.Current)
+		if st.Degraded > 0 {
+			fmt.Printf("  ·  已连续变差 %d 轮", st.Degraded)
+		}
+		fmt.Println()
+		if !st.CheckedAt.IsZero() {
+			dim.Printf("  最近一轮 %s\n", st.CheckedAt.Format("01-02 15:04:05"))
+		}
+		if st.LastError != "" {
+			color.New(color.FgYellow).Printf("  ⚠ %s\n", st.LastError)
+		}
+		if len(st.Nodes) > 0 {
+			fmt.Printf("\n  %-28s %8s %8s %6s\n", "节点", "最近", "中位", "丢包")
+			for _, n := range st.Nodes {
+				mark := "  "
+				if n.Name == st.Current {
+					mark = "→ "
+				}
+				fmt.Printf("%s%-28s %8s %8s %5.0f%%\n", mark, n.Name, delayText(n.LastMs), delayText(n.MedianMs), n.Loss*100)
+			}
+		}
+		if len(switches) > 0 {
+			fmt.Println()
+			for _, s := range switches {
+				dim.Printf("  %s  %s → %s  %s\n", s.At.Format("01-02 15:04"), s.From, s.To, s.Reason)
+			}
+		}
+		return nil
+	},
+}
+
+var (
+	nodeTestURL     string
+	nodeTestTimeout int
+	nodeTestJSON    bool
+)
+
+var nodeTestCmd = &cobra.Command{
+	Use:   "test [group|node]",
+	Short: "测速：整组或单个节点，结果记进测速历史（--json 机器可读）",
+	Long: `不带参数测 Proxy 组。参数是策略组名时整组并发测，否则当作单个节点测。
+每次结果都记进测速历史，用 gateway node history <节点> 看中位延迟 / p95 / 失败率；
+想攒长期数据可以放进 cron，比如每 10 分钟跑一次 gateway node test。
+
+例：
+  gateway node test
+  gateway node test "🇭🇰 香港" --timeout 3000
+  gateway node test HK-01 --url http://cp.cloudflare.com --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if nodeTestTimeout <= 0 {
+			return fmt.Errorf("--timeout 必须 > 0")
+		}
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), time.Duration(nodeTestTimeout+10000)*time.Millisecond)
+		defer cancel()
+		run, err := a.TestNodes(ctx, target, nodeTestURL, nodeTestTimeout)
+		if err != nil && run.Delays == nil {
+			return err
+		}
+		if nodeTestJSON {
+			b, _ := json.MarshalIndent(run, "", "  ")
+			fmt.Println(string(b))
+			return err
+		}
+		names := make([]string, 0, len(run.Delays))
+		for n := range run.Delays {
+			names = append(names, n)
+		}
+		// 通的按延迟升序，不通的排最后。
+		sort.Slice(names, func(i, j int) bool {
+			di, dj := run.Delays[names[i]], run.Delays[names[j]]
+			if (di == 0) != (dj == 0) {
+				return dj == 0
+			}
+			if di != dj {
+				return di < dj
+			}
+			return names[i] < names[j]
+		})
+		failed := 0
+		for _, n := range names {
+			if d := run.Delays[n]; d > 0 {
+				fmt.Printf("  %-32s %8s\n", n, delayText(d))
+			} else {
+				failed++
+				color.New(color.FgRed).Printf("  %-32s %8s\n", n, "超时")
+			}
+		}
+		color.New(color.Faint).Printf("  共 %d 个，%d 个不通 · %s\n", len(names), failed, run.URL)
+		return err
+	},
+}
+
+var (
+	nodeHistorySince string
+	nodeHistoryJSON  bool
+)
+
+var nodeHistoryCmd = &cobra.Command{
+	Use:   "history <node>",
+	Short: "某节点的测速历史：中位延迟、p95、失败率（按天）",
+	Long: `统计 gateway node test 记下来的测速结果。延迟只算测通的次数。
+
+例：
+  gateway node history HK-01
+  gateway node history HK-01 --since 30d --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		since, err := stats.ParseSince(nodeHistorySince, now)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		h, err := a.NodeHistory(args[0], since, now)
+		if err != nil {
+			return err
+		}
+		if nodeHistoryJSON {
+			b, _ := json.MarshalIndent(h, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		dim := color.New(color.Faint)
+		dim.Printf("  %s · 自 %s 起\n", h.Node, since.Format("2006-01-02 15:04"))
+		if h.Total.Tests == 0 {
+			color.New(color.FgYellow).Println("  没有测速记录（先跑 gateway node test）")
+			return nil
+		}
+		fmt.Printf("  %-12s %6s %8s %8s %8s\n", "日期", "次数", "失败率", "中位", "p95")
+		for _, d := range h.Days {
+			fmt.Printf("  %-12s %6d %7.0f%% %8s %8s\n", d.Date, d.Tests, d.FailureRate*100, delayText(d.MedianMs), delayText(d.P95Ms))
+		}
+		t := h.Total
+		fmt.Printf("  %-12s %6d %7.0f%% %8s %8s\n", "合计", t.Tests, t.FailureRate*100, delayText(t.MedianMs), delayText(t.P95Ms))
+		return nil
+	},
+}
+
+func delayText(ms int) string {
+	if ms <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dms", ms)
+}
+
 func init() {
 	nodeListCmd.Flags().BoolVar(&nodeListJSON, "json", false, "机器可读 JSON 输出")
-	nodeCmd.AddCommand(nodeListCmd, nodeSwitchCmd)
+	nodeAutoPilotCmd.Flags().BoolVar(&nodeAutoPilotJSON, "json", false, "机器可读 JSON 输出")
+	nodeTestCmd.Flags().StringVar(&nodeTestURL, "url", config.DefaultAutoPilotURL, "测速地址")
+	nodeTestCmd.Flags().IntVar(&nodeTestTimeout, "timeout", 5000, "单节点超时（毫秒）")
+	nodeTestCmd.Flags().BoolVar(&nodeTestJSON, "json", false, "机器可读 JSON 输出")
+	nodeHistoryCmd.Flags().StringVar(&nodeHistorySince, "since", "7d", "起始时间：7d / 12h / today / month / 2006-01-02")
+	nodeHistoryCmd.Flags().BoolVar(&nodeHistoryJSON, "json", false, "机器可读 JSON 输出")
+	nodeCmd.AddCommand(nodeListCmd, nodeSwitchCmd, nodeAutoPilotCmd, nodeTestCmd, nodeHistoryCmd)
 }
@@ -0,0 +1,61 @@
+package cmd
+
+// notify.go 管事件通知（runtime.notify）。通知本身由跑着 supervisor 的进程
+// （菜单或 start --foreground）在事件发生时发，这里只提供一个「发条测试消息」
+// 的入口，配完出口马上能验证。
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "事件通知（webhook / ntfy / Gotify / Bark / 本机命令）",
+}
+
+var notifyEvent string
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "给所有通知出口发一条测试消息",
+	Long: `按 runtime.notify 的配置同步发一条测试消息，不受限流影响，哪个出口失败当场报出来。
+--event 指定按哪类事件发（只订了部分事件的出口，事件不匹配时不会收到）。
+
+可选事件: ` + strings.Join(config.NotifyEvents, " / ") + `
+
+例：
+  gateway notify test
+  gateway notify test --event engine_crash`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if notifyEvent != "" && !slices.Contains(config.NotifyEvents, notifyEvent) {
+			return fmt.Errorf("--event 只能是 %s", strings.Join(config.NotifyEvents, " / "))
+		}
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		errs := a.TestNotify(cmd.Context(), notifyEvent)
+		for _, e := range errs {
+			color.New(color.FgYellow).Printf("  ⚠ %v\n", e)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d 个出口发送失败", len(errs))
+		}
+		fmt.Println("✓ 测试消息已发出")
+		return nil
+	},
+}
+
+func init() {
+	notifyTestCmd.Flags().StringVar(&notifyEvent, "event", "", "按哪类事件发，默认 source_down")
+	notifyCmd.AddCommand(notifyTestCmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+// profile.go 管理「源档案」（source.profiles）：把常用的几套代理源存成有名字的
+// 档案，一条命令切换。切换走 app.UseProfile —— 存盘 + 重新渲染 + 热重载一步完成。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "管理源档案（工作订阅 / 家里文件 / 出差远程代理 一键切换）",
+	Long: `源档案 = 一套有名字的代理源配置。存好之后一条命令切换，mihomo 在跑时自动热重载。
+
+例：
+  gateway profile add work --type subscription --url https://example.com/sub
+  gateway profile add home --type file --path ~/clash/home.yaml
+  gateway profile add travel --from-current
+  gateway profile use work
+  gateway profile list --json
+  gateway profile rm travel`,
+}
+
+// profileView 是 profile list 的机器可读条目。
+type profileView struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Summary string `json:"summary,omitempty"`
+	Current bool   `json:"current"`
+}
+
+func buildProfileViews(a *app.App) []profileView {
+	views := []profileView{}
+	for _, p := range a.Profiles() {
+		views = append(views, profileView{
+			Name:    p.Name,
+			Type:    p.Type,
+			Summary: p.Summary(),
+			Current: p.Name == a.Cfg.Source.Current,
+		})
+	}
+	return views
+}
+
+// ---- profile list ----
+
+var profileListJSON bool
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出源档案（* 为当前，--json 机器可读）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		views := buildProfileViews(a)
+		if profileListJSON {
+			b, _ := json.MarshalIndent(views, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		if len(views) == 0 {
+			fmt.Println("还没有档案。用 `gateway profile add <name> --from-current` 把当前源存一份。")
+			return nil
+		}
+		for _, v := range views {
+			mark := " "
+			if v.Current {
+				mark = "*"
+			}
+			fmt.Printf("%s %-12s %-12s %s\n", mark, v.Name, v.Type, v.Summary)
+		}
+		return nil
+	},
+}
+
+// ---- profile add ----
+
+var (
+	profileFromCurrent bool
+	profileSrcFlags    sourceFlags
+)
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "新增档案（--from-current 存当前源，或用 --type 等 flag 指定）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		var src config.SourceConfig
+		switch {
+		case profileFromCurrent:
+			src = a.Cfg.Source
+		case profileSrcFlags.typ != "":
+			src, err = sourceFromFlags(profileSrcFlags)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("需要 --from-current 或 --type")
+		}
+		if err := a.AddProfile(config.ProfileFromSource(args[0], src)); err != nil {
+			return err
+		}
+		fmt.Printf("✓ 已存档案 %s (%s)\n", args[0], src.Type)
+		return nil
+	},
+}
+
+// ---- profile use / rm ----
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "切到指定档案（存盘 + 热重载）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		if err := a.UseProfile(context.Background(), args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ 已切到档案 %s (%s)\n", args[0], a.Cfg.Source.Type)
+		return nil
+	},
+}
+
+var profileRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "删除档案（删当前档案不会改动正在用的源）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		if err := a.RemoveProfile(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("✓ 已删档案 %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	profileListCmd.Flags().BoolVar(&profileListJSON, "json", false, "机器可读 JSON 输出")
+
+	profileAddCmd.Flags().BoolVar(&profileFromCurrent, "from-current", false, "把当前生效的源存成档案")
+	bindSourceFlags(profileAddCmd, &profileSrcFlags)
+
+	profileCmd.AddCommand(profileListCmd, profileAddCmd, profileUseCmd, profileRmCmd)
+}
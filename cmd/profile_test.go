@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestBuildProfileViews(t *testing.T) {
+	cfg := config.Default()
+	config.Normalize(cfg)
+	cfg.Source.Profiles = []config.Profile{
+		{Name: "work", Type: config.SourceTypeSubscription, Subscription: &config.SubscriptionSource{URL: "https://e.com/sub"}},
+		{Name: "travel", Type: config.SourceTypeRemote, Remote: &config.RemoteProxy{Server: "1.2.3.4", Port: 1080, Kind: "socks5"}},
+	}
+	cfg.Source.Current = "travel"
+	views := buildProfileViews(&app.App{Cfg: cfg})
+	if len(views) != 2 {
+		t.Fatalf("expected 2 views, got %+v", views)
+	}
+	if views[0].Current || !views[1].Current {
+		t.Fatalf("current 标记不对: %+v", views)
+	}
+	if views[0].Summary != "https://e.com/sub" || views[1].Summary != "1.2.3.4:1080 socks5" {
+		t.Fatalf("summary 不对: %+v", views)
+	}
+}
+
+func TestSourceFromFlagsRequiresURL(t *testing.T) {
+	if _, err := sourceFromFlags(sourceFlags{typ: config.SourceTypeSubscription}); err == nil {
+		t.Fatal("subscription 缺 --url 应报错")
+	}
+	src, err := sourceFromFlags(sourceFlags{})
+	if err != nil || src.Type != config.SourceTypeNone {
+		t.Fatalf("空 type 应回退 none，got %+v err=%v", src, err)
+	}
+}
+
+func TestSourceFromFlagsMulti(t *testing.T) {
+	src, err := sourceFromFlags(sourceFlags{
+		typ:  config.SourceTypeMulti,
+		subs: []string{"airA=https://a.example/sub?token=x=y", "self=https://b.example/nodes"},
+	})
+	if err != nil {
+		t.Fatalf("multi: %v", err)
+	}
+	if len(src.Subscriptions) != 2 || src.Subscriptions[0].URL != "https://a.example/sub?token=x=y" {
+		t.Fatalf("只按第一个 = 切 name/url: %+v", src.Subscriptions)
+	}
+	if _, err := sourceFromFlags(sourceFlags{typ: config.SourceTypeMulti, subs: []string{"no-url"}}); err == nil {
+		t.Fatal("--sub 缺 = 应报错")
+	}
+}
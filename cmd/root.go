@@ -52,5 +52,11 @@ func init() {
 		updateCmd,
 		configCmd,
 		nodeCmd,
+		profileCmd,
+		ruleCmd,
+		statsCmd,
+		connCmd,
+		logsCmd,
+		notifyCmd,
 	)
 }
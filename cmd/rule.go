@@ -0,0 +1,127 @@
+package cmd
+
+// rule.go 是规则排查工具：`gateway rule test` 解释某个域名 / IP 会被哪条规则命中，
+// `gateway rule lint` 检查自定义规则。判断逻辑在 app / traffic，这里只负责输出。
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
+)
+
+var ruleCmd = &cobra.Command{
+	Use:   "rule",
+	Short: "规则排查（某个网站走了哪条规则）",
+}
+
+var (
+	ruleTestSrc  string
+	ruleTestJSON bool
+)
+
+var ruleTestCmd = &cobra.Command{
+	Use:   "test <domain|ip>",
+	Short: "解释一个域名 / IP 会命中哪条规则、来自哪里、最终去向",
+	Long: `按当前 gateway.yaml 渲染出的规则顺序（广告拦截 → 设备策略 → 自定义 → 外部规则集 →
+内置规则集 → MATCH）逐条比对，输出第一条命中的规则。
+
+例：
+  gateway rule test www.google.com
+  gateway rule test 1.1.1.1
+  gateway rule test youtube.com --src 192.168.1.23   # 带上设备 IP，看设备策略
+  gateway rule test baidu.com --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		res := a.TestRule(cmd.Context(), args[0], ruleTestSrc)
+		if ruleTestJSON {
+			b, _ := json.MarshalIndent(res, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		printRuleTest(res)
+		return nil
+	},
+}
+
+func printRuleTest(res app.RuleTestResult) {
+	dim := color.New(color.Faint)
+	fmt.Printf("  目标:   %s", res.Host)
+	if res.Src != "" {
+		fmt.Printf("（来自 %s）", res.Src)
+	}
+	fmt.Println()
+	if len(res.IPs) > 0 {
+		fmt.Printf("  解析:   %v\n", res.IPs)
+	}
+	if res.Matched {
+		fmt.Printf("  命中:   #%d  %s\n", res.Index+1, res.Rule.Line)
+		fmt.Printf("  来源:   %s\n", traffic.SourceLabel(res.Rule.Source))
+		color.New(color.FgGreen, color.Bold).Printf("  去向:   %s\n", res.Verdict)
+	} else {
+		color.New(color.FgYellow).Println("  命中:   无")
+	}
+	if n := len(res.Skipped); n > 0 {
+		dim.Printf("  跳过了 %d 条无法离线判断的规则（如 %s），实际结果可能更早命中\n", n, res.Skipped[0].Line)
+	}
+	if res.Note != "" {
+		color.New(color.FgYellow).Printf("  ⚠ %s\n", res.Note)
+	}
+}
+
+var ruleLintJSON bool
+
+var ruleLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "检查自定义规则：语法错误、重复、被前面规则挡住、指向不存在的策略组",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		issues := a.LintRules(cmd.Context())
+		if ruleLintJSON {
+			if issues == nil {
+				issues = []traffic.Issue{}
+			}
+			b, _ := json.MarshalIndent(issues, "", "  ")
+			fmt.Println(string(b))
+		} else if len(issues) == 0 {
+			fmt.Println("✓ 自定义规则没发现问题")
+		}
+		errs := 0
+		for _, is := range issues {
+			if is.Severity == traffic.SeverityError {
+				errs++
+			}
+			if ruleLintJSON {
+				continue
+			}
+			c, label := color.New(color.FgYellow), "提醒"
+			if is.Severity == traffic.SeverityError {
+				c, label = color.New(color.FgRed), "错误"
+			}
+			c.Printf("  %s  %s  %s\n", label, is.Where, is.Rule)
+			fmt.Printf("        %s\n", is.Message)
+		}
+		if errs > 0 {
+			return fmt.Errorf("%d 条规则有错误，mihomo 会拒绝加载", errs)
+		}
+		return nil
+	},
+}
+
+func init() {
+	ruleLintCmd.Flags().BoolVar(&ruleLintJSON, "json", false, "机器可读 JSON 输出")
+	ruleTestCmd.Flags().StringVar(&ruleTestSrc, "src", "", "发起连接的 LAN 设备 IP（判断设备策略用）")
+	ruleTestCmd.Flags().BoolVar(&ruleTestJSON, "json", false, "机器可读 JSON 输出")
+	ruleCmd.AddCommand(ruleTestCmd, ruleLintCmd)
+}
@@ -36,6 +36,9 @@ var startCmd = &cobra.Command{
 			return err
 		}
 		a.StartSupervisor(cmd.Context())
+		a.StartSubscriptionRefresher(cmd.Context())
+		a.StartDeviceScheduler(cmd.Context())
+		a.StartTrafficCollector(cmd.Context())
 		color.Green("✔ 网关已启动")
 		color.New(color.Faint).Println(a.Engine.LogPath())
 
@@ -47,6 +50,12 @@ var startCmd = &cobra.Command{
 		}
 
 		printMihomoConsoleHint(a)
+		// /metrics 只在前台（服务）模式下跑：后台模式的 gateway 进程马上就退出了。
+		if err := a.StartMetrics(cmd.Context()); err != nil {
+			color.New(color.FgYellow).Printf("  ⚠ %v\n", err)
+		} else if a.Cfg.Runtime.Metrics.Enabled {
+			color.New(color.Faint).Printf("Prometheus 指标: http://%s/metrics\n", a.Cfg.Runtime.Metrics.ListenAddr())
+		}
 
 		// --foreground: launchd / systemd 要前台进程，等 Ctrl+C 再优雅停止。
 		sig := make(chan os.Signal, 1)
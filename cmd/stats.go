@@ -0,0 +1,160 @@
+package cmd
+
+// stats.go 查看按设备记下来的流量。采样由 `start --foreground` / 菜单里的后台
+// collector 负责，这里只读账本。
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "流量统计",
+}
+
+var (
+	statsSince string
+	statsJSON  bool
+)
+
+var statsDevicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "按 LAN 设备汇总上下行流量",
+	Long: `按来源 IP 汇总一段时间内的上下行流量（按小时记账，重启不丢）。
+
+只有 gateway 在跑（菜单开着，或 start --foreground / 系统服务）时才会记账。
+
+例：
+  gateway stats devices                 # 最近 7 天
+  gateway stats devices --since today
+  gateway stats devices --since month   # 本月（按自然月）
+  gateway stats devices --since 30d --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		since, err := stats.ParseSince(statsSince, now)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		list, err := a.DeviceUsage(since, now)
+		if err != nil {
+			return err
+		}
+		if statsJSON {
+			b, _ := json.MarshalIndent(struct {
+				Since   time.Time           `json:"since"`
+				Until   time.Time           `json:"until"`
+				Devices []stats.DeviceUsage `json:"devices"`
+			}{since, now, list}, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		printDeviceUsage(list, since)
+		return nil
+	},
+}
+
+func printDeviceUsage(list []stats.DeviceUsage, since time.Time) {
+	dim := color.New(color.Faint)
+	dim.Printf("  自 %s 起\n", since.Format("2006-01-02 15:04"))
+	if len(list) == 0 {
+		color.New(color.FgYellow).Println("  还没有记录（gateway 需要在前台或作为服务运行才会记账）")
+		return
+	}
+	fmt.Printf("  %-18s %-14s %10s %10s %10s\n", "IP", "名称", "上行", "下行", "合计")
+	var total stats.Usage
+	for _, d := range list {
+		fmt.Printf("  %-18s %-14s %10s %10s %10s\n", d.IP, d.Name,
+			stats.FormatBytes(d.Total.Up), stats.FormatBytes(d.Total.Down), stats.FormatBytes(d.Total.Total()))
+		total.Up += d.Total.Up
+		total.Down += d.Total.Down
+	}
+	dim.Printf("  %-18s %-14s %10s %10s %10s\n", "全部", "",
+		stats.FormatBytes(total.Up), stats.FormatBytes(total.Down), stats.FormatBytes(total.Total()))
+}
+
+var statsQuotaJSON bool
+
+var statsQuotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "查看设备流量额度：本周期用量、是否已超额、最近的封禁 / 解除记录",
+	Long: `额度在 gateway.yaml 的 gateway.device_quotas 里配置；超额的设备按 action 改走
+reject 或 direct，到周期结束（daily 每天 0 点、monthly 每月 1 号）自动解除。
+封禁靠规则执行，只在 rule 模式下生效；global / direct 模式下会提醒。`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		a, err := app.New()
+		if err != nil {
+			return err
+		}
+		list, err := a.QuotaStatuses(time.Now())
+		if err != nil {
+			return err
+		}
+		events, err := a.Stats().QuotaEvents(10)
+		if err != nil {
+			return err
+		}
+		if statsQuotaJSON {
+			b, _ := json.MarshalIndent(struct {
+				Quotas []app.QuotaStatus  `json:"quotas"`
+				Events []stats.QuotaEvent `json:"events,omitempty"`
+			}{list, events}, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		if len(list) == 0 {
+			color.New(color.Faint).Println("  没有配置流量额度（gateway.yaml → gateway.device_quotas）")
+		}
+		if w := a.QuotaModeWarning(); w != "" {
+			color.New(color.FgYellow).Printf("  ⚠ %s\n\n", w)
+		}
+		for _, q := range list {
+			name := q.Device
+			if q.Name != "" && q.Name != q.Device {
+				name += "（" + q.Name + "）"
+			}
+			period := "今日"
+			if q.Period == "monthly" {
+				period = "本月"
+			}
+			line := fmt.Sprintf("  %-24s %s %s / %s", name, period, stats.FormatBytes(q.Used), stats.FormatBytes(q.LimitBytes))
+			if q.Blocked {
+				color.New(color.FgYellow).Printf("%s  已超额 → %s，%s 解除\n", line, q.Action, q.ResetAt.Format("01-02 15:04"))
+			} else {
+				fmt.Println(line)
+			}
+		}
+		if len(events) > 0 {
+			fmt.Println()
+			dim := color.New(color.Faint)
+			for _, e := range events {
+				what := "解除"
+				if e.Kind == stats.QuotaExceeded {
+					what = fmt.Sprintf("超额（%s / %s）→ %s", stats.FormatBytes(e.Used), stats.FormatBytes(e.Limit), e.Target)
+				}
+				dim.Printf("  %s  %s %s\n", e.At.Format("01-02 15:04"), e.Device, what)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	statsDevicesCmd.Flags().StringVar(&statsSince, "since", "7d", "起始时间：7d / 12h / today / month / 2006-01-02")
+	statsDevicesCmd.Flags().BoolVar(&statsJSON, "json", false, "机器可读 JSON 输出")
+	statsQuotaCmd.Flags().BoolVar(&statsQuotaJSON, "json", false, "机器可读 JSON 输出")
+	statsCmd.AddCommand(statsDevicesCmd, statsQuotaCmd)
+}
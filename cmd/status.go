@@ -3,12 +3,15 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/tght/lan-proxy-gateway/internal/app"
 	"github.com/tght/lan-proxy-gateway/internal/gateway"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
 var statusJSON bool
@@ -32,7 +35,41 @@ var statusCmd = &cobra.Command{
 		fmt.Printf("  配置:   %v (%s)\n", s.Configured, s.ConfigFile)
 		fmt.Printf("  运行:   %v\n", s.Running)
 		fmt.Printf("  模式:   %s   广告拦截: %v   TUN: %v\n", s.Mode, s.Adblock, s.TUN)
-		fmt.Printf("  源:     %s\n", s.Source)
+		if s.Profile != "" {
+			fmt.Printf("  源:     %s（档案 %s）\n", s.Source, s.Profile)
+		} else {
+			fmt.Printf("  源:     %s\n", s.Source)
+		}
+		if n := len(s.Crashes); n > 0 {
+			color.New(color.FgYellow).Printf("  ⚠ mihomo 最近 24 小时意外退出 %d 次，最近一次 %s（%s）；`gateway logs --crashes` 看现场\n",
+				n, s.Crashes[n-1].At.Format("01-02 15:04"), crashOutcome(s.Crashes[n-1]))
+		}
+		if s.Failover != "" {
+			color.New(color.FgYellow).Printf("  ⚠ 主源不通，正在用备用源 %s（主源恢复后自动切回）\n", s.Failover)
+		}
+		for _, sub := range s.Subscriptions {
+			fmt.Printf("  流量:   %s  %s\n", sub.Name, sub.Summary(time.Now()))
+		}
+		for _, w := range a.QuotaWarnings(time.Now()) {
+			color.New(color.FgYellow).Printf("  ⚠ %s\n", w)
+		}
+		for _, tr := range s.Schedule {
+			action := "结束"
+			if tr.Start {
+				action = "开始 → " + traffic.ProviderTarget(tr.Target)
+			}
+			fmt.Printf("  定时:   %s  %s %s\n", tr.At.Format("01-02 Mon 15:04"), tr.Schedule, action)
+		}
+		for _, b := range s.QuotaBlocks {
+			color.New(color.FgYellow).Printf("  超额:   %s 已用 %s / %s → %s，%s 解除\n", b.Device,
+				stats.FormatBytes(b.Used), stats.FormatBytes(b.Limit), traffic.ProviderTarget(b.Target), b.Until.Format("01-02 15:04"))
+		}
+		if w := a.QuotaModeWarning(); w != "" {
+			color.New(color.FgYellow).Printf("  ⚠ %s\n", w)
+		}
+		for _, w := range s.RenderWarnings {
+			color.New(color.FgYellow).Printf("  ⚠ %s\n", w)
+		}
 		fmt.Printf("  端口:   mixed=%d  api=%d  redir=%d\n", s.Ports.Mixed, s.Ports.API, s.Ports.Redir)
 		fmt.Printf("  mihomo: %s\n", firstNonEmpty(s.MihomoBin, "(未找到)"))
 		fmt.Println()
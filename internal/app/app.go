@@ -14,7 +14,11 @@ import (
 	"github.com/tght/lan-proxy-gateway/internal/config"
 	"github.com/tght/lan-proxy-gateway/internal/engine"
 	"github.com/tght/lan-proxy-gateway/internal/gateway"
+	"github.com/tght/lan-proxy-gateway/internal/notify"
 	"github.com/tght/lan-proxy-gateway/internal/platform"
+	"github.com/tght/lan-proxy-gateway/internal/source"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
 // App wires together config, engine, gateway and platform.
@@ -28,6 +32,24 @@ type App struct {
 	// health 是代理源 supervisor 维护的健康看板；由 StartSupervisor 懒启动。
 	health         *healthState
 	supervisorOnce sync.Once
+	refresherOnce  sync.Once
+	schedulerOnce  sync.Once
+	collectorOnce  sync.Once
+
+	// stats 是设备流量账本，Stats() 第一次调用时打开。
+	stats     *stats.Store
+	statsOnce sync.Once
+	// counters / delays 是 /metrics 导出的进程内计数和最近一次测速结果。
+	counters     *stats.Counters
+	countersOnce sync.Once
+	delays       delayCache
+	// pilot 是自动选节点的滚动测速历史，supervisor 里的 autopilotLoop 写。
+	pilot autoPilot
+	// dog 是 mihomo 崩溃看门狗的退避状态。
+	dog watchdog
+	// notif 是事件通知的限流器和投递，notifier() 第一次调用时创建。
+	notif        *notify.Notifier
+	notifierOnce sync.Once
 }
 
 // New builds an App. It loads the config from disk; if missing, it returns one
@@ -68,7 +90,8 @@ func (a *App) Save() error {
 
 // Start brings up the LAN gateway and the mihomo engine.
 func (a *App) Start(ctx context.Context) error {
-	effective := config.EffectiveRuntimeConfig(a.Cfg)
+	a.dog.setStopped(false)
+	effective := a.runtimeConfig()
 	if effective.Gateway.Enabled {
 		mode := effective.Gateway.Mode
 		if mode == "" {
@@ -94,6 +117,8 @@ func (a *App) Start(ctx context.Context) error {
 
 // Stop tears everything down, best-effort.
 func (a *App) Stop() error {
+	// 先告诉 watchdog：接下来 mihomo 没了是我们停的，别拉起来。
+	a.dog.setStopped(true)
 	var firstErr error
 	if err := a.restoreLocalDNSIfLoopback(); err != nil && firstErr == nil {
 		firstErr = err
@@ -141,7 +166,7 @@ func (a *App) SetMode(ctx context.Context, mode string) error {
 		return err
 	}
 	if a.Engine.Running() {
-		return a.Engine.Reload(ctx, config.EffectiveRuntimeConfig(a.Cfg))
+		return a.Engine.Reload(ctx, a.runtimeConfig())
 	}
 	return nil
 }
@@ -153,7 +178,7 @@ func (a *App) ToggleAdblock(ctx context.Context) error {
 		return err
 	}
 	if a.Engine.Running() {
-		return a.Engine.Reload(ctx, config.EffectiveRuntimeConfig(a.Cfg))
+		return a.Engine.Reload(ctx, a.runtimeConfig())
 	}
 	return nil
 }
@@ -165,7 +190,7 @@ func (a *App) ToggleTUN(ctx context.Context) error {
 		return err
 	}
 	if a.Engine.Running() {
-		return a.Engine.Reload(ctx, config.EffectiveRuntimeConfig(a.Cfg))
+		return a.Engine.Reload(ctx, a.runtimeConfig())
 	}
 	return nil
 }
@@ -191,7 +216,19 @@ func (a *App) SetGatewayMode(ctx context.Context, mode string) error {
 }
 
 // SetSource replaces the source config wholesale, saves and reloads.
+//
+// src.Profiles / src.Failover 为 nil 时沿用已保存的 —— `config source` 这类只关心
+// 当前源的调用方不用自己搬档案列表。src.Current 原样写入：UseProfile 会带上档案名，
+// 手动设源的调用方留空，表示当前源不再对应任何档案。
 func (a *App) SetSource(ctx context.Context, src config.SourceConfig) error {
+	if src.Profiles == nil {
+		src.Profiles = a.Cfg.Source.Profiles
+	}
+	if src.Failover == nil {
+		src.Failover = a.Cfg.Source.Failover
+	}
+	// 换了主源，之前顶替旧主源的备用就不作数了，交给 supervisor 重新判断。
+	_ = a.saveActiveFailover("", time.Now())
 	a.Cfg.Source = src
 	return a.saveAndReload(ctx)
 }
@@ -202,13 +239,17 @@ func (a *App) saveAndReload(ctx context.Context) error {
 		return err
 	}
 	if a.Engine != nil && a.Engine.Running() {
-		return a.Engine.Reload(ctx, config.EffectiveRuntimeConfig(a.Cfg))
+		return a.Engine.Reload(ctx, a.runtimeConfig())
 	}
 	return nil
 }
 
 // AddRule 把一条规则按裁决（direct/proxy/reject）追加到 Traffic.Extras，存盘+热重载。
+// 语法不对（类型拼错、网段 / 端口写错）直接拒绝，不写进配置。
 func (a *App) AddRule(ctx context.Context, verdict, rule string) error {
+	if err := traffic.ValidateRule(rule); err != nil {
+		return fmt.Errorf("规则 %q 无效: %w", rule, err)
+	}
 	switch verdict {
 	case "direct":
 		a.Cfg.Traffic.Extras.Direct = append(a.Cfg.Traffic.Extras.Direct, rule)
@@ -222,6 +263,16 @@ func (a *App) AddRule(ctx context.Context, verdict, rule string) error {
 	return a.saveAndReload(ctx)
 }
 
+// ImportRules 把导入的规则合并进 Traffic.Extras（已有的跳过），存盘+热重载，返回实际新增的条目。
+func (a *App) ImportRules(ctx context.Context, rules []traffic.ImportedRule) ([]traffic.ImportedRule, error) {
+	merged, added := traffic.MergeImport(a.Cfg.Traffic.Extras, rules)
+	if len(added) == 0 {
+		return nil, nil
+	}
+	a.Cfg.Traffic.Extras = merged
+	return added, a.saveAndReload(ctx)
+}
+
 // RemoveRule 按裁决+从 0 起的索引删一条自定义规则，存盘+热重载。
 func (a *App) RemoveRule(ctx context.Context, verdict string, index int) error {
 	var list *[]string
@@ -252,15 +303,28 @@ type Status struct {
 	TUN         bool                `json:"tun"`
 	GatewayMode string              `json:"gateway_mode"`
 	Source      string              `json:"source"`
+	Profile     string              `json:"profile,omitempty"`
 	Gateway     gateway.Status      `json:"gateway"`
 	Ports       config.RuntimePorts `json:"ports"`
 	MihomoBin   string              `json:"mihomo_bin"`
 	ConfigFile  string              `json:"config_file"`
+	// Subscriptions 是机场订阅的流量 / 到期信息（subscription-userinfo），没有就省略。
+	Subscriptions []source.SubscriptionInfo `json:"subscriptions,omitempty"`
+	// Schedule 是接下来几次设备定时策略的切换（家长控制等），没配就省略。
+	Schedule []config.ScheduleTransition `json:"schedule,omitempty"`
+	// QuotaBlocks 是因流量超额被临时改道的设备，周期结束自动解除。
+	QuotaBlocks []stats.QuotaBlock `json:"quota_blocks,omitempty"`
+	// Failover 是正在顶替主源的备用档案（source.failover）；此时 Source 是备用的类型。
+	Failover string `json:"failover,omitempty"`
+	// Crashes 是最近 24 小时 mihomo 的意外退出，带崩溃时的日志末尾。
+	Crashes []stats.EngineCrash `json:"crashes,omitempty"`
+	// RenderWarnings 是最近一次渲染 config.yaml 时跳过的东西（离线设备的 MAC 策略等）。
+	RenderWarnings []string `json:"render_warnings,omitempty"`
 }
 
 // Status returns the current runtime status (no blocking network calls).
 func (a *App) Status() Status {
-	effective := config.EffectiveRuntimeConfig(a.Cfg)
+	effective := a.runtimeConfig()
 	gs, _ := a.Gateway.Status()
 	bin := ""
 	if p, err := a.Plat.ResolveMihomoPath(""); err == nil {
@@ -278,9 +342,18 @@ func (a *App) Status() Status {
 		TUN:         effective.Gateway.TUN.Enabled,
 		GatewayMode: gwMode,
 		Source:      effective.Source.Type,
+		Profile:     a.Cfg.Source.Current,
+		Failover:    a.activeFailover(),
 		Gateway:     gs,
 		Ports:       effective.Runtime.Ports,
 		MihomoBin:   bin,
 		ConfigFile:  a.Paths.ConfigFile,
+
+		Subscriptions: a.SubscriptionInfo(),
+		Schedule:      a.UpcomingScheduleTransitions(time.Now()),
+		QuotaBlocks:   a.QuotaBlocks(time.Now()),
+		Crashes:       a.EngineCrashes(time.Now().Add(-24 * time.Hour)),
+
+		RenderWarnings: engine.LoadRenderWarnings(a.Paths.MihomoDir),
 	}
 }
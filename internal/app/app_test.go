@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -69,3 +70,16 @@ func TestStopReportsLocalDNSRestoreFailure(t *testing.T) {
 		t.Fatalf("Stop error = %v, want restore error", err)
 	}
 }
+
+func TestAddRuleRejectsInvalidRule(t *testing.T) {
+	a := newProfileTestApp(t)
+	if err := a.AddRule(context.Background(), "proxy", "DOMAIN-SUFIX,openai.com"); err == nil {
+		t.Fatal("typo in rule type should be rejected")
+	}
+	if len(a.Cfg.Traffic.Extras.Proxy) != 0 {
+		t.Fatalf("invalid rule must not be stored: %v", a.Cfg.Traffic.Extras.Proxy)
+	}
+	if err := a.AddRule(context.Background(), "proxy", "DOMAIN-SUFFIX,openai.com"); err != nil {
+		t.Fatalf("valid rule rejected: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// builtinOutbounds 是 mihomo 内置出站。它们也在 Selector 的 all 里、测速还很快
+// （DIRECT 几十毫秒），但不是「节点」，自动选节点不能切过去。
+var builtinOutbounds = map[string]bool{
+	"DIRECT": true, "REJECT": true, "REJECT-DROP": true, "PASS": true, "COMPATIBLE": true, "GLOBAL": true,
+}
+
+// NodeStat 是一个节点在滚动窗口里的表现。
+type NodeStat struct {
+	Name     string  `json:"name"`
+	LastMs   int     `json:"last_ms"`   // 0 = 最近一轮没测通
+	MedianMs int     `json:"median_ms"` // 只算测通的轮次；一次没通过为 0
+	Loss     float64 `json:"loss"`      // 0-1，测不通的轮次占比
+	Rounds   int     `json:"rounds"`
+}
+
+// AutoPilotStatus 是自动选节点的当前状态，给 `gateway node autopilot` 展示。
+type AutoPilotStatus struct {
+	Enabled   bool       `json:"enabled"`
+	Group     string     `json:"group"`
+	Current   string     `json:"current,omitempty"`
+	Degraded  int        `json:"degraded_rounds"` // 当前节点已经连续变差几轮
+	CheckedAt time.Time  `json:"checked_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	Nodes     []NodeStat `json:"nodes,omitempty"`
+}
+
+// autoPilot 是滚动测速历史和滞回计数。每轮测速 observe 一次，只有 observe 决定
+// 切换时才动 mihomo。
+type autoPilot struct {
+	mu        sync.Mutex
+	group     string
+	current   string
+	degraded  int
+	history   map[string][]int // 节点 → 最近 Window 轮的延迟，0 = 测不通
+	checkedAt time.Time
+	lastErr   string
+}
+
+// observe 记一轮测速结果并决定要不要切。members 是组里的全部选项，delays 是
+// GroupDelay 的结果（测不通的节点不在 map 里）。返回要切到的节点和原因；
+// 不切时 to 为空。
+//
+// 切换条件（滞回）：当前节点连续 Degrade 轮测不通或延迟超过 MaxDelay，并且有候选
+// 在同样这几轮里每轮都测通、不超过 MaxDelay、还比当前节点快至少 MinGain。符合的
+// 候选里挑窗口内丢包最少、中位延迟最低的。
+func (p *autoPilot) observe(cfg config.AutoPilotConfig, group, current string, members []string, delays map[string]int, now time.Time) (to, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.group != group || p.history == nil {
+		p.group, p.history, p.degraded = group, map[string][]int{}, 0
+	}
+	if current != p.current {
+		// 用户手动切了（或者刚启动）：滞回从头算。
+		p.current, p.degraded = current, 0
+	}
+	p.checkedAt, p.lastErr = now, ""
+
+	window := cfg.Rounds()
+	keep := make(map[string][]int, len(members))
+	for _, m := range members {
+		h := append(p.history[m], delays[m])
+		if len(h) > window {
+			h = h[len(h)-window:]
+		}
+		keep[m] = h
+	}
+	p.history = keep
+
+	cur := delays[current]
+	if cur == 0 || cur > cfg.MaxDelay() {
+		p.degraded++
+	} else {
+		p.degraded = 0
+	}
+	k := cfg.Degrade()
+	if p.degraded < k {
+		return "", ""
+	}
+
+	curRecent := lastN(p.history[current], k)
+	var best string
+	var bestStat NodeStat
+	for _, m := range members {
+		if m == current || builtinOutbounds[m] {
+			continue
+		}
+		recent := lastN(p.history[m], k)
+		if len(recent) < k {
+			continue
+		}
+		better := true
+		for i, d := range recent {
+			c := 0
+			if i < len(curRecent) {
+				c = curRecent[i]
+			}
+			if d == 0 || d > cfg.MaxDelay() || (c != 0 && d+cfg.MinGain() > c) {
+				better = false
+				break
+			}
+		}
+		if !better {
+			continue
+		}
+		st := nodeStat(m, p.history[m])
+		if best == "" || st.Loss < bestStat.Loss || (st.Loss == bestStat.Loss && st.MedianMs < bestStat.MedianMs) {
+			best, bestStat = m, st
+		}
+	}
+	if best == "" {
+		return "", ""
+	}
+	curDesc := "测不通"
+	if cur > 0 {
+		curDesc = fmt.Sprintf("%dms", cur)
+	}
+	reason = fmt.Sprintf("%s 连续 %d 轮变差（最近 %s）；%s 中位 %dms、丢包 %.0f%%",
+		current, p.degraded, curDesc, best, bestStat.MedianMs, bestStat.Loss*100)
+	p.current, p.degraded = best, 0
+	return best, reason
+}
+
+func (p *autoPilot) fail(group string, err error, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.group, p.checkedAt, p.lastErr = group, now, err.Error()
+}
+
+func (p *autoPilot) status() AutoPilotStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := AutoPilotStatus{
+		Group: p.group, Current: p.current, Degraded: p.degraded,
+		CheckedAt: p.checkedAt, LastError: p.lastErr,
+	}
+	for name, h := range p.history {
+		st.Nodes = append(st.Nodes, nodeStat(name, h))
+	}
+	sort.Slice(st.Nodes, func(i, j int) bool {
+		a, b := st.Nodes[i], st.Nodes[j]
+		if a.Loss != b.Loss {
+			return a.Loss < b.Loss
+		}
+		if (a.MedianMs == 0) != (b.MedianMs == 0) {
+			return b.MedianMs == 0
+		}
+		if a.MedianMs != b.MedianMs {
+			return a.MedianMs < b.MedianMs
+		}
+		return a.Name < b.Name
+	})
+	return st
+}
+
+func nodeStat(name string, h []int) NodeStat {
+	st := NodeStat{Name: name, Rounds: len(h)}
+	if len(h) == 0 {
+		return st
+	}
+	st.LastMs = h[len(h)-1]
+	var ok []int
+	for _, d := range h {
+		if d > 0 {
+			ok = append(ok, d)
+		}
+	}
+	st.Loss = float64(len(h)-len(ok)) / float64(len(h))
+	if len(ok) > 0 {
+		sort.Ints(ok)
+		st.MedianMs = ok[len(ok)/2]
+	}
+	return st
+}
+
+func lastN(h []int, n int) []int {
+	if len(h) <= n {
+		return h
+	}
+	return h[len(h)-n:]
+}
+
+// autopilotStateFile 是最近一轮的状态快照。测速历史只在跑着 supervisor 的进程
+// （菜单或 `start --foreground`）内存里，`gateway node autopilot` 是另一个进程，
+// 靠这个文件看。
+const autopilotStateFile = "autopilot.json"
+
+// AutoPilot 返回自动选节点的当前状态：本进程在跑就用内存里的，否则读最近一轮
+// 落盘的快照。
+func (a *App) AutoPilot() AutoPilotStatus {
+	cfg := a.Cfg.Traffic.AutoPilot
+	st := a.pilot.status()
+	if st.CheckedAt.IsZero() {
+		if b, err := os.ReadFile(filepath.Join(a.Paths.Root, autopilotStateFile)); err == nil {
+			_ = json.Unmarshal(b, &st)
+		}
+	}
+	st.Enabled = cfg.Enabled
+	if st.Group == "" {
+		st.Group = cfg.TargetGroup()
+	}
+	return st
+}
+
+// autopilotLoop 按 traffic.auto_pilot.interval 测速，每轮都重新读配置：菜单里
+// 开关 / 改组不用重启。
+func (a *App) autopilotLoop(ctx context.Context) {
+	t := time.NewTimer(a.Cfg.Traffic.AutoPilot.Every())
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			a.autopilotRound(ctx, now)
+			t.Reset(a.Cfg.Traffic.AutoPilot.Every())
+		}
+	}
+}
+
+// autopilotRound 测一轮，需要时切节点并记一条切换日志。源异常、已经 fallback 到
+// direct 的时候不测：那时所有节点都不通，测了只会污染历史。
+func (a *App) autopilotRound(ctx context.Context, now time.Time) {
+	cfg := a.Cfg.Traffic.AutoPilot
+	if !cfg.Enabled || a.Engine == nil || !a.Engine.Running() || a.Health().FallbackActive {
+		return
+	}
+	group := cfg.TargetGroup()
+	cli := a.Engine.API()
+	listCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+	groups, err := cli.ListProxyGroups(listCtx)
+	cancel()
+	defer a.savePilotStatus()
+	if err != nil {
+		a.pilot.fail(group, err, now)
+		return
+	}
+	var members []string
+	var current string
+	found := false
+	for _, g := range groups {
+		if g.Name != group {
+			continue
+		}
+		if g.Type != "Selector" {
+			a.pilot.fail(group, fmt.Errorf("策略组 %s 是 %s 类型，自动选节点只管 select 组", group, g.Type), now)
+			return
+		}
+		members, current, found = g.All, g.Now, true
+	}
+	if !found {
+		a.pilot.fail(group, fmt.Errorf("没有叫 %s 的策略组", group), now)
+		return
+	}
+
+	delayCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout()+5000)*time.Millisecond)
+	delays, err := cli.GroupDelay(delayCtx, group, cfg.TestURL(), cfg.Timeout())
+	cancel()
+	if err != nil {
+		a.pilot.fail(group, err, now)
+		return
+	}
+	to, reason := a.pilot.observe(cfg, group, current, members, delays, now)
+	if to == "" {
+		return
+	}
+	selCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+	err = cli.SelectNode(selCtx, group, to)
+	cancel()
+	if err != nil {
+		a.pilot.fail(group, fmt.Errorf("切到 %s 失败: %w", to, err), now)
+		return
+	}
+	_ = a.Stats().AppendNodeSwitch(stats.NodeSwitch{At: now, Group: group, From: current, To: to, Reason: reason})
+}
+
+func (a *App) savePilotStatus() {
+	b, err := json.MarshalIndent(a.pilot.status(), "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(filepath.Join(a.Paths.Root, autopilotStateFile), b, 0o644)
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestAutoPilotHysteresis(t *testing.T) {
+	cfg := config.AutoPilotConfig{Enabled: true, DegradeRounds: 3, MaxDelayMs: 500, MinGainMs: 100}
+	members := []string{"DIRECT", "HK-01", "HK-02", "JP-01"}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var p autoPilot
+	round := func(current string, delays map[string]int) string {
+		now = now.Add(2 * time.Minute)
+		to, _ := p.observe(cfg, "Proxy", current, members, delays, now)
+		return to
+	}
+
+	// 当前节点偶尔抖一下：中间夹一轮正常就重新计数，不切。
+	for _, d := range []map[string]int{
+		{"DIRECT": 5, "HK-01": 900, "HK-02": 120, "JP-01": 200},
+		{"DIRECT": 5, "HK-01": 900, "HK-02": 120, "JP-01": 200},
+		{"DIRECT": 5, "HK-01": 150, "HK-02": 120, "JP-01": 200},
+		{"DIRECT": 5, "HK-02": 120, "JP-01": 200}, // HK-01 超时
+		{"DIRECT": 5, "HK-01": 700, "HK-02": 120, "JP-01": 200},
+	} {
+		if to := round("HK-01", d); to != "" {
+			t.Fatalf("switched to %s before %d degraded rounds", to, cfg.DegradeRounds)
+		}
+	}
+	// 第三轮连续变差。HK-02 这三轮里有一轮测不通，不算「稳定地更好」；JP-01 一直稳。
+	// DIRECT 最快但不是节点，不能选。
+	to := round("HK-01", map[string]int{"DIRECT": 5, "HK-01": 800, "JP-01": 210})
+	if to != "JP-01" {
+		t.Fatalf("want switch to JP-01, got %q", to)
+	}
+	// 刚切完，滞回清零：新节点立刻变差也要再攒满三轮。
+	if to := round("JP-01", map[string]int{"HK-01": 100, "HK-02": 100}); to != "" {
+		t.Fatalf("switched again right away to %s", to)
+	}
+
+	st := p.status()
+	if st.Current != "JP-01" || st.Degraded != 1 || len(st.Nodes) != len(members) {
+		t.Fatalf("status = %+v", st)
+	}
+	for _, n := range st.Nodes {
+		if n.Name == "HK-01" && (n.Rounds != 7 || n.Loss < 0.14 || n.Loss > 0.15) {
+			t.Errorf("HK-01 stat = %+v", n)
+		}
+	}
+}
+
+func TestAutoPilotNeedsMinGain(t *testing.T) {
+	cfg := config.AutoPilotConfig{Enabled: true, DegradeRounds: 2, MaxDelayMs: 300, MinGainMs: 100}
+	members := []string{"A", "B"}
+	var p autoPilot
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		// A 一直 350ms 算变差，但 B 只快 50ms，不值得切。
+		if to, _ := p.observe(cfg, "Proxy", "A", members, map[string]int{"A": 350, "B": 300}, now); to != "" {
+			t.Fatalf("round %d: switched to %s with gain below min_gain_ms", i, to)
+		}
+	}
+	to, _ := p.observe(cfg, "Proxy", "A", members, map[string]int{"B": 290}, now)
+	if to != "" {
+		// 最近两轮里 B 还有一轮只比 A 快 50ms，不算连续更好。
+		t.Fatalf("switched to %s with only one round of real gain", to)
+	}
+	to, reason := p.observe(cfg, "Proxy", "A", members, map[string]int{"B": 280}, now)
+	if to != "B" || !strings.Contains(reason, "测不通") {
+		t.Fatalf("want switch to B after A timed out twice, got %q (%s)", to, reason)
+	}
+}
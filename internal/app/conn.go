@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+)
+
+// ConnFilter 筛选活跃连接。Device 是来源 IP 或 gateway.device_labels 里的名字；
+// Host 含 * / ? 时按通配符整串匹配（*.google.com），否则按子串匹配，都不分大小写。
+// 零值不过滤。
+type ConnFilter struct {
+	Device string
+	Host   string
+}
+
+// Empty 报告是否没有任何筛选条件。
+func (f ConnFilter) Empty() bool {
+	return strings.TrimSpace(f.Device) == "" && strings.TrimSpace(f.Host) == ""
+}
+
+// ConnInfo 是一条活跃连接整理后的样子，给 `gateway conn list` 和菜单的连接页用。
+type ConnInfo struct {
+	ID       string        `json:"id"`
+	Source   string        `json:"source"`
+	Name     string        `json:"name,omitempty"`
+	Host     string        `json:"host"` // 域名，没有域名时是 目标IP:端口
+	Network  string        `json:"network"`
+	Rule     string        `json:"rule"`  // 规则类型,payload，如 DOMAIN-SUFFIX,google.com
+	Chain    string        `json:"chain"` // 策略组 → … → 出口节点
+	Start    time.Time     `json:"start"`
+	Age      time.Duration `json:"-"` // JSON 里看 start 就够了
+	Upload   int64         `json:"upload"`
+	Download int64         `json:"download"`
+}
+
+// Connections 拉当前活跃连接，按 filter 过滤，按开始时间从新到旧排。
+func (a *App) Connections(ctx context.Context, filter ConnFilter) ([]ConnInfo, error) {
+	if a.Engine == nil || !a.Engine.Running() {
+		return nil, fmt.Errorf("网关未运行，先 `gateway start`")
+	}
+	snap, err := a.Engine.API().GetConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterConnections(snap.Connections, filter, a.Cfg.Gateway.DeviceLabels, time.Now()), nil
+}
+
+// CloseConnections 逐条关闭连接，返回成功关掉的条数。连接在列出和关闭之间自己
+// 结束了也算失败，但不影响后面的继续关；最后返回第一个错误。
+func (a *App) CloseConnections(ctx context.Context, ids []string) (int, error) {
+	if a.Engine == nil || !a.Engine.Running() {
+		return 0, fmt.Errorf("网关未运行，先 `gateway start`")
+	}
+	cli := a.Engine.API()
+	closed := 0
+	var firstErr error
+	for _, id := range ids {
+		if err := cli.CloseConnection(ctx, id); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		closed++
+	}
+	return closed, firstErr
+}
+
+// filterConnections 把 mihomo 的原始连接整理成 ConnInfo 并过滤。
+func filterConnections(conns []engine.Connection, f ConnFilter, labels map[string]string, now time.Time) []ConnInfo {
+	device := resolveDeviceFilter(strings.TrimSpace(f.Device), labels)
+	host := strings.ToLower(strings.TrimSpace(f.Host))
+	out := make([]ConnInfo, 0, len(conns))
+	for _, c := range conns {
+		info := connInfo(c, labels, now)
+		if device != "" && info.Source != device {
+			continue
+		}
+		if host != "" && !matchHost(host, strings.ToLower(info.Host)) {
+			continue
+		}
+		out = append(out, info)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.After(out[j].Start) })
+	return out
+}
+
+// resolveDeviceFilter 把 --device 的名字换成 IP；本身就是 IP 或者找不到名字时原样返回。
+func resolveDeviceFilter(device string, labels map[string]string) string {
+	if device == "" || net.ParseIP(device) != nil {
+		return device
+	}
+	for ip, name := range labels {
+		if strings.EqualFold(name, device) {
+			return ip
+		}
+	}
+	return device
+}
+
+func matchHost(pattern, host string) bool {
+	if strings.ContainsAny(pattern, "*?") {
+		ok, _ := path.Match(pattern, host)
+		return ok
+	}
+	return strings.Contains(host, pattern)
+}
+
+func connInfo(c engine.Connection, labels map[string]string, now time.Time) ConnInfo {
+	m := c.Metadata
+	info := ConnInfo{
+		ID:       c.ID,
+		Source:   m.SourceIP,
+		Name:     labels[m.SourceIP],
+		Host:     m.Host,
+		Network:  m.Network,
+		Rule:     c.Rule,
+		Upload:   c.Upload,
+		Download: c.Download,
+	}
+	if info.Host == "" {
+		info.Host = net.JoinHostPort(m.DestinationIP, m.DestinationPort)
+	}
+	if c.RulePayload != "" {
+		info.Rule += "," + c.RulePayload
+	}
+	// chains 是 [出口节点, …, 策略组]，倒过来才是流量实际走的顺序。
+	chain := make([]string, 0, len(c.Chains))
+	for i := len(c.Chains) - 1; i >= 0; i-- {
+		chain = append(chain, c.Chains[i])
+	}
+	info.Chain = strings.Join(chain, " → ")
+	if t, err := time.Parse(time.RFC3339Nano, c.Start); err == nil {
+		info.Start = t
+		info.Age = now.Sub(t).Truncate(time.Second)
+	}
+	return info
+}
+
+// MatchConnIDs 在 list 里找 prefixes 对应的完整连接 ID（前缀匹配，conn list 只显示
+// 前 8 位）。prefixes 为空时返回 list 里所有连接；没匹配上的前缀放进 missing。
+// 空前缀（会匹配所有连接）和匹配到不止一条连接的前缀直接报错，列出候选 ——
+// 关连接没法撤销，写短了宁可让用户再补几位，也不能顺手全关了。
+func MatchConnIDs(list []ConnInfo, prefixes []string) (ids, missing []string, err error) {
+	if len(prefixes) == 0 {
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		return ids, nil, nil
+	}
+	seen := map[string]bool{}
+	for _, p := range prefixes {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			return nil, nil, errors.New("连接 ID 不能为空")
+		}
+		var hits []ConnInfo
+		for _, c := range list {
+			if strings.HasPrefix(c.ID, p) {
+				hits = append(hits, c)
+			}
+		}
+		switch len(hits) {
+		case 0:
+			missing = append(missing, p)
+		case 1:
+			if !seen[hits[0].ID] {
+				seen[hits[0].ID] = true
+				ids = append(ids, hits[0].ID)
+			}
+		default:
+			cands := make([]string, 0, len(hits))
+			for _, c := range hits {
+				cands = append(cands, c.ID+"（"+c.Host+"）")
+			}
+			return nil, nil, fmt.Errorf("%q 对应 %d 条连接，多写几位 ID 区分：\n  %s", p, len(hits), strings.Join(cands, "\n  "))
+		}
+	}
+	return ids, missing, nil
+}
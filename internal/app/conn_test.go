@@ -0,0 +1,94 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+)
+
+func TestFilterConnections(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	conns := []engine.Connection{
+		{
+			ID: "a", Upload: 10, Download: 20, Start: now.Add(-90 * time.Second).Format(time.RFC3339Nano),
+			Chains: []string{"HK-01", "Proxy"}, Rule: "DomainSuffix", RulePayload: "google.com",
+			Metadata: engine.ConnectionMetadata{Network: "tcp", SourceIP: "192.168.1.20", Host: "www.google.com"},
+		},
+		{
+			ID: "b", Start: now.Add(-10 * time.Second).Format(time.RFC3339Nano),
+			Chains: []string{"DIRECT"}, Rule: "Match",
+			Metadata: engine.ConnectionMetadata{Network: "udp", SourceIP: "192.168.1.30", DestinationIP: "1.1.1.1", DestinationPort: "443"},
+		},
+	}
+	labels := map[string]string{"192.168.1.20": "iPad"}
+
+	all := filterConnections(conns, ConnFilter{}, labels, now)
+	if len(all) != 2 || all[0].ID != "b" {
+		t.Fatalf("want newest first, got %+v", all)
+	}
+	if all[0].Host != "1.1.1.1:443" {
+		t.Errorf("host without domain = %q", all[0].Host)
+	}
+	a := all[1]
+	if a.Name != "iPad" || a.Rule != "DomainSuffix,google.com" || a.Chain != "Proxy → HK-01" || a.Age != 90*time.Second {
+		t.Errorf("conn a = %+v", a)
+	}
+
+	cases := []struct {
+		f    ConnFilter
+		want []string
+	}{
+		{ConnFilter{Device: "192.168.1.20"}, []string{"a"}},
+		{ConnFilter{Device: "ipad"}, []string{"a"}},
+		{ConnFilter{Device: "tv"}, nil},
+		{ConnFilter{Host: "GOOGLE"}, []string{"a"}},
+		{ConnFilter{Host: "*.google.com"}, []string{"a"}},
+		{ConnFilter{Host: "google.*"}, nil},
+		{ConnFilter{Host: "1.1.1.1:*"}, []string{"b"}},
+		{ConnFilter{Device: "192.168.1.30", Host: "google"}, nil},
+	}
+	for _, tc := range cases {
+		got := filterConnections(conns, tc.f, labels, now)
+		var ids []string
+		for _, c := range got {
+			ids = append(ids, c.ID)
+		}
+		if len(ids) != len(tc.want) || (len(ids) > 0 && ids[0] != tc.want[0]) {
+			t.Errorf("%+v: got %v, want %v", tc.f, ids, tc.want)
+		}
+	}
+}
+
+func TestMatchConnIDs(t *testing.T) {
+	list := []ConnInfo{{ID: "3f2a9c1e-0001"}, {ID: "77b0d4aa-0002"}}
+	ids, missing, err := MatchConnIDs(list, nil)
+	if len(ids) != 2 || missing != nil || err != nil {
+		t.Fatalf("no prefixes: ids=%v missing=%v err=%v", ids, missing, err)
+	}
+	ids, missing, err = MatchConnIDs(list, []string{"3f2a9c1e", "3f2a9c1e-0001", "deadbeef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "3f2a9c1e-0001" {
+		t.Errorf("ids = %v", ids)
+	}
+	if len(missing) != 1 || missing[0] != "deadbeef" {
+		t.Errorf("missing = %v", missing)
+	}
+
+	// 空串会匹配所有连接，绕开「必须给 ID 或 --device / --host」的保护。
+	if ids, _, err := MatchConnIDs(list, []string{""}); err == nil {
+		t.Fatalf("empty prefix should be rejected, got %v", ids)
+	}
+	// 前缀对应不止一条：报错并列出候选，一条也不关。
+	list = append(list, ConnInfo{ID: "3f2a0000-0003", Host: "example.com"})
+	ids, _, err = MatchConnIDs(list, []string{"3f2a"})
+	if err == nil || ids != nil {
+		t.Fatalf("ambiguous prefix: ids=%v err=%v", ids, err)
+	}
+	if !strings.Contains(err.Error(), "3f2a9c1e-0001") || !strings.Contains(err.Error(), "3f2a0000-0003") {
+		t.Fatalf("error should list candidates: %v", err)
+	}
+}
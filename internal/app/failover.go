@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/source"
+)
+
+// 备用源链（source.failover）：主源连续探测失败时，supervisor 按顺序找第一个
+// 测得通的备用档案，用它重新渲染 mihomo；主源恢复后切回。正在用哪个备用只记在
+// mihomo 工作目录的 source-failover.json 里，不改 a.Cfg —— 存盘、菜单里看到的
+// 始终是用户选的主源，菜单和后台服务两个进程热重载时也都能读到同一份。
+
+const (
+	failoverStateFile = "source-failover.json"
+	// maxTransitions 是 SourceHealth.Transitions 最多保留几条。
+	maxTransitions = 10
+	// directTarget 是备用全都不通、强切 direct 时切换记录里的去向。
+	directTarget = "direct"
+)
+
+// SourceTransition 是一次源切换：主源 → 备用、备用之间、备用 → 主源，
+// 或者全都不通退到 direct。From / To 是档案名，主源记 primaryLabel。
+type SourceTransition struct {
+	At     time.Time
+	From   string
+	To     string
+	Reason string
+}
+
+type failoverState struct {
+	Active string    `json:"active"`
+	Since  time.Time `json:"since"`
+}
+
+// activeFailover 返回正在顶替主源的备用档案名，用的是主源时返回空。档案被删了、
+// 或者已经不在 failover 列表里的旧状态视为无效，回到主源。
+func (a *App) activeFailover() string {
+	b, err := os.ReadFile(filepath.Join(a.Paths.MihomoDir, failoverStateFile))
+	if err != nil {
+		return ""
+	}
+	var st failoverState
+	if json.Unmarshal(b, &st) != nil {
+		return ""
+	}
+	if !slices.Contains(a.Cfg.Source.Failover, st.Active) || a.Cfg.Source.FindProfile(st.Active) < 0 {
+		return ""
+	}
+	return st.Active
+}
+
+func (a *App) saveActiveFailover(name string, now time.Time) error {
+	path := filepath.Join(a.Paths.MihomoDir, failoverStateFile)
+	if name == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(failoverState{Active: name, Since: now}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// runtimeConfig 是交给 engine 渲染的配置：有备用源顶替时先把它套上，再走
+// EffectiveRuntimeConfig。supervisor 正在 direct 兜底时 mode 也渲染成 direct ——
+// 重载是 Stop+Start，按配置里的 mode 起来就等于悄悄撤了兜底，而 FallbackActive
+// 还是 true，supervisor 不会再切一次。所有热重载都要经过这里，否则开关个广告
+// 拦截就把备用源 / 兜底冲掉了。
+func (a *App) runtimeConfig() *config.Config {
+	cfg := config.EffectiveRuntimeConfig(a.withSource(a.activeFailover()))
+	if a.Health().FallbackActive {
+		// EffectiveRuntimeConfig 返回的已经是副本，改它不会动到 a.Cfg。
+		cfg.Traffic.Mode = config.ModeDirect
+	}
+	return cfg
+}
+
+// reloadIfRunning 是后台循环（定时策略、流量额度、订阅刷新）热重载 mihomo 的
+// 统一入口：mihomo 没在跑就什么也不做，在跑就按 runtimeConfig 重载。
+func (a *App) reloadIfRunning(ctx context.Context) error {
+	if a.Engine == nil || !a.Engine.Running() {
+		return nil
+	}
+	return a.Engine.Reload(ctx, a.runtimeConfig())
+}
+
+// withSource 返回把档案 name 套到源上的配置副本；name 为空或找不到时原样返回 a.Cfg。
+func (a *App) withSource(name string) *config.Config {
+	i := a.Cfg.Source.FindProfile(name)
+	if name == "" || i < 0 {
+		return a.Cfg
+	}
+	c := *a.Cfg
+	c.Source = c.Source.ApplyProfile(c.Source.Profiles[i])
+	return &c
+}
+
+// primaryLabel 是切换记录里主源的名字：当前源对应某个档案时用档案名。
+func (a *App) primaryLabel() string {
+	if a.Cfg.Source.Current != "" {
+		return "主源（" + a.Cfg.Source.Current + "）"
+	}
+	return "主源"
+}
+
+// testSource 测一遍 cfg 的源，选项和主源探测一致。
+func testSource(ctx context.Context, cfg *config.Config) error {
+	testCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+	defer cancel()
+	return source.TestWithOptions(testCtx, cfg.Source, source.TestOptions{
+		SubscriptionProxyURL: source.LocalMixedProxyURL(cfg.Runtime.Ports.Mixed),
+		ProxyTCPOnly:         config.UsesLocalExternalProxy(cfg),
+	})
+}
+
+// pickBackup 按顺序测备用档案，返回第一个通的；全都不通时返回空，fails 是每个
+// 档案不通的原因。
+func pickBackup(chain []string, probe func(name string) error) (name string, fails []string) {
+	for _, n := range chain {
+		err := probe(n)
+		if err == nil {
+			return n, fails
+		}
+		fails = append(fails, fmt.Sprintf("%s: %v", n, err))
+	}
+	return "", fails
+}
+
+// switchSource 把运行中的 mihomo 从档案 from 切到 to（空 = 主源）。先落盘再重载：
+// 重载失败时把状态文件改回去，下一轮再试。
+func (a *App) switchSource(ctx context.Context, from, to string, now time.Time) error {
+	if err := a.saveActiveFailover(to, now); err != nil {
+		return err
+	}
+	if err := a.Engine.Reload(ctx, a.runtimeConfig()); err != nil {
+		_ = a.saveActiveFailover(from, now)
+		return err
+	}
+	return nil
+}
+
+func (a *App) sourceLabel(name string) string {
+	if name == "" {
+		return a.primaryLabel()
+	}
+	return name
+}
+
+// checkFailover 是配了 source.failover 时的处理：主源连续 supervisorMaxFails 次
+// 不通就切到第一个测得通的备用；正在用备用时每轮照样先测主源，主源一通就切回，
+// 排在前面的备用恢复了也往前挪。备用全都不通才走老路子强切 direct。
+func (a *App) checkFailover(ctx context.Context, primaryErr error, prev SourceHealth, now time.Time) {
+	active := a.activeFailover()
+	// 切换记录里的「从哪来」：已经强切 direct 时是 direct，否则是正在用的源。
+	from := a.sourceLabel(active)
+	if prev.FallbackActive {
+		from = directTarget
+	}
+	if primaryErr == nil {
+		if active != "" {
+			if err := a.switchSource(ctx, active, "", now); err != nil {
+				h := prev
+				h.CheckedAt = now
+				h.LastError = fmt.Sprintf("主源已恢复，但切回失败: %v", err)
+				a.health.set(h)
+				return
+			}
+		}
+		if prev.FallbackActive {
+			a.restoreMode(ctx, prev.OriginalMode)
+		}
+		if active != "" || prev.FallbackActive {
+			a.health.addTransition(SourceTransition{At: now, From: from, To: a.primaryLabel(), Reason: "主源恢复"})
+		}
+		a.health.set(SourceHealth{Healthy: true, CheckedAt: now})
+		return
+	}
+
+	h := SourceHealth{
+		LastError:      primaryErr.Error(),
+		FallbackActive: prev.FallbackActive,
+		OriginalMode:   prev.OriginalMode,
+		CheckedAt:      now,
+		FailCount:      prev.FailCount + 1,
+		ActiveSource:   active,
+	}
+	if h.FailCount < supervisorMaxFails {
+		a.health.set(h)
+		return
+	}
+
+	next, fails := pickBackup(a.Cfg.Source.Failover, func(name string) error {
+		return testSource(ctx, a.withSource(name))
+	})
+	if next != "" {
+		reason := fmt.Sprintf("主源连续 %d 次探测失败：%s", h.FailCount, h.LastError)
+		switch {
+		case h.FallbackActive:
+			reason = "备用 " + next + " 已恢复"
+		case active == "":
+			// 头一回离开主源，用上面的默认原因。
+		case slices.Index(a.Cfg.Source.Failover, next) < slices.Index(a.Cfg.Source.Failover, active):
+			reason = "排在前面的 " + next + " 已恢复"
+		default:
+			reason = "备用 " + active + " 也不通"
+		}
+		if next != active {
+			if err := a.switchSource(ctx, active, next, now); err != nil {
+				h.LastError = fmt.Sprintf("%s；切到备用 %s 失败: %v", h.LastError, next, err)
+				a.health.set(h)
+				return
+			}
+			h.ActiveSource = next
+		}
+		if h.FallbackActive {
+			// 刚才在 direct 兜底，重载时 runtimeConfig 还是按 direct 渲染的，这里切回去。
+			a.restoreMode(ctx, h.OriginalMode)
+			h.FallbackActive, h.OriginalMode = false, ""
+		}
+		if next != active || prev.FallbackActive {
+			a.health.addTransition(SourceTransition{At: now, From: from, To: next, Reason: reason})
+		}
+		a.health.set(h)
+		return
+	}
+
+	h.LastError = fmt.Sprintf("%s；备用源也都不通（%s）", h.LastError, strings.Join(fails, "；"))
+	if !h.FallbackActive && !config.UsesLocalExternalProxy(a.withSource(active)) {
+		apiCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+		defer cancel()
+		if err := a.Engine.API().SetMode(apiCtx, config.ModeDirect); err == nil {
+			h.FallbackActive, h.OriginalMode = true, a.Cfg.Traffic.Mode
+			a.health.addTransition(SourceTransition{At: now, From: from, To: directTarget, Reason: "主源和备用源都不通"})
+		}
+	}
+	a.health.set(h)
+}
+
+// restoreMode 把 fallback 时强切的 direct 换回 mode（空 = 配置里的 mode）。
+func (a *App) restoreMode(ctx context.Context, mode string) {
+	if mode == "" {
+		mode = a.Cfg.Traffic.Mode
+	}
+	apiCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+	defer cancel()
+	_ = a.Engine.API().SetMode(apiCtx, mode)
+}
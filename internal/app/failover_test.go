@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+)
+
+func newFailoverTestApp(t *testing.T) *App {
+	t.Helper()
+	a := newProfileTestApp(t)
+	a.Paths.MihomoDir = t.TempDir()
+	a.Cfg.Source.Type = config.SourceTypeSubscription
+	a.Cfg.Source.Subscription = config.SubscriptionSource{Name: "airport", URL: "https://example.com/sub"}
+	a.Cfg.Source.Profiles = []config.Profile{
+		{Name: "socks", Type: config.SourceTypeRemote, Remote: &config.RemoteProxy{Kind: "socks5", Server: "1.2.3.4", Port: 1080}},
+		{Name: "clash", Type: config.SourceTypeExternal, External: &config.ExternalProxy{Server: "127.0.0.1", Port: 7897}},
+	}
+	a.Cfg.Source.Failover = []string{"socks", "clash"}
+	return a
+}
+
+func TestRuntimeConfigAppliesActiveFailover(t *testing.T) {
+	a := newFailoverTestApp(t)
+	if got := a.runtimeConfig().Source.Type; got != config.SourceTypeSubscription {
+		t.Fatalf("no failover state: source = %q, want subscription", got)
+	}
+	if err := a.saveActiveFailover("socks", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	rt := a.runtimeConfig()
+	if rt.Source.Type != config.SourceTypeRemote || rt.Source.Remote.Server != "1.2.3.4" {
+		t.Fatalf("backup not applied: %+v", rt.Source)
+	}
+	if a.Cfg.Source.Type != config.SourceTypeSubscription {
+		t.Fatalf("failover must not touch the saved config, got %q", a.Cfg.Source.Type)
+	}
+
+	// 档案从链里拿掉后，旧状态文件不再作数。
+	a.Cfg.Source.Failover = []string{"clash"}
+	if got := a.activeFailover(); got != "" {
+		t.Fatalf("stale failover state honoured: %q", got)
+	}
+	a.Cfg.Source.Failover = []string{"socks", "clash"}
+
+	// 手动换主源会清掉顶替状态。
+	if err := a.SetSource(context.Background(), config.SourceConfig{Type: config.SourceTypeNone}); err != nil {
+		t.Fatalf("SetSource: %v", err)
+	}
+	if got := a.activeFailover(); got != "" {
+		t.Fatalf("SetSource should reset failover, still on %q", got)
+	}
+	if len(a.Cfg.Source.Failover) != 2 {
+		t.Fatalf("SetSource without failover should keep the chain, got %v", a.Cfg.Source.Failover)
+	}
+}
+
+func TestPickBackupTakesFirstHealthy(t *testing.T) {
+	var probed []string
+	down := map[string]bool{"socks": true}
+	name, fails := pickBackup([]string{"socks", "clash", "direct"}, func(n string) error {
+		probed = append(probed, n)
+		if down[n] {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if name != "clash" {
+		t.Fatalf("picked %q, want clash", name)
+	}
+	if len(probed) != 2 {
+		t.Fatalf("should stop at the first healthy backup, probed %v", probed)
+	}
+	if len(fails) != 1 || fails[0] != "socks: connection refused" {
+		t.Fatalf("fails = %v", fails)
+	}
+
+	down["clash"], down["direct"] = true, true
+	if name, fails = pickBackup([]string{"socks", "clash", "direct"}, func(n string) error {
+		if down[n] {
+			return errors.New("timeout")
+		}
+		return nil
+	}); name != "" || len(fails) != 3 {
+		t.Fatalf("all down: name=%q fails=%v", name, fails)
+	}
+}
+
+// 兜底 direct 期间的热重载（定时策略、额度、订阅刷新）不能按配置把 rule 渲染回去。
+func TestReloadKeepsDirectFallback(t *testing.T) {
+	a := newFailoverTestApp(t)
+	a.Cfg.Traffic.Mode = config.ModeRule
+	a.health = &healthState{}
+	if err := a.saveActiveFailover("socks", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	a.health.set(SourceHealth{FallbackActive: true, OriginalMode: config.ModeRule, FailCount: supervisorMaxFails})
+
+	out, err := engine.Render(context.Background(), a.runtimeConfig(), t.TempDir())
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(string(out), "mode: direct") {
+		t.Fatalf("reload during fallback should render direct mode:\n%s", out)
+	}
+	if a.Cfg.Traffic.Mode != config.ModeRule {
+		t.Fatalf("fallback must not touch the saved mode, got %q", a.Cfg.Traffic.Mode)
+	}
+
+	a.health.set(SourceHealth{Healthy: true})
+	if got := a.runtimeConfig().Traffic.Mode; got != config.ModeRule {
+		t.Fatalf("after recovery mode = %q, want rule", got)
+	}
+}
+
+func TestHealthTransitionsSurviveSetAndAreCapped(t *testing.T) {
+	var s healthState
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < maxTransitions+3; i++ {
+		s.addTransition(SourceTransition{At: t0.Add(time.Duration(i) * time.Minute), From: "主源", To: "socks"})
+	}
+	s.set(SourceHealth{Healthy: true})
+	h := s.snapshot()
+	if len(h.Transitions) != maxTransitions {
+		t.Fatalf("transitions = %d, want %d", len(h.Transitions), maxTransitions)
+	}
+	if !h.Transitions[0].At.Equal(t0.Add(3 * time.Minute)) {
+		t.Fatalf("oldest kept transition = %v, want the 4th", h.Transitions[0].At)
+	}
+}
+
+func TestRemoveProfileDropsItFromFailover(t *testing.T) {
+	a := newFailoverTestApp(t)
+	if err := a.RemoveProfile("socks"); err != nil {
+		t.Fatalf("RemoveProfile: %v", err)
+	}
+	if len(a.Cfg.Source.Failover) != 1 || a.Cfg.Source.Failover[0] != "clash" {
+		t.Fatalf("failover = %v, want [clash]", a.Cfg.Source.Failover)
+	}
+	if _, err := config.LoadFrom(a.Paths.ConfigFile); err != nil {
+		t.Fatalf("saved config no longer loads: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/metrics"
+	"github.com/tght/lan-proxy-gateway/internal/source"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+const (
+	// metricsDelayInterval 是后台测速间隔。测速会真的对每个节点发请求，
+	// 不能跟着 Prometheus 每 15 秒抓一次的节奏来。
+	metricsDelayInterval = 5 * time.Minute
+	metricsDelayTimeout  = 3000 // ms，单节点
+	metricsDelayURL      = "http://www.gstatic.com/generate_204"
+)
+
+// delayCache 是最近一次测速结果。
+type delayCache struct {
+	mu     sync.Mutex
+	group  string
+	delays map[string]int
+}
+
+func (c *delayCache) set(group string, d map[string]int) {
+	c.mu.Lock()
+	c.group, c.delays = group, d
+	c.mu.Unlock()
+}
+
+func (c *delayCache) get() (string, map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.group, c.delays
+}
+
+// trafficCounters 返回进程内按设备 / 策略组的字节计数，由流量采样喂数据。
+func (a *App) trafficCounters() *stats.Counters {
+	a.countersOnce.Do(func() {
+		a.counters = stats.NewCounters()
+	})
+	return a.counters
+}
+
+// StartMetrics 在 runtime.metrics.listen 上起 /metrics，ctx 结束时关掉。
+// 没开 runtime.metrics.enabled 时什么都不做。端口被占这类错误直接返回，
+// 由调用方决定要不要因此退出。
+func (a *App) StartMetrics(ctx context.Context) error {
+	m := a.Cfg.Runtime.Metrics
+	if !m.Enabled {
+		return nil
+	}
+	ln, err := net.Listen("tcp", m.ListenAddr())
+	if err != nil {
+		return fmt.Errorf("metrics 监听 %s 失败: %w", m.ListenAddr(), err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		a.writeMetrics(r.Context(), w)
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	// Serve 只会在 Shutdown 或监听被关时返回，这两种都不用处理。
+	go func() { _ = srv.Serve(ln) }()
+	go func() {
+		<-ctx.Done()
+		shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutCtx)
+	}()
+	go a.delayLoop(ctx, m.Group())
+	return nil
+}
+
+// delayLoop 定时测一遍 group 里的节点，结果给 /metrics 读。
+func (a *App) delayLoop(ctx context.Context, group string) {
+	probe := func() {
+		if a.Engine == nil || !a.Engine.Running() {
+			return
+		}
+		pctx, cancel := context.WithTimeout(ctx, time.Duration(metricsDelayTimeout+5000)*time.Millisecond)
+		defer cancel()
+		d, err := a.Engine.API().GroupDelay(pctx, group, metricsDelayURL, metricsDelayTimeout)
+		if err != nil {
+			return
+		}
+		a.delays.set(group, d)
+	}
+	probe()
+	t := time.NewTicker(metricsDelayInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			probe()
+		}
+	}
+}
+
+// metricsSnapshot 是一次抓取要导出的全部数据，先收集再统一格式化。
+type metricsSnapshot struct {
+	up            bool
+	conns         *engine.ConnectionsSnapshot
+	devices       map[string]stats.Usage
+	groups        map[string]stats.Usage
+	labels        map[string]string
+	health        SourceHealth
+	delayGroup    string
+	delays        map[string]int
+	subscriptions []source.SubscriptionInfo
+}
+
+func (a *App) writeMetrics(ctx context.Context, w io.Writer) {
+	snap := metricsSnapshot{
+		labels:        a.Cfg.Gateway.DeviceLabels,
+		health:        a.Health(),
+		subscriptions: a.SubscriptionInfo(),
+	}
+	snap.devices, snap.groups = a.trafficCounters().Snapshot()
+	snap.delayGroup, snap.delays = a.delays.get()
+	if a.Engine != nil && a.Engine.Running() {
+		snap.up = true
+		fetchCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		snap.conns, _ = a.Engine.API().GetConnections(fetchCtx)
+		cancel()
+	}
+	writeMetricsSnapshot(w, snap)
+}
+
+func writeMetricsSnapshot(w io.Writer, s metricsSnapshot) {
+	m := metrics.NewWriter(w)
+	defer m.Flush()
+
+	m.Gauge("lan_gateway_mihomo_up", "mihomo 是否在运行", metrics.Bool(s.up))
+	if s.conns != nil {
+		m.Counter("lan_gateway_upload_bytes_total", "mihomo 启动以来的上行字节数", float64(s.conns.UploadTotal))
+		m.Counter("lan_gateway_download_bytes_total", "mihomo 启动以来的下行字节数", float64(s.conns.DownloadTotal))
+		m.Gauge("lan_gateway_connections", "当前活跃连接数", float64(len(s.conns.Connections)))
+		perDevice := map[string]int{}
+		for _, c := range s.conns.Connections {
+			if !stats.SkipSource(c.Metadata.SourceIP) {
+				perDevice[c.Metadata.SourceIP]++
+			}
+		}
+		for _, ip := range sortedKeys(perDevice) {
+			m.Gauge("lan_gateway_device_connections", "每台 LAN 设备的活跃连接数", float64(perDevice[ip]),
+				"ip", ip, "name", s.labels[ip])
+		}
+	}
+
+	devs := sortedKeys(s.devices)
+	for _, ip := range devs {
+		m.Counter("lan_gateway_device_upload_bytes_total", "每台 LAN 设备的上行字节数（本进程采样以来）",
+			float64(s.devices[ip].Up), "ip", ip, "name", s.labels[ip])
+	}
+	for _, ip := range devs {
+		m.Counter("lan_gateway_device_download_bytes_total", "每台 LAN 设备的下行字节数（本进程采样以来）",
+			float64(s.devices[ip].Down), "ip", ip, "name", s.labels[ip])
+	}
+	groups := sortedKeys(s.groups)
+	for _, g := range groups {
+		m.Counter("lan_gateway_group_upload_bytes_total", "每个策略组的上行字节数（本进程采样以来）",
+			float64(s.groups[g].Up), "group", g)
+	}
+	for _, g := range groups {
+		m.Counter("lan_gateway_group_download_bytes_total", "每个策略组的下行字节数（本进程采样以来）",
+			float64(s.groups[g].Down), "group", g)
+	}
+
+	if !s.health.CheckedAt.IsZero() {
+		m.Gauge("lan_gateway_source_healthy", "代理源健康探测是否通过", metrics.Bool(s.health.Healthy))
+		m.Gauge("lan_gateway_source_fail_count", "代理源连续失败次数", float64(s.health.FailCount))
+		m.Gauge("lan_gateway_source_fallback_active", "是否因源异常临时切到了 direct", metrics.Bool(s.health.FallbackActive))
+		m.Gauge("lan_gateway_source_failover_active", "是否正在用备用源顶替主源", metrics.Bool(s.health.ActiveSource != ""))
+	}
+
+	nodes := sortedKeys(s.delays)
+	for _, n := range nodes {
+		m.Gauge("lan_gateway_node_up", "节点最近一次测速是否成功", metrics.Bool(s.delays[n] > 0),
+			"group", s.delayGroup, "node", n)
+	}
+	for _, n := range nodes {
+		if s.delays[n] > 0 {
+			m.Gauge("lan_gateway_node_delay_ms", "节点最近一次测速延迟（毫秒）", float64(s.delays[n]),
+				"group", s.delayGroup, "node", n)
+		}
+	}
+
+	for _, info := range s.subscriptions {
+		m.Gauge("lan_gateway_subscription_used_bytes", "订阅已用流量", float64(info.Used()), "name", info.Name)
+	}
+	for _, info := range s.subscriptions {
+		if info.Total > 0 {
+			m.Gauge("lan_gateway_subscription_total_bytes", "订阅总流量", float64(info.Total), "name", info.Name)
+		}
+	}
+	for _, info := range s.subscriptions {
+		if !info.Expire.IsZero() {
+			m.Gauge("lan_gateway_subscription_expire_timestamp_seconds", "订阅到期时间（Unix 秒）",
+				float64(info.Expire.Unix()), "name", info.Name)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/source"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+func TestWriteMetricsSnapshot(t *testing.T) {
+	var b strings.Builder
+	writeMetricsSnapshot(&b, metricsSnapshot{
+		up: true,
+		conns: &engine.ConnectionsSnapshot{
+			UploadTotal: 100, DownloadTotal: 2000,
+			Connections: []engine.Connection{
+				{Metadata: engine.ConnectionMetadata{SourceIP: "192.168.1.23"}},
+				{Metadata: engine.ConnectionMetadata{SourceIP: "192.168.1.23"}},
+				{Metadata: engine.ConnectionMetadata{SourceIP: "127.0.0.1"}},
+			},
+		},
+		devices:       map[string]stats.Usage{"192.168.1.23": {Up: 10, Down: 20}},
+		groups:        map[string]stats.Usage{"Proxy": {Up: 5, Down: 6}},
+		labels:        map[string]string{"192.168.1.23": "Switch"},
+		health:        SourceHealth{Healthy: false, FailCount: 3, FallbackActive: true, CheckedAt: time.Now()},
+		delayGroup:    "Proxy",
+		delays:        map[string]int{"HK 01": 120, "JP 02": 0},
+		subscriptions: []source.SubscriptionInfo{{Name: "机场A", Download: 1024, Total: 4096}},
+	})
+	out := b.String()
+	for _, want := range []string{
+		"lan_gateway_mihomo_up 1\n",
+		"lan_gateway_download_bytes_total 2000\n",
+		"lan_gateway_connections 3\n",
+		`lan_gateway_device_connections{ip="192.168.1.23",name="Switch"} 2` + "\n",
+		`lan_gateway_device_download_bytes_total{ip="192.168.1.23",name="Switch"} 20` + "\n",
+		`lan_gateway_group_upload_bytes_total{group="Proxy"} 5` + "\n",
+		"lan_gateway_source_fail_count 3\n",
+		"lan_gateway_source_fallback_active 1\n",
+		`lan_gateway_node_up{group="Proxy",node="JP 02"} 0` + "\n",
+		`lan_gateway_node_delay_ms{group="Proxy",node="HK 01"} 120` + "\n",
+		`lan_gateway_subscription_total_bytes{name="机场A"} 4096` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `node_delay_ms{group="Proxy",node="JP 02"}`) {
+		t.Errorf("timed-out node should not export a delay:\n%s", out)
+	}
+	if strings.Contains(out, "127.0.0.1") {
+		t.Errorf("loopback connections are not LAN devices:\n%s", out)
+	}
+	if strings.Count(out, "# TYPE lan_gateway_device_connections ") != 1 {
+		t.Errorf("TYPE line should appear once per family:\n%s", out)
+	}
+}
+
+func TestWriteMetricsSnapshotMihomoDown(t *testing.T) {
+	var b strings.Builder
+	writeMetricsSnapshot(&b, metricsSnapshot{})
+	if out := b.String(); !strings.Contains(out, "lan_gateway_mihomo_up 0\n") || strings.Contains(out, "lan_gateway_connections") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// TestNodes 测一次延迟并记进测速历史。target 是策略组名时整组并发测（GroupDelay），
+// 否则当单个节点测（/proxies/:name/delay）；为空时测 Proxy 组。组里测不通的节点
+// mihomo 不返回，这里按 0 补齐，失败次数才算得对。
+func (a *App) TestNodes(ctx context.Context, target, testURL string, timeoutMs int) (stats.DelayRun, error) {
+	if a.Engine == nil || !a.Engine.Running() {
+		return stats.DelayRun{}, fmt.Errorf("网关未运行，先 `gateway start`")
+	}
+	if target == "" {
+		target = config.DefaultDelayGroup
+	}
+	if testURL == "" {
+		testURL = config.DefaultAutoPilotURL
+	}
+	cli := a.Engine.API()
+	groups, err := cli.ListProxyGroups(ctx)
+	if err != nil {
+		return stats.DelayRun{}, err
+	}
+	run := stats.DelayRun{At: time.Now(), URL: testURL, Delays: map[string]int{}}
+	isGroup := false
+	for _, g := range groups {
+		if g.Name != target {
+			continue
+		}
+		isGroup = true
+		delays, err := cli.GroupDelay(ctx, target, testURL, timeoutMs)
+		if err != nil {
+			return stats.DelayRun{}, err
+		}
+		run.Group = target
+		for _, m := range g.All {
+			if !builtinOutbounds[m] {
+				run.Delays[m] = delays[m]
+			}
+		}
+	}
+	if !isGroup {
+		d, err := cli.ProxyDelay(ctx, target, testURL, timeoutMs)
+		if err != nil {
+			return stats.DelayRun{}, err
+		}
+		run.Delays[target] = d
+	}
+	if err := a.Stats().AppendDelayRun(run); err != nil {
+		return run, fmt.Errorf("测速结果没能写进历史: %w", err)
+	}
+	return run, nil
+}
+
+// NodeHistory 汇总 node 自 since 以来的测速历史。
+func (a *App) NodeHistory(node string, since, until time.Time) (stats.NodeHistory, error) {
+	runs, err := a.Stats().DelayRuns(since, until)
+	if err != nil {
+		return stats.NodeHistory{}, err
+	}
+	return stats.SummarizeNode(runs, node, since), nil
+}
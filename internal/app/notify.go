@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/notify"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// 事件通知（runtime.notify）：supervisor / 流量采样已经在盯的那几件事，
+// 状态一变就顺手推出去。限流在 notify.Notifier 里做，这里只管「发生了什么」。
+
+// crashLogLines 是 mihomo 崩溃通知里带的日志行数（存档的 crashTailLines 更多）。
+const crashLogLines = 10
+
+func (a *App) notifier() *notify.Notifier {
+	a.notifierOnce.Do(func() { a.notif = notify.New() })
+	return a.notif
+}
+
+// notify 按 runtime.notify 推一条事件（异步、限流）。没配出口时什么也不做。
+func (a *App) notify(kind, key, title, message string) {
+	a.notifier().Notify(a.Cfg.Runtime.Notify, notify.Event{
+		Kind: kind, Key: key, Title: title, Message: message, At: time.Now(),
+	})
+}
+
+// TestNotify 给所有出口同步发一条测试消息，返回失败的出口。kind 为空时按
+// source_down 发（多数出口都会订它）。
+func (a *App) TestNotify(ctx context.Context, kind string) []error {
+	n := a.Cfg.Runtime.Notify
+	if !n.Enabled() {
+		return []error{notify.ErrNoSinks}
+	}
+	if kind == "" {
+		kind = config.NotifySourceDown
+	}
+	return notify.Send(ctx, n, notify.Event{
+		Kind: kind, Title: "测试通知", At: time.Now(),
+		Message: "这是 lan-proxy-gateway 的测试消息，收到说明 runtime.notify 配好了。",
+	})
+}
+
+// notifyHealth 对比一轮源检查前后的健康状态，把值得推的变化发出去：
+// 刚判定为不通（连续失败达到 supervisorMaxFails）、刚强切 direct、从不通恢复。
+func (a *App) notifyHealth(before, after SourceHealth) {
+	wasDown := before.FailCount >= supervisorMaxFails
+	switch {
+	case !wasDown && after.FailCount >= supervisorMaxFails:
+		msg := after.LastError
+		if after.ActiveSource != "" {
+			msg = fmt.Sprintf("已切到备用源 %s。原因: %s", after.ActiveSource, after.LastError)
+		}
+		a.notify(config.NotifySourceDown, "", "代理源不通", msg)
+	case wasDown && after.Healthy:
+		a.notify(config.NotifySourceUp, "", "代理源已恢复", "主源探测恢复正常，已切回。")
+	}
+	if !before.FallbackActive && after.FallbackActive {
+		a.notify(config.NotifyFallbackDirect, "", "已临时切到直连",
+			"LAN 设备不会断网，但不再走代理；源恢复后自动切回。原因: "+after.LastError)
+	}
+}
+
+// notifyExpiry 推订阅到期提醒；同一份订阅一天最多一次（notify.minInterval）。
+func (a *App) notifyExpiry(now time.Time) {
+	for _, info := range a.SubscriptionInfo() {
+		if w, ok := info.ExpiryWarning(now, a.Cfg.Source.ExpiryWarnDays); ok {
+			a.notify(config.NotifySubExpiry, info.Name, "订阅快到期", w)
+		}
+	}
+}
+
+// notifyEngineCrash 在 mihomo 没走 Stop 就退出时推一条，说明 watchdog 打算怎么
+// 拉起来，并带上日志末尾几行。
+func (a *App) notifyEngineCrash(tail string, delay time.Duration, lastGood bool) {
+	msg := "mihomo 进程意外退出，LAN 设备暂时断网；" + restartPlan(delay, lastGood) + "。"
+	if tail = lastLines(tail, crashLogLines); tail != "" {
+		msg += "\n\n日志末尾：\n" + tail
+	}
+	a.notify(config.NotifyEngineCrash, "", "mihomo 意外退出", msg)
+}
+
+// lastLines 返回 s 的最后 n 行。
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// notifyNewDevices 把这次采样里第一次出现的设备记下来并推送。已经在
+// gateway.device_labels 里打过标签的设备算认识的，不报。
+func (a *App) notifyNewDevices(samples []stats.Sample, now time.Time) {
+	ips := make([]string, 0, len(samples))
+	for _, s := range samples {
+		ips = append(ips, s.SourceIP)
+	}
+	fresh, err := a.Stats().MarkSeen(ips, now)
+	if err != nil {
+		return
+	}
+	for _, ip := range fresh {
+		if a.Cfg.Gateway.DeviceLabels[ip] != "" {
+			continue
+		}
+		a.notify(config.NotifyNewDevice, ip, "发现新设备",
+			fmt.Sprintf("%s 第一次通过网关上网。认识的话可以在菜单「设备标签」里给它起个名字。", ip))
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/notify"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// newNotifyTestApp 返回一个把通知 POST 到本地 webhook 的 App，和收通知的 channel。
+func newNotifyTestApp(t *testing.T) (*App, <-chan notify.Event) {
+	t.Helper()
+	got := make(chan notify.Event, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev notify.Event
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		got <- ev
+	}))
+	t.Cleanup(srv.Close)
+	a := newProfileTestApp(t)
+	a.Paths.Root = t.TempDir()
+	a.Cfg.Runtime.Notify = config.NotifyConfig{Sinks: []config.NotifySink{{Type: config.NotifySinkWebhook, URL: srv.URL}}}
+	return a, got
+}
+
+func recvKinds(t *testing.T, got <-chan notify.Event, n int) map[string]notify.Event {
+	t.Helper()
+	out := map[string]notify.Event{}
+	for i := 0; i < n; i++ {
+		select {
+		case ev := <-got:
+			out[ev.Kind] = ev
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d of %d notifications: %v", i, n, out)
+		}
+	}
+	select {
+	case ev := <-got:
+		t.Fatalf("unexpected extra notification %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return out
+}
+
+func TestNotifyHealthTransitions(t *testing.T) {
+	a, got := newNotifyTestApp(t)
+
+	// 第一次失败还不算挂。
+	a.notifyHealth(SourceHealth{Healthy: true}, SourceHealth{FailCount: 1, LastError: "timeout"})
+	recvKinds(t, got, 0)
+
+	a.notifyHealth(SourceHealth{FailCount: 1}, SourceHealth{FailCount: 2, LastError: "timeout", FallbackActive: true})
+	evs := recvKinds(t, got, 2)
+	if _, ok := evs[config.NotifySourceDown]; !ok {
+		t.Fatalf("missing source_down: %v", evs)
+	}
+	if _, ok := evs[config.NotifyFallbackDirect]; !ok {
+		t.Fatalf("missing fallback_direct: %v", evs)
+	}
+
+	// 还挂着：不重复报。
+	a.notifyHealth(SourceHealth{FailCount: 2, FallbackActive: true}, SourceHealth{FailCount: 3, FallbackActive: true})
+	recvKinds(t, got, 0)
+
+	a.notifyHealth(SourceHealth{FailCount: 3, FallbackActive: true}, SourceHealth{Healthy: true})
+	if evs := recvKinds(t, got, 1); evs[config.NotifySourceUp].Title == "" {
+		t.Fatalf("missing source_up: %v", evs)
+	}
+}
+
+func TestNotifyNewDevicesSkipsLabelledAndKnown(t *testing.T) {
+	a, got := newNotifyTestApp(t)
+	a.Cfg.Gateway.DeviceLabels = map[string]string{"192.168.1.30": "Switch"}
+	now := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	samples := func(ips ...string) []stats.Sample {
+		var out []stats.Sample
+		for _, ip := range ips {
+			out = append(out, stats.Sample{SourceIP: ip})
+		}
+		return out
+	}
+
+	a.notifyNewDevices(samples("192.168.1.10"), now) // 第一次只建名单
+	recvKinds(t, got, 0)
+	a.notifyNewDevices(samples("192.168.1.10", "192.168.1.20", "192.168.1.30"), now.Add(time.Minute))
+	if ev := recvKinds(t, got, 1)[config.NotifyNewDevice]; ev.Key != "192.168.1.20" {
+		t.Fatalf("new device event = %+v", ev)
+	}
+}
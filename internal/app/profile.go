@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// Profiles 返回已保存的源档案（source.profiles），只读快照。
+func (a *App) Profiles() []config.Profile {
+	return append([]config.Profile(nil), a.Cfg.Source.Profiles...)
+}
+
+// AddProfile 新增一个源档案并存盘。同名已存在时报错，不静默覆盖 ——
+// 想改就先 rm 再 add。不影响当前生效的源，所以不热重载。
+func (a *App) AddProfile(p config.Profile) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("档案名不能为空")
+	}
+	if a.Cfg.Source.FindProfile(p.Name) >= 0 {
+		return fmt.Errorf("档案 %q 已存在", p.Name)
+	}
+	before := a.Cfg.Source.Profiles
+	a.Cfg.Source.Profiles = append(append([]config.Profile(nil), before...), p)
+	if err := a.Save(); err != nil {
+		a.Cfg.Source.Profiles = before
+		return err
+	}
+	return nil
+}
+
+// UseProfile 切到指定档案：套用到 source、记下 current，存盘并热重载，一步到位。
+func (a *App) UseProfile(ctx context.Context, name string) error {
+	i := a.Cfg.Source.FindProfile(name)
+	if i < 0 {
+		return fmt.Errorf("没有名为 %q 的档案", name)
+	}
+	return a.SetSource(ctx, a.Cfg.Source.ApplyProfile(a.Cfg.Source.Profiles[i]))
+}
+
+// RemoveProfile 删掉一个档案。删的是当前档案时只清 current，当前生效的源保持不变
+// （LAN 设备不该因为整理档案而断网）；在备用源链里的也一并拿掉。
+func (a *App) RemoveProfile(name string) error {
+	i := a.Cfg.Source.FindProfile(name)
+	if i < 0 {
+		return fmt.Errorf("没有名为 %q 的档案", name)
+	}
+	profiles := append([]config.Profile(nil), a.Cfg.Source.Profiles[:i]...)
+	a.Cfg.Source.Profiles = append(profiles, a.Cfg.Source.Profiles[i+1:]...)
+	if a.Cfg.Source.Current == name {
+		a.Cfg.Source.Current = ""
+	}
+	if j := slices.Index(a.Cfg.Source.Failover, name); j >= 0 {
+		a.Cfg.Source.Failover = slices.Delete(slices.Clone(a.Cfg.Source.Failover), j, j+1)
+	}
+	return a.Save()
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func newProfileTestApp(t *testing.T) *App {
+	t.Helper()
+	cfg := config.Default()
+	config.Normalize(cfg)
+	return &App{
+		Cfg:   cfg,
+		Paths: config.Paths{ConfigFile: filepath.Join(t.TempDir(), "gateway.yaml")},
+		Plat:  &fakePlatform{},
+	}
+}
+
+func TestUseProfileSwitchesSourceAndSavesCurrent(t *testing.T) {
+	a := newProfileTestApp(t)
+	if err := a.AddProfile(config.Profile{
+		Name: "work", Type: config.SourceTypeSubscription,
+		Subscription: &config.SubscriptionSource{URL: "https://example.com/sub", Name: "work"},
+	}); err != nil {
+		t.Fatalf("AddProfile: %v", err)
+	}
+	if err := a.UseProfile(context.Background(), "work"); err != nil {
+		t.Fatalf("UseProfile: %v", err)
+	}
+	onDisk, err := config.LoadFrom(a.Paths.ConfigFile)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if onDisk.Source.Type != config.SourceTypeSubscription || onDisk.Source.Subscription.URL != "https://example.com/sub" {
+		t.Fatalf("profile not applied to source: %+v", onDisk.Source)
+	}
+	if onDisk.Source.Current != "work" {
+		t.Fatalf("current = %q, want work", onDisk.Source.Current)
+	}
+}
+
+func TestAddProfileRejectsDuplicate(t *testing.T) {
+	a := newProfileTestApp(t)
+	p := config.Profile{Name: "home", Type: config.SourceTypeFile, File: &config.FileSource{Path: "/tmp/home.yaml"}}
+	if err := a.AddProfile(p); err != nil {
+		t.Fatalf("AddProfile: %v", err)
+	}
+	if err := a.AddProfile(p); err == nil {
+		t.Fatal("expected duplicate profile error")
+	}
+	if len(a.Profiles()) != 1 {
+		t.Fatalf("duplicate must not be appended; got %d", len(a.Profiles()))
+	}
+}
+
+func TestAddProfileInvalidDoesNotMutate(t *testing.T) {
+	a := newProfileTestApp(t)
+	if err := a.AddProfile(config.Profile{Name: "bad", Type: config.SourceTypeFile}); err == nil {
+		t.Fatal("expected validation error for file profile without path")
+	}
+	if len(a.Profiles()) != 0 {
+		t.Fatalf("invalid profile must be rolled back; got %v", a.Profiles())
+	}
+}
+
+func TestRemoveCurrentProfileKeepsSource(t *testing.T) {
+	a := newProfileTestApp(t)
+	_ = a.AddProfile(config.Profile{Name: "home", Type: config.SourceTypeFile, File: &config.FileSource{Path: "/tmp/home.yaml"}})
+	if err := a.UseProfile(context.Background(), "home"); err != nil {
+		t.Fatalf("UseProfile: %v", err)
+	}
+	if err := a.RemoveProfile("home"); err != nil {
+		t.Fatalf("RemoveProfile: %v", err)
+	}
+	if a.Cfg.Source.Current != "" {
+		t.Fatalf("current must be cleared, got %q", a.Cfg.Source.Current)
+	}
+	if a.Cfg.Source.Type != config.SourceTypeFile {
+		t.Fatalf("active source must stay untouched, got %q", a.Cfg.Source.Type)
+	}
+}
+
+// `config source` 构造的 SourceConfig 不带档案列表，SetSource 不能把档案冲掉。
+func TestSetSourcePreservesProfiles(t *testing.T) {
+	a := newProfileTestApp(t)
+	_ = a.AddProfile(config.Profile{Name: "home", Type: config.SourceTypeFile, File: &config.FileSource{Path: "/tmp/home.yaml"}})
+	_ = a.UseProfile(context.Background(), "home")
+	if err := a.SetSource(context.Background(), config.SourceConfig{Type: config.SourceTypeNone}); err != nil {
+		t.Fatalf("SetSource: %v", err)
+	}
+	if len(a.Cfg.Source.Profiles) != 1 {
+		t.Fatalf("profiles dropped by SetSource: %+v", a.Cfg.Source)
+	}
+	if a.Cfg.Source.Current != "" {
+		t.Fatalf("manual SetSource must clear current, got %q", a.Cfg.Source.Current)
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// quotaEvery 是每隔几轮流量采样检查一次额度（10s × 6 = 1 分钟）。月额度要读
+// 整月的按天文件，没必要每次采样都算；超额晚一分钟封不是问题。
+const quotaEvery = 6
+
+// QuotaStatus 是一条额度当前周期的用量，给 `gateway stats quota` 展示。
+type QuotaStatus struct {
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	Name       string    `json:"name,omitempty"`
+	Period     string    `json:"period"`
+	Action     string    `json:"action"`
+	LimitBytes int64     `json:"limit_bytes"`
+	Used       int64     `json:"used"`
+	Blocked    bool      `json:"blocked"`
+	ResetAt    time.Time `json:"reset_at"`
+}
+
+// QuotaStatuses 返回每条额度当前周期的用量和是否已封。
+func (a *App) QuotaStatuses(now time.Time) ([]QuotaStatus, error) {
+	blocks, _ := stats.LoadQuotaBlocks(a.Paths.MihomoDir)
+	out := make([]QuotaStatus, 0, len(a.Cfg.Gateway.DeviceQuotas))
+	for _, q := range a.Cfg.Gateway.DeviceQuotas {
+		ip := a.Cfg.Gateway.QuotaIP(q)
+		start, end := q.PeriodBounds(now)
+		used, err := a.usedSince(ip, start, now)
+		if err != nil {
+			return nil, err
+		}
+		st := QuotaStatus{
+			Device: q.Device, IP: ip, Name: a.Cfg.Gateway.DeviceLabels[ip],
+			Period: q.Period, Action: q.QuotaAction(),
+			LimitBytes: q.LimitBytes(), Used: used, ResetAt: end,
+		}
+		for _, b := range blocks {
+			if b.IP == ip && b.Period == q.Period && b.ActiveAt(now) {
+				st.Blocked = true
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// QuotaModeWarning 在配了设备流量额度、但 traffic.mode 不是 rule 时返回提醒：
+// 超额封禁是一条 SRC-IP-CIDR 规则，global / direct 模式下 mihomo 不看规则。
+func (a *App) QuotaModeWarning() string {
+	mode := a.Cfg.Traffic.Mode
+	if len(a.Cfg.Gateway.DeviceQuotas) == 0 || mode == config.ModeRule {
+		return ""
+	}
+	return fmt.Sprintf("当前是 %s 模式，mihomo 不看规则，设备流量额度超了也拦不住（用量照记）；切回 rule 模式才生效", mode)
+}
+
+// QuotaBlocks 返回当前生效的超额封禁，给 status 展示。
+func (a *App) QuotaBlocks(now time.Time) []stats.QuotaBlock {
+	blocks, _ := stats.LoadQuotaBlocks(a.Paths.MihomoDir)
+	var out []stats.QuotaBlock
+	for _, b := range blocks {
+		if b.ActiveAt(now) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// checkQuotas 按当前用量重算封禁表；有变化就落盘、记事件、热重载 mihomo。
+// 封禁表放在 mihomo 工作目录，渲染时由 engine 读取。
+func (a *App) checkQuotas(ctx context.Context, now time.Time) {
+	prev, err := stats.LoadQuotaBlocks(a.Paths.MihomoDir)
+	if err != nil {
+		return
+	}
+	if len(prev) == 0 && len(a.Cfg.Gateway.DeviceQuotas) == 0 {
+		return
+	}
+	next, events := evaluateQuotas(a.Cfg.Gateway, prev, now, func(ip string, since time.Time) int64 {
+		used, _ := a.usedSince(ip, since, now)
+		return used
+	})
+	if len(events) == 0 {
+		return
+	}
+	if err := stats.SaveQuotaBlocks(a.Paths.MihomoDir, next); err != nil {
+		return
+	}
+	for _, e := range events {
+		_ = a.Stats().AppendQuotaEvent(e)
+	}
+	_ = a.reloadIfRunning(ctx)
+}
+
+func (a *App) usedSince(ip string, since, now time.Time) (int64, error) {
+	if ip == "" {
+		return 0, nil
+	}
+	list, err := a.Stats().Usage(since, now.Add(time.Hour))
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range list {
+		if d.IP == ip {
+			return d.Total.Total(), nil
+		}
+	}
+	return 0, nil
+}
+
+// evaluateQuotas 算出 now 时的封禁表和相对 prev 的变化事件。used 返回某设备自
+// since 起的用量。已经封着的设备沿用原来的封禁时间，不重复记事件；周期过了、
+// 额度调大了、或者额度被删了的，记一条 reset 解封。
+func evaluateQuotas(g config.GatewayConfig, prev []stats.QuotaBlock, now time.Time, used func(ip string, since time.Time) int64) ([]stats.QuotaBlock, []stats.QuotaEvent) {
+	prevByKey := map[string]stats.QuotaBlock{}
+	for _, b := range prev {
+		prevByKey[b.IP+"|"+b.Period] = b
+	}
+	var next []stats.QuotaBlock
+	var events []stats.QuotaEvent
+	kept := map[string]bool{}
+	for _, q := range g.DeviceQuotas {
+		ip := g.QuotaIP(q)
+		if ip == "" {
+			continue
+		}
+		start, end := q.PeriodBounds(now)
+		limit := q.LimitBytes()
+		n := used(ip, start)
+		if limit <= 0 || n < limit {
+			continue
+		}
+		key := ip + "|" + q.Period
+		if b, ok := prevByKey[key]; ok && b.ActiveAt(now) && b.Target == q.QuotaAction() {
+			b.Used, b.Limit = n, limit
+			next = append(next, b)
+			kept[key] = true
+			continue
+		}
+		b := stats.QuotaBlock{
+			IP: ip, Device: q.Device, Target: q.QuotaAction(), Period: q.Period,
+			Limit: limit, Used: n, At: now, Until: end,
+		}
+		next = append(next, b)
+		kept[key] = true
+		events = append(events, stats.QuotaEvent{
+			At: now, Kind: stats.QuotaExceeded, IP: ip, Device: q.Device,
+			Target: b.Target, Period: q.Period, Limit: limit, Used: n,
+		})
+	}
+	for _, b := range prev {
+		if kept[b.IP+"|"+b.Period] {
+			continue
+		}
+		events = append(events, stats.QuotaEvent{
+			At: now, Kind: stats.QuotaReset, IP: b.IP, Device: b.Device,
+			Period: b.Period, Limit: b.Limit,
+		})
+	}
+	return next, events
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+func TestEvaluateQuotas(t *testing.T) {
+	g := config.GatewayConfig{
+		DeviceLabels: map[string]string{"192.168.1.23": "平板"},
+		DeviceQuotas: []config.DeviceQuota{
+			{Device: "平板", Period: config.QuotaDaily, Limit: "1KB"},
+			{Device: "192.168.1.50", Period: config.QuotaMonthly, Limit: "1MB", Action: "direct"},
+		},
+	}
+	usage := map[string]int64{"192.168.1.23": 2048, "192.168.1.50": 10}
+	used := func(ip string, _ time.Time) int64 { return usage[ip] }
+	now := time.Date(2026, 10, 18, 20, 0, 0, 0, time.Local)
+
+	next, events := evaluateQuotas(g, nil, now, used)
+	if len(next) != 1 || next[0].IP != "192.168.1.23" || next[0].Target != "reject" {
+		t.Fatalf("blocks = %+v", next)
+	}
+	if !next[0].Until.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("until = %v", next[0].Until)
+	}
+	if len(events) != 1 || events[0].Kind != stats.QuotaExceeded {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// 再算一次：还封着，不重复记事件，封禁时间不变。
+	again, events := evaluateQuotas(g, next, now.Add(time.Minute), used)
+	if len(again) != 1 || len(events) != 0 || !again[0].At.Equal(now) {
+		t.Fatalf("repeat: blocks=%+v events=%+v", again, events)
+	}
+
+	// 过了 0 点：新周期用量清零，解封。
+	usage["192.168.1.23"] = 0
+	after, events := evaluateQuotas(g, next, now.Add(5*time.Hour), used)
+	if len(after) != 0 || len(events) != 1 || events[0].Kind != stats.QuotaReset {
+		t.Fatalf("reset: blocks=%+v events=%+v", after, events)
+	}
+
+	// 额度被删了：同样解封。
+	g.DeviceQuotas = g.DeviceQuotas[1:]
+	usage["192.168.1.23"] = 4096
+	after, events = evaluateQuotas(g, next, now.Add(time.Minute), used)
+	if len(after) != 0 || len(events) != 1 || events[0].Kind != stats.QuotaReset {
+		t.Fatalf("removed: blocks=%+v events=%+v", after, events)
+	}
+}
+
+func TestQuotaModeWarning(t *testing.T) {
+	a := newProfileTestApp(t)
+	a.Cfg.Traffic.Mode = config.ModeGlobal
+	if w := a.QuotaModeWarning(); w != "" {
+		t.Fatalf("no quotas configured, got %q", w)
+	}
+	a.Cfg.Gateway.DeviceQuotas = []config.DeviceQuota{{Device: "192.168.1.23", Period: config.QuotaDaily, Limit: "1GB"}}
+	if w := a.QuotaModeWarning(); w == "" {
+		t.Fatal("global mode with quotas should warn")
+	}
+	a.Cfg.Traffic.Mode = config.ModeRule
+	if w := a.QuotaModeWarning(); w != "" {
+		t.Fatalf("rule mode enforces quotas, got %q", w)
+	}
+}
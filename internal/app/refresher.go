@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/source"
+)
+
+// refresherTick 是后台刷新的检查粒度；每份订阅真正多久拉一次看它自己的 refresh_interval。
+const (
+	refresherTick    = time.Minute
+	refresherTimeout = 30 * time.Second
+)
+
+// StartSubscriptionRefresher 启一个后台 goroutine，按各订阅的 refresh_interval
+// 定时重拉（条件请求），节点集合变了才热重载 mihomo。没配 refresh_interval 的订阅
+// 不受影响。重复调用是安全的。
+func (a *App) StartSubscriptionRefresher(ctx context.Context) {
+	a.refresherOnce.Do(func() {
+		go a.refresherLoop(ctx)
+	})
+}
+
+func (a *App) refresherLoop(ctx context.Context) {
+	// key = 订阅名 + URL：用户改了链接就当新订阅，从下一轮重新计时。
+	last := map[string]time.Time{}
+	t := time.NewTicker(refresherTick)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if a.Engine == nil || !a.Engine.Running() {
+				continue
+			}
+			proxyURL := source.LocalMixedProxyURL(a.Cfg.Runtime.Ports.Mixed)
+			// 刷新失败不处理：缓存没动、mihomo 也没重载，正在跑的配置不受影响；
+			// 源真挂了由 supervisor 负责告警 / 切直连。
+			changed, _ := a.refreshDueSubscriptions(ctx, now, last, proxyURL)
+			if changed {
+				_ = a.reloadIfRunning(ctx)
+			}
+		}
+	}
+}
+
+// refreshDueSubscriptions 刷新所有到点的订阅，返回是否有任一份节点变了。
+// 每份订阅第一次出现时只记时间不拉 —— Start / Reload 刚拉过，没必要马上再拉。
+func (a *App) refreshDueSubscriptions(ctx context.Context, now time.Time, last map[string]time.Time, proxyURL string) (changed bool, errs []error) {
+	for _, s := range source.RefreshableSubscriptions(a.Cfg.Source) {
+		key := s.Name + "\x00" + s.URL
+		prev, seen := last[key]
+		if !seen {
+			last[key] = now
+			continue
+		}
+		if now.Sub(prev) < s.RefreshEvery() {
+			continue
+		}
+		last[key] = now
+		fetchCtx, cancel := context.WithTimeout(ctx, refresherTimeout)
+		c, err := source.RefreshSubscription(fetchCtx, a.Cfg.Source, a.Paths.MihomoDir, s.Name, proxyURL)
+		cancel()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed = changed || c
+	}
+	return changed, errs
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestRefreshDueSubscriptionsHonoursInterval(t *testing.T) {
+	body := "proxies:\n  - {name: HK, type: http, server: 1.1.1.1, port: 80}\n"
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	a := newProfileTestApp(t)
+	a.Paths.MihomoDir = t.TempDir()
+	a.Cfg.Source.Type = config.SourceTypeSubscription
+	a.Cfg.Source.Subscription = config.SubscriptionSource{Name: "airport", URL: srv.URL, RefreshInterval: "1h"}
+
+	last := map[string]time.Time{}
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+
+	// 第一轮只记时间，不拉
+	if changed, errs := a.refreshDueSubscriptions(context.Background(), t0, last, ""); changed || len(errs) != 0 || hits != 0 {
+		t.Fatalf("first tick should only arm the timer: changed=%v errs=%v hits=%d", changed, errs, hits)
+	}
+	// 没到点
+	if _, _ = a.refreshDueSubscriptions(context.Background(), t0.Add(30*time.Minute), last, ""); hits != 0 {
+		t.Fatalf("refresh before interval: hits=%d", hits)
+	}
+	// 到点：缓存里原来没东西，节点算「变了」
+	changed, errs := a.refreshDueSubscriptions(context.Background(), t0.Add(time.Hour), last, "")
+	if hits != 1 || !changed || len(errs) != 0 {
+		t.Fatalf("due refresh: hits=%d changed=%v errs=%v", hits, changed, errs)
+	}
+	// 再过一小时，内容一样 → 拉了但不变
+	changed, _ = a.refreshDueSubscriptions(context.Background(), t0.Add(2*time.Hour), last, "")
+	if hits != 2 || changed {
+		t.Fatalf("unchanged refresh: hits=%d changed=%v", hits, changed)
+	}
+}
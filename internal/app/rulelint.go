@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
+)
+
+// PolicyTargets 返回自定义规则可以指向的策略名：内置的 DIRECT / REJECT / Proxy 等，
+// 加上 mihomo 里实际存在的策略组。mihomo 在跑就问 API；没跑就读上次渲染的
+// config.yaml。两边都拿不到时返回 nil —— 不知道有哪些组，调用方应跳过目标检查。
+func (a *App) PolicyTargets(ctx context.Context) []string {
+	var groups []string
+	if a.Engine != nil && a.Engine.Running() {
+		listCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		list, err := a.Engine.API().ListProxyGroups(listCtx)
+		cancel()
+		if err == nil {
+			for _, g := range list {
+				groups = append(groups, g.Name)
+			}
+			return append(append([]string{}, traffic.BuiltinTargets...), groups...)
+		}
+	}
+	if a.Engine == nil {
+		return nil
+	}
+	data, err := os.ReadFile(a.Engine.ConfigPath())
+	if err != nil {
+		return nil
+	}
+	var doc struct {
+		ProxyGroups []struct {
+			Name string `yaml:"name"`
+		} `yaml:"proxy-groups"`
+	}
+	if yaml.Unmarshal(data, &doc) != nil {
+		return nil
+	}
+	for _, g := range doc.ProxyGroups {
+		groups = append(groups, g.Name)
+	}
+	return append(append([]string{}, traffic.BuiltinTargets...), groups...)
+}
+
+// LintRules 检查全部自定义规则：语法错误、重复、被前面规则挡住、指向不存在的策略组。
+func (a *App) LintRules(ctx context.Context) []traffic.Issue {
+	return traffic.Lint(a.Cfg.Traffic.Extras, a.PolicyTargets(ctx))
+}
+
+// RuleIssues 只挑出和某条规则相关的问题（加规则后提示用）。
+func RuleIssues(issues []traffic.Issue, rule string) []traffic.Issue {
+	var out []traffic.Issue
+	for _, is := range issues {
+		if is.Rule == rule {
+			out = append(out, is)
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/geoip"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
+)
+
+// ruleTestResolveTimeout 是 rule test 里解析域名的超时：只为了判断 IP / GEOIP 规则，
+// 解析不出来就当没有 IP，不值得卡住命令行。
+const ruleTestResolveTimeout = 3 * time.Second
+
+// RuleTestResult 是 `gateway rule test` / 自定义规则页「测一下」的结果。
+type RuleTestResult struct {
+	Host    string `json:"host"`
+	Src     string `json:"src,omitempty"`
+	Matched bool   `json:"matched"`
+	traffic.Explanation
+	// Note 是判断之外的提醒：global 模式不看规则、订阅自带规则接管了 MATCH 等。
+	Note string `json:"note,omitempty"`
+}
+
+// TestRule 按当前配置渲染出的规则列表，解释 host（域名或 IP）会命中哪一条。
+// src 是发起连接的 LAN 设备 IP，用来判断设备策略（SRC-IP-CIDR，含超额封禁和按
+// MAC 配的设备），可空。
+// GEOIP 查 mihomo 工作目录里的 country.mmdb；没有这个文件时只认 GEOIP,LAN。
+func (a *App) TestRule(ctx context.Context, host, src string) RuleTestResult {
+	policies, _ := engine.DevicePolicies(a.Cfg, a.Paths.MihomoDir, time.Now())
+	rules := traffic.Rules(a.Cfg.Traffic, policies)
+	q := traffic.Query{
+		Host:  host,
+		SrcIP: src,
+		Resolve: func(h string) []net.IP {
+			rctx, cancel := context.WithTimeout(ctx, ruleTestResolveTimeout)
+			defer cancel()
+			ips, _ := net.DefaultResolver.LookupIP(rctx, "ip", h)
+			return ips
+		},
+	}
+	if db, err := geoip.Open(filepath.Join(a.Paths.MihomoDir, "country.mmdb")); err == nil {
+		defer db.Close()
+		q.Country = func(ip net.IP) string {
+			code, _ := db.Lookup(ip)
+			return code
+		}
+	}
+	ex, ok := traffic.Explain(rules, q)
+	res := RuleTestResult{Host: host, Src: src, Matched: ok, Explanation: ex}
+	switch {
+	case a.Cfg.Traffic.Mode == config.ModeGlobal:
+		res.Note = "当前是 global 模式：mihomo 不看规则，所有连接都走 Proxy；上面是切回 rule 模式后的结果"
+	case !ok:
+		res.Note = "没有命中任何规则，按 " + a.Cfg.Traffic.Mode + " 模式的默认出口走"
+	case ex.Rule.Source == "match" && sourceHasOwnRules(a.Cfg.Source.Type):
+		res.Note = "代理源（订阅 / 配置文件）自带的规则会替换这条 MATCH，实际去向以源里的规则为准"
+	}
+	return res
+}
+
+// sourceHasOwnRules 报告这类源渲染时可能带上自己的 rules（接在我们的规则后面、替换 MATCH）。
+func sourceHasOwnRules(t string) bool {
+	switch t {
+	case config.SourceTypeSubscription, config.SourceTypeMulti, config.SourceTypeFile:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// rule test 要和渲染看同一份设备策略：超额封禁的设备也得判成 REJECT。
+func TestRuleTestSeesQuotaBlocks(t *testing.T) {
+	a := newProfileTestApp(t)
+	a.Paths.MihomoDir = t.TempDir()
+	if err := stats.SaveQuotaBlocks(a.Paths.MihomoDir, []stats.QuotaBlock{
+		{IP: "192.168.1.23", Target: "reject", Until: time.Now().Add(time.Hour)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res := a.TestRule(context.Background(), "1.1.1.1", "192.168.1.23")
+	if !res.Matched || res.Rule.Source != "device" || res.Verdict != "REJECT" {
+		t.Fatalf("quota-blocked device: got %s → %s (matched=%v)", res.Rule.Source, res.Verdict, res.Matched)
+	}
+	if res := a.TestRule(context.Background(), "1.1.1.1", "192.168.1.50"); res.Rule.Source == "device" {
+		t.Fatalf("other devices should not hit the quota block: %+v", res.Rule)
+	}
+}
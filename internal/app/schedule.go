@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// scheduleMaxWait 是定时策略循环两次检查之间最长等多久：下一个窗口边界在几小时后
+// 也不死等，这样用户在菜单里新加 / 改了定时策略、或者系统时钟被校准，最多一分钟就能跟上。
+const scheduleMaxWait = time.Minute
+
+// scheduleStatusLimit 是 `gateway status` 里最多列出的即将切换次数。
+const scheduleStatusLimit = 5
+
+// StartDeviceScheduler 启一个后台 goroutine，在设备定时策略的窗口边界重新渲染 +
+// 热重载 mihomo（SRC-IP-CIDR 规则是渲染时按当前时间算的）。没配定时策略时
+// 只是空转。重复调用是安全的。
+func (a *App) StartDeviceScheduler(ctx context.Context) {
+	a.schedulerOnce.Do(func() {
+		go a.schedulerLoop(ctx)
+	})
+}
+
+func (a *App) schedulerLoop(ctx context.Context) {
+	last := time.Now()
+	for {
+		wait := scheduleMaxWait
+		if next := a.Cfg.Gateway.UpcomingTransitions(last, 1); len(next) > 0 {
+			if d := time.Until(next[0].At); d < wait {
+				wait = d
+			}
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			if scheduleChanged(a.Cfg.Gateway, last, now) {
+				_ = a.reloadIfRunning(ctx)
+			}
+			last = now
+		}
+	}
+}
+
+// scheduleChanged 报告 prev → now 之间生效的设备策略有没有变。两头都用「当前」
+// 配置算：用户改配置那一刻已经走过 saveAndReload，这里只管时间推进带来的切换，
+// 不会因为改配置再多重载一次。
+func scheduleChanged(g config.GatewayConfig, prev, now time.Time) bool {
+	return policiesKey(g.ActiveDevicePolicies(prev)) != policiesKey(g.ActiveDevicePolicies(now))
+}
+
+func policiesKey(list []config.DevicePolicy) string {
+	var b strings.Builder
+	for _, p := range list {
+		b.WriteString(p.IP + "|" + p.MAC + "|" + p.Target + "\n")
+	}
+	return b.String()
+}
+
+// UpcomingScheduleTransitions 返回接下来几次定时策略切换，给 status 展示。
+func (a *App) UpcomingScheduleTransitions(now time.Time) []config.ScheduleTransition {
+	return a.Cfg.Gateway.UpcomingTransitions(now, scheduleStatusLimit)
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestScheduleChangedOnlyAtBoundaries(t *testing.T) {
+	g := config.GatewayConfig{
+		DeviceSchedules: []config.DeviceSchedule{
+			{DevicePolicy: config.DevicePolicy{IP: "192.168.1.23", Target: "reject"}, From: "22:00", To: "07:00"},
+		},
+	}
+	at := func(hh, mm int) time.Time { return time.Date(2026, 10, 18, hh, mm, 0, 0, time.Local) }
+	if scheduleChanged(g, at(20, 0), at(21, 59)) {
+		t.Fatal("no boundary crossed, should not reload")
+	}
+	if !scheduleChanged(g, at(21, 59), at(22, 0)) {
+		t.Fatal("window start should trigger reload")
+	}
+	if scheduleChanged(g, at(22, 0), at(23, 0)) {
+		t.Fatal("inside the window, should not reload")
+	}
+	// 跨了一整个窗口（比如机器睡眠）回到同样的状态：不必重载
+	if scheduleChanged(g, at(21, 0), time.Date(2026, 10, 19, 8, 0, 0, 0, time.Local)) {
+		t.Fatal("same active set on both ends should not reload")
+	}
+}
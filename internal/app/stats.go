@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// statsInterval 是流量采样间隔。连接在两次采样之间关闭时最后一段会漏记，
+// 间隔越短越准；10 秒一次 /connections 对 mihomo 没什么压力。
+const statsInterval = 10 * time.Second
+
+// Stats 返回设备流量账本（Root/stats 下按天一个文件）。
+func (a *App) Stats() *stats.Store {
+	a.statsOnce.Do(func() {
+		a.stats = stats.Open(filepath.Join(a.Paths.Root, "stats"))
+	})
+	return a.stats
+}
+
+// StartTrafficCollector 启一个后台 goroutine，定时采样 mihomo /connections，把每台
+// LAN 设备的上下行增量记进账本。mihomo 没跑时跳过这一轮。重复调用是安全的。
+func (a *App) StartTrafficCollector(ctx context.Context) {
+	a.collectorOnce.Do(func() {
+		go a.collectorLoop(ctx)
+	})
+}
+
+func (a *App) collectorLoop(ctx context.Context) {
+	store := a.Stats()
+	_ = store.Prune(time.Now())
+	lastPrune := time.Now()
+
+	// 先查一次额度：上次退出时还封着、现在周期已经过了的设备马上解封。
+	a.checkQuotas(ctx, time.Now())
+
+	t := time.NewTicker(statsInterval)
+	defer t.Stop()
+	for tick := 1; ; tick++ {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			a.sampleTraffic(ctx, now)
+			if tick%quotaEvery == 0 {
+				a.checkQuotas(ctx, now)
+			}
+			if now.Sub(lastPrune) > 24*time.Hour {
+				_ = store.Prune(now)
+				lastPrune = now
+			}
+		}
+	}
+}
+
+// sampleTraffic 拉一次 /connections 记账。拉不到就跳过这一轮。
+func (a *App) sampleTraffic(ctx context.Context, now time.Time) {
+	if a.Engine == nil || !a.Engine.Running() {
+		return
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, supervisorTimeout)
+	defer cancel()
+	snap, err := a.Engine.API().GetConnections(fetchCtx)
+	if err != nil {
+		return
+	}
+	samples := make([]stats.Sample, 0, len(snap.Connections))
+	for _, c := range snap.Connections {
+		s := stats.Sample{
+			ID:       c.ID,
+			SourceIP: c.Metadata.SourceIP,
+			Upload:   c.Upload,
+			Download: c.Download,
+		}
+		// chains 从出口节点往回排，最后一项是规则命中的策略组。
+		if n := len(c.Chains); n > 0 {
+			s.Group = c.Chains[n-1]
+		}
+		samples = append(samples, s)
+	}
+	_, _ = a.Stats().Record(samples, now)
+	a.trafficCounters().Observe(samples)
+	a.notifyNewDevices(samples, now)
+}
+
+// DeviceUsage 返回 [since, until) 的各设备用量，带上 gateway.device_labels 里的名字。
+func (a *App) DeviceUsage(since, until time.Time) ([]stats.DeviceUsage, error) {
+	list, err := a.Stats().Usage(since, until)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Name = a.Cfg.Gateway.DeviceLabels[list[i].IP]
+	}
+	return list, nil
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/source"
+)
+
+// SubscriptionInfo 返回最近一次拉订阅时记下的流量 / 到期信息（读 mihomo 工作目录里
+// 的 subscription-info.json，不发网络请求）。当前源不是订阅类时返回 nil ——
+// 切到本机代理后不该继续显示上一家机场的余量。
+func (a *App) SubscriptionInfo() []source.SubscriptionInfo {
+	switch a.Cfg.Source.Type {
+	case config.SourceTypeSubscription, config.SourceTypeMulti:
+	default:
+		return nil
+	}
+	infos, err := source.LoadSubscriptionInfo(a.Paths.MihomoDir)
+	if err != nil {
+		return nil
+	}
+	return infos
+}
+
+// QuotaWarnings 汇总所有订阅的流量 / 到期告警：已用 ≥ 90% 或 ExpiryWarnDays 天内到期。
+func (a *App) QuotaWarnings(now time.Time) []string {
+	var out []string
+	for _, info := range a.SubscriptionInfo() {
+		out = append(out, info.Warnings(now, a.Cfg.Source.ExpiryWarnDays)...)
+	}
+	return out
+}
@@ -19,17 +19,37 @@ type SourceHealth struct {
 	OriginalMode   string
 	CheckedAt      time.Time
 	FailCount      int
+	// QuotaWarnings 是订阅流量 / 到期告警（已用 ≥ 90% 或快到期）。和源通不通无关，
+	// 每次检查都按 subscription-info.json 重新算。
+	QuotaWarnings []string
+	// ActiveSource 是正在顶替主源的备用档案名（source.failover），用主源时为空。
+	// 此时 Healthy / LastError 说的仍是主源。
+	ActiveSource string
+	// Transitions 是最近几次源切换（主源 ↔ 备用 ↔ direct），旧的在前。
+	Transitions []SourceTransition
 }
 
 type healthState struct {
-	mu sync.RWMutex
-	h  SourceHealth
+	mu          sync.RWMutex
+	h           SourceHealth
+	transitions []SourceTransition // 单独存：各分支整体 set 时不会把历史冲掉
 }
 
 func (s *healthState) snapshot() SourceHealth {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.h
+	h := s.h
+	h.Transitions = append([]SourceTransition(nil), s.transitions...)
+	return h
+}
+
+func (s *healthState) addTransition(t SourceTransition) {
+	s.mu.Lock()
+	s.transitions = append(s.transitions, t)
+	if len(s.transitions) > maxTransitions {
+		s.transitions = s.transitions[len(s.transitions)-maxTransitions:]
+	}
+	s.mu.Unlock()
 }
 
 func (s *healthState) set(h SourceHealth) {
@@ -38,6 +58,12 @@ func (s *healthState) set(h SourceHealth) {
 	s.mu.Unlock()
 }
 
+func (s *healthState) setQuotaWarnings(w []string) {
+	s.mu.Lock()
+	s.h.QuotaWarnings = w
+	s.mu.Unlock()
+}
+
 // Health 返回当前代理源健康状态快照，UI 层用于显示告警。
 func (a *App) Health() SourceHealth {
 	if a.health == nil {
@@ -47,8 +73,10 @@ func (a *App) Health() SourceHealth {
 }
 
 // StartSupervisor 启一个后台 goroutine，周期性检查代理源。
-// 普通订阅/文件源异常时自动切到 direct；本机单点代理只告警，不自动改 mode，
+// 配了 source.failover 时先切备用源；普通订阅/文件源异常时自动切到 direct；本机单点代理只告警，不自动改 mode，
 // 避免健康探测波动反过来干扰用户正在测试的本机代理链路。
+// 同时带起 mihomo 崩溃看门狗和自动选节点（traffic.auto_pilot），后者没开时每轮
+// 只看一眼配置。
 // 重复调用是安全的（第二次会 no-op，通过 supervisorStarted 标记）。
 func (a *App) StartSupervisor(ctx context.Context) {
 	if a.health == nil {
@@ -56,6 +84,8 @@ func (a *App) StartSupervisor(ctx context.Context) {
 	}
 	a.supervisorOnce.Do(func() {
 		go a.supervisorLoop(ctx)
+		go a.watchdogLoop(ctx)
+		go a.autopilotLoop(ctx)
 	})
 }
 
@@ -86,8 +116,16 @@ func (a *App) supervisorLoop(ctx context.Context) {
 // 注意：fallback 不修改 a.Cfg.Traffic.Mode（用户视角 mode 没变），只是运行时
 // 临时覆盖，这样恢复时能无损还原。
 func (a *App) checkSourceHealth(ctx context.Context) {
+	// 下面各分支都是整体 set 一个新 SourceHealth，流量告警和事件通知放到最后统一做。
+	before := a.health.snapshot()
+	defer func() {
+		now := time.Now()
+		a.health.setQuotaWarnings(a.QuotaWarnings(now))
+		a.notifyHealth(before, a.health.snapshot())
+		a.notifyExpiry(now)
+	}()
 	if a.Engine == nil || !a.Engine.Running() {
-		// mihomo 没跑，无从判断也无从 fallback，状态置空。
+		// mihomo 没跑（崩了的话 watchdog 在拉），无从判断也无从 fallback，状态置空。
 		a.health.set(SourceHealth{})
 		return
 	}
@@ -107,6 +145,10 @@ func (a *App) checkSourceHealth(ctx context.Context) {
 	prev := a.health.snapshot()
 	now := time.Now()
 
+	if len(a.Cfg.Source.Failover) > 0 {
+		a.checkFailover(ctx, err, prev, now)
+		return
+	}
 	if err != nil {
 		errMsg := err.Error()
 		failCount := prev.FailCount + 1
@@ -138,6 +180,7 @@ func (a *App) checkSourceHealth(ctx context.Context) {
 			defer cancelAPI()
 			originalMode := a.Cfg.Traffic.Mode
 			if switchErr := a.Engine.API().SetMode(apiCtx, config.ModeDirect); switchErr == nil {
+				a.health.addTransition(SourceTransition{At: now, From: a.primaryLabel(), To: directTarget, Reason: errMsg})
 				a.health.set(SourceHealth{
 					Healthy:        false,
 					LastError:      errMsg,
@@ -162,13 +205,8 @@ func (a *App) checkSourceHealth(ctx context.Context) {
 
 	// 源健康：如果之前 fallback 过，切回原 mode。
 	if prev.FallbackActive {
-		apiCtx, cancelAPI := context.WithTimeout(ctx, supervisorTimeout)
-		defer cancelAPI()
-		target := prev.OriginalMode
-		if target == "" {
-			target = a.Cfg.Traffic.Mode
-		}
-		_ = a.Engine.API().SetMode(apiCtx, target)
+		a.restoreMode(ctx, prev.OriginalMode)
+		a.health.addTransition(SourceTransition{At: now, From: directTarget, To: a.primaryLabel(), Reason: "主源恢复"})
 	}
 	a.health.set(SourceHealth{
 		Healthy:   true,
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// mihomo 崩溃看门狗：OOM / panic 之后进程没了，以前没人发现，仪表盘只显示
+// 「未启动」，LAN 设备断网直到有人登上来。watchdog 跟 supervisor 一起跑，
+// 发现 mihomo 不是 Stop 停的（Engine.Crashed）就按指数退避重启；短时间内崩得
+// 太多，多半是新配置有问题，改用 last-known-good 的 config.yaml 启动。
+//
+// 只有本进程之前亲眼见过 mihomo 在跑，才把「pidfile 在、进程不在」算成崩溃：
+// 断电重启后留下的旧 pidfile 也长这样，打开菜单就把 mihomo 拉起来（网关设置都
+// 没做）还记一次崩溃，是误报。
+
+const (
+	watchdogInterval = 3 * time.Second
+	// watchdogWindow 内崩 watchdogMaxCrashes 次（重启失败也算）就退回 last-known-good。
+	watchdogWindow     = 10 * time.Minute
+	watchdogMaxCrashes = 3
+	watchdogBackoffMin = 2 * time.Second
+	watchdogBackoffMax = 2 * time.Minute
+	// watchdogStable 是一份配置连续跑多久才记成 last-known-good。
+	watchdogStable = 3 * time.Minute
+	// crashTailLines 是每次崩溃存下来的日志行数。
+	crashTailLines = 30
+)
+
+// watchdog 记着窗口内的崩溃时间，决定下次重启等多久、用哪份配置。
+type watchdog struct {
+	mu      sync.Mutex
+	crashes []time.Time
+	stopped bool // App.Stop 主动停的，别再拉起来
+	seen    bool // 上一轮看到 mihomo 在跑
+}
+
+// observe 记下这一轮 mihomo 的状态，返回要不要按崩溃处理：只有上一轮还见它在跑、
+// 这一轮 pidfile 在而进程不在才算。没在跑又不算崩溃（被停了 / 从没起过）时清掉
+// seen，之后别的进程再起来、再崩，要重新见过一次才管。
+func (w *watchdog) observe(running, crashed bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if running {
+		w.seen = true
+		return false
+	}
+	crash := w.seen && crashed
+	w.seen = false
+	return crash
+}
+
+// crashed 记一次崩溃（或一次失败的重启），返回重启前要等多久、要不要退回
+// last-known-good。退避按窗口内的次数翻倍：2s、4s、8s……封顶 2 分钟；
+// 稳定跑过一个窗口后自然归零。
+func (w *watchdog) crashed(now time.Time) (delay time.Duration, lastGood bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keep := w.crashes[:0]
+	for _, t := range w.crashes {
+		if now.Sub(t) < watchdogWindow {
+			keep = append(keep, t)
+		}
+	}
+	w.crashes = append(keep, now)
+	n := len(w.crashes)
+	delay = watchdogBackoffMax
+	if n <= 16 {
+		delay = min(watchdogBackoffMin<<(n-1), watchdogBackoffMax)
+	}
+	return delay, n >= watchdogMaxCrashes
+}
+
+func (w *watchdog) setStopped(v bool) {
+	w.mu.Lock()
+	w.stopped = v
+	w.mu.Unlock()
+}
+
+func (w *watchdog) isStopped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopped
+}
+
+// watchdogLoop 每隔几秒看一眼 mihomo：崩了就拉起来，稳定跑着就顺手更新
+// last-known-good。
+func (a *App) watchdogLoop(ctx context.Context) {
+	t := time.NewTicker(watchdogInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if a.Engine == nil || a.dog.isStopped() {
+				continue
+			}
+			running := a.Engine.Running()
+			if a.dog.observe(running, !running && a.Engine.Crashed()) {
+				a.recoverEngine(ctx)
+				continue
+			}
+			if running {
+				_, _ = a.Engine.MarkGood(watchdogStable, now)
+			}
+		}
+	}
+}
+
+// recoverEngine 存下崩溃现场，按退避重启直到成功、被 Stop（本进程或别的进程）、
+// 或者别的进程（菜单 / 系统服务）已经先拉起来了。每次崩溃记一条 engine-crashes.jsonl。
+func (a *App) recoverEngine(ctx context.Context) {
+	rec := stats.EngineCrash{At: time.Now(), LogTail: engine.TailLog(a.Engine.LogPath(), crashTailLines)}
+	// 菜单和系统服务可能同时在跑 watchdog：别的进程先拉起来的话由它记，这里不重复。
+	elsewhere := false
+	defer func() {
+		if !elsewhere {
+			_ = a.Stats().AppendEngineCrash(rec)
+		}
+	}()
+
+	for {
+		delay, lastGood := a.dog.crashed(time.Now())
+		lastGood = lastGood && a.Engine.HasGoodConfig()
+		if rec.Attempts == 0 {
+			a.notifyEngineCrash(rec.LogTail, delay, lastGood)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if a.dog.isStopped() {
+			return
+		}
+		if a.Engine.Running() {
+			elsewhere = rec.Attempts == 0
+			return
+		}
+		// 退避期间别的进程 gateway stop 了：不拉。第一次重启之前 pidfile 没了也是
+		// 有人动过（停了，或者起了又停了）；之后 pidfile 是我们自己的失败重启删的，
+		// 只能看 Stop 留下的标记。
+		if a.Engine.Stopped() || (rec.Attempts == 0 && !a.Engine.Crashed()) {
+			return
+		}
+		rec.Attempts++
+		rec.LastGood = lastGood
+		startCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		var err error
+		if lastGood {
+			err = a.Engine.StartLastGood(startCtx, a.runtimeConfig())
+		} else {
+			err = a.Engine.Start(startCtx, a.runtimeConfig())
+		}
+		cancel()
+		if err == nil {
+			rec.Restarted, rec.Error = true, ""
+			return
+		}
+		rec.Error = err.Error()
+	}
+}
+
+// EngineCrashes 返回 since 以来 mihomo 的意外退出，给 status / 菜单提示用。
+func (a *App) EngineCrashes(since time.Time) []stats.EngineCrash {
+	all, _ := a.Stats().EngineCrashes(0)
+	var out []stats.EngineCrash
+	for _, c := range all {
+		if !c.At.Before(since) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func restartPlan(delay time.Duration, lastGood bool) string {
+	s := fmt.Sprintf("%s 后自动重启", delay)
+	if lastGood {
+		s += "（短时间内反复崩溃，改用上次稳定运行的配置）"
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+func TestWatchdogBackoffAndLastGood(t *testing.T) {
+	var w watchdog
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	want := []struct {
+		delay    time.Duration
+		lastGood bool
+	}{
+		{2 * time.Second, false},
+		{4 * time.Second, false},
+		{8 * time.Second, true}, // 窗口内第 3 次：退回 last-known-good
+		{16 * time.Second, true},
+	}
+	for i, tc := range want {
+		delay, lastGood := w.crashed(t0.Add(time.Duration(i) * time.Minute))
+		if delay != tc.delay || lastGood != tc.lastGood {
+			t.Fatalf("crash #%d: delay=%s lastGood=%v, want %s/%v", i+1, delay, lastGood, tc.delay, tc.lastGood)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		w.crashed(t0.Add(5 * time.Minute))
+	}
+	if delay, _ := w.crashed(t0.Add(5 * time.Minute)); delay != watchdogBackoffMax {
+		t.Fatalf("backoff should cap at %s, got %s", watchdogBackoffMax, delay)
+	}
+
+	// 稳定跑过一个窗口之后，重新从 2s、正常配置开始。
+	delay, lastGood := w.crashed(t0.Add(5*time.Minute + watchdogWindow))
+	if delay != watchdogBackoffMin || lastGood {
+		t.Fatalf("after a quiet window: delay=%s lastGood=%v", delay, lastGood)
+	}
+}
+
+func TestEngineCrashesSince(t *testing.T) {
+	a := newProfileTestApp(t)
+	a.Paths.Root = t.TempDir()
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	for i, c := range []stats.EngineCrash{
+		{At: t0.Add(-30 * time.Hour), Attempts: 1, Restarted: true},
+		{At: t0.Add(-2 * time.Hour), Attempts: 3, Restarted: true, LastGood: true, LogTail: "panic: runtime error"},
+	} {
+		if err := a.Stats().AppendEngineCrash(c); err != nil {
+			t.Fatalf("append #%d: %v", i, err)
+		}
+	}
+	got := a.EngineCrashes(t0.Add(-24 * time.Hour))
+	if len(got) != 1 || !got[0].LastGood || got[0].LogTail != "panic: runtime error" {
+		t.Fatalf("crashes in last 24h = %+v", got)
+	}
+}
+
+func TestWatchdogObserveNeedsSeenRunning(t *testing.T) {
+	var w watchdog
+	// 断电留下的旧 pidfile：本进程从没见过它在跑，不算崩溃。
+	if w.observe(false, true) {
+		t.Fatal("stale pidfile at startup must not count as a crash")
+	}
+	if w.observe(true, false) || !w.observe(false, true) {
+		t.Fatal("running → pidfile left behind should count as a crash")
+	}
+	// 别的进程 gateway stop：pidfile 先删，这一轮是「没在跑、也没崩」。之后再出现
+	// 旧 pidfile（比如又被别人起了再断电）也不该算到本进程头上。
+	w.observe(true, false)
+	if w.observe(false, false) || w.observe(false, true) {
+		t.Fatal("stop from another process must not count as a crash")
+	}
+}
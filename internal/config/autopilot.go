@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AutoPilotConfig 是「自动选最优节点」。mihomo 自带的 url-test 只看单次测速、
+// 谁快切谁，节点延迟一抖就来回切，视频会议会断；这里由 supervisor 定时测一个
+// Selector 组，按每个节点最近几轮的延迟和丢包决定，而且要当前节点连续几轮都
+// 变差、候选节点同样几轮都稳定地更好才切（滞回）。例：
+//
+//	traffic:
+//	  auto_pilot:
+//	    enabled: true
+//	    group: Proxy            # 必须是 select 类型的组
+//	    interval: 2m
+//	    degrade_rounds: 3       # 当前节点连续 3 轮变差才考虑切
+//	    max_delay_ms: 600       # 超过这个延迟算变差（测不通一定算）
+//	    min_gain_ms: 100        # 候选要比当前快这么多才算「更好」
+type AutoPilotConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Group    string `yaml:"group,omitempty"`    // 默认 Proxy
+	Interval string `yaml:"interval,omitempty"` // 默认 2m，最短 30s
+	URL      string `yaml:"url,omitempty"`      // 测速地址，默认 gstatic generate_204
+	// TimeoutMs 是单节点测速超时，默认 3000。
+	TimeoutMs int `yaml:"timeout_ms,omitempty"`
+	// Window 是每个节点保留最近多少轮结果，用来算中位延迟和丢包率，默认 10。
+	Window        int `yaml:"window,omitempty"`
+	DegradeRounds int `yaml:"degrade_rounds,omitempty"` // 默认 3
+	MaxDelayMs    int `yaml:"max_delay_ms,omitempty"`   // 默认 600
+	MinGainMs     int `yaml:"min_gain_ms,omitempty"`    // 默认 100
+}
+
+// 自动选节点默认值。
+const (
+	DefaultAutoPilotInterval = 2 * time.Minute
+	DefaultAutoPilotURL      = "http://www.gstatic.com/generate_204"
+	minAutoPilotInterval     = 30 * time.Second
+)
+
+// TargetGroup 返回要管的 Selector 组名，默认 Proxy。
+func (p AutoPilotConfig) TargetGroup() string {
+	if p.Group == "" {
+		return DefaultDelayGroup
+	}
+	return p.Group
+}
+
+// Every 返回测速间隔；没填或写错时用默认值（Validate 会先拦住写错的）。
+func (p AutoPilotConfig) Every() time.Duration {
+	d, err := time.ParseDuration(p.Interval)
+	if err != nil || d <= 0 {
+		return DefaultAutoPilotInterval
+	}
+	return d
+}
+
+// TestURL 返回测速地址。
+func (p AutoPilotConfig) TestURL() string {
+	if p.URL == "" {
+		return DefaultAutoPilotURL
+	}
+	return p.URL
+}
+
+// Timeout 返回单节点超时（ms）。
+func (p AutoPilotConfig) Timeout() int { return positiveOr(p.TimeoutMs, 3000) }
+
+// Rounds 返回每个节点保留的轮数。
+func (p AutoPilotConfig) Rounds() int { return positiveOr(p.Window, 10) }
+
+// Degrade 返回触发切换前当前节点要连续变差的轮数。
+func (p AutoPilotConfig) Degrade() int { return positiveOr(p.DegradeRounds, 3) }
+
+// MaxDelay 返回「变差」的延迟门槛（ms）。
+func (p AutoPilotConfig) MaxDelay() int { return positiveOr(p.MaxDelayMs, 600) }
+
+// MinGain 返回候选节点至少要快多少（ms）。
+func (p AutoPilotConfig) MinGain() int { return positiveOr(p.MinGainMs, 100) }
+
+func positiveOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func validateAutoPilot(p AutoPilotConfig) error {
+	if !p.Enabled {
+		return nil
+	}
+	if p.Interval != "" {
+		d, err := time.ParseDuration(p.Interval)
+		if err != nil {
+			return fmt.Errorf("traffic.auto_pilot.interval 写法不对（例 2m / 90s）：%q", p.Interval)
+		}
+		if d < minAutoPilotInterval {
+			return fmt.Errorf("traffic.auto_pilot.interval 最短 %s，当前: %s", minAutoPilotInterval, p.Interval)
+		}
+	}
+	if p.URL != "" && !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+		return fmt.Errorf("traffic.auto_pilot.url 必须是 http(s) 地址，当前: %q", p.URL)
+	}
+	for _, f := range []struct {
+		name string
+		v    int
+	}{
+		{"timeout_ms", p.TimeoutMs}, {"window", p.Window}, {"degrade_rounds", p.DegradeRounds},
+		{"max_delay_ms", p.MaxDelayMs}, {"min_gain_ms", p.MinGainMs},
+	} {
+		if f.v < 0 {
+			return fmt.Errorf("traffic.auto_pilot.%s 不能是负数", f.name)
+		}
+	}
+	if p.Window > 0 && p.Window < p.Degrade() {
+		return fmt.Errorf("traffic.auto_pilot.window（%d）不能小于 degrade_rounds（%d）", p.Window, p.Degrade())
+	}
+	return nil
+}
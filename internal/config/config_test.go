@@ -3,6 +3,7 @@ package config
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultIsValid(t *testing.T) {
@@ -232,3 +233,331 @@ func TestEffectiveRuntimeConfigAllowsPortOnlyModeWhenGatewayDisabled(t *testing.
 		t.Fatalf("local external proxy should not force local bypass when TUN is off")
 	}
 }
+
+func TestValidateProfiles(t *testing.T) {
+	cfg := Default()
+	cfg.Source.Profiles = []Profile{
+		{Name: "work", Type: SourceTypeSubscription, Subscription: &SubscriptionSource{URL: "https://example.com/sub"}},
+		{Name: "work", Type: SourceTypeNone},
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "重名") {
+		t.Fatalf("expected duplicate-name error, got %v", err)
+	}
+	cfg.Source.Profiles = cfg.Source.Profiles[:1]
+	cfg.Source.Current = "travel"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected error for current pointing at missing profile")
+	}
+	cfg.Source.Current = "work"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid profiles rejected: %v", err)
+	}
+}
+
+func TestValidateFailover(t *testing.T) {
+	cfg := Default()
+	cfg.Source.Profiles = []Profile{
+		{Name: "socks", Type: SourceTypeRemote, Remote: &RemoteProxy{Server: "1.2.3.4", Port: 1080}},
+		{Name: "direct", Type: SourceTypeNone},
+	}
+	cfg.Source.Failover = []string{"socks", "clash"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "failover[1]") {
+		t.Fatalf("expected missing-profile error, got %v", err)
+	}
+	cfg.Source.Failover = []string{"socks", "socks"}
+	if err := Validate(cfg); err == nil {
+		t.Fatal("duplicate failover entries must fail")
+	}
+	cfg.Source.Failover = []string{"socks", "direct"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid failover rejected: %v", err)
+	}
+}
+
+func TestProfileRoundTripThroughSource(t *testing.T) {
+	src := SourceConfig{
+		Type:   SourceTypeRemote,
+		Remote: RemoteProxy{Name: "travel", Kind: "socks5", Server: "1.2.3.4", Port: 1080},
+		File:   FileSource{Path: "/tmp/home.yaml"},
+	}
+	p := ProfileFromSource("travel", src)
+	if p.Remote == nil || p.File != nil {
+		t.Fatalf("snapshot must only carry the active slot: %+v", p)
+	}
+	got := SourceConfig{Type: SourceTypeNone, File: FileSource{Path: "/keep.yaml"}}.ApplyProfile(p)
+	if got.Type != SourceTypeRemote || got.Remote.Server != "1.2.3.4" || got.Current != "travel" {
+		t.Fatalf("apply mismatch: %+v", got)
+	}
+	if got.File.Path != "/keep.yaml" {
+		t.Fatalf("unrelated slots must survive apply, got %q", got.File.Path)
+	}
+}
+
+func TestProfileSummary(t *testing.T) {
+	for _, tc := range []struct {
+		p    Profile
+		want string
+	}{
+		{Profile{Type: SourceTypeSubscription, Subscription: &SubscriptionSource{URL: "https://sub.example.com/x"}}, "https://sub.example.com/x"},
+		{Profile{Type: SourceTypeMulti, Subscriptions: []SubscriptionSource{{Name: "airA"}, {Name: "airB"}}}, "airA + airB"},
+		{Profile{Type: SourceTypeRemote, Remote: &RemoteProxy{Server: "1.2.3.4", Port: 1080, Kind: "socks5"}}, "1.2.3.4:1080 socks5"},
+		{Profile{Type: SourceTypeFile}, ""}, // 槽位没填
+	} {
+		if got := tc.p.Summary(); got != tc.want {
+			t.Errorf("%s: Summary() = %q, want %q", tc.p.Type, got, tc.want)
+		}
+	}
+}
+
+func TestValidateMultiSource(t *testing.T) {
+	cfg := Default()
+	cfg.Source.Type = SourceTypeMulti
+	if err := Validate(cfg); err == nil {
+		t.Fatal("multi without subscriptions must fail")
+	}
+	cfg.Source.Subscriptions = []SubscriptionSource{{URL: "https://a.example/sub"}, {URL: "https://b.example/sub"}}
+	Normalize(cfg)
+	if cfg.Source.Subscriptions[1].Name != "sub2" {
+		t.Fatalf("unnamed subscription should default to sub2, got %q", cfg.Source.Subscriptions[1].Name)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid multi rejected: %v", err)
+	}
+	cfg.Source.Subscriptions[1].Name = "sub1"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("duplicate subscription names must fail")
+	}
+}
+
+func TestValidateRefreshInterval(t *testing.T) {
+	cfg := Default()
+	cfg.Source.Type = SourceTypeSubscription
+	cfg.Source.Subscription = SubscriptionSource{URL: "https://example.com/sub", RefreshInterval: "6h"}
+	Normalize(cfg)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("6h should be valid: %v", err)
+	}
+	if got := cfg.Source.Subscription.RefreshEvery(); got != 6*time.Hour {
+		t.Fatalf("RefreshEvery = %v", got)
+	}
+	for _, bad := range []string{"soon", "1m"} {
+		cfg.Source.Subscription.RefreshInterval = bad
+		if err := Validate(cfg); err == nil {
+			t.Fatalf("refresh_interval %q should be rejected", bad)
+		}
+	}
+	cfg.Source.Type = SourceTypeMulti
+	cfg.Source.Subscriptions = []SubscriptionSource{{Name: "a", URL: "https://a", RefreshInterval: "2m"}}
+	if err := Validate(cfg); err == nil {
+		t.Fatal("multi subscription refresh_interval below minimum should be rejected")
+	}
+}
+
+func TestValidateNodeFilter(t *testing.T) {
+	cfg := Default()
+	cfg.Source.Type = SourceTypeFile
+	cfg.Source.File = FileSource{Path: "/tmp/clash.yaml", Filter: NodeFilter{
+		Exclude: "剩余|官网",
+		Rename:  []RenameRule{{Pattern: `^\[(\w+)\]`, Replace: "$1 "}},
+	}}
+	Normalize(cfg)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid filter rejected: %v", err)
+	}
+	cfg.Source.File.Filter.Include = "("
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "include") {
+		t.Fatalf("bad include regex should be rejected, got %v", err)
+	}
+	cfg.Source.File.Filter.Include = ""
+	cfg.Source.File.Filter.Rename = []RenameRule{{Pattern: ""}}
+	if err := Validate(cfg); err == nil {
+		t.Fatal("empty rename pattern should be rejected")
+	}
+}
+
+func TestValidateRuleProviders(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	cfg.Traffic.RuleProviders = []RuleProvider{
+		{Name: "openai", Type: RuleProviderHTTP, Behavior: "classical", URL: "https://example.com/openai.yaml", Target: "proxy"},
+		{Name: "lan", Type: RuleProviderFile, Behavior: "ipcidr", Format: "text", Path: "/etc/lan.txt", Target: "direct"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid providers rejected: %v", err)
+	}
+	bad := []RuleProvider{
+		{Name: "x", Type: "ftp", Behavior: "domain", Target: "proxy"},
+		{Name: "x", Type: RuleProviderHTTP, Behavior: "domain", URL: "example.com", Target: "proxy"},
+		{Name: "x", Type: RuleProviderFile, Behavior: "domain", Target: "proxy"},
+		{Name: "x", Type: RuleProviderFile, Behavior: "geo", Path: "/a", Target: "proxy"},
+		{Name: "x", Type: RuleProviderFile, Behavior: "domain", Format: "mrs", Path: "/a", Target: "proxy"},
+		{Name: "x", Type: RuleProviderFile, Behavior: "domain", Path: "/a"},
+		{Name: "a/b", Type: RuleProviderFile, Behavior: "domain", Path: "/a", Target: "proxy"},
+	}
+	for i, p := range bad {
+		cfg.Traffic.RuleProviders = []RuleProvider{p}
+		if err := Validate(cfg); err == nil {
+			t.Errorf("case %d should be rejected: %+v", i, p)
+		}
+	}
+	cfg.Traffic.RuleProviders = []RuleProvider{
+		{Name: "dup", Type: RuleProviderFile, Behavior: "domain", Path: "/a", Target: "proxy"},
+		{Name: "dup", Type: RuleProviderFile, Behavior: "domain", Path: "/b", Target: "proxy"},
+	}
+	if err := Validate(cfg); err == nil {
+		t.Fatal("duplicate provider names should be rejected")
+	}
+}
+
+func TestValidateDevicePolicies(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	cfg.Gateway.DevicePolicies = []DevicePolicy{
+		{IP: "192.168.1.50", Target: "direct"},
+		{IP: "192.168.2.0/24", Target: "🇺🇸 美国"},
+		{MAC: "AA-BB-CC-DD-EE-FF", Target: "reject"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid policies rejected: %v", err)
+	}
+	bad := [][]DevicePolicy{
+		{{Target: "direct"}},
+		{{IP: "192.168.1", Target: "direct"}},
+		{{MAC: "aa:bb:cc", Target: "direct"}},
+		{{IP: "192.168.1.50"}},
+		{{IP: "192.168.1.50", Target: "a,b"}},
+		{{IP: "192.168.1.50", Target: "direct"}, {IP: "192.168.1.50", Target: "proxy"}},
+	}
+	for i, list := range bad {
+		cfg.Gateway.DevicePolicies = list
+		if err := Validate(cfg); err == nil {
+			t.Errorf("case %d should be rejected: %+v", i, list)
+		}
+	}
+}
+
+func TestValidateDNS(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	cfg.Gateway.DNS.EnhancedMode = DNSModeRedirHost
+	cfg.Gateway.TUN.Enabled = false // Mac 上 TUN + redir-host 会被拒，见 TestValidateDNSWithTUN
+	cfg.Gateway.DNS.Nameservers = []string{"223.5.5.5", "119.29.29.29:53", "[2400:3200::1]:53", "tls://dns.alidns.com", "system"}
+	cfg.Gateway.DNS.Fallback = []string{"https://1.1.1.1/dns-query#Proxy"}
+	cfg.Gateway.DNS.NameserverPolicy = []NameserverPolicy{
+		{Match: "+.corp.example.com", Servers: []string{"10.0.0.53"}},
+		{Match: "geosite:cn", Servers: []string{"223.5.5.5"}},
+	}
+	cfg.Gateway.DNS.FakeIPFilter = []string{"+.nintendo.net"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid dns rejected: %v", err)
+	}
+	bad := []func(d *DNSConfig){
+		func(d *DNSConfig) { d.EnhancedMode = "mapping" },
+		func(d *DNSConfig) { d.Nameservers = []string{"dns.alidns.com"} },
+		func(d *DNSConfig) { d.Nameservers = []string{"doh://1.1.1.1"} },
+		func(d *DNSConfig) { d.Fallback = []string{""} },
+		func(d *DNSConfig) { d.NameserverPolicy = []NameserverPolicy{{Match: "+.a.com"}} },
+		func(d *DNSConfig) { d.NameserverPolicy = []NameserverPolicy{{Servers: []string{"1.1.1.1"}}} },
+		func(d *DNSConfig) {
+			d.NameserverPolicy = []NameserverPolicy{{Match: "a.com", Servers: []string{"1.1.1.1"}}, {Match: "a.com", Servers: []string{"8.8.8.8"}}}
+		},
+		func(d *DNSConfig) { d.FakeIPFilter = []string{"a b"} },
+	}
+	for i, mutate := range bad {
+		c := Default()
+		Normalize(c)
+		mutate(&c.Gateway.DNS)
+		if err := Validate(c); err == nil {
+			t.Errorf("case %d should be rejected: %+v", i, c.Gateway.DNS)
+		}
+	}
+}
+
+func TestValidateDNSWithTUN(t *testing.T) {
+	g := GatewayConfig{TUN: TUNConfig{Enabled: true}, DNS: DNSConfig{EnhancedMode: DNSModeRedirHost}}
+	if err := validateDNSWithTUN(g, "darwin"); err == nil {
+		t.Fatal("darwin TUN + redir-host should be rejected")
+	}
+	if err := validateDNSWithTUN(g, "linux"); err != nil {
+		t.Fatalf("linux TUN hijacks all traffic, redir-host is fine: %v", err)
+	}
+	g.TUN.Enabled = false
+	if err := validateDNSWithTUN(g, "darwin"); err != nil {
+		t.Fatalf("darwin without TUN: %v", err)
+	}
+	g.TUN.Enabled, g.DNS.EnhancedMode = true, DNSModeFakeIP
+	if err := validateDNSWithTUN(g, "darwin"); err != nil {
+		t.Fatalf("darwin TUN + fake-ip: %v", err)
+	}
+}
+
+func TestValidateMetricsListen(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	cfg.Runtime.Metrics.Enabled = true
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("default listen rejected: %v", err)
+	}
+	cfg.Runtime.Metrics.Listen = "19100"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("listen without host:port should be rejected")
+	}
+	cfg.Runtime.Metrics.Enabled = false
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("disabled metrics should not be validated: %v", err)
+	}
+}
+
+func TestValidateAutoPilot(t *testing.T) {
+	cases := []struct {
+		p  AutoPilotConfig
+		ok bool
+	}{
+		{AutoPilotConfig{}, true},
+		{AutoPilotConfig{Enabled: true}, true},
+		{AutoPilotConfig{Enabled: true, Interval: "90s", Window: 5, DegradeRounds: 5}, true},
+		{AutoPilotConfig{Enabled: true, Interval: "10s"}, false},
+		{AutoPilotConfig{Enabled: true, Interval: "two minutes"}, false},
+		{AutoPilotConfig{Enabled: true, URL: "www.gstatic.com"}, false},
+		{AutoPilotConfig{Enabled: true, MaxDelayMs: -1}, false},
+		{AutoPilotConfig{Enabled: true, Window: 2}, false}, // 小于默认 degrade_rounds 3
+		{AutoPilotConfig{Enabled: false, Interval: "10s"}, true},
+	}
+	for _, tc := range cases {
+		err := validateAutoPilot(tc.p)
+		if (err == nil) != tc.ok {
+			t.Errorf("validateAutoPilot(%+v) = %v, want ok=%v", tc.p, err, tc.ok)
+		}
+	}
+	p := AutoPilotConfig{}
+	if p.TargetGroup() != "Proxy" || p.Every() != DefaultAutoPilotInterval || p.Degrade() != 3 || p.Rounds() != 10 {
+		t.Errorf("defaults = %s %s %d %d", p.TargetGroup(), p.Every(), p.Degrade(), p.Rounds())
+	}
+}
+
+func TestValidateNotify(t *testing.T) {
+	cfg := Default()
+	cfg.Runtime.Notify = NotifyConfig{Sinks: []NotifySink{{Type: NotifySinkGotify, URL: "https://gotify.example.com"}}}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Fatalf("gotify without token must fail, got %v", err)
+	}
+	cfg.Runtime.Notify.Sinks[0].Token = "app-token"
+	cfg.Runtime.Notify.Sinks[0].Events = []string{"source_gone"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "source_gone") {
+		t.Fatalf("unknown event must fail, got %v", err)
+	}
+	cfg.Runtime.Notify.Sinks[0].Events = []string{NotifyEngineCrash}
+	cfg.Runtime.Notify.Sinks = append(cfg.Runtime.Notify.Sinks, NotifySink{Type: NotifySinkCommand})
+	if err := Validate(cfg); err == nil {
+		t.Fatal("command sink without argv must fail")
+	}
+	cfg.Runtime.Notify.Sinks[1].Command = []string{"/bin/true"}
+	cfg.Runtime.Notify.Cooldown = "10m"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid notify rejected: %v", err)
+	}
+	n := cfg.Runtime.Notify
+	if !n.Wants(n.Sinks[0], NotifyEngineCrash) || n.Wants(n.Sinks[0], NotifyNewDevice) || !n.Wants(n.Sinks[1], NotifyNewDevice) {
+		t.Fatal("per-sink events filter mismatch")
+	}
+}
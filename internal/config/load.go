@@ -3,10 +3,13 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -211,6 +214,13 @@ func Normalize(cfg *Config) {
 	if cfg.Source.Subscription.Name == "" {
 		cfg.Source.Subscription.Name = "subscription"
 	}
+	fillSubscriptionNames(cfg.Source.Subscriptions)
+	if cfg.Source.ExpiryWarnDays == 0 {
+		cfg.Source.ExpiryWarnDays = 7
+	}
+	for i := range cfg.Source.Profiles {
+		fillSubscriptionNames(cfg.Source.Profiles[i].Subscriptions)
+	}
 	if cfg.Runtime.Ports.Mixed == 0 {
 		cfg.Runtime.Ports.Mixed = 17890
 	}
@@ -226,6 +236,9 @@ func Normalize(cfg *Config) {
 	if cfg.Gateway.DNS.Port == 0 {
 		cfg.Gateway.DNS.Port = 53
 	}
+	if cfg.Gateway.DNS.EnhancedMode == "" {
+		cfg.Gateway.DNS.EnhancedMode = DNSModeFakeIP
+	}
 	if cfg.Runtime.LogLevel == "" {
 		cfg.Runtime.LogLevel = "warning"
 	}
@@ -238,10 +251,39 @@ func Validate(cfg *Config) error {
 	default:
 		return fmt.Errorf("traffic.mode 必须是 rule/global/direct，当前: %q", cfg.Traffic.Mode)
 	}
+	if err := validateRuleProviders(cfg.Traffic.RuleProviders); err != nil {
+		return err
+	}
+	if err := validateAutoPilot(cfg.Traffic.AutoPilot); err != nil {
+		return err
+	}
+	if err := validateNotify(cfg.Runtime.Notify); err != nil {
+		return err
+	}
+	if err := validateDevicePolicies(cfg.Gateway.DevicePolicies); err != nil {
+		return err
+	}
+	if err := validateDeviceSchedules(cfg.Gateway.DeviceSchedules); err != nil {
+		return err
+	}
+	if err := validateDeviceQuotas(cfg.Gateway); err != nil {
+		return err
+	}
+	if err := validateDNS(cfg.Gateway.DNS); err != nil {
+		return err
+	}
+	if err := validateDNSWithTUN(cfg.Gateway, runtime.GOOS); err != nil {
+		return err
+	}
+	if m := cfg.Runtime.Metrics; m.Enabled {
+		if _, port, err := net.SplitHostPort(m.ListenAddr()); err != nil || port == "" {
+			return fmt.Errorf("runtime.metrics.listen 应为 host:port（例 0.0.0.0:19100），当前: %q", m.Listen)
+		}
+	}
 	switch cfg.Source.Type {
-	case SourceTypeExternal, SourceTypeSubscription, SourceTypeFile, SourceTypeRemote, SourceTypeNone:
+	case SourceTypeExternal, SourceTypeSubscription, SourceTypeMulti, SourceTypeFile, SourceTypeRemote, SourceTypeNone:
 	default:
-		return fmt.Errorf("source.type 必须是 external/subscription/file/remote/none，当前: %q", cfg.Source.Type)
+		return fmt.Errorf("source.type 必须是 external/subscription/multi/file/remote/none，当前: %q", cfg.Source.Type)
 	}
 	switch cfg.Source.Type {
 	case SourceTypeExternal:
@@ -256,14 +298,281 @@ func Validate(cfg *Config) error {
 		if cfg.Source.Subscription.URL == "" {
 			return errors.New("source.subscription.url 不能为空")
 		}
+		if err := validateRefreshInterval(cfg.Source.Subscription); err != nil {
+			return fmt.Errorf("source.subscription: %w", err)
+		}
+		if err := validateNodeFilter(cfg.Source.Subscription.Filter); err != nil {
+			return fmt.Errorf("source.subscription.filter: %w", err)
+		}
+	case SourceTypeMulti:
+		if err := validateSubscriptions(cfg.Source.Subscriptions); err != nil {
+			return err
+		}
 	case SourceTypeFile:
 		if cfg.Source.File.Path == "" {
 			return errors.New("source.file.path 不能为空")
 		}
+		if err := validateNodeFilter(cfg.Source.File.Filter); err != nil {
+			return fmt.Errorf("source.file.filter: %w", err)
+		}
 	case SourceTypeRemote:
 		if cfg.Source.Remote.Server == "" || cfg.Source.Remote.Port <= 0 {
 			return errors.New("source.remote 必须指定 server 和 port")
 		}
 	}
+	return validateProfiles(cfg.Source)
+}
+
+// fillSubscriptionNames 给没起名的多订阅补 sub1 / sub2 …（按位置编号）。
+func fillSubscriptionNames(subs []SubscriptionSource) {
+	for i := range subs {
+		if subs[i].Name == "" {
+			subs[i].Name = fmt.Sprintf("sub%d", i+1)
+		}
+	}
+}
+
+// validateSubscriptions 检查 multi 源：至少一份订阅，URL 非空，名字互不相同
+// （名字会变成子分组名和重名节点的后缀，重了 mihomo 会报 duplicate）。
+func validateSubscriptions(subs []SubscriptionSource) error {
+	if len(subs) == 0 {
+		return errors.New("source.subscriptions 至少要有一份订阅")
+	}
+	seen := map[string]bool{}
+	for i, s := range subs {
+		if s.URL == "" {
+			return fmt.Errorf("source.subscriptions[%d].url 不能为空", i)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("source.subscriptions 里有重名: %q", s.Name)
+		}
+		seen[s.Name] = true
+		if err := validateRefreshInterval(s); err != nil {
+			return fmt.Errorf("source.subscriptions[%d]: %w", i, err)
+		}
+		if err := validateNodeFilter(s.Filter); err != nil {
+			return fmt.Errorf("source.subscriptions[%d].filter: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateRuleProviders 检查 traffic.rule_providers：名字唯一且能当文件名，
+// type / behavior / format 合法，对应的 url / path 已填，target 非空。
+func validateRuleProviders(list []RuleProvider) error {
+	seen := map[string]bool{}
+	for i, p := range list {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return fmt.Errorf("traffic.rule_providers[%d].name 不能为空", i)
+		}
+		if strings.ContainsAny(name, `,/\`) {
+			return fmt.Errorf("rule_provider %q: 名字里不能有逗号或斜杠", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("traffic.rule_providers 里有重名: %q", name)
+		}
+		seen[name] = true
+		switch p.Type {
+		case RuleProviderHTTP:
+			if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+				return fmt.Errorf("rule_provider %q: type=http 需要 http(s) url", name)
+			}
+		case RuleProviderFile:
+			if p.Path == "" {
+				return fmt.Errorf("rule_provider %q: type=file 需要 path", name)
+			}
+		default:
+			return fmt.Errorf("rule_provider %q: type 必须是 http/file，当前: %q", name, p.Type)
+		}
+		switch p.Behavior {
+		case "domain", "ipcidr", "classical":
+		default:
+			return fmt.Errorf("rule_provider %q: behavior 必须是 domain/ipcidr/classical，当前: %q", name, p.Behavior)
+		}
+		switch p.Format {
+		case "", "yaml", "text":
+		default:
+			return fmt.Errorf("rule_provider %q: format 必须是 yaml/text，当前: %q", name, p.Format)
+		}
+		if p.Interval < 0 {
+			return fmt.Errorf("rule_provider %q: interval 不能为负", name)
+		}
+		if strings.TrimSpace(p.Target) == "" {
+			return fmt.Errorf("rule_provider %q: target 不能为空（direct/proxy/reject 或策略组名）", name)
+		}
+	}
+	return nil
+}
+
+// validateDevicePolicies 检查每条设备策略的 IP / MAC 格式和 target，同一设备不许写两条。
+func validateDevicePolicies(list []DevicePolicy) error {
+	seen := map[string]bool{}
+	for i, p := range list {
+		if err := validateDevicePolicy(p); err != nil {
+			return fmt.Errorf("gateway.device_policies[%d]: %w", i, err)
+		}
+		key := strings.ToLower(p.Key())
+		if seen[key] {
+			return fmt.Errorf("gateway.device_policies 里 %s 出现了两次", p.Key())
+		}
+		seen[key] = true
+	}
 	return nil
 }
+
+// validateDevicePolicy 检查单条设备策略（设备策略和定时策略共用）。
+func validateDevicePolicy(p DevicePolicy) error {
+	if p.IP == "" && p.MAC == "" {
+		return errors.New("ip 和 mac 至少填一个")
+	}
+	if p.IP != "" && !validDeviceIP(p.IP) {
+		return fmt.Errorf("ip 不是合法的 IP / CIDR: %q", p.IP)
+	}
+	if p.MAC != "" {
+		if _, err := net.ParseMAC(p.MAC); err != nil {
+			return fmt.Errorf("mac 格式不对: %q", p.MAC)
+		}
+	}
+	if strings.TrimSpace(p.Target) == "" {
+		return errors.New("target 不能为空（direct/proxy/reject 或策略组名）")
+	}
+	if strings.Contains(p.Target, ",") {
+		return fmt.Errorf("target 里不能有逗号: %q", p.Target)
+	}
+	return nil
+}
+
+func validDeviceIP(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// validateNodeFilter 检查过滤 / 改名规则里的正则都能编译。
+func validateNodeFilter(f NodeFilter) error {
+	for _, p := range []struct{ field, expr string }{{"include", f.Include}, {"exclude", f.Exclude}} {
+		if p.expr == "" {
+			continue
+		}
+		if _, err := regexp.Compile(p.expr); err != nil {
+			return fmt.Errorf("%s 正则无效: %w", p.field, err)
+		}
+	}
+	for i, r := range f.Rename {
+		if r.Pattern == "" {
+			return fmt.Errorf("rename[%d].pattern 不能为空", i)
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("rename[%d].pattern 正则无效: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateRefreshInterval 检查 refresh_interval 能被 time.ParseDuration 解析且不低于下限。
+func validateRefreshInterval(s SubscriptionSource) error {
+	if s.RefreshInterval == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s.RefreshInterval)
+	if err != nil {
+		return fmt.Errorf("refresh_interval %q 不是合法时长（例: 30m / 6h）", s.RefreshInterval)
+	}
+	if d < MinRefreshInterval {
+		return fmt.Errorf("refresh_interval 不能小于 %s，当前: %s", MinRefreshInterval, s.RefreshInterval)
+	}
+	return nil
+}
+
+// validateDNS 检查 gateway.dns 的上游配置。写错的上游 mihomo 会直接拒绝启动，
+// 这里提前报出是哪一条。
+func validateDNS(d DNSConfig) error {
+	switch d.EnhancedMode {
+	case "", DNSModeFakeIP, DNSModeRedirHost:
+	default:
+		return fmt.Errorf("gateway.dns.enhanced_mode 必须是 fake-ip/redir-host，当前: %q", d.EnhancedMode)
+	}
+	for i, s := range d.Nameservers {
+		if err := validateNameserver(s); err != nil {
+			return fmt.Errorf("gateway.dns.nameservers[%d]: %w", i, err)
+		}
+	}
+	for i, s := range d.Fallback {
+		if err := validateNameserver(s); err != nil {
+			return fmt.Errorf("gateway.dns.fallback[%d]: %w", i, err)
+		}
+	}
+	seen := map[string]bool{}
+	for i, p := range d.NameserverPolicy {
+		field := fmt.Sprintf("gateway.dns.nameserver_policy[%d]", i)
+		match := strings.TrimSpace(p.Match)
+		if match == "" || strings.ContainsAny(match, " \t") {
+			return fmt.Errorf("%s.match 不能为空或带空格（例 +.corp.example.com / geosite:cn）", field)
+		}
+		if seen[match] {
+			return fmt.Errorf("gateway.dns.nameserver_policy 里 %q 出现了两次", match)
+		}
+		seen[match] = true
+		if len(p.Servers) == 0 {
+			return fmt.Errorf("%s.servers 不能为空", field)
+		}
+		for j, s := range p.Servers {
+			if err := validateNameserver(s); err != nil {
+				return fmt.Errorf("%s.servers[%d]: %w", field, j, err)
+			}
+		}
+	}
+	for i, f := range d.FakeIPFilter {
+		if strings.TrimSpace(f) == "" || strings.ContainsAny(f, " \t") {
+			return fmt.Errorf("gateway.dns.fake_ip_filter[%d] 不能为空或带空格: %q", i, f)
+		}
+	}
+	return nil
+}
+
+// validateDNSWithTUN 拦住 macOS 上 TUN + redir-host 的组合：Mac 的 TUN 只接管
+// fake-ip 段（auto-route 把 198.18/16 灌进 utun，见 engine.renderTUNBlock），
+// redir-host 给 LAN 设备返回真实 IP，连接根本不进 mihomo，分流悄悄失效。
+func validateDNSWithTUN(g GatewayConfig, goos string) error {
+	if goos == "darwin" && g.TUN.Enabled && g.DNS.EnhancedMode == DNSModeRedirHost {
+		return errors.New("macOS 上开着 TUN 时 gateway.dns.enhanced_mode 不能是 redir-host：TUN 只接管 fake-ip 段，返回真实 IP 的连接不会走代理；改回 fake-ip，或者关掉 TUN")
+	}
+	return nil
+}
+
+// nameserverSchemes 是 mihomo 认识的上游协议前缀。
+var nameserverSchemes = map[string]bool{
+	"udp": true, "tcp": true, "tls": true, "https": true, "quic": true, "dhcp": true, "system": true,
+}
+
+// validateNameserver 接受 mihomo 的上游写法：1.2.3.4 / 1.2.3.4:53 / [::1]:53 /
+// tls://dns.example / https://dns.example/dns-query#Proxy / system。
+func validateNameserver(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return errors.New("上游 DNS 不能为空")
+	}
+	if strings.ContainsAny(s, " \t") {
+		return fmt.Errorf("上游 DNS 里不能有空格: %q", s)
+	}
+	if scheme, _, ok := strings.Cut(s, "://"); ok {
+		if !nameserverSchemes[strings.ToLower(scheme)] {
+			return fmt.Errorf("不支持的上游协议 %q（udp/tcp/tls/https/quic/dhcp）: %q", scheme, s)
+		}
+		return nil
+	}
+	if s == "system" {
+		return nil
+	}
+	host, _, _ := strings.Cut(s, "#")
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil && net.ParseIP(h) != nil {
+		return nil
+	}
+	return fmt.Errorf("上游 DNS 不带协议时必须是 IP 或 IP:端口（域名请写 tls:// 或 https://）: %q", s)
+}
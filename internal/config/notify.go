@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NotifyConfig 是网关事件通知：源挂了、切直连、订阅快到期、mihomo 意外退出、
+// 局域网出现新设备时推给手机 / 群聊 / 自己的脚本。例：
+//
+//	runtime:
+//	  notify:
+//	    cooldown: 30m                 # 同一件事 30 分钟内只发一次
+//	    sinks:
+//	      - type: ntfy
+//	        url: https://ntfy.sh/my-gateway
+//	      - type: command
+//	        command: [/usr/local/bin/on-gateway-event.sh]
+//	        events: [engine_crash]    # 只关心 mihomo 崩溃
+type NotifyConfig struct {
+	// Cooldown 是同一事件（同一订阅 / 同一台设备）两次通知的最短间隔，默认 30m。
+	// 源来回抖动时靠它防刷屏；订阅到期提醒另外至少隔 24h。
+	Cooldown string `yaml:"cooldown,omitempty"`
+	// Events 只发这些事件，空 = 全部。
+	Events []string     `yaml:"events,omitempty"`
+	Sinks  []NotifySink `yaml:"sinks,omitempty"`
+}
+
+// NotifySink 是一个通知出口。不同 Type 用到的字段：
+//
+//	webhook  url，可选 headers —— POST 事件 JSON
+//	ntfy     url（含 topic），可选 token
+//	gotify   url（服务器地址），token（应用 token）
+//	bark     url（含 device key，例 https://api.day.app/XXXX）
+//	command  command（argv）—— 事件 JSON 走 stdin，另有 GATEWAY_EVENT_* 环境变量
+type NotifySink struct {
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url,omitempty"`
+	Token   string            `yaml:"token,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Command []string          `yaml:"command,omitempty"`
+	// Events 非空时覆盖 notify.events，只给这个出口发这些事件。
+	Events []string `yaml:"events,omitempty"`
+}
+
+// 通知出口类型。
+const (
+	NotifySinkWebhook = "webhook"
+	NotifySinkCommand = "command"
+	NotifySinkNtfy    = "ntfy"
+	NotifySinkGotify  = "gotify"
+	NotifySinkBark    = "bark"
+)
+
+// 通知事件。
+const (
+	NotifySourceDown     = "source_down"     // 代理源连续探测失败
+	NotifySourceUp       = "source_up"       // 代理源恢复
+	NotifyFallbackDirect = "fallback_direct" // 源不通、已临时强切 direct
+	NotifySubExpiry      = "subscription_expiry"
+	NotifyEngineCrash    = "engine_crash" // mihomo 没走 Stop 就退出了
+	NotifyNewDevice      = "new_device"   // 局域网里第一次见到的设备
+)
+
+// NotifyEvents 是全部事件名，校验和 `gateway notify test` 用。
+var NotifyEvents = []string{
+	NotifySourceDown, NotifySourceUp, NotifyFallbackDirect,
+	NotifySubExpiry, NotifyEngineCrash, NotifyNewDevice,
+}
+
+// DefaultNotifyCooldown 是同一事件两次通知的默认最短间隔。
+const DefaultNotifyCooldown = 30 * time.Minute
+
+// Enabled 报告是否配了任何出口。
+func (n NotifyConfig) Enabled() bool { return len(n.Sinks) > 0 }
+
+// CooldownDuration 返回限流间隔；没填或写错时用默认值（Validate 会先拦住写错的）。
+func (n NotifyConfig) CooldownDuration() time.Duration {
+	d, err := time.ParseDuration(n.Cooldown)
+	if err != nil || d < 0 {
+		return DefaultNotifyCooldown
+	}
+	return d
+}
+
+// Wants 报告出口 s 要不要 event 这类事件。
+func (n NotifyConfig) Wants(s NotifySink, event string) bool {
+	events := n.Events
+	if len(s.Events) > 0 {
+		events = s.Events
+	}
+	return len(events) == 0 || slices.Contains(events, event)
+}
+
+func validateNotify(n NotifyConfig) error {
+	if n.Cooldown != "" {
+		if d, err := time.ParseDuration(n.Cooldown); err != nil || d < 0 {
+			return fmt.Errorf("runtime.notify.cooldown 写法不对（例 30m / 2h）：%q", n.Cooldown)
+		}
+	}
+	if err := validateNotifyEvents("runtime.notify.events", n.Events); err != nil {
+		return err
+	}
+	for i, s := range n.Sinks {
+		field := fmt.Sprintf("runtime.notify.sinks[%d]", i)
+		switch s.Type {
+		case NotifySinkCommand:
+			if len(s.Command) == 0 || strings.TrimSpace(s.Command[0]) == "" {
+				return fmt.Errorf("%s: command 不能为空", field)
+			}
+		case NotifySinkWebhook, NotifySinkNtfy, NotifySinkGotify, NotifySinkBark:
+			if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+				return fmt.Errorf("%s: url 必须是 http(s) 地址，当前: %q", field, s.URL)
+			}
+			if s.Type == NotifySinkGotify && s.Token == "" {
+				return fmt.Errorf("%s: gotify 需要 token（应用 token）", field)
+			}
+		default:
+			return fmt.Errorf("%s: type 必须是 webhook/command/ntfy/gotify/bark，当前: %q", field, s.Type)
+		}
+		if err := validateNotifyEvents(field+".events", s.Events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateNotifyEvents(field string, events []string) error {
+	for _, e := range events {
+		if !slices.Contains(NotifyEvents, e) {
+			return fmt.Errorf("%s 里有未知事件 %q，可选: %s", field, e, strings.Join(NotifyEvents, " / "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FindProfile 按名字找 profile，返回下标；找不到返回 -1。名字大小写敏感，
+// 和 YAML 里写的一致，避免 "Work" / "work" 两个 profile 被当成同一个。
+func (s SourceConfig) FindProfile(name string) int {
+	for i, p := range s.Profiles {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ProfileFromSource 把当前生效的源快照成一个 profile。只拷贝 Type 对应的那一块，
+// 其余槽位留 nil —— 切回来时不会覆盖用户在别的槽位里填过的东西。
+func ProfileFromSource(name string, src SourceConfig) Profile {
+	p := Profile{Name: name, Type: src.Type}
+	switch src.Type {
+	case SourceTypeExternal:
+		e := src.External
+		p.External = &e
+	case SourceTypeSubscription:
+		s := src.Subscription
+		p.Subscription = &s
+	case SourceTypeMulti:
+		p.Subscriptions = append([]SubscriptionSource(nil), src.Subscriptions...)
+	case SourceTypeFile:
+		f := src.File
+		p.File = &f
+	case SourceTypeRemote:
+		r := src.Remote
+		p.Remote = &r
+	}
+	return p
+}
+
+// ApplyProfile 返回把 p 套到 src 上之后的新源：Type 换成 p.Type，p 里非 nil 的
+// 槽位覆盖对应字段，Current 记成 p.Name。Profiles / 脚本等其余字段原样保留。
+func (s SourceConfig) ApplyProfile(p Profile) SourceConfig {
+	out := s
+	out.Type = p.Type
+	if p.External != nil {
+		out.External = *p.External
+	}
+	if p.Subscription != nil {
+		out.Subscription = *p.Subscription
+	}
+	if p.File != nil {
+		out.File = *p.File
+	}
+	if p.Remote != nil {
+		out.Remote = *p.Remote
+	}
+	if p.Subscriptions != nil {
+		out.Subscriptions = append([]SubscriptionSource(nil), p.Subscriptions...)
+	}
+	out.Current = p.Name
+	return out
+}
+
+// Summary 给档案一行人读摘要：订阅 URL / 合并的订阅名 / 文件路径 / server:port。
+// `profile list` 和菜单的档案页都用它，缩略、上色由各自的界面决定。
+func (p Profile) Summary() string {
+	switch p.Type {
+	case SourceTypeSubscription:
+		if p.Subscription != nil {
+			return p.Subscription.URL
+		}
+	case SourceTypeMulti:
+		names := make([]string, 0, len(p.Subscriptions))
+		for _, s := range p.Subscriptions {
+			names = append(names, s.Name)
+		}
+		return strings.Join(names, " + ")
+	case SourceTypeFile:
+		if p.File != nil {
+			return p.File.Path
+		}
+	case SourceTypeExternal:
+		if p.External != nil {
+			return fmt.Sprintf("%s:%d %s", p.External.Server, p.External.Port, p.External.Kind)
+		}
+	case SourceTypeRemote:
+		if p.Remote != nil {
+			return fmt.Sprintf("%s:%d %s", p.Remote.Server, p.Remote.Port, p.Remote.Kind)
+		}
+	}
+	return ""
+}
+
+// validateProfiles 检查 profiles 名字唯一、类型合法、对应槽位已填；Current 若非空
+// 必须指向一个存在的 profile。
+func validateProfiles(src SourceConfig) error {
+	seen := map[string]bool{}
+	for i, p := range src.Profiles {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return fmt.Errorf("source.profiles[%d].name 不能为空", i)
+		}
+		if seen[name] {
+			return fmt.Errorf("source.profiles 里有重名: %q", name)
+		}
+		seen[name] = true
+		switch p.Type {
+		case SourceTypeExternal:
+			if p.External == nil || p.External.Port <= 0 {
+				return fmt.Errorf("profile %q: external 必须指定 port", name)
+			}
+		case SourceTypeSubscription:
+			if p.Subscription == nil || p.Subscription.URL == "" {
+				return fmt.Errorf("profile %q: subscription.url 不能为空", name)
+			}
+		case SourceTypeMulti:
+			if err := validateSubscriptions(p.Subscriptions); err != nil {
+				return fmt.Errorf("profile %q: %w", name, err)
+			}
+		case SourceTypeFile:
+			if p.File == nil || p.File.Path == "" {
+				return fmt.Errorf("profile %q: file.path 不能为空", name)
+			}
+		case SourceTypeRemote:
+			if p.Remote == nil || p.Remote.Server == "" || p.Remote.Port <= 0 {
+				return fmt.Errorf("profile %q: remote 必须指定 server 和 port", name)
+			}
+		case SourceTypeNone:
+		default:
+			return fmt.Errorf("profile %q: type 必须是 external/subscription/multi/file/remote/none，当前: %q", name, p.Type)
+		}
+	}
+	if src.Current != "" && !seen[src.Current] {
+		return fmt.Errorf("source.current = %q，但 profiles 里没有这个名字", src.Current)
+	}
+	return validateFailover(src)
+}
+
+// validateFailover 检查 failover 里每个名字都指向 profiles，且不重复。
+// 不拦 type=none 的档案：把「全部直连」放在链尾是合法的兜底写法。
+func validateFailover(src SourceConfig) error {
+	seen := map[string]bool{}
+	for i, name := range src.Failover {
+		if src.FindProfile(name) < 0 {
+			return fmt.Errorf("source.failover[%d] = %q，但 profiles 里没有这个名字", i, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("source.failover 里 %q 写了两次", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
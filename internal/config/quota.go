@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceQuota 是一台设备的流量额度。例：
+//
+//	device_quotas:
+//	  - device: 孩子的平板        # IP，或 device_labels 里的名字
+//	    period: daily
+//	    limit: 2GB
+//	    action: reject
+//	  - device: 192.168.1.64
+//	    period: monthly
+//	    limit: 20GB
+//	    action: direct           # 超额后只许直连
+//
+// 用量按来源 IP 统计（和 `gateway stats devices` 同一本账），上下行合计。
+// 周期按本机时区算：daily 每天 0 点、monthly 每月 1 号 0 点重置。
+//
+// 超额是靠渲染一条 SRC-IP-CIDR 设备规则执行的，只在 traffic.mode: rule 下生效：
+// global / direct 模式 mihomo 不看规则，超额的设备照样畅通（用量照记）。
+// `gateway status` / `gateway stats quota` 在这种情况下会提醒。
+type DeviceQuota struct {
+	Device string `yaml:"device"`
+	Period string `yaml:"period"`           // daily | monthly
+	Limit  string `yaml:"limit"`            // 500MB / 20GB（1024 进制）
+	Action string `yaml:"action,omitempty"` // reject（默认）| direct
+}
+
+// 额度周期。
+const (
+	QuotaDaily   = "daily"
+	QuotaMonthly = "monthly"
+)
+
+// QuotaAction 返回超额后的去向，空按 reject。
+func (q DeviceQuota) QuotaAction() string {
+	if q.Action == "" {
+		return "reject"
+	}
+	return q.Action
+}
+
+// LimitBytes 返回额度字节数；Limit 写错时返回 0（Validate 会先拦住）。
+func (q DeviceQuota) LimitBytes() int64 {
+	n, _ := ParseByteSize(q.Limit)
+	return n
+}
+
+// PeriodBounds 返回 now 所在周期的 [start, end)。
+func (q DeviceQuota) PeriodBounds(now time.Time) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	loc := now.Location()
+	if q.Period == QuotaMonthly {
+		start := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// QuotaIP 把额度里的 device 换成 IP：本身是 IP 直接用，否则按名字查 device_labels。
+// 查不到返回空串。
+func (g GatewayConfig) QuotaIP(q DeviceQuota) string {
+	dev := strings.TrimSpace(q.Device)
+	if net.ParseIP(dev) != nil {
+		return dev
+	}
+	for ip, name := range g.DeviceLabels {
+		if name == dev {
+			return ip
+		}
+	}
+	return ""
+}
+
+// ParseByteSize 解析 500MB / 20GB / 1.5TB / 1024（纯数字按字节）。单位不分大小写，
+// B 可省略（20G），一律 1024 进制 —— 和机场、路由器面板的习惯一致。
+func ParseByteSize(raw string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if s == "" {
+		return 0, errors.New("不能为空")
+	}
+	units := []struct {
+		suffix string
+		mul    float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	mul := 1.0
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, mul = strings.TrimSpace(num), u.mul
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("看不懂流量大小 %q（例 500MB / 20GB）", raw)
+	}
+	return int64(v * mul), nil
+}
+
+// validateDeviceQuotas 检查设备、周期、额度和去向；同一设备同一周期只能有一条。
+func validateDeviceQuotas(g GatewayConfig) error {
+	seen := map[string]bool{}
+	for i, q := range g.DeviceQuotas {
+		field := fmt.Sprintf("gateway.device_quotas[%d]", i)
+		if strings.TrimSpace(q.Device) == "" {
+			return fmt.Errorf("%s.device 不能为空（IP 或 device_labels 里的名字）", field)
+		}
+		ip := g.QuotaIP(q)
+		if ip == "" {
+			return fmt.Errorf("%s.device: %q 既不是 IP，也不在 device_labels 里", field, q.Device)
+		}
+		switch q.Period {
+		case QuotaDaily, QuotaMonthly:
+		default:
+			return fmt.Errorf("%s.period 必须是 daily/monthly，当前: %q", field, q.Period)
+		}
+		if _, err := ParseByteSize(q.Limit); err != nil {
+			return fmt.Errorf("%s.limit: %w", field, err)
+		}
+		switch q.Action {
+		case "", "reject", "direct":
+		default:
+			return fmt.Errorf("%s.action 必须是 reject/direct，当前: %q", field, q.Action)
+		}
+		key := ip + "|" + q.Period
+		if seen[key] {
+			return fmt.Errorf("gateway.device_quotas 里 %s 的 %s 额度出现了两次", q.Device, q.Period)
+		}
+		seen[key] = true
+	}
+	return nil
+}
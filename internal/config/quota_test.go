@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"500MB": 500 << 20,
+		"20gb":  20 << 30,
+		"1.5G":  3 << 29,
+		"2 TB":  2 << 40,
+	}
+	for in, want := range cases {
+		got, err := ParseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "GB", "-1GB", "0", "ten"} {
+		if _, err := ParseByteSize(bad); err == nil {
+			t.Errorf("ParseByteSize(%q) should fail", bad)
+		}
+	}
+}
+
+func TestQuotaPeriodBounds(t *testing.T) {
+	now := time.Date(2026, 12, 18, 15, 0, 0, 0, time.Local)
+	start, end := DeviceQuota{Period: QuotaDaily}.PeriodBounds(now)
+	if !start.Equal(time.Date(2026, 12, 18, 0, 0, 0, 0, time.Local)) || !end.Equal(time.Date(2026, 12, 19, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("daily = %v ~ %v", start, end)
+	}
+	start, end = DeviceQuota{Period: QuotaMonthly}.PeriodBounds(now)
+	if !start.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)) || !end.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("monthly = %v ~ %v", start, end)
+	}
+}
+
+func TestValidateDeviceQuotas(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	cfg.Gateway.DeviceLabels = map[string]string{"192.168.1.23": "平板"}
+	cfg.Gateway.DeviceQuotas = []DeviceQuota{
+		{Device: "平板", Period: QuotaDaily, Limit: "2GB"},
+		{Device: "192.168.1.23", Period: QuotaMonthly, Limit: "20GB", Action: "direct"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid quotas rejected: %v", err)
+	}
+	if ip := cfg.Gateway.QuotaIP(cfg.Gateway.DeviceQuotas[0]); ip != "192.168.1.23" {
+		t.Fatalf("label not resolved: %q", ip)
+	}
+	bad := [][]DeviceQuota{
+		{{Period: QuotaDaily, Limit: "1GB"}},
+		{{Device: "不存在的设备", Period: QuotaDaily, Limit: "1GB"}},
+		{{Device: "192.168.1.23", Period: "weekly", Limit: "1GB"}},
+		{{Device: "192.168.1.23", Period: QuotaDaily, Limit: "lots"}},
+		{{Device: "192.168.1.23", Period: QuotaDaily, Limit: "1GB", Action: "proxy"}},
+		{{Device: "平板", Period: QuotaDaily, Limit: "1GB"}, {Device: "192.168.1.23", Period: QuotaDaily, Limit: "2GB"}},
+	}
+	for i, list := range bad {
+		cfg.Gateway.DeviceQuotas = list
+		if err := Validate(cfg); err == nil {
+			t.Errorf("case %d should be rejected: %+v", i, list)
+		}
+	}
+}
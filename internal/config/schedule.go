@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceSchedule 是只在某个时间窗口里生效的设备策略。例：
+//
+//	device_schedules:
+//	  - name: Switch 学校日晚上断网
+//	    ip: 192.168.1.23
+//	    target: reject
+//	    days: [sun, mon, tue, wed, thu]   # 窗口「开始」那天；空 = 每天
+//	    from: "22:00"
+//	    to: "07:00"                       # to <= from 表示跨午夜到第二天
+//
+// 时间按运行 gateway 这台机器的本地时区算。
+type DeviceSchedule struct {
+	DevicePolicy `yaml:",inline"`
+	Name         string   `yaml:"name,omitempty"`
+	Days         []string `yaml:"days,omitempty"` // mon..sun / weekdays / weekend
+	From         string   `yaml:"from"`           // HH:MM
+	To           string   `yaml:"to"`             // HH:MM
+}
+
+// Label 返回定时策略在状态 / 日志里的名字：没起名就用设备标识。
+func (s DeviceSchedule) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Key()
+}
+
+var weekdayNames = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekend":  {time.Saturday, time.Sunday},
+}
+
+// parseClock 把 "HH:MM" 解析成当天的分钟数。
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, fmt.Errorf("时间格式应为 HH:MM，当前: %q", s)
+	}
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if err1 != nil || err2 != nil || hh < 0 || hh > 23 || mm < 0 || mm > 59 {
+		return 0, fmt.Errorf("时间格式应为 HH:MM，当前: %q", s)
+	}
+	return hh*60 + mm, nil
+}
+
+// onDay 报告窗口是否在 weekday 这天开始。
+func (s DeviceSchedule) onDay(d time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, name := range s.Days {
+		for _, wd := range weekdayNames[strings.ToLower(strings.TrimSpace(name))] {
+			if wd == d {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// windows 返回 around 前一天到之后 7 天里所有开始的窗口 [start, end)。
+// 从前一天算起是为了覆盖「昨晚 22:00 开始、现在还没结束」的跨午夜窗口。
+func (s DeviceSchedule) windows(around time.Time) [][2]time.Time {
+	from, err1 := parseClock(s.From)
+	to, err2 := parseClock(s.To)
+	if err1 != nil || err2 != nil || from == to {
+		return nil
+	}
+	y, mo, d := around.Date()
+	loc := around.Location()
+	var out [][2]time.Time
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(y, mo, d+offset, 0, 0, 0, 0, loc)
+		if !s.onDay(day.Weekday()) {
+			continue
+		}
+		start := time.Date(y, mo, d+offset, from/60, from%60, 0, 0, loc)
+		endDay := d + offset
+		if to < from {
+			endDay++
+		}
+		end := time.Date(y, mo, endDay, to/60, to%60, 0, 0, loc)
+		out = append(out, [2]time.Time{start, end})
+	}
+	return out
+}
+
+// ActiveAt 报告 t 是否落在某个窗口里（含开始、不含结束）。
+func (s DeviceSchedule) ActiveAt(t time.Time) bool {
+	for _, w := range s.windows(t) {
+		if !t.Before(w[0]) && t.Before(w[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveDevicePolicies 返回 now 这一刻要渲染的设备策略：生效中的定时策略在前
+// （SRC-IP-CIDR 先到先得，同一设备以定时为准），常驻的 DevicePolicies 在后。
+func (g GatewayConfig) ActiveDevicePolicies(now time.Time) []DevicePolicy {
+	var out []DevicePolicy
+	for _, s := range g.DeviceSchedules {
+		if s.ActiveAt(now) {
+			out = append(out, s.DevicePolicy)
+		}
+	}
+	return append(out, g.DevicePolicies...)
+}
+
+// ScheduleTransition 是定时策略的一次切换：Start=true 表示窗口开始。
+type ScheduleTransition struct {
+	At       time.Time `json:"at"`
+	Schedule string    `json:"schedule"`
+	Target   string    `json:"target"`
+	Start    bool      `json:"start"`
+}
+
+// UpcomingTransitions 返回 now 之后（不含 now）最近的 limit 次切换，按时间排序。
+// 只看未来 7 天；limit <= 0 表示不限。
+func (g GatewayConfig) UpcomingTransitions(now time.Time, limit int) []ScheduleTransition {
+	var out []ScheduleTransition
+	for _, s := range g.DeviceSchedules {
+		for _, w := range s.windows(now) {
+			if w[0].After(now) {
+				out = append(out, ScheduleTransition{At: w[0], Schedule: s.Label(), Target: s.Target, Start: true})
+			}
+			if w[1].After(now) {
+				out = append(out, ScheduleTransition{At: w[1], Schedule: s.Label(), Target: s.Target})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// validateDeviceSchedules 检查时间窗口和设备字段；同一设备可以有多条定时策略。
+func validateDeviceSchedules(list []DeviceSchedule) error {
+	for i, s := range list {
+		field := fmt.Sprintf("gateway.device_schedules[%d]", i)
+		if err := validateDevicePolicy(s.DevicePolicy); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		from, err := parseClock(s.From)
+		if err != nil {
+			return fmt.Errorf("%s.from: %w", field, err)
+		}
+		to, err := parseClock(s.To)
+		if err != nil {
+			return fmt.Errorf("%s.to: %w", field, err)
+		}
+		if from == to {
+			return fmt.Errorf("%s: from 和 to 不能相同（全天生效请用 device_policies）", field)
+		}
+		for _, d := range s.Days {
+			if _, ok := weekdayNames[strings.ToLower(strings.TrimSpace(d))]; !ok {
+				return fmt.Errorf("%s.days: 不认识 %q（mon..sun / weekdays / weekend）", field, d)
+			}
+		}
+	}
+	return nil
+}
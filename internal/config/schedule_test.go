@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeviceScheduleActiveAcrossMidnight(t *testing.T) {
+	// 学校日晚上：周日到周四 22:00 开始，第二天 07:00 结束
+	s := DeviceSchedule{
+		DevicePolicy: DevicePolicy{IP: "192.168.1.23", Target: "reject"},
+		Days:         []string{"sun", "mon", "tue", "wed", "thu"},
+		From:         "22:00",
+		To:           "07:00",
+	}
+	at := func(day, hh, mm int) time.Time { return time.Date(2026, 3, day, hh, mm, 0, 0, time.Local) } // 2026-03-01 是周日
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(1, 21, 59), false},
+		{at(1, 22, 0), true},  // 周日晚开始
+		{at(2, 6, 59), true},  // 跨午夜到周一早上
+		{at(2, 7, 0), false},  // 结束时刻不含
+		{at(6, 23, 0), false}, // 周五晚不在 days 里
+		{at(7, 3, 0), false},  // 周六凌晨：周五那晚没开始
+		{at(5, 23, 30), true}, // 周四晚
+		{at(6, 6, 0), true},   // 周四晚延续到周五早上
+	}
+	for _, tc := range cases {
+		if got := s.ActiveAt(tc.t); got != tc.want {
+			t.Errorf("ActiveAt(%s) = %v, want %v", tc.t.Format("Mon 15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestActiveDevicePoliciesAndTransitions(t *testing.T) {
+	g := GatewayConfig{
+		DevicePolicies: []DevicePolicy{{IP: "192.168.1.23", Target: "proxy"}},
+		DeviceSchedules: []DeviceSchedule{
+			{Name: "work", DevicePolicy: DevicePolicy{IP: "192.168.1.30", Target: "direct"}, Days: []string{"weekdays"}, From: "09:00", To: "18:00"},
+			{Name: "bedtime", DevicePolicy: DevicePolicy{IP: "192.168.1.23", Target: "reject"}, From: "22:00", To: "07:00"},
+		},
+	}
+	now := time.Date(2026, 3, 2, 23, 0, 0, 0, time.Local) // 周一 23:00
+	got := g.ActiveDevicePolicies(now)
+	if len(got) != 2 || got[0].Target != "reject" || got[1].Target != "proxy" {
+		t.Fatalf("active policies = %+v, want scheduled reject before static proxy", got)
+	}
+
+	trs := g.UpcomingTransitions(now, 3)
+	want := []struct {
+		at    time.Time
+		name  string
+		start bool
+	}{
+		{time.Date(2026, 3, 3, 7, 0, 0, 0, time.Local), "bedtime", false},
+		{time.Date(2026, 3, 3, 9, 0, 0, 0, time.Local), "work", true},
+		{time.Date(2026, 3, 3, 18, 0, 0, 0, time.Local), "work", false},
+	}
+	if len(trs) != len(want) {
+		t.Fatalf("transitions = %+v", trs)
+	}
+	for i, w := range want {
+		if !trs[i].At.Equal(w.at) || trs[i].Schedule != w.name || trs[i].Start != w.start {
+			t.Errorf("transition %d = %+v, want %+v", i, trs[i], w)
+		}
+	}
+}
+
+func TestValidateDeviceSchedules(t *testing.T) {
+	cfg := Default()
+	Normalize(cfg)
+	ok := DeviceSchedule{DevicePolicy: DevicePolicy{IP: "192.168.1.23", Target: "reject"}, Days: []string{"Mon", "weekend"}, From: "22:00", To: "07:00"}
+	cfg.Gateway.DeviceSchedules = []DeviceSchedule{ok, ok} // 同一设备多条窗口是允许的
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("valid schedule rejected: %v", err)
+	}
+	mutate := []func(*DeviceSchedule){
+		func(s *DeviceSchedule) { s.From = "25:00" },
+		func(s *DeviceSchedule) { s.To = "7" },
+		func(s *DeviceSchedule) { s.To = s.From },
+		func(s *DeviceSchedule) { s.Days = []string{"someday"} },
+		func(s *DeviceSchedule) { s.IP = "" },
+	}
+	for i, m := range mutate {
+		s := ok
+		m(&s)
+		cfg.Gateway.DeviceSchedules = []DeviceSchedule{s}
+		if err := Validate(cfg); err == nil {
+			t.Errorf("case %d should be rejected: %+v", i, s)
+		}
+	}
+}
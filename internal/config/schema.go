@@ -9,6 +9,7 @@ package config
 import (
 	"net"
 	"strings"
+	"time"
 )
 
 // Version is the current schema version. Bump when a breaking change lands.
@@ -32,6 +33,36 @@ type GatewayConfig struct {
 	// DeviceLabels 把 LAN 设备 IP 映射成人读的名字（例如 "192.168.1.23" → "Switch"），
 	// 给仪表盘设备表用。反向 DNS 拿不到/不准时用户可以在菜单里手动打标签覆盖。
 	DeviceLabels map[string]string `yaml:"device_labels,omitempty"`
+	// DevicePolicies 按 LAN 设备来源 IP 强制走某个出口（直连 / 某个策略组 / 拒绝），
+	// 渲染成 SRC-IP-CIDR 规则排在用户自定义规则前面。例如电视盒子永远直连、
+	// 孩子的平板走 REJECT。
+	DevicePolicies []DevicePolicy `yaml:"device_policies,omitempty"`
+	// DeviceSchedules 是按时间段生效的设备策略（家长控制：「学校日晚 22:00–07:00
+	// 断 Switch 的网」）。生效中的条目排在 DevicePolicies 前面，同一设备以定时为准。
+	DeviceSchedules []DeviceSchedule `yaml:"device_schedules,omitempty"`
+	// DeviceQuotas 是按设备的日 / 月流量额度，超额后这台设备改走 reject 或 direct，
+	// 直到周期重置（备用 4G 这类按量计费的线路用）。
+	DeviceQuotas []DeviceQuota `yaml:"device_quotas,omitempty"`
+}
+
+// DevicePolicy 是一台设备的强制出口。IP 和 MAC 至少填一个：
+//   - IP 可以是单个地址，也可以是 CIDR（一整段设备走同一策略）；
+//   - 只填 MAC 时，启动 / 重载那一刻查本机 ARP 表换成 IP，查不到就跳过这条
+//     （设备不在线或不在同一二层网段），下次重载再试。
+//
+// 两个都填时以 IP 为准，MAC 只作备注。
+type DevicePolicy struct {
+	IP     string `yaml:"ip,omitempty"`
+	MAC    string `yaml:"mac,omitempty"`
+	Target string `yaml:"target"` // direct | proxy | reject | 策略组名
+}
+
+// Key 返回这条策略在菜单 / 日志里的标识：优先 IP，其次 MAC。
+func (p DevicePolicy) Key() string {
+	if p.IP != "" {
+		return p.IP
+	}
+	return p.MAC
 }
 
 // TUNConfig toggles the TUN virtual interface.
@@ -41,11 +72,47 @@ type TUNConfig struct {
 }
 
 // DNSConfig toggles the DNS listener exposed to LAN devices.
+//
+// 上游相关字段留空 = 用内置默认（国内 DNS + 走 Proxy 的 DoH fallback），
+// 绝大多数人不用动。分流 DNS 的典型用法：
+//
+//	dns:
+//	  nameserver_policy:
+//	    - match: "+.corp.example.com"   # 公司域名走内网 DNS
+//	      servers: [10.0.0.53]
+//	    - match: "geosite:cn"
+//	      servers: [223.5.5.5]
 type DNSConfig struct {
 	Enabled bool `yaml:"enabled"`
 	Port    int  `yaml:"port"`
+	// EnhancedMode: fake-ip（默认）| redir-host。redir-host 返回真实 IP，
+	// 兼容性好一些，但 TUN 模式下分流要等连接建立后才能按域名判断。
+	// macOS 上开着 TUN 时不能用 redir-host：Mac 的 TUN 只接管 fake-ip 段，
+	// 真实 IP 的连接不进 mihomo（Validate 会拒绝）。
+	EnhancedMode string `yaml:"enhanced_mode,omitempty"`
+	// Nameservers / Fallback 非空时整体替换内置默认。fallback 里国外 DoH
+	// 记得带 #Proxy，否则直连被墙会一直超时。
+	Nameservers []string `yaml:"nameservers,omitempty"`
+	Fallback    []string `yaml:"fallback,omitempty"`
+	// NameserverPolicy 按域名指定上游，按顺序渲染（mihomo 先到先得）。
+	NameserverPolicy []NameserverPolicy `yaml:"nameserver_policy,omitempty"`
+	// FakeIPFilter 追加在内置的 *.lan / *.local 之后：这些域名返回真实 IP 而不是 fake-ip。
+	FakeIPFilter []string `yaml:"fake_ip_filter,omitempty"`
 }
 
+// NameserverPolicy 是一条分流 DNS：Match 是 mihomo 的 nameserver-policy 键
+// （example.com / +.example.com / geosite:cn / rule-set:name）。
+type NameserverPolicy struct {
+	Match   string   `yaml:"match"`
+	Servers []string `yaml:"servers"`
+}
+
+// DNS 增强模式。
+const (
+	DNSModeFakeIP    = "fake-ip"
+	DNSModeRedirHost = "redir-host"
+)
+
 // TrafficConfig is the traffic policy (the "sub" feature).
 type TrafficConfig struct {
 	Mode     string         `yaml:"mode"` // rule | global | direct
@@ -58,8 +125,40 @@ type TrafficConfig struct {
 	// 本字段是把能力补回来。默认 false：升级用户 config 不主动变，想要的用户
 	// 在菜单 [M] → 2 → 自动补全策略组 主动开启。
 	AutoGroups bool `yaml:"auto_groups,omitempty"`
+	// RegionGroups 开启后，subscription / multi / file 源按节点名里的国旗 emoji /
+	// 关键词生成「🇭🇰 香港」「🇺🇸 美国」这类地区 url-test 组，并挂进 Proxy 组。
+	// 组名固定，extras.groups 的 target 可以直接写地区组名。默认 false。
+	RegionGroups bool `yaml:"region_groups,omitempty"`
+	// RuleProviders 是外部规则集（mihomo rule-providers）。内置 rulesets 跟着版本走，
+	// 发版之间会过时；这里声明的规则集由 mihomo 按 interval 自己更新，渲染成
+	// RULE-SET,name,目标 插在自定义规则之后、内置规则集之前。
+	RuleProviders []RuleProvider `yaml:"rule_providers,omitempty"`
+	// AutoPilot 让 supervisor 定时测速、在当前节点持续变差时自动换到更稳的节点。
+	AutoPilot AutoPilotConfig `yaml:"auto_pilot,omitempty"`
+}
+
+// RuleProvider 声明一个 mihomo rule-provider。
+//
+// 规则文件都缓存在 mihomo 工作目录的 rule-providers/ 下：http 类型启动前预拉一份，
+// file 类型把用户文件拷进去（mihomo 只认工作目录里的路径）。断网启动时用缓存，
+// 连缓存都没有就放一个空规则集占位，保证 mihomo 能起来。
+type RuleProvider struct {
+	Name     string `yaml:"name"`               // 规则集名，也是缓存文件名
+	Type     string `yaml:"type"`               // http | file
+	Behavior string `yaml:"behavior"`           // domain | ipcidr | classical
+	Format   string `yaml:"format,omitempty"`   // yaml（默认）| text
+	URL      string `yaml:"url,omitempty"`      // type=http 必填
+	Path     string `yaml:"path,omitempty"`     // type=file 必填：本地规则文件
+	Interval int    `yaml:"interval,omitempty"` // 秒；http 默认 86400（一天）
+	// Target 是命中后的去向：direct / proxy / reject，或任意策略组名（如「🇺🇸 美国」）。
+	Target string `yaml:"target"`
 }
 
+const (
+	RuleProviderHTTP = "http"
+	RuleProviderFile = "file"
+)
+
 // ExtraRules lets the user add custom rules without touching rulesets.
 type ExtraRules struct {
 	Direct []string        `yaml:"direct"`
@@ -86,7 +185,7 @@ type RulesetToggles struct {
 
 // SourceConfig is the proxy source (the "extension" feature).
 type SourceConfig struct {
-	Type         string             `yaml:"type"` // external | subscription | file | remote | none
+	Type         string             `yaml:"type"` // external | subscription | multi | file | remote | none
 	External     ExternalProxy      `yaml:"external"`
 	Subscription SubscriptionSource `yaml:"subscription"`
 	File         FileSource         `yaml:"file"`
@@ -97,8 +196,18 @@ type SourceConfig struct {
 	// ChainResidential 非 nil 时，render 阶段会用 preset 模板生成链式代理脚本，
 	// 覆盖 ScriptPath 指向渲染后的文件。字段为空时不启用链式代理。
 	ChainResidential *ChainResidentialConfig `yaml:"chain_residential,omitempty"`
-	Profiles         []Profile               `yaml:"profiles"`
-	Current          string                  `yaml:"current"`
+	// Subscriptions 是 type=multi 时要合并的多份订阅。每份的 Name 会成为一个
+	// 同名子分组，节点重名时也用它做后缀区分，所以必须互不相同。
+	Subscriptions []SubscriptionSource `yaml:"subscriptions,omitempty"`
+	// ExpiryWarnDays：订阅（机场）到期前多少天开始在 supervisor / 控制台告警，默认 7。
+	// 到期时间来自订阅响应的 subscription-userinfo 头，没这个头的机场不会告警；设成负数关闭到期告警。
+	ExpiryWarnDays int       `yaml:"expiry_warn_days,omitempty"`
+	Profiles       []Profile `yaml:"profiles"`
+	Current        string    `yaml:"current"`
+	// Failover 是备用源链：按顺序写 profiles 里的名字。当前源连续探测失败时
+	// supervisor 依次试这些档案，用第一个通的重新渲染；当前源恢复后自动切回。
+	// 全都不通才退到强制 direct。只在运行时生效，不改写 current。
+	Failover []string `yaml:"failover,omitempty"`
 }
 
 // ChainResidentialConfig 是「链式代理 · 住宅 IP 落地」预设需要的用户填写字段。
@@ -128,11 +237,53 @@ type ExternalProxy struct {
 type SubscriptionSource struct {
 	URL  string `yaml:"url"`
 	Name string `yaml:"name"`
+	// RefreshInterval 是后台定时刷新订阅的间隔（Go duration，如 "6h" / "30m"），
+	// 留空 = 只在启动 / 重载时拉。刷新走 ETag / Last-Modified 条件请求，
+	// 节点没变不会重启 mihomo。
+	RefreshInterval string `yaml:"refresh_interval,omitempty"`
+	// Filter 在生成分组前过滤 / 改名节点，去掉「剩余流量」「官网」这类伪节点。
+	Filter NodeFilter `yaml:"filter,omitempty"`
+}
+
+// NodeFilter 是订阅 / 本地文件源的节点过滤 + 改名规则，按「include → exclude →
+// rename」顺序作用在节点名上。正则用 Go regexp 语法，忽略大小写写 (?i)。
+type NodeFilter struct {
+	Include string       `yaml:"include,omitempty"` // 非空时只保留名字匹配的节点
+	Exclude string       `yaml:"exclude,omitempty"` // 去掉名字匹配的节点，例: "剩余|到期|官网"
+	Rename  []RenameRule `yaml:"rename,omitempty"`  // 依次对保留下来的节点名做正则替换
+}
+
+// RenameRule 是一条节点改名规则：Pattern 匹配到的部分替换成 Replace（支持 $1 引用）。
+type RenameRule struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
+}
+
+// IsZero 报告是否没配任何过滤 / 改名规则。
+func (f NodeFilter) IsZero() bool {
+	return f.Include == "" && f.Exclude == "" && len(f.Rename) == 0
+}
+
+// MinRefreshInterval 是 refresh_interval 的下限，防止手滑写成 "1s" 把机场刷爆。
+const MinRefreshInterval = 5 * time.Minute
+
+// RefreshEvery 解析 RefreshInterval；留空或写错时返回 0（不定时刷新）。
+// 写错的情况 Validate 会先拦下，这里只做兜底。
+func (s SubscriptionSource) RefreshEvery() time.Duration {
+	if s.RefreshInterval == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(s.RefreshInterval)
+	if err != nil || d < MinRefreshInterval {
+		return 0
+	}
+	return d
 }
 
 // FileSource loads a local Clash/mihomo YAML file.
 type FileSource struct {
-	Path string `yaml:"path"`
+	Path   string     `yaml:"path"`
+	Filter NodeFilter `yaml:"filter,omitempty"`
 }
 
 // RemoteProxy is a single remote socks5/http proxy.
@@ -153,6 +304,8 @@ type Profile struct {
 	Subscription *SubscriptionSource `yaml:"subscription,omitempty"`
 	File         *FileSource         `yaml:"file,omitempty"`
 	Remote       *RemoteProxy        `yaml:"remote,omitempty"`
+	// Subscriptions 对应 type=multi；非 nil 时整体替换 source.subscriptions。
+	Subscriptions []SubscriptionSource `yaml:"subscriptions,omitempty"`
 }
 
 // RuntimeConfig groups technical settings (ports, secrets, logging).
@@ -161,6 +314,40 @@ type RuntimeConfig struct {
 	ProxyService ProxyServiceConfig `yaml:"proxy_service"`
 	APISecret    string             `yaml:"api_secret"`
 	LogLevel     string             `yaml:"log_level"`
+	Metrics      MetricsConfig      `yaml:"metrics,omitempty"`
+	Notify       NotifyConfig       `yaml:"notify,omitempty"`
+}
+
+// MetricsConfig 是可选的 Prometheus /metrics 导出器。只在 `gateway start --foreground`
+// （也就是 systemd / launchd 服务）里跑：那是唯一一个长期在线的 gateway 进程。
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Listen 默认 0.0.0.0:19100 —— Prometheus 一般跑在另一台机器上；只想本机抓就写 127.0.0.1:19100。
+	Listen string `yaml:"listen,omitempty"`
+	// DelayGroup 是后台定时测速、导出节点延迟的策略组，默认 Proxy。
+	DelayGroup string `yaml:"delay_group,omitempty"`
+}
+
+// 导出器默认值。
+const (
+	DefaultMetricsListen = "0.0.0.0:19100"
+	DefaultDelayGroup    = "Proxy"
+)
+
+// ListenAddr 返回监听地址，没填用 DefaultMetricsListen。
+func (m MetricsConfig) ListenAddr() string {
+	if m.Listen == "" {
+		return DefaultMetricsListen
+	}
+	return m.Listen
+}
+
+// Group 返回测速组，没填用 DefaultDelayGroup。
+func (m MetricsConfig) Group() string {
+	if m.DelayGroup == "" {
+		return DefaultDelayGroup
+	}
+	return m.DelayGroup
 }
 
 // RuntimePorts are the listen ports exposed by mihomo.
@@ -203,7 +390,7 @@ func Default() *Config {
 			// 实在受不了的用户可以在菜单切到 forward 模式（端口模式）。
 			Mode: GatewayModeTUN,
 			TUN:  TUNConfig{Enabled: true, BypassLocal: false},
-			DNS:  DNSConfig{Enabled: true, Port: 53},
+			DNS:  DNSConfig{Enabled: true, Port: 53, EnhancedMode: DNSModeFakeIP},
 		},
 		Traffic: TrafficConfig{
 			Mode:    ModeRule,
@@ -245,6 +432,7 @@ const (
 const (
 	SourceTypeExternal     = "external"
 	SourceTypeSubscription = "subscription"
+	SourceTypeMulti        = "multi"
 	SourceTypeFile         = "file"
 	SourceTypeRemote       = "remote"
 	SourceTypeNone         = "none"
@@ -41,6 +41,12 @@ func Run(ctx context.Context, a *app.App) error {
 	}
 	// 拉起代理源健康 supervisor：mihomo 在跑时自动体检，挂了切 direct 保命。
 	a.StartSupervisor(runCtx)
+	// 配了 refresh_interval 的订阅在后台定时刷新，节点变了才热重载。
+	a.StartSubscriptionRefresher(runCtx)
+	// 设备定时策略（家长控制）到窗口边界时重渲染 + 热重载。
+	a.StartDeviceScheduler(runCtx)
+	// 按设备记流量（`gateway stats devices` 看历史），菜单关了也不丢。
+	a.StartTrafficCollector(runCtx)
 	return c.main(runCtx)
 }
 
@@ -65,6 +71,9 @@ type consoleUI struct {
 	// 终端图表：网速柱状图（每帧 push 下行速率）+ 每分钟测速健康条。
 	spark  *sparkline
 	health *healthBar
+
+	// live 是 mihomo websocket 推送（/traffic、/connections），首页靠它驱动重绘。
+	live *liveFeed
 }
 
 func newConsole(a *app.App, in io.Reader, out io.Writer) *consoleUI {
@@ -74,6 +83,7 @@ func newConsole(a *app.App, in io.Reader, out io.Writer) *consoleUI {
 		out:    out,
 		spark:  newSparkline(40),
 		health: newHealthBar(60),
+		live:   newLiveFeed(),
 	}
 	// 打 country.mmdb。文件来自 EnsureGeodata；没下来就留 nil，Lookup 安全降级。
 	if a != nil && a.Engine != nil {
@@ -17,6 +17,8 @@ import (
 	"github.com/tght/lan-proxy-gateway/internal/geoip"
 	"github.com/tght/lan-proxy-gateway/internal/ipinfo"
 	"github.com/tght/lan-proxy-gateway/internal/source"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
 // ipinfoTTL 是真实出口查询的缓存有效期。ipinfo.io 免费版 1000 次/天，30 秒
@@ -77,6 +79,10 @@ type dashboardSnapshot struct {
 
 	// 设备聚合
 	devices []deviceRow
+
+	// 机场订阅流量 / 到期（subscription-userinfo），读盘得来，mihomo 没跑也能显示。
+	subscriptions []source.SubscriptionInfo
+	quotaWarnings []string
 }
 
 // proxyHop 表示一个代理位置点。
@@ -97,10 +103,12 @@ type deviceRow struct {
 
 // fetchDashboardSnapshot 并发拉 mihomo 的 /connections + /proxies，合并成
 // 一个 snapshot。全流程 2s 超时包干；任一步失败时返回 ok=false 让 render
-// 显示占位提示，不崩。
+// 显示占位提示，不崩。pushed 非 nil 时是 websocket 刚推来的连接快照，直接用，
+// 不再 REST 拉一遍。
 func fetchDashboardSnapshot(
 	ctx context.Context,
 	cli *engine.Client,
+	pushed *engine.ConnectionsSnapshot,
 	cfg *config.Config,
 	localIP string,
 	geo *geoip.DB,
@@ -124,8 +132,13 @@ func fetchDashboardSnapshot(
 		cErr, gErr error
 	)
 	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() { defer wg.Done(); conns, cErr = cli.GetConnections(fetchCtx) }()
+	if pushed != nil {
+		conns = pushed
+	} else {
+		wg.Add(1)
+		go func() { defer wg.Done(); conns, cErr = cli.GetConnections(fetchCtx) }()
+	}
+	wg.Add(1)
 	go func() { defer wg.Done(); groups, gErr = cli.ListProxyGroups(fetchCtx) }()
 	wg.Wait()
 
@@ -339,20 +352,9 @@ func takeoffFromSource(cfg *config.Config) proxyHop {
 	}
 }
 
-// extractFlag 从节点名里扫一段 2 位 regional indicator（国旗 emoji）。没找到
-// 返回空串。机场节点名基本都带 🇭🇰/🇯🇵 前缀；也兼容中间出现的形式。
+// extractFlag 从节点名里扫国旗 emoji。解析逻辑和 source 的地区分组共用一份。
 func extractFlag(name string) string {
-	runes := []rune(name)
-	for i := 0; i < len(runes)-1; i++ {
-		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
-			return string(runes[i : i+2])
-		}
-	}
-	return ""
-}
-
-func isRegionalIndicator(r rune) bool {
-	return r >= 0x1F1E6 && r <= 0x1F1FF
+	return source.ExtractFlag(name)
 }
 
 // drawDashboard 把 snapshot 画到终端。保持紧凑：没 mihomo / 拉取失败时也给一
@@ -371,6 +373,12 @@ func drawDashboard(w io.Writer, snap dashboardSnapshot, running bool, spark *spa
 
 	// 即便 ok=false 也给两行基础信息，再加一行错误
 	fmt.Fprintf(w, "  本机 IP: %s    代理源: %s\n", nonEmpty(snap.localIP, "<未检测>"), snap.proxySrc)
+	for _, sub := range snap.subscriptions {
+		dimC.Fprintf(w, "  📦 %s · %s\n", sub.Name, sub.Summary(time.Now()))
+	}
+	for _, q := range snap.quotaWarnings {
+		warnC.Fprintf(w, "  ⚠ %s\n", q)
+	}
 
 	if !snap.ok {
 		fmt.Fprintln(w)
@@ -537,19 +545,10 @@ func nonEmpty(a, b string) string {
 	return b
 }
 
-// humanBytes 把字节/速率格式化成 1.2 KB / 3.4 MB / 2.3 GB。仪表盘足够用，
-// 不用 SI vs IEC 纠结，统一 1024。
+// humanBytes 把字节/速率格式化成仪表盘用的定宽列：数字本身走 stats.FormatBytes，
+// 和 CLI 看到的一致，这里只负责右对齐。
 func humanBytes(n float64) string {
-	if n < 1024 {
-		return fmt.Sprintf("%4.0f  B", n)
-	}
-	if n < 1024*1024 {
-		return fmt.Sprintf("%5.1f KB", n/1024)
-	}
-	if n < 1024*1024*1024 {
-		return fmt.Sprintf("%5.1f MB", n/(1024*1024))
-	}
-	return fmt.Sprintf("%5.2f GB", n/(1024*1024*1024))
+	return fmt.Sprintf("%8s", stats.FormatBytes(int64(n)))
 }
 
 func probeMixedPort(localIP string, port int, timeout time.Duration) bool {
@@ -607,11 +606,12 @@ func isLocalhost(ip string) bool {
 	return false
 }
 
-// screenDeviceLabels 管理 IP → 设备名映射。A 添加 / D 删除 / 0 返回。
-// 变动立刻写回 gateway.yaml（不重启 mihomo；仪表盘下一帧就会看到新名字）。
-func (c *consoleUI) screenDeviceLabels() {
+// screenDeviceLabels 管理 IP → 设备名映射，以及按设备的强制出口策略。
+// A 添加标签 / D 删除标签：立刻写回 gateway.yaml（不重启 mihomo；仪表盘下一帧就会看到新名字）。
+// P 添加策略 / X 删除策略：策略要进 mihomo 规则，保存后走热重载。
+func (c *consoleUI) screenDeviceLabels(ctx context.Context) {
 	for {
-		c.banner("给 IP 起名字 · 设备标签")
+		c.banner("设备标签 · 设备策略")
 		dimC.Fprintln(c.out, "  路由器不报 hostname 的设备（PS5、智能电视、老 Android）可以手动打标签。")
 		dimC.Fprintln(c.out, "  打过标签的会覆盖反向 DNS，优先显示。")
 		fmt.Fprintln(c.out)
@@ -633,13 +633,33 @@ func (c *consoleUI) screenDeviceLabels() {
 		}
 
 		fmt.Fprintln(c.out)
-		titleC.Fprintln(c.out, "  ── 操作 ── A 添加   D <编号> 删除   0 返回（或按 Q）")
+		policies := c.app.Cfg.Gateway.DevicePolicies
+		if len(policies) == 0 {
+			dimC.Fprintln(c.out, "  （还没有设备策略：P 可以让某台设备永远直连 / 走指定策略组 / 断网）")
+		} else {
+			titleC.Fprintln(c.out, "  #   设备                出口")
+			for i, p := range policies {
+				who := p.Key()
+				if name := labels[p.IP]; name != "" {
+					who += " (" + name + ")"
+				}
+				fmt.Fprintf(c.out, "  %2d  %-19s %s\n", i+1, who, traffic.ProviderTarget(p.Target))
+			}
+			if c.app.Cfg.Traffic.Mode != config.ModeRule {
+				warnC.Fprintln(c.out, "  当前不是规则模式，设备策略暂不生效（mihomo 只在 rule 模式下看规则）")
+			}
+		}
+
+		fmt.Fprintln(c.out)
+		titleC.Fprintln(c.out, "  ── 操作 ── A 加标签   D <编号> 删标签   P 加策略   X <编号> 删策略   0 返回（或按 Q）")
 		input := strings.ToLower(strings.TrimSpace(c.prompt("选择：> ")))
 		switch {
 		case input == "" || input == "0" || input == "q":
 			return
 		case input == "a":
 			c.addDeviceLabel()
+		case input == "p":
+			c.addDevicePolicy(ctx)
 		case strings.HasPrefix(input, "d"):
 			numStr := strings.TrimSpace(strings.TrimPrefix(input, "d"))
 			idx, err := strconv.Atoi(numStr)
@@ -653,12 +673,69 @@ func (c *consoleUI) screenDeviceLabels() {
 			} else {
 				okC.Fprintf(c.out, "  ✓ 已删 %s\n", ips[idx-1])
 			}
+		case strings.HasPrefix(input, "x"):
+			numStr := strings.TrimSpace(strings.TrimPrefix(input, "x"))
+			idx, err := strconv.Atoi(numStr)
+			if err != nil || idx < 1 || idx > len(policies) {
+				warnC.Fprintln(c.out, "无效编号（格式: x 2 或 x2）")
+				continue
+			}
+			removed := policies[idx-1]
+			c.app.Cfg.Gateway.DevicePolicies = append(policies[:idx-1:idx-1], policies[idx:]...)
+			c.saveAndMaybeReload(ctx, fmt.Sprintf("  ✓ 已删 %s 的设备策略", removed.Key()))
 		default:
 			warnC.Fprintln(c.out, "无效操作")
 		}
 	}
 }
 
+// addDevicePolicy 引导式添加一条设备策略。设备可以填 IP / CIDR 或 MAC；同一设备
+// 已有策略时直接覆盖它的出口（和标签的「改名」一个思路）。
+func (c *consoleUI) addDevicePolicy(ctx context.Context) {
+	who := strings.TrimSpace(c.ask("  设备 IP / 网段 / MAC（例如 192.168.1.23 或 aa:bb:cc:dd:ee:ff）", ""))
+	if who == "" {
+		return
+	}
+	var p config.DevicePolicy
+	if _, err := net.ParseMAC(who); err == nil {
+		p.MAC = strings.ToLower(who)
+	} else {
+		p.IP = who
+	}
+	fmt.Fprintln(c.out, "  出口：1 直连 DIRECT   2 代理 Proxy   3 断网 REJECT   或直接输入策略组名")
+	switch target := strings.TrimSpace(c.ask("  选择", "1")); target {
+	case "1":
+		p.Target = "direct"
+	case "2":
+		p.Target = "proxy"
+	case "3":
+		p.Target = "reject"
+	default:
+		p.Target = target
+	}
+
+	next := make([]config.DevicePolicy, 0, len(c.app.Cfg.Gateway.DevicePolicies)+1)
+	replaced := false
+	for _, old := range c.app.Cfg.Gateway.DevicePolicies {
+		if strings.EqualFold(old.Key(), p.Key()) {
+			old.Target = p.Target
+			replaced = true
+		}
+		next = append(next, old)
+	}
+	if !replaced {
+		next = append(next, p)
+	}
+	prev := c.app.Cfg.Gateway.DevicePolicies
+	c.app.Cfg.Gateway.DevicePolicies = next
+	if err := config.Validate(c.app.Cfg); err != nil {
+		c.app.Cfg.Gateway.DevicePolicies = prev
+		warnC.Fprintf(c.out, "  %v，取消\n", err)
+		return
+	}
+	c.saveAndMaybeReload(ctx, fmt.Sprintf("  ✓ %s → %s", p.Key(), traffic.ProviderTarget(p.Target)))
+}
+
 // addDeviceLabel 引导式添加一条 IP → 名字。IP 做一次 net.ParseIP 校验；重复
 // IP 会直接覆盖老名字（没有二次确认，用户意图就是「改名」）。
 func (c *consoleUI) addDeviceLabel() {
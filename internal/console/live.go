@@ -0,0 +1,92 @@
+package console
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
+)
+
+// liveFresh 是推送数据被当作「还在推」的时限。超过这个时间没收到新消息（mihomo
+// 没跑 / 断线重连中），仪表盘退回每 2 秒轮询 /connections 的老路。
+const liveFresh = 3 * time.Second
+
+// liveFeed 订阅 mihomo 的 /traffic 和 /connections 推送，让首页仪表盘由数据驱动
+// 重绘：速率直接用 /traffic 每秒推来的值（比两次轮询做差准），网速柱每秒一格；
+// 设备表用 /connections 推来的快照。订阅 goroutine 写、主 loop 读，mu 保护。
+type liveFeed struct {
+	mu        sync.Mutex
+	traffic   engine.TrafficTick
+	trafficAt time.Time
+	conns     *engine.ConnectionsSnapshot
+	connsAt   time.Time
+
+	// updates 在收到新的连接快照时非阻塞地通知主 loop 重绘。
+	updates chan struct{}
+}
+
+func newLiveFeed() *liveFeed {
+	return &liveFeed{updates: make(chan struct{}, 1)}
+}
+
+// run 订阅推送直到 ctx 结束。spark 每收到一次 /traffic 推一格下行速率。
+func (l *liveFeed) run(ctx context.Context, cli *engine.Client, spark *sparkline) {
+	traffic := cli.StreamTraffic(ctx)
+	conns := cli.StreamConnections(ctx, 2*time.Second)
+	for traffic != nil || conns != nil {
+		select {
+		case t, ok := <-traffic:
+			if !ok {
+				traffic = nil
+				continue
+			}
+			l.mu.Lock()
+			l.traffic, l.trafficAt = t, time.Now()
+			l.mu.Unlock()
+			if spark != nil {
+				spark.push(float64(t.Down))
+			}
+		case s, ok := <-conns:
+			if !ok {
+				conns = nil
+				continue
+			}
+			l.mu.Lock()
+			l.conns, l.connsAt = s, time.Now()
+			l.mu.Unlock()
+			select {
+			case l.updates <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// latestTraffic 返回最近一次 /traffic 推送；ok=false 表示推送没在工作。
+func (l *liveFeed) latestTraffic(now time.Time) (engine.TrafficTick, bool) {
+	if l == nil {
+		return engine.TrafficTick{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.traffic, !l.trafficAt.IsZero() && now.Sub(l.trafficAt) < liveFresh
+}
+
+// latestConns 返回最近一次 /connections 推送；过期时返回 nil。
+func (l *liveFeed) latestConns(now time.Time) *engine.ConnectionsSnapshot {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.connsAt.IsZero() || now.Sub(l.connsAt) >= liveFresh {
+		return nil
+	}
+	return l.conns
+}
+
+// streaming 报告连接快照是否还在推；在推时主 loop 不再靠定时器重绘。
+func (l *liveFeed) streaming(now time.Time) bool {
+	return l.latestConns(now) != nil
+}
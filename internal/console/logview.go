@@ -5,6 +5,8 @@ import (
 	"io"
 	"regexp"
 	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
 )
 
 // mihomo warning/error 行里最常见的格式：
@@ -153,6 +155,19 @@ func parseMihomoLine(line string) (timeHMS, level, msg string, ok bool) {
 	return
 }
 
+// logMsgEscaper 按 mihomo 写日志文件的方式转义 msg 里的反斜杠和引号。
+var logMsgEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// mihomoLogTime 是 mihomo 日志文件里 time="…" 的格式（小数秒固定 9 位，解析时按 '.' 截掉）。
+const mihomoLogTime = "2006-01-02T15:04:05.000000000Z07:00"
+
+// mihomoLogLine 把 /logs 推来的一条日志拼回日志文件的行格式，好让推送和读文件
+// 共用同一套 humanize / 折叠逻辑。
+func mihomoLogLine(e engine.LogEntry) string {
+	return fmt.Sprintf(`time="%s" level=%s msg="%s"`,
+		e.Time.Format(mihomoLogTime), e.Type, logMsgEscaper.Replace(e.Payload))
+}
+
 // extractQuoted 从 `"xxx"...` 里取 xxx。
 func extractQuoted(s string) string {
 	if !strings.HasPrefix(s, `"`) {
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/engine"
 )
 
 func TestHumanizeMihomoLine_CommonCases(t *testing.T) {
@@ -99,3 +102,15 @@ func TestLineDeduperFlushAtEnd(t *testing.T) {
 		t.Fatalf("Flush should emit summary for tail duplicates:\n%s", buf.String())
 	}
 }
+
+func TestMihomoLogLineRoundTrip(t *testing.T) {
+	e := engine.LogEntry{
+		Time:    time.Date(2026, 4, 21, 1, 27, 55, 0, time.Local),
+		Type:    "warning",
+		Payload: `[TCP] dial Proxy (match Match/) 192.168.1.20:5000 --> "x.com":443 error: a\b`,
+	}
+	ts, level, msg, ok := parseMihomoLine(mihomoLogLine(e))
+	if !ok || ts != "01:27:55" || level != "warning" || msg != e.Payload {
+		t.Fatalf("got time=%q level=%q msg=%q ok=%v", ts, level, msg, ok)
+	}
+}
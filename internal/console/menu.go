@@ -12,13 +12,18 @@ import (
 
 // --- Main loop: 仪表盘首页 ---
 //
-// 默认只画 dashboard（实时速率 / 累计 / 起飞落地 / 设备表），每 2 秒自动刷。
+// 默认只画 dashboard（实时速率 / 累计 / 起飞落地 / 设备表）。mihomo 的 websocket
+// 推送在工作时每来一份连接快照重绘一次，推送断了退回每 2 秒轮询重绘。
 // 旧的多级菜单藏到 [M] 键后面，避免首屏被操作项淹没。
 // 快捷键：M 菜单、N 切节点、T 重测代理源、Q 退出控制台（网关留后台）。
 
 func (c *consoleUI) main(ctx context.Context) error {
 	// 后台每分钟测一次主出口组延迟，记进健康条；ctx 取消时自动退出。
 	go c.runHealthTicker(ctx)
+	// 订阅 /traffic + /connections 推送；mihomo 没跑时订阅自己退避重连。
+	if c.app.Engine != nil && c.app.Engine.API() != nil {
+		go c.live.run(ctx, c.app.Engine.API(), c.spark)
+	}
 
 	// 实时首页：每 2 秒自动重绘仪表盘，网速柱 / 健康条 / 速率随之滚动「活」起来，
 	// 一有输入立刻让位处理命令。输入用一个常驻读取 goroutine 喂进 channel，与刷新
@@ -38,13 +43,22 @@ func (c *consoleUI) main(ctx context.Context) error {
 		}
 
 		var raw string
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-refresh.C:
-			continue // 定时自动重绘，让图表动起来
-		case raw = <-lines:
-			pendingRead = false
+		redraw := false
+		for !redraw && raw == "" && pendingRead {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-c.live.updates:
+				redraw = true // 推送来了新快照
+			case <-refresh.C:
+				// 推送在工作时定时器只是兜底，不重复重绘。
+				redraw = !c.live.streaming(time.Now())
+			case raw = <-lines:
+				pendingRead = false
+			}
+		}
+		if redraw {
+			continue
 		}
 
 		raw = strings.TrimSpace(raw)
@@ -91,10 +105,20 @@ func (c *consoleUI) drawDashboardOnce(ctx context.Context) {
 	if running {
 		cli = c.app.Engine.API()
 	}
-	snap := fetchDashboardSnapshot(ctx, cli, c.app.Cfg, localIP, c.geo, c.resolver, &c.dashState)
+	now := time.Now()
+	var pushed *engine.ConnectionsSnapshot
+	if running {
+		pushed = c.live.latestConns(now)
+	}
+	snap := fetchDashboardSnapshot(ctx, cli, pushed, c.app.Cfg, localIP, c.geo, c.resolver, &c.dashState)
+	snap.subscriptions = c.app.SubscriptionInfo()
+	snap.quotaWarnings = c.app.QuotaWarnings(now)
 
-	// 把本帧下行速率 push 进网速柱状图（拉不到数据 downRate 为 0，自然画成空柱）。
-	if c.spark != nil {
+	// /traffic 在推时速率以它为准，网速柱也由推送 goroutine 每秒填一格；推送断了
+	// 才把本帧轮询算出的下行速率 push 进去（拉不到数据 downRate 为 0，画成空柱）。
+	if t, ok := c.live.latestTraffic(now); ok && snap.ok {
+		snap.downRate, snap.upRate = float64(t.Down), float64(t.Up)
+	} else if c.spark != nil {
 		c.spark.push(snap.downRate)
 	}
 
@@ -112,7 +136,10 @@ func (c *consoleUI) drawDashboardOnce(ctx context.Context) {
 		}
 	}
 	h := c.app.Health()
-	if h.FallbackActive {
+	if h.ActiveSource != "" && !h.FallbackActive {
+		warnC.Fprintln(c.out)
+		warnC.Fprintf(c.out, "  ⚠ 主源异常 · 已切到备用源 %s：%s\n", h.ActiveSource, h.LastError)
+	} else if h.FallbackActive {
 		badC.Fprintln(c.out)
 		badC.Fprintf(c.out, "  ⚠ 代理源异常 · 已临时切直连：%s\n", h.LastError)
 	} else if !h.Healthy && h.LastError != "" {
@@ -132,7 +159,7 @@ func (c *consoleUI) screenMenu(ctx context.Context) (exitConsole bool) {
 		choice := strings.ToLower(c.readLine())
 		switch choice {
 		case "1":
-			c.screenGateway()
+			c.screenGateway(ctx)
 		case "2":
 			c.screenTraffic(ctx)
 		case "3":
@@ -140,7 +167,7 @@ func (c *consoleUI) screenMenu(ctx context.Context) (exitConsole bool) {
 		case "4":
 			c.screenLifecycle(ctx)
 		case "5":
-			c.screenLogs()
+			c.screenLogs(ctx)
 		case "6":
 			if c.shutdownGateway() {
 				return true
@@ -189,10 +216,17 @@ func (c *consoleUI) printStatus() {
 	}
 	fmt.Fprintf(c.out, "    本机 IP: %s\n", ip)
 	fmt.Fprintf(c.out, "  代理源: %s\n", sourceLabel(s.Source))
+	for _, sub := range s.Subscriptions {
+		dimC.Fprintf(c.out, "    %s · %s\n", sub.Name, sub.Summary(time.Now()))
+	}
 
 	// 代理源异常 → supervisor 已切 direct 保证 LAN 通网，但要让用户一眼看到。
 	h := c.app.Health()
-	if h.FallbackActive {
+	if h.ActiveSource != "" && !h.FallbackActive {
+		warnC.Fprintf(c.out, "  ⚠ 主源异常 · 已切到备用源 %s\n", h.ActiveSource)
+		warnC.Fprintf(c.out, "    原因: %s\n", h.LastError)
+		dimC.Fprintln(c.out, "    主源恢复后会自动切回")
+	} else if h.FallbackActive {
 		badC.Fprintln(c.out, "  ⚠ 代理源异常 · 已临时切到直连（LAN 设备不会断网，但不再走代理）")
 		badC.Fprintf(c.out, "    原因: %s\n", h.LastError)
 		dimC.Fprintln(c.out, "    修复后会自动切回；想立刻重试去「代理 & 订阅 → T 重新测试」")
@@ -201,6 +235,26 @@ func (c *consoleUI) printStatus() {
 		warnC.Fprintf(c.out, "    原因: %s\n", h.LastError)
 		dimC.Fprintln(c.out, "    本机单点代理不会因探测失败自动切直连，避免影响正在使用的链路。")
 	}
+	if n := len(h.Transitions); n > 0 {
+		t := h.Transitions[n-1]
+		dimC.Fprintf(c.out, "    最近一次切源: %s  %s → %s（%s）\n", t.At.Format("01-02 15:04"), t.From, t.To, t.Reason)
+	}
+	// 机场流量快用完 / 快到期：supervisor 没跑（纯 CLI 场景）时直接按文件现算。
+	quota := h.QuotaWarnings
+	if h.CheckedAt.IsZero() {
+		quota = c.app.QuotaWarnings(time.Now())
+	}
+	for _, w := range quota {
+		warnC.Fprintf(c.out, "  ⚠ %s\n", w)
+	}
+	for _, w := range s.RenderWarnings {
+		warnC.Fprintf(c.out, "  ⚠ %s\n", w)
+	}
+	if n := len(s.Crashes); n > 0 {
+		warnC.Fprintf(c.out, "  ⚠ mihomo 最近 24 小时意外退出 %d 次（看门狗会自动重启），最近一次 %s\n",
+			n, s.Crashes[n-1].At.Format("01-02 15:04"))
+		dimC.Fprintln(c.out, "    崩溃时的日志: gateway logs --crashes")
+	}
 
 	admin, _ := c.app.Plat.IsAdmin()
 	if !admin {
@@ -225,6 +279,8 @@ func sourceLabel(s string) string {
 		return "单点代理（本机，external）"
 	case config.SourceTypeSubscription:
 		return "机场订阅（subscription）"
+	case config.SourceTypeMulti:
+		return "多订阅合并（multi）"
 	case config.SourceTypeFile:
 		return "本地配置文件（file）"
 	case config.SourceTypeRemote:
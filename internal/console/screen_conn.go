@@ -0,0 +1,110 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/app"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// connPageSize 是连接页一屏最多列多少条；再多就得靠 F 过滤，翻页在这种输入式
+// 菜单里不好用。
+const connPageSize = 30
+
+// screenConnections 列出活跃连接，可以按编号关单条、按设备整批关。
+func (c *consoleUI) screenConnections(ctx context.Context) {
+	var filter app.ConnFilter
+	for {
+		title := "活跃连接"
+		if !filter.Empty() {
+			title += "  ·  过滤: " + strings.TrimSpace(filter.Device+" "+filter.Host)
+		}
+		c.banner(title)
+		fetchCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		list, err := c.app.Connections(fetchCtx, filter)
+		cancel()
+		if err != nil {
+			badC.Fprintf(c.out, "  拉取连接失败：%v\n", err)
+			c.pause()
+			return
+		}
+		if len(list) == 0 {
+			dimC.Fprintln(c.out, "  没有匹配的活跃连接")
+		}
+		shown := list
+		if len(shown) > connPageSize {
+			shown = shown[:connPageSize]
+		}
+		for i, ci := range shown {
+			fmt.Fprintf(c.out, "  %2d  %s %s %s\n", i+1,
+				padRightWide(truncate(connSource(ci), 22), 22), padRight(ci.Network, 4), truncate(ci.Host, 40))
+			dimC.Fprintf(c.out, "      %s  ·  %s  ·  %s  ↑%s ↓%s\n",
+				truncate(ci.Rule, 32), ci.Chain, ci.Age,
+				stats.FormatBytes(ci.Upload), stats.FormatBytes(ci.Download))
+		}
+		if len(list) > len(shown) {
+			dimC.Fprintf(c.out, "  … 还有 %d 条没列出，按 F 过滤\n", len(list)-len(shown))
+		}
+
+		fmt.Fprintln(c.out)
+		titleC.Fprint(c.out, "  ── 操作 ── ")
+		fmt.Fprintln(c.out, "<编号> 关闭该连接   D 关闭某设备全部连接   F 过滤   R 刷新   0 返回（或按 Q）")
+		input := strings.ToLower(strings.TrimSpace(c.prompt("选择：> ")))
+		switch input {
+		case "", "0", "q":
+			return
+		case "r":
+			continue
+		case "f":
+			filter.Device = strings.TrimSpace(c.ask("  设备（IP 或名字，留空不限）", filter.Device))
+			filter.Host = strings.TrimSpace(c.ask("  域名（子串或 *.example.com，留空不限）", filter.Host))
+		case "d":
+			device := strings.TrimSpace(c.ask("  设备（IP 或名字）", filter.Device))
+			if device == "" {
+				continue
+			}
+			devList, err := c.app.Connections(ctx, app.ConnFilter{Device: device})
+			if err != nil {
+				badC.Fprintln(c.out, err.Error())
+				continue
+			}
+			if len(devList) == 0 {
+				warnC.Fprintf(c.out, "%s 没有活跃连接\n", device)
+				continue
+			}
+			if !c.yesNo(fmt.Sprintf("  确认关闭 %s 的 %d 条连接？", device, len(devList)), false) {
+				continue
+			}
+			ids, _, _ := app.MatchConnIDs(devList, nil)
+			c.closeConnections(ctx, ids)
+		default:
+			idx, err := strconv.Atoi(input)
+			if err != nil || idx < 1 || idx > len(shown) {
+				warnC.Fprintln(c.out, "无效选项（按编号关闭 / D 按设备 / F 过滤 / R 刷新 / 0 返回）")
+				continue
+			}
+			c.closeConnections(ctx, []string{shown[idx-1].ID})
+		}
+	}
+}
+
+func (c *consoleUI) closeConnections(ctx context.Context, ids []string) {
+	n, err := c.app.CloseConnections(ctx, ids)
+	if err != nil {
+		warnC.Fprintf(c.out, "已关闭 %d / %d 条，有的失败了（可能已经自己结束）：%v\n", n, len(ids), err)
+		return
+	}
+	okC.Fprintf(c.out, "已关闭 %d 条连接\n", n)
+}
+
+// connSource 是连接列表里的来源列：有名字时「名字(IP)」，否则 IP。
+func connSource(ci app.ConnInfo) string {
+	if ci.Name != "" {
+		return ci.Name + "(" + ci.Source + ")"
+	}
+	return ci.Source
+}
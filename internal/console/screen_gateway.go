@@ -1,6 +1,7 @@
 package console
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -12,7 +13,7 @@ import (
 
 // --- Screens ---
 
-func (c *consoleUI) screenGateway() {
+func (c *consoleUI) screenGateway(ctx context.Context) {
 	for {
 		c.banner("设备接入指引")
 		_ = c.app.Gateway.Detect()
@@ -24,10 +25,10 @@ func (c *consoleUI) screenGateway() {
 		// 自相矛盾。
 		if runtime.GOOS == "windows" {
 			fmt.Fprintln(c.out)
-			titleC.Fprintln(c.out, "  ── 操作 ── T 给 IP 起名字   0 返回（或按 Q）")
+			titleC.Fprintln(c.out, "  ── 操作 ── T 设备名字 / 策略   0 返回（或按 Q）")
 			switch strings.ToLower(strings.TrimSpace(c.prompt("选择：> "))) {
 			case "t":
-				c.screenDeviceLabels()
+				c.screenDeviceLabels(ctx)
 			case "", "0", "q":
 				return
 			default:
@@ -44,7 +45,7 @@ func (c *consoleUI) screenGateway() {
 			dimC.Fprintln(c.out, "\n  本机 DNS: 默认")
 		}
 		fmt.Fprintln(c.out)
-		titleC.Fprintln(c.out, "  ── 操作 ── L 切本机 DNS   R 恢复 DNS   T 标记设备   0 返回（或按 Q）")
+		titleC.Fprintln(c.out, "  ── 操作 ── L 切本机 DNS   R 恢复 DNS   T 设备名字 / 策略   0 返回（或按 Q）")
 
 		switch strings.ToLower(strings.TrimSpace(c.prompt("选择：> "))) {
 		case "l":
@@ -70,7 +71,7 @@ func (c *consoleUI) screenGateway() {
 				okC.Fprintln(c.out, "  ✓ 已恢复系统默认 DNS")
 			}
 		case "t":
-			c.screenDeviceLabels()
+			c.screenDeviceLabels(ctx)
 		case "", "0", "q":
 			return
 		default:
@@ -160,7 +160,7 @@ func (c *consoleUI) cleanupStaleMihomo() {
 	}
 }
 
-func (c *consoleUI) screenLogs() {
+func (c *consoleUI) screenLogs(ctx context.Context) {
 	path := c.app.Engine.LogPath()
 	tailN := 30
 	rawMode := false // 默认走易读视图；r 切换回原始 mihomo 行
@@ -181,7 +181,11 @@ func (c *consoleUI) screenLogs() {
 		case strings.EqualFold(input, "q") || strings.EqualFold(input, "quit"):
 			return
 		case strings.EqualFold(input, "t") || strings.EqualFold(input, "tail"):
-			c.tailFollow(path, tailN, rawMode)
+			if c.app.Engine.Running() {
+				c.streamFollow(ctx, path, tailN, rawMode)
+			} else {
+				c.tailFollow(path, tailN, rawMode)
+			}
 		case strings.EqualFold(input, "r") || strings.EqualFold(input, "raw"):
 			rawMode = !rawMode
 		default:
@@ -223,6 +227,45 @@ func (c *consoleUI) renderTail(path string, n int, rawMode bool) error {
 	return nil
 }
 
+// streamFollow 是 mihomo 在跑时的实时跟随：先打印日志文件末尾 n 行做上下文，
+// 之后订阅 /logs 推送，来一条打一条，不再轮询文件。按回车退出。
+func (c *consoleUI) streamFollow(ctx context.Context, path string, n int, rawMode bool) {
+	c.banner("日志 · 实时推送")
+	_ = c.renderTail(path, n, rawMode)
+	dimC.Fprintln(c.out, "\n（实时跟踪中，按回车退出）")
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	entries := c.app.Engine.API().StreamLogs(streamCtx, "info")
+	done := make(chan struct{})
+	go func() {
+		_ = c.readLine()
+		close(done)
+	}()
+
+	var dd *lineDeduper
+	if !rawMode {
+		dd = newLineDeduper(c.out)
+		defer dd.Flush()
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case e, ok := <-entries:
+			if !ok {
+				return
+			}
+			if rawMode {
+				fmt.Fprintln(c.out, mihomoLogLine(e))
+				continue
+			}
+			formatted, key, t := humanizeMihomoLineWithKey(mihomoLogLine(e))
+			dd.Write(formatted, key, t)
+		}
+	}
+}
+
 // tailFollow 进入实时跟随模式：先打印末尾 n 行做上下文，然后轮询文件 size 把
 // 新增字节流写到终端，按回车退出。文件被截断（mihomo rotate/重启）时重置 offset。
 // rawMode=false 时按行缓冲并逐行 humanize。
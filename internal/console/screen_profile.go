@@ -0,0 +1,80 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// screenProfiles 是「源档案」页：列出已存的档案，按编号一键切换。
+// 切换走 app.UseProfile（存盘 + 热重载），和 `gateway profile use` 同一条路径。
+func (c *consoleUI) screenProfiles(ctx context.Context) {
+	for {
+		c.banner("源档案  ·  当前: " + firstNonEmpty(c.app.Cfg.Source.Current, "(未使用档案)"))
+		profiles := c.app.Profiles()
+		if len(profiles) == 0 {
+			dimC.Fprintln(c.out, "  还没有档案。按 A 把当前代理源存成一个，之后就能一键切回来。")
+		}
+		for i, p := range profiles {
+			mark := " "
+			if p.Name == c.app.Cfg.Source.Current {
+				mark = okC.Sprint("●")
+			}
+			fmt.Fprintf(c.out, "  %s %2d  %s  %s  %s\n",
+				mark, i+1, padRightWide(p.Name, 14), padRightWide(p.Type, 12), dimC.Sprint(homeAbbrev(p.Summary())))
+		}
+
+		fmt.Fprintln(c.out)
+		titleC.Fprint(c.out, "  ── 操作 ── ")
+		fmt.Fprintln(c.out, "<编号> 切到该档案   A 存当前源为档案   D 删除档案   0 返回（或按 Q）")
+		input := strings.ToLower(strings.TrimSpace(c.prompt("选择：> ")))
+		switch input {
+		case "", "0", "q":
+			return
+		case "a":
+			name := strings.TrimSpace(c.ask("  档案名（如 work / home / travel）", ""))
+			if name == "" {
+				continue
+			}
+			if err := c.app.AddProfile(config.ProfileFromSource(name, c.app.Cfg.Source)); err != nil {
+				badC.Fprintln(c.out, err.Error())
+				continue
+			}
+			okC.Fprintf(c.out, "已存档案 %s\n", name)
+		case "d":
+			idx, err := strconv.Atoi(strings.TrimSpace(c.ask("  要删除的档案编号", "")))
+			if err != nil || idx < 1 || idx > len(profiles) {
+				warnC.Fprintln(c.out, "无效编号")
+				continue
+			}
+			name := profiles[idx-1].Name
+			if !c.yesNo(fmt.Sprintf("  确认删除档案 %s？", name), false) {
+				continue
+			}
+			if err := c.app.RemoveProfile(name); err != nil {
+				badC.Fprintln(c.out, err.Error())
+				continue
+			}
+			okC.Fprintf(c.out, "已删档案 %s\n", name)
+		default:
+			idx, err := strconv.Atoi(input)
+			if err != nil || idx < 1 || idx > len(profiles) {
+				warnC.Fprintln(c.out, "无效选项（按编号切换 / A 新增 / D 删除 / 0 返回）")
+				continue
+			}
+			name := profiles[idx-1].Name
+			if err := c.app.UseProfile(ctx, name); err != nil {
+				badC.Fprintf(c.out, "切换失败：%v\n", err)
+				continue
+			}
+			if c.app.Engine != nil && c.app.Engine.Running() {
+				okC.Fprintf(c.out, "已切到档案 %s，已热重载\n", name)
+			} else {
+				okC.Fprintf(c.out, "已切到档案 %s（下次 start 生效）\n", name)
+			}
+		}
+	}
+}
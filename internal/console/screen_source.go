@@ -20,7 +20,11 @@ func (c *consoleUI) screenSource(ctx context.Context) {
 	// 避免每次菜单循环都去重复测（订阅 URL 能慢到 5-10 秒）。
 	probes := c.probeAllSources(ctx, c.app.Cfg)
 	for {
-		c.banner("代理 & 订阅  ·  当前: " + sourceLabel(c.app.Cfg.Source.Type))
+		title := "代理 & 订阅  ·  当前: " + sourceLabel(c.app.Cfg.Source.Type)
+		if cur := c.app.Cfg.Source.Current; cur != "" {
+			title += "  ·  档案: " + cur
+		}
+		c.banner(title)
 
 		// 代理源选项：编号 / 标签 / 图标 / 值 四列对齐（按显示宽度，不是字节）
 		renderRow := func(num, label string, p sourceSlot) {
@@ -54,6 +58,7 @@ func (c *consoleUI) screenSource(ctx context.Context) {
 		titleC.Fprint(c.out, "  ── 操作 ── ")
 		ops := []string{}
 		if c.app.Cfg.Source.Type == config.SourceTypeSubscription ||
+			c.app.Cfg.Source.Type == config.SourceTypeMulti ||
 			c.app.Cfg.Source.Type == config.SourceTypeFile {
 			ops = append(ops, "N 切换节点")
 		}
@@ -61,7 +66,7 @@ func (c *consoleUI) screenSource(ctx context.Context) {
 		if c.app.Cfg.Source.ChainResidential != nil || c.app.Cfg.Source.ScriptPath != "" {
 			scriptMark = okC.Sprint(" ●")
 		}
-		ops = append(ops, "S 全局扩展脚本"+scriptMark, "T 重新测试", "0 返回（或按 Q）")
+		ops = append(ops, "S 全局扩展脚本"+scriptMark, "P 源档案", "T 重新测试", "0 返回（或按 Q）")
 		fmt.Fprintln(c.out, strings.Join(ops, "   "))
 
 		choice := strings.ToLower(c.prompt("选择：> "))
@@ -80,6 +85,10 @@ func (c *consoleUI) screenSource(ctx context.Context) {
 			continue
 		case "s":
 			c.configureScript()
+		case "p":
+			c.screenProfiles(ctx)
+			probes = c.probeAllSources(ctx, c.app.Cfg)
+			continue
 		case "t":
 			probes = c.probeAllSources(ctx, c.app.Cfg)
 			continue
@@ -93,6 +102,11 @@ func (c *consoleUI) screenSource(ctx context.Context) {
 		if !changed {
 			continue
 		}
+		// 手动改了源（1-4）就不再对应任何档案，current 清掉，免得状态页显示名不副实的档案。
+		switch choice {
+		case "1", "2", "3", "4":
+			c.app.Cfg.Source.Current = ""
+		}
 		if err := c.app.Save(); err != nil {
 			badC.Fprintln(c.out, err.Error())
 			continue
@@ -10,8 +10,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tght/lan-proxy-gateway/internal/app"
 	"github.com/tght/lan-proxy-gateway/internal/config"
 	"github.com/tght/lan-proxy-gateway/internal/engine"
+	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
 func (c *consoleUI) screenTraffic(ctx context.Context) {
@@ -35,6 +37,8 @@ func (c *consoleUI) screenTraffic(ctx context.Context) {
 		fmt.Fprintf(c.out, "  5  策略组自动补全 %s   订阅里缺 Auto/Fallback 时自动加（直选节点也能自动切换）\n",
 			onOff(cfg.Traffic.AutoGroups))
 		fmt.Fprintf(c.out, "  6  切换网关模式   当前: %s\n", gatewayModeLabel(gwMode))
+		fmt.Fprintf(c.out, "  7  地区分组 %s       按国旗 / 地名生成「🇭🇰 香港」「🇺🇸 美国」等自动测速组\n",
+			onOff(cfg.Traffic.RegionGroups))
 		dimC.Fprintln(c.out, "  9  高级设置     （DNS 开关 / 端口调整，端口冲突时才来）")
 		fmt.Fprintln(c.out)
 		titleC.Fprintln(c.out, "  ── 操作 ── 0 返回主菜单（或按 Q）")
@@ -92,6 +96,11 @@ func (c *consoleUI) screenTraffic(ctx context.Context) {
 			// 引用订阅里全部节点。reload 后 mihomo Web UI 就能看到新组。
 			c.app.Cfg.Traffic.AutoGroups = !c.app.Cfg.Traffic.AutoGroups
 			c.saveAndMaybeReload(ctx, fmt.Sprintf("策略组自动补全已 %s", onOff(c.app.Cfg.Traffic.AutoGroups)))
+		case "7":
+			// 地区分组：同样只影响下一次 render。组名固定，自定义规则的目标组
+			// 可以直接填「🇺🇸 美国」这类名字。
+			c.app.Cfg.Traffic.RegionGroups = !c.app.Cfg.Traffic.RegionGroups
+			c.saveAndMaybeReload(ctx, fmt.Sprintf("地区分组已 %s", onOff(c.app.Cfg.Traffic.RegionGroups)))
 		case "6":
 			c.switchGatewayMode(ctx)
 		case "9":
@@ -144,7 +153,7 @@ func (c *consoleUI) screenCustomRules(ctx context.Context) {
 
 		fmt.Fprintln(c.out)
 		titleC.Fprint(c.out, "  ── 操作 ── ")
-		fmt.Fprintln(c.out, "A 添加一条   D <编号> 删除某条   0 返回（或按 Q）")
+		fmt.Fprintln(c.out, "A 添加一条   D <编号> 删除某条   T 测一下某个网站走哪条   0 返回（或按 Q）")
 		input := strings.ToLower(strings.TrimSpace(c.prompt("选择：> ")))
 
 		switch {
@@ -152,6 +161,8 @@ func (c *consoleUI) screenCustomRules(ctx context.Context) {
 			return
 		case input == "a":
 			c.addCustomRule(ctx)
+		case input == "t":
+			c.testRule(ctx)
 		case strings.HasPrefix(input, "d"):
 			// 兼容 "d 3" 和 "d3"
 			numStr := strings.TrimSpace(strings.TrimPrefix(input, "d"))
@@ -163,11 +174,39 @@ func (c *consoleUI) screenCustomRules(ctx context.Context) {
 			target := listed[idx-1]
 			c.deleteCustomRule(ctx, target.verdict, target.target, target.rule)
 		default:
-			warnC.Fprintln(c.out, "无效操作（A 添加 / D <编号> 删除 / 0 返回）")
+			warnC.Fprintln(c.out, "无效操作（A 添加 / D <编号> 删除 / T 测试 / 0 返回）")
 		}
 	}
 }
 
+// testRule 是 `gateway rule test` 的菜单版：问一个域名 / IP，解释它命中哪条规则。
+func (c *consoleUI) testRule(ctx context.Context) {
+	host := strings.TrimSpace(c.ask("  域名或 IP（例如 www.google.com）", ""))
+	if host == "" {
+		return
+	}
+	src := strings.TrimSpace(c.ask("  来自哪台设备的 IP（可空，判断设备策略用）", ""))
+	res := c.app.TestRule(ctx, host, src)
+	fmt.Fprintln(c.out)
+	if len(res.IPs) > 0 {
+		dimC.Fprintf(c.out, "  解析: %s\n", strings.Join(res.IPs, ", "))
+	}
+	if res.Matched {
+		fmt.Fprintf(c.out, "  命中: #%d  %s\n", res.Index+1, res.Rule.Line)
+		fmt.Fprintf(c.out, "  来源: %s\n", traffic.SourceLabel(res.Rule.Source))
+		okC.Fprintf(c.out, "  去向: %s\n", res.Verdict)
+	} else {
+		warnC.Fprintln(c.out, "  命中: 无")
+	}
+	if n := len(res.Skipped); n > 0 {
+		dimC.Fprintf(c.out, "  跳过了 %d 条无法离线判断的规则（如 %s），实际结果可能更早命中\n", n, res.Skipped[0].Line)
+	}
+	if res.Note != "" {
+		warnC.Fprintf(c.out, "  ⚠ %s\n", res.Note)
+	}
+	c.pause()
+}
+
 // addCustomRule 引导式添加一条自定义规则。
 func (c *consoleUI) addCustomRule(ctx context.Context) {
 	fmt.Fprintln(c.out, "\n  匹配类型：")
@@ -200,6 +239,10 @@ func (c *consoleUI) addCustomRule(ctx context.Context) {
 			return
 		}
 	}
+	if err := traffic.ValidateRule(rule); err != nil {
+		badC.Fprintf(c.out, "  规则无效：%v\n", err)
+		return
+	}
 
 	fmt.Fprintln(c.out, "\n  命中后去向：")
 	fmt.Fprintln(c.out, "    1) 直连 DIRECT")
@@ -230,6 +273,11 @@ func (c *consoleUI) addCustomRule(ctx context.Context) {
 		label = "Proxy"
 	}
 	c.saveAndMaybeReload(ctx, fmt.Sprintf("  ✓ 已加规则：%s → %s", rule, label))
+	// 语法已经过关，这里只剩重复 / 被挡住 / 策略组不存在这类提醒。
+	issues := c.app.LintRules(ctx)
+	for _, is := range append(app.RuleIssues(issues, rule), app.RuleIssues(issues, label)...) {
+		warnC.Fprintf(c.out, "  ⚠ %s: %s\n", is.Where, is.Message)
+	}
 }
 
 // deleteCustomRule 删除命中的某条。
@@ -18,6 +18,8 @@ var slashCommands = []slashCmd{
 	{"/status", "查看运行状态（模式 / TUN / 源 / 端口…）"},
 	{"/node", "切换代理节点"},
 	{"/source", "代理源 / 订阅 · 连通测试"},
+	{"/profile", "源档案：工作 / 家里 / 出差 一键切换"},
+	{"/conn", "活跃连接：按设备 / 域名查看，关掉卡住的连接"},
 	{"/menu", "完整菜单：设备接入 · 分流规则 · 启停 · 看日志"},
 	{"/help", "显示这个命令清单"},
 	{"/quit", "退出控制台（网关留后台继续跑）"},
@@ -49,6 +51,10 @@ func (c *consoleUI) handleSlash(ctx context.Context, raw string) (quit bool) {
 		c.screenSwitchNode(ctx)
 	case "/source", "/src", "/sub":
 		c.screenSource(ctx)
+	case "/profile", "/profiles", "/p":
+		c.screenProfiles(ctx)
+	case "/conn", "/conns", "/c":
+		c.screenConnections(ctx)
 	case "/quit", "/exit", "/q":
 		return true
 	default:
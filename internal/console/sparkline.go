@@ -1,8 +1,12 @@
 // internal/console/sparkline.go
 package console
 
+import "sync"
+
 // sparkline 是定长环形缓冲，用 Unicode 区块字符渲染成柱状图。
+// /traffic 推送 goroutine 写、主 loop 渲染，mu 保护。
 type sparkline struct {
+	mu   sync.Mutex
 	buf  []float64
 	size int
 	n    int // 已写入总数（>size 时取最近 size 个）
@@ -18,6 +22,8 @@ func (s *sparkline) push(v float64) {
 	if v < 0 {
 		v = 0
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.buf[s.n%s.size] = v
 	s.n++
 }
@@ -44,7 +50,9 @@ func (s *sparkline) ordered() []float64 {
 }
 
 func (s *sparkline) render() string {
+	s.mu.Lock()
 	vals := s.ordered()
+	s.mu.Unlock()
 	max := 0.0
 	for _, v := range vals {
 		if v > max {
@@ -0,0 +1,91 @@
+package devices
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ARPTable 读本机邻居表，返回 MAC（小写冒号格式）→ IPv4。给「按 MAC 配的设备策略」
+// 换成 SRC-IP-CIDR 用：mihomo 只认来源 IP，不认 MAC。
+//
+// Linux 直接读 /proc/net/arp；macOS / Windows 跑 `arp -a`。读不到返回空表 + 错误，
+// 调用方当「这次解析不了」处理即可。
+func ARPTable() (map[string]string, error) {
+	if runtime.GOOS == "linux" {
+		data, err := os.ReadFile("/proc/net/arp")
+		if err != nil {
+			return map[string]string{}, err
+		}
+		return ParseARP(string(data)), nil
+	}
+	args := []string{"-an"}
+	if runtime.GOOS == "windows" {
+		args = []string{"-a"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "arp", args...).Output()
+	if err != nil {
+		return map[string]string{}, err
+	}
+	return ParseARP(string(out)), nil
+}
+
+// ParseARP 解析三种常见输出格式，不按平台分支，逐行找「一个 IPv4 + 一个 MAC」：
+//
+//	/proc/net/arp:  192.168.1.23  0x1  0x2  aa:bb:cc:dd:ee:ff  *  eth0
+//	macOS arp -an:  ? (192.168.1.23) at aa:bb:c:d:ee:f on en0 ifscope [ethernet]
+//	Windows arp -a:   192.168.1.23          aa-bb-cc-dd-ee-ff     dynamic
+//
+// 未完成的条目（全 0 MAC / "(incomplete)"）跳过。同一 MAC 出现多次取第一条。
+func ParseARP(text string) map[string]string {
+	out := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		var ip, mac string
+		for _, f := range strings.Fields(line) {
+			f = strings.Trim(f, "()")
+			if ip == "" {
+				if parsed := net.ParseIP(f); parsed != nil && parsed.To4() != nil {
+					ip = parsed.String()
+					continue
+				}
+			}
+			if mac == "" {
+				mac = NormalizeMAC(f)
+			}
+		}
+		if ip == "" || mac == "" || mac == "00:00:00:00:00:00" {
+			continue
+		}
+		if _, ok := out[mac]; !ok {
+			out[mac] = ip
+		}
+	}
+	return out
+}
+
+// NormalizeMAC 把 aa-bb-… / AA:BB:… / macOS 省略前导 0 的 a:b:… 统一成
+// 小写两位冒号格式；不是 6 段 MAC 返回空串。
+func NormalizeMAC(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 || strings.Count(s, ":")+strings.Count(s, "-") != 5 {
+		return ""
+	}
+	for i, p := range parts {
+		if len(p) > 2 {
+			return ""
+		}
+		v, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return ""
+		}
+		parts[i] = strconv.FormatUint(v|0x100, 16)[1:]
+	}
+	return strings.Join(parts, ":")
+}
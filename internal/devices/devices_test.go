@@ -52,3 +52,32 @@ func TestSetLabels(t *testing.T) {
 		t.Errorf("after SetLabels want Router, got %q", got)
 	}
 }
+
+func TestParseARP(t *testing.T) {
+	procNet := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.23     0x1         0x2         AA:BB:CC:DD:EE:01     *        eth0
+192.168.1.99     0x1         0x0         00:00:00:00:00:00     *        eth0
+`
+	darwin := `? (192.168.1.24) at aa:bb:c:d:ee:2 on en0 ifscope [ethernet]
+? (192.168.1.25) at (incomplete) on en0 ifscope [ethernet]
+`
+	windows := `
+Interface: 192.168.1.10 --- 0x7
+  Internet Address      Physical Address      Type
+  192.168.1.26          aa-bb-cc-dd-ee-03     dynamic
+`
+	got := ParseARP(procNet + darwin + windows)
+	want := map[string]string{
+		"aa:bb:cc:dd:ee:01": "192.168.1.23",
+		"aa:bb:0c:0d:ee:02": "192.168.1.24",
+		"aa:bb:cc:dd:ee:03": "192.168.1.26",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for mac, ip := range want {
+		if got[mac] != ip {
+			t.Errorf("%s: got %q, want %q", mac, got[mac], ip)
+		}
+	}
+}
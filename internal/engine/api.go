@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -124,6 +125,40 @@ func (c *Client) GroupDelay(ctx context.Context, group, testURL string, timeoutM
 	return out, nil
 }
 
+// ProxyDelay 测单个节点（也可以是策略组，测的是它当前选中的节点）的延迟。
+// 返回 0 表示超时或不通；mihomo 这时回 408 / 503 / 504，不当作错误。
+func (c *Client) ProxyDelay(ctx context.Context, name, testURL string, timeoutMs int) (int, error) {
+	q := url.Values{"url": {testURL}, "timeout": {fmt.Sprint(timeoutMs)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		c.baseURL+"/proxies/"+url.PathEscape(name)+"/delay?"+q.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	client := &http.Client{Timeout: time.Duration(timeoutMs+3000) * time.Millisecond}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return 0, nil
+	}
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("proxy delay %s: HTTP %d", name, resp.StatusCode)
+	}
+	var out struct {
+		Delay int `json:"delay"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, err
+	}
+	return out.Delay, nil
+}
+
 // SelectNode picks a node inside a group.
 func (c *Client) SelectNode(ctx context.Context, group, node string) error {
 	body := strings.NewReader(fmt.Sprintf(`{"name":%q}`, node))
@@ -183,13 +218,14 @@ type ConnectionsSnapshot struct {
 
 // Connection 只投影仪表盘会用的字段。mihomo 字段很多，这里按需扩。
 type Connection struct {
-	ID       string             `json:"id"`
-	Upload   int64              `json:"upload"`
-	Download int64              `json:"download"`
-	Start    string             `json:"start"`    // RFC3339
-	Chains   []string           `json:"chains"`   // 代理链（最后一跳通常是最终出口节点名）
-	Rule     string             `json:"rule"`
-	Metadata ConnectionMetadata `json:"metadata"`
+	ID          string             `json:"id"`
+	Upload      int64              `json:"upload"`
+	Download    int64              `json:"download"`
+	Start       string             `json:"start"`  // RFC3339
+	Chains      []string           `json:"chains"` // 代理链：[出口节点, …, 规则命中的策略组]，mihomo 从里往外追加
+	Rule        string             `json:"rule"`
+	RulePayload string             `json:"rulePayload"`
+	Metadata    ConnectionMetadata `json:"metadata"`
 }
 
 // ConnectionMetadata 关键字段：sourceIP 用来聚合 LAN 设备；host 给「连了什么」
@@ -230,6 +266,28 @@ func (c *Client) GetConnections(ctx context.Context) (*ConnectionsSnapshot, erro
 	return &snap, nil
 }
 
+// CloseConnection 让 mihomo 关掉一条连接（DELETE /connections/:id）。连接已经
+// 自己结束了 mihomo 也返回 204，调用方不用区分。
+func (c *Client) CloseConnection(ctx context.Context, id string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete,
+		c.baseURL+"/connections/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("close connection %s: HTTP %d", id, resp.StatusCode)
+	}
+	return nil
+}
+
 // ReloadConfig asks mihomo to reload its config from disk.
 func (c *Client) ReloadConfig(ctx context.Context, path string) error {
 	body := strings.NewReader(fmt.Sprintf(`{"path":%q}`, path))
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCloseConnection(t *testing.T) {
+	var gotMethod, gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth = r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization")
+		if r.URL.Path == "/connections/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.secret = "s3cret"
+	if err := c.CloseConnection(context.Background(), "a1b2/c3"); err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/connections/a1b2%2Fc3" || gotAuth != "Bearer s3cret" {
+		t.Fatalf("request = %s %s auth=%q", gotMethod, gotPath, gotAuth)
+	}
+	if err := c.CloseConnection(context.Background(), "missing"); err == nil {
+		t.Fatal("HTTP 404 should be an error")
+	}
+}
+
+func TestProxyDelay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/proxies/HK%2001/delay":
+			if r.URL.Query().Get("timeout") != "2000" || r.URL.Query().Get("url") != "http://cp.cloudflare.com" {
+				t.Errorf("query = %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"delay":123}`))
+		case "/proxies/dead/delay":
+			w.WriteHeader(http.StatusGatewayTimeout)
+			w.Write([]byte(`{"message":"Timeout"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+	if d, err := c.ProxyDelay(ctx, "HK 01", "http://cp.cloudflare.com", 2000); err != nil || d != 123 {
+		t.Fatalf("HK 01 = %d, %v", d, err)
+	}
+	if d, err := c.ProxyDelay(ctx, "dead", "http://cp.cloudflare.com", 2000); err != nil || d != 0 {
+		t.Fatalf("timeout should be 0, nil; got %d, %v", d, err)
+	}
+	if _, err := c.ProxyDelay(ctx, "missing", "http://cp.cloudflare.com", 2000); err == nil {
+		t.Fatal("404 should be an error")
+	}
+}
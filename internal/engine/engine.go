@@ -110,6 +110,8 @@ func (e *Engine) startRendered(ctx context.Context, cfg *configpkg.Config, rende
 	// 冷启动（workdir 被清掉）会静默下载。
 	upstream := localUpstreamURL(cfg)
 	_ = mihomo.EnsureGeodata(e.workdir, e.cacheDir, upstream, nil)
+	// 外部规则集同理：启动前先在 workdir 里备好文件，断网也能起来。
+	mihomo.EnsureRuleProviders(ruleProviderFiles(cfg, e.workdir), upstream, nil)
 
 	data := rendered
 	if data == nil {
@@ -131,6 +133,7 @@ func (e *Engine) startRendered(ctx context.Context, cfg *configpkg.Config, rende
 		return fmt.Errorf("write config: %w", err)
 	}
 
+	_ = os.Remove(e.stoppedPath())
 	logPath := filepath.Join(e.workdir, "mihomo.log")
 	_ = os.Truncate(logPath, 0) // start fresh so tail-on-fail shows only this run
 	e.proc = newProcess(e.bin, e.workdir, logPath)
@@ -153,13 +156,28 @@ func (e *Engine) startRendered(ctx context.Context, cfg *configpkg.Config, rende
 }
 
 // Stop kills the mihomo process. Safe to call if never started.
+//
+// 停之前先留一个 mihomo.stopped 标记（下次 Start 时删掉）：别的进程的 watchdog
+// 退避等待期间，靠它知道 mihomo 是被人主动停的，别再拉起来。
 func (e *Engine) Stop() error {
 	if e.proc == nil {
 		return nil
 	}
+	if err := os.MkdirAll(e.workdir, 0o755); err == nil {
+		_ = os.WriteFile(e.stoppedPath(), nil, 0o644)
+	}
 	return e.proc.Stop()
 }
 
+// Stopped 报告 mihomo 上一次是不是走 Stop 停的（之后还没有再 Start 过）。
+// 和 Crashed 一样看的是 workdir 里的文件，所以别的进程停的也认得出来。
+func (e *Engine) Stopped() bool {
+	_, err := os.Stat(e.stoppedPath())
+	return err == nil
+}
+
+func (e *Engine) stoppedPath() string { return filepath.Join(e.workdir, "mihomo.stopped") }
+
 // Reload 重新渲染 config 并重启 mihomo。
 //
 // 以前走 API /configs reload（快、不断流），但 mihomo 的 API reload **不更新
@@ -193,6 +211,13 @@ func (e *Engine) Running() bool {
 	return e.apiPort > 0 && probeAPIPort(e.apiPort)
 }
 
+// Crashed 报告 mihomo 是不是没走 Stop 就没了：pidfile 还在，进程已经不在。
+// Stop 发信号前就删了 pidfile，Reload 中途的空档、别的进程正在停的那几秒都不会
+// 被当成崩溃。断电留下的旧 pidfile 也是 true，调用方要自己结合「之前见过它在跑」判断。
+func (e *Engine) Crashed() bool {
+	return e.proc != nil && e.proc.exitedUnexpectedly()
+}
+
 // LogPath returns the path to the mihomo log file.
 func (e *Engine) LogPath() string { return filepath.Join(e.workdir, "mihomo.log") }
 
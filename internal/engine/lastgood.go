@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	configpkg "github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// last-known-good：mihomo 用某份 config.yaml 稳定跑过一段时间后，把它另存一份。
+// 新配置（订阅更新、改规则、增强脚本）把 mihomo 弄得反复崩溃时，watchdog 用它
+// 兜底，先让 LAN 设备恢复上网。
+
+// GoodConfigPath 返回 last-known-good 配置的路径。
+func (e *Engine) GoodConfigPath() string { return filepath.Join(e.workdir, "config.good.yaml") }
+
+// HasGoodConfig 报告有没有 last-known-good 配置。
+func (e *Engine) HasGoodConfig() bool {
+	st, err := os.Stat(e.GoodConfigPath())
+	return err == nil && st.Size() > 0
+}
+
+// MarkGood 在当前 config.yaml 已经跑满 stableFor 时把它记成 last-known-good，
+// 返回这次有没有写。config.yaml 每次启动都会重写，所以它的修改时间就是这次
+// mihomo 起来的时间；调用方要保证此刻 mihomo 在跑。
+func (e *Engine) MarkGood(stableFor time.Duration, now time.Time) (bool, error) {
+	st, err := os.Stat(e.ConfigPath())
+	if err != nil || now.Sub(st.ModTime()) < stableFor {
+		return false, nil
+	}
+	if gst, err := os.Stat(e.GoodConfigPath()); err == nil && !gst.ModTime().Before(st.ModTime()) {
+		return false, nil
+	}
+	data, err := os.ReadFile(e.ConfigPath())
+	if err != nil {
+		return false, err
+	}
+	if prev, err := os.ReadFile(e.GoodConfigPath()); err == nil && bytes.Equal(prev, data) {
+		// 内容一样只是重启过：刷新时间，下次不用再读。
+		return false, os.Chtimes(e.GoodConfigPath(), now, now)
+	}
+	tmp := e.GoodConfigPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, e.GoodConfigPath())
+}
+
+// StartLastGood 不渲染，直接用 last-known-good 配置启动 mihomo。cfg 只用来做端口
+// 预检和接 API —— 端口改过的话以 last-known-good 里的为准，API 可能连不上，
+// 这是兜底路径能接受的代价。
+func (e *Engine) StartLastGood(ctx context.Context, cfg *configpkg.Config) error {
+	data, err := os.ReadFile(e.GoodConfigPath())
+	if err != nil || len(data) == 0 {
+		return fmt.Errorf("没有可用的 last-known-good 配置 (%s)", e.GoodConfigPath())
+	}
+	return e.startRendered(ctx, configpkg.EffectiveRuntimeConfig(cfg), data)
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMarkGoodWaitsForStableRun(t *testing.T) {
+	e := New("", t.TempDir(), "")
+	now := time.Now()
+	if err := os.WriteFile(e.ConfigPath(), []byte("mode: rule\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := e.MarkGood(3*time.Minute, now); ok || e.HasGoodConfig() {
+		t.Fatal("a config that just started must not be marked good")
+	}
+
+	started := now.Add(-5 * time.Minute)
+	_ = os.Chtimes(e.ConfigPath(), started, started)
+	if ok, err := e.MarkGood(3*time.Minute, now); !ok || err != nil {
+		t.Fatalf("stable config not marked: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := e.MarkGood(3*time.Minute, now.Add(time.Minute)); ok {
+		t.Fatal("already marked; must not rewrite")
+	}
+
+	// 新配置起来了但还没跑满：last-known-good 保持旧的。
+	if err := os.WriteFile(e.ConfigPath(), []byte("mode: global\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fresh := now.Add(2 * time.Minute)
+	_ = os.Chtimes(e.ConfigPath(), fresh, fresh)
+	if ok, _ := e.MarkGood(3*time.Minute, now.Add(3*time.Minute)); ok {
+		t.Fatal("young config replaced last-known-good")
+	}
+	if b, _ := os.ReadFile(e.GoodConfigPath()); string(b) != "mode: rule\n" {
+		t.Fatalf("last-known-good = %q", b)
+	}
+	if ok, _ := e.MarkGood(3*time.Minute, now.Add(6*time.Minute)); !ok {
+		t.Fatal("new config should become last-known-good once stable")
+	}
+}
@@ -88,7 +88,12 @@ const stopGrace = 8 * time.Second
 // signal to be delivered before SIGKILL.
 const orphanGrace = 2 * time.Second
 
+// Stop 先删 pidfile 再发信号：进程退出之前 pidfile 就已经没了，别的进程（菜单 /
+// 系统服务各跑一个 watchdog）在 stopGrace / orphanGrace 的空档里看到的是「已停」
+// 而不是「pidfile 还在、进程没了」的崩溃。
 func (p *process) Stop() error {
+	pid, hasPID := p.readPID()
+	_ = os.Remove(p.pidFile)
 	if p.cmd != nil && p.cmd.Process != nil {
 		terminateProcess(p.cmd.Process)
 		done := make(chan error, 1)
@@ -98,7 +103,7 @@ func (p *process) Stop() error {
 		case <-time.After(stopGrace):
 			_ = p.cmd.Process.Kill()
 		}
-	} else if pid, ok := p.readPID(); ok {
+	} else if hasPID {
 		if proc, err := os.FindProcess(pid); err == nil {
 			terminateProcess(proc)
 			time.Sleep(orphanGrace)
@@ -107,7 +112,6 @@ func (p *process) Stop() error {
 			}
 		}
 	}
-	_ = os.Remove(p.pidFile)
 	return nil
 }
 
@@ -124,6 +128,14 @@ func (p *process) Alive() bool {
 	return pidAlive(pid)
 }
 
+// exitedUnexpectedly：pidfile 指向的进程已经不在了。Stop 发信号之前就删了
+// pidfile，所以这只会是进程自己退出的（崩溃 / OOM / 被别人 kill），或者上次
+// 断电留下的旧 pidfile —— 后者由调用方自己区分（见 app.watchdog）。
+func (p *process) exitedUnexpectedly() bool {
+	pid, ok := p.readPID()
+	return ok && !pidAlive(pid)
+}
+
 func (p *process) readPID() (int, bool) {
 	data, err := os.ReadFile(p.pidFile)
 	if err != nil {
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeMihomo 写一个只会 sleep 的「mihomo」，够 process.Start / Stop 用。
+func fakeMihomo(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs /bin/sh")
+	}
+	bin := filepath.Join(t.TempDir(), "mihomo")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+// 菜单和系统服务各有一个 Engine 指着同一个 workdir：一边 Stop（走没有 cmd handle 的
+// orphan 路径，要等 orphanGrace），另一边的 watchdog 在这期间不能把它当成崩溃。
+func TestStopFromAnotherProcessIsNotACrash(t *testing.T) {
+	bin, dir := fakeMihomo(t), t.TempDir()
+	owner := New(bin, dir, "")
+	if err := owner.proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = owner.proc.cmd.Process.Kill() })
+	watcher := New(bin, dir, "")
+	if !watcher.Running() || watcher.Crashed() {
+		t.Fatal("watcher should see mihomo running")
+	}
+
+	stopper := New(bin, dir, "")
+	done := make(chan struct{})
+	go func() {
+		_ = stopper.Stop()
+		close(done)
+	}()
+	for stopping := true; stopping; {
+		select {
+		case <-done:
+			stopping = false
+		case <-time.After(50 * time.Millisecond):
+		}
+		if watcher.Crashed() {
+			t.Fatal("a deliberate stop was taken for a crash")
+		}
+	}
+	if watcher.Running() || !watcher.Stopped() {
+		t.Fatalf("after stop: running=%v stopped=%v", watcher.Running(), watcher.Stopped())
+	}
+}
+
+func TestCrashLeavesPIDFile(t *testing.T) {
+	bin, dir := fakeMihomo(t), t.TempDir()
+	e := New(bin, dir, "")
+	if err := e.proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	_ = e.proc.cmd.Process.Kill()
+	deadline := time.Now().Add(5 * time.Second)
+	for !e.Crashed() {
+		if time.Now().After(deadline) {
+			t.Fatal("killed mihomo should be reported as crashed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if e.Stopped() {
+		t.Fatal("a crash is not a stop")
+	}
+}
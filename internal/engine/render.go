@@ -6,15 +6,20 @@ package engine
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tght/lan-proxy-gateway/embed"
 	configpkg "github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/devices"
+	"github.com/tght/lan-proxy-gateway/internal/mihomo"
 	"github.com/tght/lan-proxy-gateway/internal/script"
 	"github.com/tght/lan-proxy-gateway/internal/script/presets"
 	"github.com/tght/lan-proxy-gateway/internal/source"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
 	"github.com/tght/lan-proxy-gateway/internal/traffic"
 )
 
@@ -34,12 +39,15 @@ func renderWithOptions(ctx context.Context, cfg *configpkg.Config, workDir strin
 	frag, err := source.MaterializeWithOptions(ctx, cfg.Source, workDir, source.MaterializeOptions{
 		SubscriptionProxyURL: opts.subscriptionProxyURL,
 		AutoGroups:           cfg.Traffic.AutoGroups,
+		RegionGroups:         cfg.Traffic.RegionGroups,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("materialize source: %w", err)
 	}
 
-	rules := traffic.Render(cfg.Traffic)
+	policies, warnings := DevicePolicies(cfg, workDir, time.Now())
+	_ = saveRenderWarnings(workDir, warnings)
+	rules := traffic.Render(cfg.Traffic, policies)
 	// 用户源（订阅/本地文件）带了自己的 rules：把 base rules 末尾的
 	// MATCH,Proxy 兜底去掉，换成用户 rules 做兜底（用户 yaml 里一般自己
 	// 就有 MATCH）。这样用户订阅里的 GEOSITE/GEOIP/DOMAIN 规则链能生效，
@@ -64,9 +72,9 @@ func renderWithOptions(ctx context.Context, cfg *configpkg.Config, workDir strin
 	out = strings.ReplaceAll(out, "{{MIHOMO_MODE}}", cfg.Traffic.Mode)
 	out = strings.ReplaceAll(out, "{{LOG_LEVEL}}", cfg.Runtime.LogLevel)
 	out = strings.ReplaceAll(out, "{{TUN_CONFIG}}", renderTUNBlock(cfg))
-	out = strings.ReplaceAll(out, "{{DNS_ENABLED}}", boolStr(cfg.Gateway.DNS.Enabled))
-	out = strings.ReplaceAll(out, "{{DNS_PORT}}", strconv.Itoa(cfg.Gateway.DNS.Port))
+	out = strings.ReplaceAll(out, "{{DNS_BLOCK}}", renderDNSBlock(cfg.Gateway.DNS))
 	out = strings.ReplaceAll(out, "{{PROXY_BLOCK}}", frag.YAML)
+	out = strings.ReplaceAll(out, "{{RULE_PROVIDERS_BLOCK}}", traffic.RenderProviders(cfg.Traffic))
 	out = strings.ReplaceAll(out, "{{RULES_BLOCK}}", rules)
 
 	// 增强脚本：先看是否有「链式代理预设」要实例化，再退化到用户自定义 ScriptPath。
@@ -88,6 +96,87 @@ func renderWithOptions(ctx context.Context, cfg *configpkg.Config, workDir strin
 	return []byte(out), nil
 }
 
+// DevicePolicies 返回 now 时渲染进 config.yaml 的设备策略：超额封禁在前，然后是
+// 定时 / 常驻策略，按 MAC 配的换成 ARP 表里的 IP。渲染和 `rule test` 都走这里，
+// 解释出来的去向才跟 mihomo 实际用的一致。warnings 是这次跳过的东西，交给调用方
+// 决定怎么给人看（渲染时落盘到 RenderWarningsFile）。
+func DevicePolicies(cfg *configpkg.Config, workDir string, now time.Time) (policies []configpkg.DevicePolicy, warnings []string) {
+	quota, err := quotaPolicies(workDir, now)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("读取 %s 失败（%v），流量额度这次不生效", stats.QuotaBlocksFile, err))
+	}
+	policies, skipped := resolveDevicePolicies(append(quota, cfg.Gateway.ActiveDevicePolicies(now)...), devices.ARPTable)
+	return policies, append(warnings, skipped...)
+}
+
+// resolveDevicePolicies 把只填了 MAC 的设备策略换成当前 ARP 表里的 IP。
+// ARP 表只在确实需要时读一次；查不到的设备记一条 warning 后跳过，不阻塞渲染
+// （设备离线很正常，下次重载再试）。
+func resolveDevicePolicies(list []configpkg.DevicePolicy, arpTable func() (map[string]string, error)) (out []configpkg.DevicePolicy, warnings []string) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	var arp map[string]string
+	out = make([]configpkg.DevicePolicy, 0, len(list))
+	for _, p := range list {
+		if p.IP == "" {
+			if arp == nil {
+				var err error
+				if arp, err = arpTable(); err != nil {
+					warnings = append(warnings, fmt.Sprintf("读取 ARP 表失败（%v），按 MAC 配的设备策略这次不生效", err))
+				}
+				if arp == nil {
+					arp = map[string]string{}
+				}
+			}
+			ip, ok := arp[devices.NormalizeMAC(p.MAC)]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("设备 %s 不在 ARP 表里（离线？），它的策略这次跳过", p.MAC))
+				continue
+			}
+			p.IP = ip
+		}
+		out = append(out, p)
+	}
+	return out, warnings
+}
+
+// quotaPolicies 把 workDir 里还没到期的超额封禁换成设备策略，排在最前面：
+// 额度用完以后，定时策略和常驻策略都不该再把这台设备放出去。
+func quotaPolicies(workDir string, now time.Time) ([]configpkg.DevicePolicy, error) {
+	blocks, err := stats.LoadQuotaBlocks(workDir)
+	if err != nil {
+		return nil, err
+	}
+	var out []configpkg.DevicePolicy
+	for _, b := range blocks {
+		if b.ActiveAt(now) {
+			out = append(out, configpkg.DevicePolicy{IP: b.IP, Target: b.Target})
+		}
+	}
+	return out, nil
+}
+
+// ruleProviderFiles 把 traffic.rule_providers 翻成 mihomo 包要准备的缓存文件列表，
+// 路径与 traffic.RenderProviders 渲染进 config.yaml 的 path 一致。
+func ruleProviderFiles(cfg *configpkg.Config, workDir string) []mihomo.RuleProviderFile {
+	var files []mihomo.RuleProviderFile
+	for _, p := range cfg.Traffic.RuleProviders {
+		f := mihomo.RuleProviderFile{
+			Name: p.Name,
+			Path: filepath.Join(workDir, filepath.FromSlash(traffic.ProviderPath(p))),
+			Text: p.Format == "text",
+		}
+		if p.Type == configpkg.RuleProviderFile {
+			f.Source = p.Path
+		} else {
+			f.URL = p.URL
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
 func renderMixedPortBlock(cfg *configpkg.Config) string {
 	if !cfg.Runtime.ProxyService.IsEnabled() {
 		return "mixed-port: 0"
@@ -138,6 +227,76 @@ func renderTUNBlock(cfg *configpkg.Config) string {
 	return b.String()
 }
 
+// 内置 DNS 上游，gateway.dns 对应字段留空时使用。
+var (
+	// 专门给代理服务器本身的域名用的 DNS。没这个的话当 Proxy 组的节点是域名
+	// 形式（例如 abc.example.com:443），mihomo 解析这个域名时又要走 Proxy 组
+	// → 自己等自己 → 死锁（日志里会看到大面积 `dns resolve failed: context
+	// canceled`）。用国内 DNS IP 直接解。
+	defaultProxyServerNameservers = []string{"223.5.5.5", "119.29.29.29"}
+	defaultNameservers            = []string{"223.5.5.5", "119.29.29.29", "https://dns.alidns.com/dns-query"}
+	// fallback DoH 必须走 Proxy 组；否则国内直连 1.1.1.1 / 8.8.8.8 被墙，
+	// fallback 永远超时，拖慢所有国外域名的解析。
+	defaultFallback     = []string{"https://1.1.1.1/dns-query#Proxy", "https://8.8.8.8/dns-query#Proxy"}
+	defaultFakeIPFilter = []string{"*.lan", "*.local", "localhost.ptlogin2.qq.com"}
+)
+
+// renderDNSBlock 渲染 dns 段。上游 / fallback 留空走内置默认；fake_ip_filter 是
+// 追加不是替换（*.lan / *.local 拿到 fake-ip 会让局域网设备互相找不到）。
+// redir-host 模式不需要 fake-ip-range / fake-ip-filter，不写。
+func renderDNSBlock(d configpkg.DNSConfig) string {
+	mode := d.EnhancedMode
+	if mode == "" {
+		mode = configpkg.DNSModeFakeIP
+	}
+	nameservers := d.Nameservers
+	if len(nameservers) == 0 {
+		nameservers = defaultNameservers
+	}
+	fallback := d.Fallback
+	if len(fallback) == 0 {
+		fallback = defaultFallback
+	}
+
+	var b strings.Builder
+	b.WriteString("dns:\n")
+	b.WriteString("  enable: " + boolStr(d.Enabled) + "\n")
+	b.WriteString("  listen: 0.0.0.0:" + strconv.Itoa(d.Port) + "\n")
+	// 返回 AAAA：对照顶部 `ipv6: true`，不然客户端拿不到 IPv6 地址也就无从发起
+	// IPv6 连接，跟关 ipv6 效果一样。
+	b.WriteString("  ipv6: true\n")
+	b.WriteString("  enhanced-mode: " + mode + "\n")
+	if mode == configpkg.DNSModeFakeIP {
+		b.WriteString("  fake-ip-range: 198.18.0.1/16\n")
+		writeYAMLList(&b, "  fake-ip-filter:", append(append([]string(nil), defaultFakeIPFilter...), d.FakeIPFilter...))
+	}
+	writeYAMLList(&b, "  proxy-server-nameserver:", defaultProxyServerNameservers)
+	writeYAMLList(&b, "  nameserver:", nameservers)
+	if len(d.NameserverPolicy) > 0 {
+		b.WriteString("  nameserver-policy:\n")
+		for _, p := range d.NameserverPolicy {
+			b.WriteString("    " + strconv.Quote(strings.TrimSpace(p.Match)) + ":\n")
+			for _, s := range p.Servers {
+				b.WriteString("      - " + strconv.Quote(strings.TrimSpace(s)) + "\n")
+			}
+		}
+	}
+	writeYAMLList(&b, "  fallback:", fallback)
+	b.WriteString("  fallback-filter:\n")
+	b.WriteString("    geoip: true\n")
+	b.WriteString("    geoip-code: CN\n")
+	return b.String()
+}
+
+// writeYAMLList 写「key:」加一串带引号的列表项（缩进比 key 多两格）。
+func writeYAMLList(b *strings.Builder, key string, items []string) {
+	indent := key[:len(key)-len(strings.TrimLeft(key, " "))]
+	b.WriteString(key + "\n")
+	for _, s := range items {
+		b.WriteString(indent + "  - " + strconv.Quote(strings.TrimSpace(s)) + "\n")
+	}
+}
+
 func boolStr(v bool) string {
 	if v {
 		return "true"
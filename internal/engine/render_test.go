@@ -2,11 +2,15 @@ package engine
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tght/lan-proxy-gateway/internal/config"
+	"github.com/tght/lan-proxy-gateway/internal/stats"
 )
 
 func TestRenderMacTUNOmitsDNSHijack(t *testing.T) {
@@ -208,3 +212,156 @@ func TestRenderLocalExternalProxyRespectsTUNOff(t *testing.T) {
 		t.Fatalf("local external proxy should not force DNS listener back on:\n%s", contextAround(s, "dns:", 220))
 	}
 }
+
+func TestRenderRuleProvidersBlock(t *testing.T) {
+	cfg := config.Default()
+	cfg.Source.Type = config.SourceTypeNone
+	cfg.Traffic.RuleProviders = []config.RuleProvider{
+		{Name: "openai", Type: config.RuleProviderHTTP, Behavior: "domain", URL: "https://example.com/openai.yaml", Target: "proxy"},
+	}
+	out, err := Render(context.Background(), cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	s := string(out)
+	if strings.Contains(s, "{{RULE_PROVIDERS_BLOCK}}") {
+		t.Fatal("placeholder not replaced")
+	}
+	if !strings.Contains(s, "rule-providers:\n  \"openai\":") || !strings.Contains(s, "  - RULE-SET,openai,Proxy\n") {
+		t.Fatalf("rule provider not rendered:\n%s", s)
+	}
+}
+
+func TestResolveDevicePoliciesByMAC(t *testing.T) {
+	calls := 0
+	arp := func() (map[string]string, error) {
+		calls++
+		return map[string]string{"aa:bb:cc:dd:ee:ff": "192.168.1.77"}, nil
+	}
+	got, warnings := resolveDevicePolicies([]config.DevicePolicy{
+		{IP: "192.168.1.50", Target: "direct"},
+		{MAC: "AA-BB-CC-DD-EE-FF", Target: "reject"},
+		{MAC: "11:22:33:44:55:66", Target: "proxy"}, // 离线：跳过
+	}, arp)
+	if len(got) != 2 || got[0].IP != "192.168.1.50" || got[1].IP != "192.168.1.77" {
+		t.Fatalf("unexpected resolution: %+v", got)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "11:22:33:44:55:66") {
+		t.Fatalf("offline device should be reported, got %q", warnings)
+	}
+	if calls != 1 {
+		t.Fatalf("ARP table should be read once, got %d", calls)
+	}
+	if got, _ := resolveDevicePolicies([]config.DevicePolicy{{IP: "10.0.0.2", Target: "direct"}}, func() (map[string]string, error) {
+		t.Fatal("ARP table should not be read when every policy has an IP")
+		return nil, nil
+	}); got == nil {
+		t.Fatal("expected policies back")
+	}
+}
+
+func TestRenderSavesWarnings(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Source = config.SourceConfig{Type: config.SourceTypeNone}
+	cfg.Gateway.DevicePolicies = []config.DevicePolicy{{MAC: "02:00:00:00:00:99", Target: "direct"}}
+	config.Normalize(cfg)
+	if _, err := Render(context.Background(), cfg, dir); err != nil {
+		t.Fatal(err)
+	}
+	// 测试机上这台设备一定不在 ARP 表里（或者压根读不到 ARP 表）：要留下警告。
+	if w := LoadRenderWarnings(dir); len(w) == 0 {
+		t.Fatal("skipped MAC policy should leave a render warning")
+	}
+
+	cfg.Gateway.DevicePolicies = nil
+	if _, err := Render(context.Background(), cfg, dir); err != nil {
+		t.Fatal(err)
+	}
+	if w := LoadRenderWarnings(dir); w != nil {
+		t.Fatalf("a clean render should clear old warnings, got %q", w)
+	}
+}
+
+func TestRenderDNSBlockDefaults(t *testing.T) {
+	s := renderDNSBlock(config.DNSConfig{Enabled: true, Port: 53})
+	for _, want := range []string{
+		"dns:\n  enable: true\n  listen: 0.0.0.0:53\n",
+		"  enhanced-mode: fake-ip\n  fake-ip-range: 198.18.0.1/16\n",
+		"  fake-ip-filter:\n    - \"*.lan\"\n",
+		"  nameserver:\n    - \"223.5.5.5\"\n",
+		"    - \"https://1.1.1.1/dns-query#Proxy\"\n",
+		"    geoip-code: CN\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "nameserver-policy") {
+		t.Errorf("no policy configured, block should be omitted:\n%s", s)
+	}
+}
+
+func TestRenderDNSBlockCustom(t *testing.T) {
+	s := renderDNSBlock(config.DNSConfig{
+		Enabled:      true,
+		Port:         1053,
+		EnhancedMode: config.DNSModeRedirHost,
+		Nameservers:  []string{"10.0.0.1"},
+		Fallback:     []string{"tls://8.8.8.8#Proxy"},
+		NameserverPolicy: []config.NameserverPolicy{
+			{Match: "+.corp.example.com", Servers: []string{"10.0.0.53", "10.0.0.54"}},
+			{Match: "geosite:cn", Servers: []string{"223.5.5.5"}},
+		},
+		FakeIPFilter: []string{"+.nintendo.net"},
+	})
+	if strings.Contains(s, "fake-ip-range") || strings.Contains(s, "fake-ip-filter") {
+		t.Errorf("redir-host should not render fake-ip settings:\n%s", s)
+	}
+	for _, want := range []string{
+		"  listen: 0.0.0.0:1053\n",
+		"  enhanced-mode: redir-host\n",
+		"  nameserver:\n    - \"10.0.0.1\"\n  nameserver-policy:\n",
+		"    \"+.corp.example.com\":\n      - \"10.0.0.53\"\n      - \"10.0.0.54\"\n    \"geosite:cn\":\n",
+		"  fallback:\n    - \"tls://8.8.8.8#Proxy\"\n  fallback-filter:\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in:\n%s", want, s)
+		}
+	}
+	if strings.Contains(s, "dns.alidns.com") {
+		t.Errorf("custom nameservers should replace defaults:\n%s", s)
+	}
+
+	fake := renderDNSBlock(config.DNSConfig{Enabled: true, Port: 53, FakeIPFilter: []string{"+.nintendo.net"}})
+	if !strings.Contains(fake, "    - \"localhost.ptlogin2.qq.com\"\n    - \"+.nintendo.net\"\n") {
+		t.Errorf("fake_ip_filter should append to builtin filters:\n%s", fake)
+	}
+}
+
+func TestQuotaPoliciesFromWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	if err := stats.SaveQuotaBlocks(dir, []stats.QuotaBlock{
+		{IP: "192.168.1.23", Target: "reject", Until: now.Add(time.Hour)},
+		{IP: "192.168.1.50", Target: "direct", Until: now.Add(-time.Minute)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := quotaPolicies(dir, now)
+	if err != nil || len(got) != 1 || got[0].IP != "192.168.1.23" || got[0].Target != "reject" {
+		t.Fatalf("quota policies = %+v, %v", got, err)
+	}
+	if got, err := quotaPolicies(t.TempDir(), now); got != nil || err != nil {
+		t.Fatalf("no blocks file should yield no policies, got %+v, %v", got, err)
+	}
+
+	// 封禁表坏了：不打 stderr，作为渲染警告交给调用方。
+	if err := os.WriteFile(filepath.Join(dir, stats.QuotaBlocksFile), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	if _, warnings := DevicePolicies(cfg, dir, now); len(warnings) != 1 || !strings.Contains(warnings[0], stats.QuotaBlocksFile) {
+		t.Fatalf("broken quota blocks should be a warning, got %q", warnings)
+	}
+}
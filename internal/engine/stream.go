@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// --- mihomo 推送接口：/traffic、/logs、/connections（websocket）---
+//
+// 每个 Stream* 起一个后台 goroutine 订阅，把消息解码后送进返回的 channel；断线
+// （mihomo 重启 / 热重载 / 还没起来）时退避重连，ctx 结束时关 channel。消费方
+// 处理慢时丢最新一条而不是卡住读取 —— 这些都是「最新状态」型数据，丢一帧无所谓。
+
+const (
+	streamBackoffMin = 1 * time.Second
+	streamBackoffMax = 15 * time.Second
+)
+
+// LogLevels 是 mihomo /logs 接受的级别，从详细到安静。
+var LogLevels = []string{"debug", "info", "warning", "error", "silent"}
+
+// ValidLogLevel 报告 level 是不是 mihomo 认识的日志级别。
+func ValidLogLevel(level string) bool {
+	for _, l := range LogLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// TrafficTick 是 /traffic 每秒推一次的全局速率（bytes/s）。
+type TrafficTick struct {
+	Up   int64 `json:"up"`
+	Down int64 `json:"down"`
+}
+
+// LogEntry 是 /logs 推来的一条日志。mihomo 不带时间，Time 是收到的时刻。
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // debug / info / warning / error
+	Payload string    `json:"payload"`
+}
+
+// StreamTraffic 订阅全局实时速率。
+func (c *Client) StreamTraffic(ctx context.Context) <-chan TrafficTick {
+	out := make(chan TrafficTick, 1)
+	go func() {
+		defer close(out)
+		c.subscribe(ctx, "/traffic", nil, func(b []byte) {
+			var t TrafficTick
+			if json.Unmarshal(b, &t) == nil {
+				offer(out, t)
+			}
+		})
+	}()
+	return out
+}
+
+// StreamLogs 订阅 level 及以上的 mihomo 日志；level 为空时用 info。日志一条都不
+// 想丢，所以这里的 channel 留了缓冲，满了才丢。
+func (c *Client) StreamLogs(ctx context.Context, level string) <-chan LogEntry {
+	if level == "" {
+		level = "info"
+	}
+	out := make(chan LogEntry, 256)
+	go func() {
+		defer close(out)
+		c.subscribe(ctx, "/logs", url.Values{"level": {level}}, func(b []byte) {
+			var e LogEntry
+			if json.Unmarshal(b, &e) == nil {
+				e.Time = time.Now()
+				offer(out, e)
+			}
+		})
+	}()
+	return out
+}
+
+// StreamConnections 订阅连接快照，mihomo 每 interval 推一次完整的 /connections。
+func (c *Client) StreamConnections(ctx context.Context, interval time.Duration) <-chan *ConnectionsSnapshot {
+	out := make(chan *ConnectionsSnapshot, 1)
+	q := url.Values{"interval": {strconv.FormatInt(interval.Milliseconds(), 10)}}
+	go func() {
+		defer close(out)
+		c.subscribe(ctx, "/connections", q, func(b []byte) {
+			var s ConnectionsSnapshot
+			if json.Unmarshal(b, &s) == nil {
+				offer(out, &s)
+			}
+		})
+	}()
+	return out
+}
+
+// offer 非阻塞地送一条；channel 满了就丢掉这条。
+func offer[T any](ch chan T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+// subscribe 连上 path 持续读消息交给 handle，断了退避重连，直到 ctx 结束。
+func (c *Client) subscribe(ctx context.Context, path string, q url.Values, handle func([]byte)) {
+	backoff := streamBackoffMin
+	for ctx.Err() == nil {
+		connected, _ := c.readStream(ctx, path, q, handle)
+		if connected {
+			backoff = streamBackoffMin
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if !connected {
+			backoff = min(backoff*2, streamBackoffMax)
+		}
+	}
+}
+
+// readStream 连一次并读到断开。connected 表示握手成功过，用来重置退避。
+func (c *Client) readStream(ctx context.Context, path string, q url.Values, handle func([]byte)) (connected bool, err error) {
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	header := http.Header{}
+	if c.secret != "" {
+		header.Set("Authorization", "Bearer "+c.secret)
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ws, err := dialWS(dialCtx, u, header)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	// ctx 结束时关连接，把阻塞中的 ReadMessage 打断。
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	defer ws.Close()
+	for {
+		msg, err := ws.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return true, ctx.Err()
+			}
+			return true, fmt.Errorf("%s: %w", path, err)
+		}
+		handle(msg)
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wsServer 是测试用的最小 websocket 服务端：握手后调用 serve 写帧。
+func wsServer(t *testing.T, serve func(r *http.Request, conn net.Conn)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "not websocket", http.StatusBadRequest)
+			return
+		}
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		brw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		brw.Flush()
+		serve(r, conn)
+	}))
+}
+
+// serverFrame 拼一个服务端帧（不加掩码）。
+func serverFrame(fin bool, op byte, payload string) []byte {
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	f := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		f = append(f, byte(n))
+	default:
+		f = append(f, 126, byte(n>>8), byte(n))
+	}
+	return append(f, payload...)
+}
+
+func TestStreamTrafficReconnects(t *testing.T) {
+	var conns atomic.Int32
+	srv := wsServer(t, func(r *http.Request, conn net.Conn) {
+		if r.URL.Path != "/traffic" || r.Header.Get("Authorization") != "Bearer s3cret" {
+			t.Errorf("unexpected request %s auth=%q", r.URL, r.Header.Get("Authorization"))
+		}
+		n := conns.Add(1)
+		// 第一条连接：ping + 分片消息，然后直接断开，客户端应自动重连。
+		conn.Write(serverFrame(true, wsOpPing, "hi"))
+		var pong [8]byte
+		if _, err := io.ReadFull(conn, pong[:]); err != nil || pong[0]&0x0F != wsOpPong || pong[1]&0x80 == 0 {
+			t.Errorf("want masked pong, got %x err=%v", pong, err)
+		}
+		if n == 1 {
+			conn.Write(serverFrame(false, wsOpText, `{"up":1,`))
+			conn.Write(serverFrame(true, wsOpContinuation, `"down":2}`))
+			return
+		}
+		conn.Write(serverFrame(true, wsOpText, `{"up":3,"down":4}`))
+		time.Sleep(time.Second)
+	})
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.secret = "s3cret"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch := c.StreamTraffic(ctx)
+	var got []TrafficTick
+	for t := range ch {
+		got = append(got, t)
+		if len(got) == 2 {
+			cancel()
+		}
+	}
+	if len(got) != 2 || got[0] != (TrafficTick{1, 2}) || got[1] != (TrafficTick{3, 4}) {
+		t.Fatalf("got %+v", got)
+	}
+	if conns.Load() != 2 {
+		t.Errorf("connections = %d, want 2 (one reconnect)", conns.Load())
+	}
+}
+
+func TestStreamLogsLevel(t *testing.T) {
+	srv := wsServer(t, func(r *http.Request, conn net.Conn) {
+		if r.URL.Query().Get("level") != "warning" {
+			t.Errorf("level = %q", r.URL.Query().Get("level"))
+		}
+		conn.Write(serverFrame(true, wsOpText, `{"type":"warning","payload":"dial failed"}`))
+		conn.Write(serverFrame(true, wsOpClose, ""))
+		time.Sleep(time.Second)
+	})
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e, ok := <-NewClient(srv.URL).StreamLogs(ctx, "warning")
+	if !ok || e.Type != "warning" || e.Payload != "dial failed" || e.Time.IsZero() {
+		t.Fatalf("got %+v ok=%v", e, ok)
+	}
+}
+
+func TestDialWSRejectsNonUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	if _, err := dialWS(context.Background(), srv.URL+"/logs", nil); err == nil {
+		t.Fatal("HTTP 401 should fail the handshake")
+	}
+}
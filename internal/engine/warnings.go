@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// RenderWarningsFile 记着最近一次渲染 config.yaml 时跳过了什么（离线设备的 MAC
+// 策略、读不到的 ARP 表……）。渲染在菜单、定时策略、订阅刷新等后台循环里都会跑，
+// 库代码直接往 stderr 打会把交互式菜单搅花，而且每次重载都刷一遍；落盘后由
+// status / 菜单按需展示，下次渲染覆盖。
+const RenderWarningsFile = "render-warnings.json"
+
+// saveRenderWarnings 覆盖写 workDir/render-warnings.json；没有警告时删掉文件。
+func saveRenderWarnings(workDir string, warnings []string) error {
+	path := filepath.Join(workDir, RenderWarningsFile)
+	if len(warnings) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(warnings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// LoadRenderWarnings 读最近一次渲染留下的警告；文件不存在或读不了返回 nil。
+func LoadRenderWarnings(workDir string) []string {
+	b, err := os.ReadFile(filepath.Join(workDir, RenderWarningsFile))
+	if err != nil {
+		return nil
+	}
+	var warnings []string
+	if json.Unmarshal(b, &warnings) != nil {
+		return nil
+	}
+	return warnings
+}
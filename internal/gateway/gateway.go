@@ -64,11 +64,16 @@ func (g *Gateway) Enable(mode string, redirPort int) error {
 		return fmt.Errorf("enable IP forwarding: %w", err)
 	}
 
+	installedRedirect := 0
 	if mode == "forward" {
-		// Best-effort: on Linux, pf/iptables redirect enables redir-port based
-		// transparent proxy. On macOS redir-port is not supported by mihomo,
-		// so we skip and fall back to TUN with bypass_local.
-		if err := g.plat.ConfigurePFRedirect(g.info.Interface, redirPort); err != nil && !errors.Is(err, platform.ErrNotSupported) {
+		// Best-effort: on Linux, iptables/nftables REDIRECT enables redir-port
+		// based transparent proxy. On macOS redir-port is not supported by
+		// mihomo, so we skip and fall back to TUN with bypass_local.
+		err := g.plat.ConfigurePFRedirect(g.info.Interface, redirPort)
+		switch {
+		case err == nil:
+			installedRedirect = redirPort
+		case !errors.Is(err, platform.ErrNotSupported):
 			return fmt.Errorf("configure pf redirect: %w", err)
 		}
 	}
@@ -80,6 +85,7 @@ func (g *Gateway) Enable(mode string, redirPort int) error {
 		NATInterface:       g.info.Interface,
 		WeEnabledIPForward: existing.WeEnabledIPForward || !priorForward,
 		GatewayMode:        mode,
+		RedirectPort:       installedRedirect,
 	}
 	_ = writeRuntimeState(g.statePath, state)
 	return nil
@@ -106,7 +112,7 @@ func (g *Gateway) Enable(mode string, redirPort int) error {
 func (g *Gateway) Disable() error {
 	state, _ := readRuntimeState(g.statePath)
 
-	if state.GatewayMode == "forward" {
+	if state.GatewayMode == "forward" || state.RedirectPort > 0 {
 		_ = g.plat.UnconfigurePFRedirect()
 	}
 
@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 
@@ -16,6 +17,8 @@ type fakePlatform struct {
 	// 模拟当前 ip_forward 的状态：start 前 false 表示用户原本是 0；true 表示原本就是 1
 	// （docker / systemd-sysctl 已经打开）
 	forwardOn bool
+	// 让 ConfigurePFRedirect 可以注入错误（比如 ErrNotSupported 模拟没装 iptables / nft）
+	pfRedirectErr error
 }
 
 func (f *fakePlatform) DetectNetwork() (platform.NetworkInfo, error) {
@@ -54,7 +57,7 @@ func (f *fakePlatform) RestoreLocalDNS() error                   { return nil }
 func (f *fakePlatform) LocalDNSIsLoopback() (bool, error)        { return false, nil }
 func (f *fakePlatform) ConfigurePFRedirect(iface string, port int) error {
 	f.calls = append(f.calls, "ConfigurePFRedirect:"+iface+":"+itoa(port))
-	return nil
+	return f.pfRedirectErr
 }
 func (f *fakePlatform) UnconfigurePFRedirect() error {
 	f.calls = append(f.calls, "UnconfigurePFRedirect")
@@ -265,3 +268,42 @@ func TestEnable_TUNModeSkipsPFRedirect(t *testing.T) {
 		}
 	}
 }
+
+// 规则真正装上时 state 要记下 redir-port，独立进程的 `gateway stop` 才知道要清。
+func TestEnable_ForwardModeRecordsRedirectPort(t *testing.T) {
+	fp := &fakePlatform{}
+	g := newGateway(t, fp)
+	if err := g.Enable("forward", 17892); err != nil {
+		t.Fatalf("Enable forward: %v", err)
+	}
+	state, err := readRuntimeState(g.statePath)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if state.RedirectPort != 17892 {
+		t.Fatalf("state.RedirectPort = %d, want 17892", state.RedirectPort)
+	}
+}
+
+// 没装 iptables / nft 时平台报 ErrNotSupported：Enable 不应失败（退化成代理服务），
+// state 里也不能记 RedirectPort。
+func TestEnable_ForwardModeToleratesNotSupported(t *testing.T) {
+	fp := &fakePlatform{pfRedirectErr: platform.ErrNotSupported}
+	g := newGateway(t, fp)
+	if err := g.Enable("forward", 17892); err != nil {
+		t.Fatalf("ErrNotSupported 应被兜住；got %v", err)
+	}
+	state, _ := readRuntimeState(g.statePath)
+	if state.RedirectPort != 0 {
+		t.Fatalf("redirect 没装上时不应记端口；got %d", state.RedirectPort)
+	}
+}
+
+// iptables 报真错误（比如缺 nat 模块）时 Enable 要把错误抛出来，不能假装成功。
+func TestEnable_ForwardModeSurfacesRedirectError(t *testing.T) {
+	fp := &fakePlatform{pfRedirectErr: errors.New("iptables: No chain/target/match by that name")}
+	g := newGateway(t, fp)
+	if err := g.Enable("forward", 17892); err == nil {
+		t.Fatal("expected configure pf redirect error")
+	}
+}
@@ -0,0 +1,95 @@
+// Package metrics 写 Prometheus 文本格式（exposition format 0.0.4）。
+//
+// 只用到 gauge / counter、不要 histogram，犯不上为此引入 client_golang 一整套依赖；
+// 这里负责 HELP / TYPE 只写一次、标签值转义、指标按名字聚在一起这几件事。
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType 是文本格式的 Content-Type。
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Writer 按调用顺序写指标。同名指标的样本要连续写（Prometheus 要求同一族在一起）。
+type Writer struct {
+	w    *bufio.Writer
+	seen map[string]bool
+}
+
+// NewWriter 包一层 w；写完调用 Flush。
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), seen: map[string]bool{}}
+}
+
+// Gauge 写一个 gauge 样本。labels 是 key, value 交替的列表。
+func (m *Writer) Gauge(name, help string, v float64, labels ...string) {
+	m.sample("gauge", name, help, v, labels)
+}
+
+// Counter 写一个 counter 样本；name 按惯例以 _total 结尾。
+func (m *Writer) Counter(name, help string, v float64, labels ...string) {
+	m.sample("counter", name, help, v, labels)
+}
+
+// Flush 把缓冲写出去。
+func (m *Writer) Flush() error {
+	return m.w.Flush()
+}
+
+func (m *Writer) sample(kind, name, help string, v float64, labels []string) {
+	if !m.seen[name] {
+		m.seen[name] = true
+		fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+	}
+	m.w.WriteString(name)
+	if len(labels) >= 2 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			m.w.WriteString(labels[i])
+			m.w.WriteString(`="`)
+			m.w.WriteString(escapeLabel(labels[i+1]))
+			m.w.WriteByte('"')
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(formatValue(v))
+	m.w.WriteByte('\n')
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Bool 把 true / false 换成 1 / 0。
+func Bool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriterFormat(t *testing.T) {
+	var b strings.Builder
+	m := NewWriter(&b)
+	m.Gauge("x_up", "是否在跑", Bool(true))
+	m.Counter("x_bytes_total", "字节数", 1.5e10, "ip", "192.168.1.2", "name", `a"b\c`)
+	m.Counter("x_bytes_total", "字节数", 3, "ip", "192.168.1.3", "name", "")
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP x_up 是否在跑
+# TYPE x_up gauge
+x_up 1
+# HELP x_bytes_total 字节数
+# TYPE x_bytes_total counter
+x_bytes_total{ip="192.168.1.2",name="a\"b\\c"} 1.5e+10
+x_bytes_total{ip="192.168.1.3",name=""} 3
+`
+	if b.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
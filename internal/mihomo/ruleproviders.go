@@ -0,0 +1,95 @@
+package mihomo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RuleProviderFile 描述一个要落到 mihomo 工作目录里的规则集缓存文件。
+// URL 和 Source 二选一：http 规则集给 URL，本地规则集给 Source（用户文件路径）。
+type RuleProviderFile struct {
+	Name   string
+	Path   string // 工作目录内的目标路径（绝对路径）
+	URL    string
+	Source string
+	Text   bool // format=text；决定占位文件写什么
+}
+
+// EnsureRuleProviders 保证每个规则集在 workDir 里都有一份可读的文件，mihomo 启动时
+// 直接加载，不因为首次下载失败而起不来：
+//   - 本地规则集：每次都从 Source 拷一份（用户改了文件，重启就生效）；拷失败保留旧的；
+//   - http 规则集：已有缓存就不动（后续由 mihomo 按 interval 自己更新）；没有就下一份，
+//     先走 upstreamProxy，代理不通退回直连；
+//   - 都拿不到：写一个空规则集占位并把 mtime 拨回 1970，mihomo 会认为它过期、联网后
+//     立即重拉，下次启动这里也会再试。
+func EnsureRuleProviders(files []RuleProviderFile, upstreamProxy string, logf func(format string, args ...any)) {
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	proxyClient := newGeodataClient(upstreamProxy)
+	directClient := newGeodataClient("")
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			logf("  ! 规则集 %s: %v", f.Name, err)
+			continue
+		}
+		switch {
+		case f.Source != "":
+			if err := copyFile(f.Source, f.Path); err != nil {
+				logf("  ! 规则集 %s: 拷贝 %s 失败: %v", f.Name, f.Source, err)
+			}
+		case f.URL != "":
+			if ruleProviderCached(f) {
+				continue
+			}
+			err := downloadTo(proxyClient, f.URL, f.Path)
+			if err != nil && upstreamProxy != "" && isProxyUnreachable(err) {
+				err = downloadTo(directClient, f.URL, f.Path)
+			}
+			if err != nil {
+				logf("  ! 规则集 %s 下载失败: %v", f.Name, err)
+			}
+		}
+		if !fileExists(f.Path) {
+			if err := writeRuleProviderPlaceholder(f); err != nil {
+				logf("  ! 规则集 %s 占位文件写入失败: %v", f.Name, err)
+			}
+		}
+	}
+}
+
+// ruleProviderPlaceholder 返回空规则集内容：yaml 是 payload: []，text 是一行注释。
+func ruleProviderPlaceholder(text bool) []byte {
+	if text {
+		return []byte("# lan-proxy-gateway placeholder: rule provider not downloaded yet\n")
+	}
+	return []byte("payload: []\n")
+}
+
+// ruleProviderCached 报告 f.Path 已有一份真正下载过的缓存（不是占位文件）。
+func ruleProviderCached(f RuleProviderFile) bool {
+	data, err := os.ReadFile(f.Path)
+	if err != nil || len(data) == 0 {
+		return false
+	}
+	return !bytes.Equal(data, ruleProviderPlaceholder(f.Text))
+}
+
+func writeRuleProviderPlaceholder(f RuleProviderFile) error {
+	if err := os.WriteFile(f.Path, ruleProviderPlaceholder(f.Text), 0o644); err != nil {
+		return err
+	}
+	epoch := time.Unix(0, 0)
+	if err := os.Chtimes(f.Path, epoch, epoch); err != nil {
+		return fmt.Errorf("chtimes: %w", err)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
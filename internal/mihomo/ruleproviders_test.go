@@ -0,0 +1,61 @@
+package mihomo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureRuleProvidersDownloadsCopiesAndPlaceholds(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, "payload:\n  - DOMAIN-SUFFIX,openai.com\n")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "my-rules.txt")
+	if err := os.WriteFile(local, []byte("DOMAIN,a.example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(dir, "work")
+	files := []RuleProviderFile{
+		{Name: "openai", Path: filepath.Join(work, "rule-providers", "openai.yaml"), URL: srv.URL},
+		{Name: "mine", Path: filepath.Join(work, "rule-providers", "mine.txt"), Source: local, Text: true},
+	}
+
+	// 断网首启：http 规则集拿不到 → 占位文件，mtime 拨回 1970 让 mihomo 立刻重拉
+	up = false
+	EnsureRuleProviders(files, "", nil)
+	data, err := os.ReadFile(files[0].Path)
+	if err != nil || string(data) != "payload: []\n" {
+		t.Fatalf("expected placeholder, got %q err=%v", data, err)
+	}
+	if info, _ := os.Stat(files[0].Path); info.ModTime().Unix() != 0 {
+		t.Fatalf("placeholder mtime should be epoch, got %v", info.ModTime())
+	}
+	if data, _ := os.ReadFile(files[1].Path); string(data) != "DOMAIN,a.example\n" {
+		t.Fatalf("local provider should be copied, got %q", data)
+	}
+
+	// 联网后再启动：占位文件不算缓存，要重新下载
+	up = true
+	EnsureRuleProviders(files, "", nil)
+	if data, _ := os.ReadFile(files[0].Path); string(data) != "payload:\n  - DOMAIN-SUFFIX,openai.com\n" {
+		t.Fatalf("expected downloaded provider, got %q", data)
+	}
+
+	// 已有真缓存：再断网也不覆盖
+	up = false
+	EnsureRuleProviders(files, "", nil)
+	if data, _ := os.ReadFile(files[0].Path); string(data) != "payload:\n  - DOMAIN-SUFFIX,openai.com\n" {
+		t.Fatalf("cached provider should survive offline start, got %q", data)
+	}
+}
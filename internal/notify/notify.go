@@ -0,0 +1,150 @@
+// Package notify 把网关事件（源挂了、切直连、订阅快到期、mihomo 崩溃、新设备）
+// 推到用户配的出口：通用 JSON webhook、本机命令，以及 ntfy / Gotify / Bark
+// 这几种手机推送服务的格式。
+//
+// 发送是异步的，出口慢或挂了不会拖住 supervisor；同一事件在冷却时间内只发
+// 一次，源来回抖动时不会刷屏。
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// Event 是一条要推送的事件。
+type Event struct {
+	Kind    string    `json:"kind"` // config.Notify* 之一
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+	// Key 区分同类事件的不同对象（哪份订阅、哪台设备），限流按 Kind+Key 算。
+	Key string `json:"key,omitempty"`
+}
+
+// sendTimeout 是单个出口一次发送的上限。
+const sendTimeout = 10 * time.Second
+
+// minInterval 是个别事件的最短间隔，比 notify.cooldown 大时以它为准：
+// 订阅到期每轮都会算出来，一天提醒一次足够。
+var minInterval = map[string]time.Duration{
+	config.NotifySubExpiry: 24 * time.Hour,
+}
+
+// Notifier 负责限流和异步投递。零值不可用，用 New。
+type Notifier struct {
+	mu   sync.Mutex
+	last map[string]time.Time // Kind|Key → 上次发出时间
+	// send 是实际投递，测试里替换。
+	send func(ctx context.Context, cfg config.NotifyConfig, ev Event) []error
+}
+
+// New 返回一个 Notifier。
+func New() *Notifier {
+	return &Notifier{last: map[string]time.Time{}, send: Send}
+}
+
+// Notify 按 cfg 异步推送 ev。没配出口、或者同一事件还在冷却期内时直接丢掉，
+// 返回 false。投递失败只能丢掉：通知本身出了问题，没有别的渠道可以报。
+func (n *Notifier) Notify(cfg config.NotifyConfig, ev Event) bool {
+	if !cfg.Enabled() {
+		return false
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	if !n.allow(cfg, ev) {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		_ = n.send(ctx, cfg, ev)
+	}()
+	return true
+}
+
+func (n *Notifier) allow(cfg config.NotifyConfig, ev Event) bool {
+	every := cfg.CooldownDuration()
+	if d := minInterval[ev.Kind]; d > every {
+		every = d
+	}
+	key := ev.Kind + "|" + ev.Key
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if last, ok := n.last[key]; ok && ev.At.Sub(last) < every {
+		return false
+	}
+	n.last[key] = ev.At
+	return true
+}
+
+// Send 同步把 ev 发给所有想要它的出口，不限流，返回每个失败出口的错误。
+// `gateway notify test` 直接用它，能把配置错误当场报出来。
+func Send(ctx context.Context, cfg config.NotifyConfig, ev Event) []error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for i, s := range cfg.Sinks {
+		if !cfg.Wants(s, ev.Kind) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := NewSink(s).Send(sendCtx, ev); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("sinks[%d] %s: %w", i, s.Type, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// Sink 是一个通知出口。
+type Sink interface {
+	Send(ctx context.Context, ev Event) error
+}
+
+// NewSink 按配置构造出口。配置已经过 config.Validate，这里不再重复校验。
+func NewSink(s config.NotifySink) Sink {
+	switch s.Type {
+	case config.NotifySinkWebhook:
+		return webhookSink{url: s.URL, headers: s.Headers}
+	case config.NotifySinkCommand:
+		return commandSink{argv: s.Command}
+	case config.NotifySinkNtfy:
+		return ntfySink{url: s.URL, token: s.Token}
+	case config.NotifySinkGotify:
+		return gotifySink{url: s.URL, token: s.Token}
+	case config.NotifySinkBark:
+		return barkSink{url: s.URL}
+	}
+	return errSink{fmt.Errorf("未知出口类型 %q", s.Type)}
+}
+
+type errSink struct{ err error }
+
+func (s errSink) Send(context.Context, Event) error { return s.err }
+
+// ErrNoSinks 是没配任何出口时 `gateway notify test` 的报错。
+var ErrNoSinks = errors.New("还没配通知出口（runtime.notify.sinks）")
+
+// urgent 报告事件要不要用高优先级推送（ntfy / Gotify 会据此响铃）。
+func urgent(kind string) bool {
+	switch kind {
+	case config.NotifySourceDown, config.NotifyFallbackDirect, config.NotifyEngineCrash:
+		return true
+	}
+	return false
+}
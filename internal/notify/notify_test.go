@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestNotifyRateLimitsPerKindAndKey(t *testing.T) {
+	var mu sync.Mutex
+	sent := 0
+	n := New()
+	n.send = func(context.Context, config.NotifyConfig, Event) []error {
+		mu.Lock()
+		sent++
+		mu.Unlock()
+		return nil
+	}
+	cfg := config.NotifyConfig{Cooldown: "30m", Sinks: []config.NotifySink{{Type: config.NotifySinkWebhook, URL: "http://x"}}}
+	t0 := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+
+	if !n.Notify(cfg, Event{Kind: config.NotifySourceDown, At: t0}) {
+		t.Fatal("first event must go out")
+	}
+	if n.Notify(cfg, Event{Kind: config.NotifySourceDown, At: t0.Add(10 * time.Minute)}) {
+		t.Fatal("flapping within cooldown must be dropped")
+	}
+	if !n.Notify(cfg, Event{Kind: config.NotifySourceUp, At: t0.Add(10 * time.Minute)}) {
+		t.Fatal("a different kind has its own cooldown")
+	}
+	if !n.Notify(cfg, Event{Kind: config.NotifySourceDown, At: t0.Add(31 * time.Minute)}) {
+		t.Fatal("after cooldown the event should go out again")
+	}
+	if !n.Notify(cfg, Event{Kind: config.NotifyNewDevice, Key: "192.168.1.20", At: t0}) ||
+		!n.Notify(cfg, Event{Kind: config.NotifyNewDevice, Key: "192.168.1.21", At: t0}) {
+		t.Fatal("different keys must not share a cooldown")
+	}
+	// 订阅到期至少隔 24h，哪怕 cooldown 更短。
+	n.Notify(cfg, Event{Kind: config.NotifySubExpiry, Key: "airport", At: t0})
+	if n.Notify(cfg, Event{Kind: config.NotifySubExpiry, Key: "airport", At: t0.Add(2 * time.Hour)}) {
+		t.Fatal("expiry reminders are at most daily")
+	}
+	if n.Notify(config.NotifyConfig{}, Event{Kind: config.NotifyEngineCrash, At: t0}) {
+		t.Fatal("no sinks configured: nothing to send")
+	}
+}
+
+func TestSendFormats(t *testing.T) {
+	type hit struct {
+		path   string
+		header http.Header
+		body   string
+	}
+	hits := make(chan hit, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		hits <- hit{r.URL.Path, r.Header.Clone(), string(b)}
+		if r.URL.Path == "/broken" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	ev := Event{Kind: config.NotifyEngineCrash, Title: "mihomo 意外退出", Message: "已自动重启", At: time.Now()}
+	cfg := config.NotifyConfig{Sinks: []config.NotifySink{
+		{Type: config.NotifySinkNtfy, URL: srv.URL + "/gw", Token: "tk"},
+	}}
+	if errs := Send(context.Background(), cfg, ev); len(errs) != 0 {
+		t.Fatalf("ntfy: %v", errs)
+	}
+	h := <-hits
+	title, _ := new(mime.WordDecoder).DecodeHeader(h.header.Get("Title"))
+	if h.path != "/gw" || h.body != "已自动重启" || title != ev.Title || h.header.Get("Priority") != "high" || h.header.Get("Authorization") != "Bearer tk" {
+		t.Fatalf("ntfy request = %+v (title %q)", h, title)
+	}
+
+	cfg.Sinks = []config.NotifySink{{Type: config.NotifySinkGotify, URL: srv.URL + "/", Token: "app"}}
+	if errs := Send(context.Background(), cfg, ev); len(errs) != 0 {
+		t.Fatalf("gotify: %v", errs)
+	}
+	h = <-hits
+	var g struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	_ = json.Unmarshal([]byte(h.body), &g)
+	if h.path != "/message" || h.header.Get("X-Gotify-Key") != "app" || g.Title != ev.Title || g.Priority != 8 {
+		t.Fatalf("gotify request = %+v / %+v", h, g)
+	}
+
+	cfg.Sinks = []config.NotifySink{
+		{Type: config.NotifySinkWebhook, URL: srv.URL + "/hook", Headers: map[string]string{"X-Token": "s"}},
+		{Type: config.NotifySinkBark, URL: srv.URL + "/broken", Events: []string{config.NotifyEngineCrash}},
+		{Type: config.NotifySinkBark, URL: srv.URL + "/skipped", Events: []string{config.NotifyNewDevice}},
+	}
+	errs := Send(context.Background(), cfg, ev)
+	if len(errs) != 1 {
+		t.Fatalf("want exactly the bark 401, got %v", errs)
+	}
+	close(hits)
+	paths := map[string]hit{}
+	for h := range hits {
+		paths[h.path] = h
+	}
+	if _, ok := paths["/skipped"]; ok {
+		t.Fatal("sink filtered by events must not be called")
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(paths["/hook"].body), &got); err != nil || got.Kind != ev.Kind || paths["/hook"].header.Get("X-Token") != "s" {
+		t.Fatalf("webhook body = %q, err %v", paths["/hook"].body, err)
+	}
+}
+
+func TestCommandSinkGetsEventOnStdinAndEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	out := filepath.Join(t.TempDir(), "event")
+	sink := NewSink(config.NotifySink{Type: config.NotifySinkCommand,
+		Command: []string{"/bin/sh", "-c", `{ echo "$GATEWAY_EVENT_KIND"; cat; } > "$0"`, out}})
+	ev := Event{Kind: config.NotifyNewDevice, Title: "发现新设备", Key: "192.168.1.20"}
+	if err := sink.Send(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(out)
+	kind, body, _ := strings.Cut(string(b), "\n")
+	if kind != config.NotifyNewDevice || !strings.Contains(body, `"key":"192.168.1.20"`) {
+		t.Fatalf("command saw %q", b)
+	}
+
+	failing := NewSink(config.NotifySink{Type: config.NotifySinkCommand, Command: []string{"/bin/sh", "-c", "echo nope >&2; exit 3"}})
+	if err := failing.Send(context.Background(), ev); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("want exit error with stderr, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// webhookSink 把事件 JSON 原样 POST 出去，给自建服务 / IFTTT / n8n 之类用。
+type webhookSink struct {
+	url     string
+	headers map[string]string
+}
+
+func (s webhookSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.url, "application/json", body, s.headers)
+}
+
+// ntfySink 走 ntfy 的纯文本发布：body 是正文，标题 / 优先级 / 标签放 header。
+// url 里带 topic，例 https://ntfy.sh/my-gateway。中文标题按 RFC 2047 编码，
+// ntfy 会解回来。
+type ntfySink struct {
+	url   string
+	token string
+}
+
+func (s ntfySink) Send(ctx context.Context, ev Event) error {
+	h := map[string]string{"Title": mime.BEncoding.Encode("UTF-8", ev.Title), "Tags": ev.Kind}
+	if urgent(ev.Kind) {
+		h["Priority"] = "high"
+	}
+	if s.token != "" {
+		h["Authorization"] = "Bearer " + s.token
+	}
+	return post(ctx, s.url, "text/plain; charset=utf-8", []byte(ev.Message), h)
+}
+
+// gotifySink 调 Gotify 的 POST /message，token 是应用 token。
+type gotifySink struct {
+	url   string
+	token string
+}
+
+func (s gotifySink) Send(ctx context.Context, ev Event) error {
+	priority := 5
+	if urgent(ev.Kind) {
+		priority = 8
+	}
+	body, err := json.Marshal(map[string]any{"title": ev.Title, "message": ev.Message, "priority": priority})
+	if err != nil {
+		return err
+	}
+	return post(ctx, strings.TrimRight(s.url, "/")+"/message", "application/json", body,
+		map[string]string{"X-Gotify-Key": s.token})
+}
+
+// barkSink 调 Bark 的 POST /:key，url 里已经带了 device key。
+type barkSink struct {
+	url string
+}
+
+func (s barkSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(map[string]string{"title": ev.Title, "body": ev.Message, "group": "lan-proxy-gateway"})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.url, "application/json", body, nil)
+}
+
+// commandSink 跑一个本机命令：事件 JSON 从 stdin 进，另外放进 GATEWAY_EVENT_*
+// 环境变量，shell 脚本不用解析 JSON 也能用。
+type commandSink struct {
+	argv []string
+}
+
+func (s commandSink) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, s.argv[0], s.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"GATEWAY_EVENT_KIND="+ev.Kind,
+		"GATEWAY_EVENT_TITLE="+ev.Title,
+		"GATEWAY_EVENT_MESSAGE="+ev.Message,
+		"GATEWAY_EVENT_KEY="+ev.Key,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, firstLine(msg))
+		}
+		return err
+	}
+	return nil
+}
+
+func post(ctx context.Context, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, firstLine(strings.TrimSpace(string(msg))))
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
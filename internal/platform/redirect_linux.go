@@ -0,0 +1,69 @@
+//go:build linux
+
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ConfigurePFRedirect 是 Linux 版的透明旁路由：把从 iface 进来的 LAN TCP
+// 流量 REDIRECT 到 mihomo 的 redir-port（语义对齐 macOS 的 pf rdr）。
+//
+// 优先 iptables（含 iptables-nft 兼容层，绝大多数发行版默认都有）；只装了
+// nft 的新系统（Debian 12 minimal / Arch）走 nftables 独占表。两套后端都：
+//   - 排除目标是本机的流量（addrtype LOCAL / fib daddr type local），避免
+//     LAN 设备访问网关自己的 Web UI / SSH 也被劫持进 mihomo；
+//   - 排除私有 / 保留网段，LAN 内互访照常走内核转发；
+//   - 每条规则打 redirectTag comment，UnconfigurePFRedirect 只删我们加的。
+//
+// 先做一遍清理再装，保证重复 Enable（进程崩溃后重启）不会叠出多份规则。
+func (p linuxPlatform) ConfigurePFRedirect(iface string, redirPort int) error {
+	if iface == "" {
+		return fmt.Errorf("empty interface name")
+	}
+	if redirPort <= 0 {
+		return fmt.Errorf("invalid redir port %d", redirPort)
+	}
+	_ = p.UnconfigurePFRedirect()
+
+	switch {
+	case commandExists("iptables"):
+		for _, args := range iptablesRedirectRules(iface, redirPort) {
+			if _, err := run("iptables", append([]string{"-t", "nat"}, args...)...); err != nil {
+				_ = p.UnconfigurePFRedirect()
+				return err
+			}
+		}
+		return nil
+	case commandExists("nft"):
+		cmd := exec.Command("nft", "-f", "-")
+		cmd.Stdin = strings.NewReader(nftRedirectScript(iface, redirPort))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("nft -f: %w: %s", err, out)
+		}
+		return nil
+	}
+	// 两个都没有时按 ErrNotSupported 报：Gateway.Enable 会兜住，退化成只跑
+	// mihomo mixed-port + DNS，和以前 stub 的行为一致。
+	return fmt.Errorf("%w: iptables / nft 都未安装", ErrNotSupported)
+}
+
+// UnconfigurePFRedirect 撤掉 ConfigurePFRedirect 加的所有规则。best-effort：
+// 两套后端都扫一遍（用户可能在两次启动之间换过后端），规则不存在不算错。
+func (linuxPlatform) UnconfigurePFRedirect() error {
+	if commandExists("iptables") {
+		if out, err := exec.Command("iptables", "-t", "nat", "-S", "PREROUTING").Output(); err == nil {
+			for _, args := range parseTaggedIptablesRules(string(out)) {
+				_, _ = run("iptables", append([]string{"-t", "nat"}, args...)...)
+			}
+		}
+		_, _ = run("iptables", "-t", "nat", "-F", redirectChain)
+		_, _ = run("iptables", "-t", "nat", "-X", redirectChain)
+	}
+	if commandExists("nft") {
+		_, _ = run("nft", "delete", "table", "ip", redirectNFTTable)
+	}
+	return nil
+}
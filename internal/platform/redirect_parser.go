@@ -0,0 +1,114 @@
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// redirectTag 是 forward 模式下我们加的每条 iptables / nftables 规则上的 comment。
+// 清理时只删带这个 tag 的规则，管理员 / docker 自己的 nat 规则一条都不碰。
+// 不含空格：`iptables -S` 输出时就不会被加引号，strings.Fields 能直接切。
+const redirectTag = "lan-proxy-gateway"
+
+// redirectChain 是 iptables nat 表里我们自建的链名；PREROUTING 只挂一条跳转。
+const redirectChain = "LAN_PROXY_GATEWAY"
+
+// redirectNFTTable 是 nftables 后端下我们独占的表（family ip）。
+// 整张表都是我们的，清理时 delete table 即可。
+const redirectNFTTable = "lan_proxy_gateway"
+
+// redirectBypassCIDRs 是 forward 模式下不 REDIRECT 的目标网段：LAN / 回环 /
+// 链路本地 / CGNAT / 组播 / 保留段。LAN 设备访问这些地址时照常走内核转发，
+// 不进 mihomo（否则 NAS / 打印机 / 路由器管理页都要绕 mihomo 一圈）。
+var redirectBypassCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+}
+
+// iptablesRedirectRules 返回 forward 模式要装进 nat 表的规则（每项是一条
+// `iptables -t nat` 的参数，不含 "iptables -t nat" 本身）。顺序即执行顺序：
+//  1. 新建 redirectChain
+//  2. 链内：目标是本机（addrtype LOCAL）→ RETURN
+//  3. 链内：目标是私有网段 → RETURN
+//  4. 链内：其余 TCP → REDIRECT 到 redir-port
+//  5. PREROUTING 里挂一条 -i iface -p tcp 的跳转
+//
+// 每条规则都打 redirectTag comment，cleanup 按 tag 精准删除。
+// 纯函数，放在无 build tag 的文件里，方便非 Linux CI 也能跑单测。
+func iptablesRedirectRules(iface string, redirPort int) [][]string {
+	comment := []string{"-m", "comment", "--comment", redirectTag}
+	rules := [][]string{
+		{"-N", redirectChain},
+		append(append([]string{"-A", redirectChain, "-m", "addrtype", "--dst-type", "LOCAL"}, comment...), "-j", "RETURN"),
+	}
+	for _, cidr := range redirectBypassCIDRs {
+		rules = append(rules, append(append([]string{"-A", redirectChain, "-d", cidr}, comment...), "-j", "RETURN"))
+	}
+	rules = append(rules,
+		append(append([]string{"-A", redirectChain, "-p", "tcp"}, comment...),
+			"-j", "REDIRECT", "--to-ports", strconv.Itoa(redirPort)),
+		append(append([]string{"-A", "PREROUTING", "-i", iface, "-p", "tcp"}, comment...),
+			"-j", redirectChain),
+	)
+	return rules
+}
+
+// parseTaggedIptablesRules 扫 `iptables -t nat -S <chain>` 的输出，把带
+// redirectTag comment 的 `-A ...` 行翻译成对应的 `-D ...` 参数，供 cleanup 逐条删除。
+//
+// 例：
+//
+//	-A PREROUTING -i eth0 -p tcp -m comment --comment lan-proxy-gateway -j LAN_PROXY_GATEWAY
+//
+// → ["-D", "PREROUTING", "-i", "eth0", "-p", "tcp", "-m", "comment", "--comment", "lan-proxy-gateway", "-j", "LAN_PROXY_GATEWAY"]
+//
+// 没有 tag 的行（docker / 管理员自己的规则）一律跳过。
+func parseTaggedIptablesRules(output string) [][]string {
+	var out [][]string
+	for _, raw := range strings.Split(output, "\n") {
+		fields := strings.Fields(strings.TrimSpace(raw))
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		tagged := false
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "--comment" && strings.Trim(fields[i+1], `"`) == redirectTag {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			continue
+		}
+		del := append([]string{"-D"}, fields[1:]...)
+		for i := range del {
+			del[i] = strings.Trim(del[i], `"`)
+		}
+		out = append(out, del)
+	}
+	return out
+}
+
+// nftRedirectScript 生成 `nft -f -` 的输入：一张独占表 + 一个 nat prerouting 链，
+// 语义与 iptablesRedirectRules 相同。priority -100 = dstnat，老版本 nft 也认。
+func nftRedirectScript(iface string, redirPort int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table ip %s {\n", redirectNFTTable)
+	b.WriteString("  chain prerouting {\n")
+	b.WriteString("    type nat hook prerouting priority -100; policy accept;\n")
+	fmt.Fprintf(&b, "    iifname %q fib daddr type local return comment %q\n", iface, redirectTag)
+	fmt.Fprintf(&b, "    iifname %q ip daddr { %s } return comment %q\n",
+		iface, strings.Join(redirectBypassCIDRs, ", "), redirectTag)
+	fmt.Fprintf(&b, "    iifname %q meta l4proto tcp redirect to :%d comment %q\n", iface, redirPort, redirectTag)
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
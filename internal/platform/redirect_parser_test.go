@@ -0,0 +1,107 @@
+package platform
+
+// Tests for the portable iptables / nftables redirect helpers. No build tag —
+// the rule builders and parser are pure Go (redirect_parser.go), only the
+// exec side lives in redirect_linux.go.
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// `iptables -t nat -S PREROUTING` 在装了 docker + 我们的规则后的真实输出形态。
+// docker 的 DOCKER 跳转必须原样保留，只有带 lan-proxy-gateway comment 的那条要删。
+const iptablesPreroutingWithDocker = `-P PREROUTING ACCEPT
+-A PREROUTING -m addrtype --dst-type LOCAL -j DOCKER
+-A PREROUTING -i eth0 -p tcp -m comment --comment lan-proxy-gateway -j LAN_PROXY_GATEWAY
+`
+
+// 老版本 iptables 会给 comment 加引号。
+const iptablesPreroutingQuoted = `-P PREROUTING ACCEPT
+-A PREROUTING -i enp3s0 -p tcp -m comment --comment "lan-proxy-gateway" -j LAN_PROXY_GATEWAY
+`
+
+func TestParseTaggedIptablesRules_OnlyOurs(t *testing.T) {
+	got := parseTaggedIptablesRules(iptablesPreroutingWithDocker)
+	want := [][]string{{
+		"-D", "PREROUTING", "-i", "eth0", "-p", "tcp",
+		"-m", "comment", "--comment", "lan-proxy-gateway", "-j", "LAN_PROXY_GATEWAY",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only our tagged rule as -D, got %v", got)
+	}
+}
+
+func TestParseTaggedIptablesRules_StripsQuotes(t *testing.T) {
+	got := parseTaggedIptablesRules(iptablesPreroutingQuoted)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 rule, got %v", got)
+	}
+	for _, f := range got[0] {
+		if strings.Contains(f, `"`) {
+			t.Fatalf("quotes must be stripped so iptables -D matches: %v", got[0])
+		}
+	}
+}
+
+func TestParseTaggedIptablesRules_CleanSystem(t *testing.T) {
+	if got := parseTaggedIptablesRules("-P PREROUTING ACCEPT\n"); len(got) != 0 {
+		t.Fatalf("clean system must yield nothing; got %v", got)
+	}
+}
+
+func TestIptablesRedirectRules_Shape(t *testing.T) {
+	rules := iptablesRedirectRules("eth0", 7892)
+	if got := strings.Join(rules[0], " "); got != "-N "+redirectChain {
+		t.Fatalf("first rule must create chain, got %q", got)
+	}
+	if got := strings.Join(rules[1], " "); !strings.Contains(got, "--dst-type LOCAL") || !strings.HasSuffix(got, "-j RETURN") {
+		t.Fatalf("second rule must bypass local destinations, got %q", got)
+	}
+	redirect := strings.Join(rules[len(rules)-2], " ")
+	if !strings.HasSuffix(redirect, "-j REDIRECT --to-ports 7892") {
+		t.Fatalf("redirect rule must target redir port, got %q", redirect)
+	}
+	jump := strings.Join(rules[len(rules)-1], " ")
+	if !strings.HasPrefix(jump, "-A PREROUTING -i eth0 -p tcp") || !strings.HasSuffix(jump, "-j "+redirectChain) {
+		t.Fatalf("last rule must hook PREROUTING, got %q", jump)
+	}
+	for _, r := range rules[1:] {
+		if !strings.Contains(strings.Join(r, " "), "--comment "+redirectTag) {
+			t.Fatalf("every rule must carry the cleanup tag: %v", r)
+		}
+	}
+}
+
+// 我们自己生成的规则经过 `iptables -S` 回显后，parser 要能完整认出来 ——
+// 保证 Configure / Unconfigure 是一对。
+func TestIptablesRedirectRules_RoundTrip(t *testing.T) {
+	var b strings.Builder
+	added := 0
+	for _, r := range iptablesRedirectRules("eth0", 7892) {
+		if r[0] == "-A" && r[1] == "PREROUTING" {
+			b.WriteString(strings.Join(r, " ") + "\n")
+			added++
+		}
+	}
+	got := parseTaggedIptablesRules(b.String())
+	if len(got) != added {
+		t.Fatalf("expected %d rules to delete, got %v", added, got)
+	}
+}
+
+func TestNftRedirectScript(t *testing.T) {
+	s := nftRedirectScript("eth0", 7892)
+	for _, want := range []string{
+		"table ip " + redirectNFTTable,
+		"type nat hook prerouting priority -100",
+		`iifname "eth0" fib daddr type local return`,
+		"192.168.0.0/16",
+		"redirect to :7892",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("nft script missing %q:\n%s", want, s)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// --- 节点过滤 / 改名 ---
+//
+// 机场订阅里常混着「剩余流量：xx GB」「官网 xxx.com」「套餐到期」这类伪节点，
+// 名字也常带一堆 emoji / 倍率后缀。NodeFilter 在 inlineUserYAML 生成 Proxy /
+// Auto / Fallback 组之前作用在 proxies 上：
+//   - include / exclude 决定节点去留，rename 再改保留下来的名字；
+//   - 订阅自带的分组里引用了被删节点的，从 proxies 列表里剔掉；被改名的跟着改名；
+//     剔空了的组补一个 DIRECT，不然 mihomo 会拒绝整份配置；
+//   - rules 里直接指向被删节点的规则丢掉，指向改名节点的跟着改。
+
+// nodeRenames 是过滤 / 改名的结果：旧名 → 新名；被删掉的节点新名为空串。
+type nodeRenames map[string]string
+
+// filterNodes 按 f 过滤、改名 proxies。改名后重名的按 uniqueName 加序号。
+func filterNodes(proxies []yaml.Node, f config.NodeFilter) ([]yaml.Node, nodeRenames, error) {
+	if f.IsZero() {
+		return proxies, nil, nil
+	}
+	var include, exclude *regexp.Regexp
+	var err error
+	if f.Include != "" {
+		if include, err = regexp.Compile(f.Include); err != nil {
+			return nil, nil, fmt.Errorf("filter.include 正则无效: %w", err)
+		}
+	}
+	if f.Exclude != "" {
+		if exclude, err = regexp.Compile(f.Exclude); err != nil {
+			return nil, nil, fmt.Errorf("filter.exclude 正则无效: %w", err)
+		}
+	}
+	type renameRule struct {
+		re      *regexp.Regexp
+		replace string
+	}
+	rules := make([]renameRule, 0, len(f.Rename))
+	for i, r := range f.Rename {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("filter.rename[%d] 正则无效: %w", i, err)
+		}
+		rules = append(rules, renameRule{re: re, replace: r.Replace})
+	}
+
+	renames := nodeRenames{}
+	taken := map[string]bool{}
+	kept := make([]yaml.Node, 0, len(proxies))
+	for _, p := range proxies {
+		name := proxyNameFromNode(p)
+		if name == "" {
+			kept = append(kept, p)
+			continue
+		}
+		if (include != nil && !include.MatchString(name)) || (exclude != nil && exclude.MatchString(name)) {
+			renames[name] = ""
+			continue
+		}
+		newName := name
+		for _, r := range rules {
+			newName = r.re.ReplaceAllString(newName, r.replace)
+		}
+		newName = strings.TrimSpace(newName)
+		if newName == "" {
+			newName = name
+		}
+		newName = uniqueName(newName, taken)
+		taken[newName] = true
+		if newName != name {
+			setProxyName(&p, newName)
+		}
+		renames[name] = newName
+		kept = append(kept, p)
+	}
+	if len(kept) == 0 && len(proxies) > 0 {
+		return nil, nil, fmt.Errorf("节点过滤后一个都不剩（共 %d 个），检查 include / exclude", len(proxies))
+	}
+	return kept, renames, nil
+}
+
+// applyRenamesToGroups 把过滤 / 改名结果同步到分组的 proxies 列表：删掉的剔除，
+// 改名的跟着改；不在 renames 里的（其它组名、DIRECT 等）原样保留。
+func applyRenamesToGroups(groups []yaml.Node, renames nodeRenames) {
+	if len(renames) == 0 {
+		return
+	}
+	for gi := range groups {
+		g := &groups[gi]
+		if g.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(g.Content); i += 2 {
+			if g.Content[i].Value != "proxies" || g.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+			seq := g.Content[i+1]
+			out := seq.Content[:0]
+			for _, item := range seq.Content {
+				if item.Kind == yaml.ScalarNode {
+					if newName, ok := renames[item.Value]; ok {
+						if newName == "" {
+							continue
+						}
+						item.Value = newName
+					}
+				}
+				out = append(out, item)
+			}
+			seq.Content = out
+			// 剔空了又没有 use / include-all 兜着：补 DIRECT，mihomo 不接受空组。
+			if len(seq.Content) == 0 && !groupHasKey(*g, "use") && !groupHasKey(*g, "include-all") {
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "DIRECT"})
+			}
+		}
+	}
+}
+
+// applyRenamesToRules 同步 rules 的目标：指向被删节点的规则丢掉，改名的跟着改。
+// 目标是最后一段（跳过 no-resolve / src 这类尾部参数），AND / OR 逻辑规则也适用。
+func applyRenamesToRules(rules []string, renames nodeRenames) []string {
+	if len(renames) == 0 {
+		return rules
+	}
+	out := make([]string, 0, len(rules))
+	for _, r := range rules {
+		parts := strings.Split(r, ",")
+		idx := len(parts) - 1
+		for idx > 0 {
+			p := strings.TrimSpace(parts[idx])
+			if p != "no-resolve" && p != "src" {
+				break
+			}
+			idx--
+		}
+		if newName, ok := renames[strings.TrimSpace(parts[idx])]; ok {
+			if newName == "" {
+				continue
+			}
+			parts[idx] = newName
+			r = strings.Join(parts, ",")
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func groupHasKey(g yaml.Node, key string) bool {
+	for i := 0; i+1 < len(g.Content); i += 2 {
+		if g.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+const yamlWithPseudoNodes = `proxies:
+  - {name: "剩余流量：88.5 GB", type: http, server: 0.0.0.0, port: 1}
+  - {name: "官网 example.com", type: http, server: 0.0.0.0, port: 1}
+  - {name: "🇭🇰 香港 01 | 1x", type: http, server: 1.1.1.1, port: 80}
+  - {name: "🇭🇰 香港 02 | 2x", type: http, server: 1.1.1.2, port: 80}
+  - {name: "🇯🇵 日本 01 | 1x", type: http, server: 1.1.1.3, port: 80}
+proxy-groups:
+  - name: 节点选择
+    type: select
+    proxies: ["剩余流量：88.5 GB", "官网 example.com", "🇭🇰 香港 01 | 1x", "🇭🇰 香港 02 | 2x", "🇯🇵 日本 01 | 1x"]
+  - name: 公告
+    type: select
+    proxies: ["剩余流量：88.5 GB", "官网 example.com"]
+rules:
+  - DOMAIN-SUFFIX,example.com,官网 example.com
+  - DOMAIN-SUFFIX,jp.example,🇯🇵 日本 01 | 1x
+  - IP-CIDR,10.0.0.0/8,🇭🇰 香港 01 | 1x,no-resolve
+  - MATCH,节点选择
+`
+
+func TestInlineUserYAMLFiltersAndRenamesNodes(t *testing.T) {
+	filter := config.NodeFilter{
+		Exclude: "剩余|官网|到期",
+		Rename: []config.RenameRule{
+			{Pattern: `\s*\|\s*\d+x$`, Replace: ""},
+			{Pattern: `^🇭🇰 香港`, Replace: "HK"},
+		},
+	}
+	frag, err := inlineUserYAML([]byte(yamlWithPseudoNodes), filter, groupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Proxies     []yaml.Node `yaml:"proxies"`
+		ProxyGroups []struct {
+			Name    string   `yaml:"name"`
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal([]byte(frag.YAML), &out); err != nil {
+		t.Fatal(err)
+	}
+	names := proxyNamesFromNodes(out.Proxies)
+	want := []string{"HK 01", "HK 02", "🇯🇵 日本 01"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Fatalf("proxies = %v, want %v", names, want)
+	}
+	groups := map[string][]string{}
+	for _, g := range out.ProxyGroups {
+		groups[g.Name] = g.Proxies
+	}
+	if got := strings.Join(groups["节点选择"], "|"); got != "HK 01|HK 02|🇯🇵 日本 01" {
+		t.Fatalf("节点选择 = %v", groups["节点选择"])
+	}
+	// 组里只剩伪节点：剔空后补 DIRECT，避免 mihomo 拒绝空组
+	if got := strings.Join(groups["公告"], "|"); got != "DIRECT" {
+		t.Fatalf("公告 = %v, want [DIRECT]", groups["公告"])
+	}
+
+	wantRules := []string{
+		"DOMAIN-SUFFIX,jp.example,🇯🇵 日本 01",
+		"IP-CIDR,10.0.0.0/8,HK 01,no-resolve",
+		"MATCH,节点选择",
+	}
+	if strings.Join(frag.Rules, "\n") != strings.Join(wantRules, "\n") {
+		t.Fatalf("rules = %q, want %q", frag.Rules, wantRules)
+	}
+}
+
+func TestFilterNodesIncludeAndCollisions(t *testing.T) {
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(yamlWithPseudoNodes), &doc); err != nil {
+		t.Fatal(err)
+	}
+	// include 只留香港；改名把倍率去掉再统一成 HK，重名加序号
+	kept, renames, err := filterNodes(doc.Proxies, config.NodeFilter{
+		Include: "香港",
+		Rename:  []config.RenameRule{{Pattern: `.*香港.*`, Replace: "HK"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(proxyNamesFromNodes(kept), "|"); got != "HK|HK 2" {
+		t.Fatalf("kept = %q", got)
+	}
+	if renames["🇯🇵 日本 01 | 1x"] != "" || renames["🇭🇰 香港 02 | 2x"] != "HK 2" {
+		t.Fatalf("unexpected renames: %v", renames)
+	}
+
+	if _, _, err := filterNodes(doc.Proxies, config.NodeFilter{Include: "美国"}); err == nil {
+		t.Fatal("filtering out every node should error")
+	}
+	if _, _, err := filterNodes(doc.Proxies, config.NodeFilter{Exclude: "("}); err == nil {
+		t.Fatal("invalid regex should error")
+	}
+}
@@ -0,0 +1,246 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// --- multi: merge several subscriptions into one source ---
+//
+// 多订阅合并（team 同时买了两家机场 + 自建节点列表的场景）：
+//   - 并发拉取每份订阅，各自备份到 workDir/subscription-<序号>.yaml；某份连不上
+//     先退回它的缓存，缓存也没有才跳过，都记进 Summary，全部失败才报错 ——
+//     一家机场挂了不该拖垮另外两家；
+//   - 只取每份的 proxies。订阅自带的 proxy-groups / rules 一律丢弃：各家都有
+//     自己的「Proxy」「节点选择」，规则还引用这些组，合并后必然重名 / 悬空；
+//   - 每份订阅自己的 filter 先作用在它的 proxies 上（过滤 / 改名），再合并；
+//   - 节点重名时后出现的改名为「原名 · 订阅名」，再重就加序号（mihomo 不允许重名）；
+//   - 每份订阅生成一个同名 select 子分组，Proxy 组 = 子分组 + 全部节点 + DIRECT；
+//   - 各家的 subscription-userinfo 合并写进 subscription-info.json，按订阅名区分；
+//     这次拉取失败（缓存也没有）的订阅保留上一次的那条，已经从配置里删掉的订阅丢弃；
+//   - 之后照常走 appendAutoFallbackGroups / augmentProxyGroupOptions，
+//     Auto / Fallback（以及开了 region_groups 时的地区组）覆盖所有订阅的节点。
+func materializeMulti(ctx context.Context, subs []config.SubscriptionSource, workDir string, proxyURL string, groups groupOptions) (Fragment, error) {
+	if len(subs) == 0 {
+		return Fragment{}, fmt.Errorf("多订阅源没有配置任何订阅")
+	}
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return Fragment{}, err
+	}
+
+	type fetched struct {
+		proxies []yaml.Node
+		info    *SubscriptionInfo
+		staleAt time.Time
+		err     error
+	}
+	results := make([]fetched, len(subs))
+	var wg sync.WaitGroup
+	for i, s := range subs {
+		wg.Add(1)
+		go func(i int, s config.SubscriptionSource) {
+			defer wg.Done()
+			data, info, staleAt, err := fetchSubscriptionOrCache(ctx, s.URL, proxyURL, subscriptionCachePath(workDir, i))
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].staleAt = staleAt
+			var doc struct {
+				Proxies []yaml.Node `yaml:"proxies"`
+			}
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				results[i].err = fmt.Errorf("解析订阅 yaml: %w", err)
+				return
+			}
+			kept, _, err := filterNodes(doc.Proxies, s.Filter)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].proxies = kept
+			if info != nil {
+				info.Name = s.Name
+				results[i].info = info
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	var doc struct {
+		Proxies     []yaml.Node `yaml:"proxies"`
+		ProxyGroups []yaml.Node `yaml:"proxy-groups"`
+		Rules       []string    `yaml:"rules"`
+	}
+	taken := map[string]bool{"Proxy": true, "DIRECT": true, "REJECT": true}
+	perSub := make([][]string, len(subs))
+	var failed, stale []string
+	var failedIdx []int
+	var infos []SubscriptionInfo
+	for i, r := range results {
+		if r.err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", subs[i].Name, r.err))
+			failedIdx = append(failedIdx, i)
+			continue
+		}
+		if !r.staleAt.IsZero() {
+			stale = append(stale, subs[i].Name+staleNote(r.staleAt))
+		}
+		if r.info != nil {
+			infos = append(infos, *r.info)
+		}
+		for _, p := range r.proxies {
+			name := proxyNameFromNode(p)
+			if name == "" {
+				continue
+			}
+			unique := name
+			if taken[unique] {
+				unique = uniqueName(name+" · "+subs[i].Name, taken)
+				setProxyName(&p, unique)
+			}
+			taken[unique] = true
+			doc.Proxies = append(doc.Proxies, p)
+			perSub[i] = append(perSub[i], unique)
+		}
+	}
+	if len(failed) == len(subs) {
+		return Fragment{}, fmt.Errorf("所有订阅都拉取失败: %s", strings.Join(failed, "; "))
+	}
+	_ = SaveSubscriptionInfo(workDir, keepFailedSubscriptionInfo(workDir, subs, failedIdx, infos))
+
+	var proxyMembers []string
+	for i, names := range perSub {
+		if len(names) == 0 {
+			continue
+		}
+		groupName := uniqueName(subs[i].Name, taken)
+		taken[groupName] = true
+		node, err := buildSelectGroupNode(groupName, names)
+		if err != nil {
+			return Fragment{}, err
+		}
+		doc.ProxyGroups = append(doc.ProxyGroups, *node)
+		proxyMembers = append(proxyMembers, groupName)
+	}
+	proxyMembers = append(proxyMembers, proxyNamesFromNodes(doc.Proxies)...)
+	proxyMembers = append(proxyMembers, "DIRECT")
+	proxyGroup, err := buildSelectGroupNode("Proxy", proxyMembers)
+	if err != nil {
+		return Fragment{}, err
+	}
+	// Proxy 放最前：和单订阅一样，它是 MATCH,Proxy 的主入口。
+	doc.ProxyGroups = append([]yaml.Node{*proxyGroup}, doc.ProxyGroups...)
+
+	if groups.auto {
+		if err := appendAutoFallbackGroups(&doc); err != nil {
+			return Fragment{}, err
+		}
+		augmentProxyGroupOptions(&doc)
+	}
+	if groups.region {
+		names, err := appendRegionGroups(&doc)
+		if err != nil {
+			return Fragment{}, err
+		}
+		addRegionGroupsToProxy(doc.ProxyGroups, names)
+	}
+
+	extract := map[string]interface{}{"proxy-groups": doc.ProxyGroups}
+	if len(doc.Proxies) > 0 {
+		extract["proxies"] = doc.Proxies
+	}
+	out, err := yaml.Marshal(extract)
+	if err != nil {
+		return Fragment{}, err
+	}
+	summary := fmt.Sprintf("多订阅 · %d 份 · %d 节点", len(subs)-len(failed), len(doc.Proxies))
+	if len(failed) > 0 {
+		summary += fmt.Sprintf("（%d 份拉取失败：%s）", len(failed), strings.Join(failed, "; "))
+	}
+	if len(stale) > 0 {
+		summary += "（" + strings.Join(stale, "; ") + "）"
+	}
+	return Fragment{YAML: string(out), Summary: summary}, nil
+}
+
+// uniqueName 在 taken 里给 base 找一个没被占用的名字：base、base 2、base 3 …
+func uniqueName(base string, taken map[string]bool) string {
+	name := base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s %d", base, i)
+	}
+	return name
+}
+
+// proxyNameFromNode 抽单个 proxy 映射的 name 字段。
+func proxyNameFromNode(p yaml.Node) string {
+	if names := proxyNamesFromNodes([]yaml.Node{p}); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// setProxyName 原地改写 proxy 映射的 name 字段。
+func setProxyName(p *yaml.Node, name string) {
+	if p.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(p.Content); i += 2 {
+		if p.Content[i].Value == "name" && p.Content[i+1].Kind == yaml.ScalarNode {
+			p.Content[i+1].Value = name
+			p.Content[i+1].Tag = "!!str"
+			return
+		}
+	}
+}
+
+// buildSelectGroupNode 拼一个 select 组的 yaml.Node，套路同 buildAutoOrFallbackNode。
+func buildSelectGroupNode(name string, members []string) (*yaml.Node, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %q\n", name)
+	b.WriteString("type: select\n")
+	b.WriteString("proxies:\n")
+	for _, n := range members {
+		fmt.Fprintf(&b, "  - %q\n", n)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(b.String()), &node); err != nil {
+		return nil, fmt.Errorf("构造 %s 组 yaml 失败: %w", name, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, fmt.Errorf("构造 %s 组返回空 node", name)
+	}
+	return node.Content[0], nil
+}
+
+// keepFailedSubscriptionInfo 把这次拉取失败的订阅在 subscription-info.json 里的旧记录
+// 接回 fresh —— 直接用 fresh 覆盖会把它的余量 / 到期一起抹掉，机场临时挂了一下，
+// status 就不再提醒它快到期。只按当前配置里的订阅名找，已经删掉的订阅不会被带回来。
+func keepFailedSubscriptionInfo(workDir string, subs []config.SubscriptionSource, failedIdx []int, fresh []SubscriptionInfo) []SubscriptionInfo {
+	if len(failedIdx) == 0 {
+		return fresh
+	}
+	prev, err := LoadSubscriptionInfo(workDir)
+	if err != nil || len(prev) == 0 {
+		return fresh
+	}
+	old := make(map[string]SubscriptionInfo, len(prev))
+	for _, info := range prev {
+		old[info.Name] = info
+	}
+	for _, i := range failedIdx {
+		if info, ok := old[subs[i].Name]; ok {
+			fresh = append(fresh, info)
+		}
+	}
+	return fresh
+}
@@ -0,0 +1,203 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func subscriptionServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const multiSubA = `proxies:
+  - {name: "HK 01", type: http, server: 1.1.1.1, port: 80}
+  - {name: "JP 01", type: http, server: 1.1.1.2, port: 80}
+proxy-groups:
+  - name: 节点选择
+    type: select
+    proxies: ["HK 01", "JP 01"]
+rules:
+  - MATCH,节点选择
+`
+
+const multiSubB = `proxies:
+  - {name: "HK 01", type: http, server: 2.2.2.1, port: 80}
+  - {name: "US 01", type: http, server: 2.2.2.2, port: 80}
+`
+
+type mergedDoc struct {
+	Proxies []struct {
+		Name string `yaml:"name"`
+	} `yaml:"proxies"`
+	ProxyGroups []struct {
+		Name    string   `yaml:"name"`
+		Type    string   `yaml:"type"`
+		Proxies []string `yaml:"proxies"`
+	} `yaml:"proxy-groups"`
+}
+
+func parseMerged(t *testing.T, frag Fragment) mergedDoc {
+	t.Helper()
+	var doc mergedDoc
+	if err := yaml.Unmarshal([]byte(frag.YAML), &doc); err != nil {
+		t.Fatalf("merged yaml invalid: %v\n%s", err, frag.YAML)
+	}
+	return doc
+}
+
+func TestMaterializeMulti_MergesAndRenamesDuplicates(t *testing.T) {
+	a := subscriptionServer(t, multiSubA)
+	b := subscriptionServer(t, multiSubB)
+	frag, err := Materialize(context.Background(), config.SourceConfig{
+		Type: config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{
+			{Name: "airA", URL: a.URL},
+			{Name: "airB", URL: b.URL},
+		},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatalf("materialize: %v", err)
+	}
+	doc := parseMerged(t, frag)
+
+	var names []string
+	for _, p := range doc.Proxies {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "HK 01,JP 01,HK 01 · airB,US 01" {
+		t.Fatalf("unexpected proxy names: %s", got)
+	}
+	if len(frag.Rules) != 0 {
+		t.Fatalf("per-subscription rules must be dropped, got %v", frag.Rules)
+	}
+
+	groups := map[string][]string{}
+	for _, g := range doc.ProxyGroups {
+		groups[g.Name] = g.Proxies
+	}
+	if _, ok := groups["节点选择"]; ok {
+		t.Fatal("subscription's own groups must be dropped")
+	}
+	if got := strings.Join(groups["airB"], ","); got != "HK 01 · airB,US 01" {
+		t.Fatalf("airB sub-group = %s", got)
+	}
+	if doc.ProxyGroups[0].Name != "Proxy" {
+		t.Fatalf("Proxy group must come first, got %s", doc.ProxyGroups[0].Name)
+	}
+	proxy := strings.Join(groups["Proxy"], ",")
+	if !strings.HasPrefix(proxy, "airA,airB,HK 01") || !strings.HasSuffix(proxy, "DIRECT") {
+		t.Fatalf("Proxy group = %s", proxy)
+	}
+}
+
+func TestMaterializeMulti_AutoGroupsCoverAllSubscriptions(t *testing.T) {
+	a := subscriptionServer(t, multiSubA)
+	b := subscriptionServer(t, multiSubB)
+	frag, err := MaterializeWithOptions(context.Background(), config.SourceConfig{
+		Type: config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{
+			{Name: "airA", URL: a.URL},
+			{Name: "airB", URL: b.URL},
+		},
+	}, t.TempDir(), MaterializeOptions{AutoGroups: true})
+	if err != nil {
+		t.Fatalf("materialize: %v", err)
+	}
+	doc := parseMerged(t, frag)
+	for _, g := range doc.ProxyGroups {
+		if g.Type == "url-test" && len(g.Proxies) != 4 {
+			t.Fatalf("Auto must reference all 4 merged nodes, got %v", g.Proxies)
+		}
+		if g.Name == "Proxy" && !strings.Contains(strings.Join(g.Proxies, ","), "Auto") {
+			t.Fatalf("Proxy group must offer Auto, got %v", g.Proxies)
+		}
+	}
+}
+
+func TestMaterializeMulti_SkipsFailedSubscription(t *testing.T) {
+	a := subscriptionServer(t, multiSubA)
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer bad.Close()
+
+	frag, err := Materialize(context.Background(), config.SourceConfig{
+		Type: config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{
+			{Name: "airA", URL: a.URL},
+			{Name: "dead", URL: bad.URL},
+		},
+	}, t.TempDir())
+	if err != nil {
+		t.Fatalf("one live subscription must be enough: %v", err)
+	}
+	if !strings.Contains(frag.Summary, "1 份拉取失败") {
+		t.Fatalf("summary should mention failed subscription: %q", frag.Summary)
+	}
+
+	_, err = Materialize(context.Background(), config.SourceConfig{
+		Type:          config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{{Name: "dead", URL: bad.URL}},
+	}, t.TempDir())
+	if err == nil {
+		t.Fatal("expected error when every subscription fails")
+	}
+}
+
+func TestMaterializeMulti_KeepsInfoOfFailedSubscription(t *testing.T) {
+	var down atomic.Bool
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.Header().Set("Subscription-Userinfo", "upload=1; download=2; total=100; expire=1767225600")
+		_, _ = io.WriteString(w, multiSubB)
+	}))
+	defer flaky.Close()
+	a := subscriptionServer(t, multiSubA)
+
+	dir := t.TempDir()
+	src := config.SourceConfig{
+		Type: config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{
+			{Name: "airA", URL: a.URL},
+			{Name: "airB", URL: flaky.URL},
+		},
+	}
+	if _, err := Materialize(context.Background(), src, dir); err != nil {
+		t.Fatal(err)
+	}
+	// 之前配过、现在已经删掉的订阅：不该被带回来。
+	infos, _ := LoadSubscriptionInfo(dir)
+	if err := SaveSubscriptionInfo(dir, append(infos, SubscriptionInfo{Name: "removed", Total: 1})); err != nil {
+		t.Fatal(err)
+	}
+
+	// airB 这次 410（不算连不上，不走缓存），它的流量 / 到期信息要留着。
+	down.Store(true)
+	if _, err := Materialize(context.Background(), src, dir); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := LoadSubscriptionInfo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "airB" || infos[0].Total != 100 || infos[0].Expire.IsZero() {
+		t.Fatalf("failed subscription's info should survive: %+v", infos)
+	}
+}
@@ -0,0 +1,221 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// --- 订阅缓存 + 定时刷新 ---
+//
+// 每份订阅上次成功拉到的内容存在 workDir/subscription.yaml（多订阅是
+// subscription-<序号>.yaml），旁边的 .meta.json 记 ETag / Last-Modified /
+// userinfo。fetchSubscription 每次都带条件请求，所以 Start / Reload / 后台刷新
+// 三条路径都能吃到 304。
+//
+// 后台刷新（app 层定时调 RefreshSubscription）只负责回答「节点变没变」：
+// 变了由 app 触发 Reload，Reload 再渲染时命中 304 直接用刚写下的缓存。
+// 刷新失败不动缓存，也不触发 Reload —— 正在跑的 mihomo 配置原样保留。
+//
+// Start / Reload 渲染时机场连不上（网络错误、5xx）就退回这份缓存，Summary 里
+// 注明用的是哪天的缓存；4xx、空订阅之类机场明确回了话的错误照常报出来。
+
+// subscriptionCacheMeta 是缓存旁边的 .meta.json。
+type subscriptionCacheMeta struct {
+	URL          string            `json:"url"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	Info         *SubscriptionInfo `json:"info,omitempty"`
+	FetchedAt    time.Time         `json:"fetched_at"`
+}
+
+// subscriptionCachePath 返回第 index 份订阅的缓存路径；index < 0 表示单订阅。
+func subscriptionCachePath(workDir string, index int) string {
+	if index < 0 {
+		return filepath.Join(workDir, "subscription.yaml")
+	}
+	return filepath.Join(workDir, fmt.Sprintf("subscription-%d.yaml", index+1))
+}
+
+// loadSubscriptionCache 读缓存内容和 meta。meta 缺失、URL 对不上（用户换了订阅链接）
+// 或缓存文件不在时 cached 为 nil，调用方就当没有缓存、发无条件请求。
+func loadSubscriptionCache(cachePath, rawURL string) (subscriptionCacheMeta, []byte) {
+	var meta subscriptionCacheMeta
+	b, err := os.ReadFile(cachePath + ".meta.json")
+	if err != nil || json.Unmarshal(b, &meta) != nil || meta.URL != rawURL {
+		return subscriptionCacheMeta{}, nil
+	}
+	cached, err := os.ReadFile(cachePath)
+	if err != nil || len(cached) == 0 {
+		return subscriptionCacheMeta{}, nil
+	}
+	return meta, cached
+}
+
+// saveSubscriptionCache 写缓存内容 + meta，best-effort：写失败只是下次少一次 304。
+func saveSubscriptionCache(cachePath string, data []byte, meta subscriptionCacheMeta) {
+	if err := os.WriteFile(cachePath, data, 0o600); err != nil {
+		return
+	}
+	if b, err := json.MarshalIndent(meta, "", "  "); err == nil {
+		_ = os.WriteFile(cachePath+".meta.json", b, 0o600)
+	}
+}
+
+// unreachableError 标记「机场没连上」一类的拉取失败：网络错误、5xx。只有这类
+// 错误渲染时才退回缓存。
+type unreachableError struct{ err error }
+
+func (e unreachableError) Error() string { return e.err.Error() }
+func (e unreachableError) Unwrap() error { return e.err }
+
+// fetchSubscriptionOrCache 是渲染路径用的 fetchSubscription：机场连不上时退回
+// 上次成功拉到的缓存（只有校验过的内容才会写进缓存，meta 里的 URL 也对得上），
+// staleAt 是那份缓存的拉取时间；拉到新内容时 staleAt 为零值。
+func fetchSubscriptionOrCache(ctx context.Context, rawURL, proxyURL, cachePath string) (data []byte, info *SubscriptionInfo, staleAt time.Time, err error) {
+	data, info, err = fetchSubscription(ctx, rawURL, proxyURL, cachePath)
+	var unreachable unreachableError
+	if err == nil || !errors.As(err, &unreachable) {
+		return data, info, time.Time{}, err
+	}
+	meta, cached := loadSubscriptionCache(cachePath, rawURL)
+	if cached == nil {
+		return nil, nil, time.Time{}, err
+	}
+	return cached, meta.Info, meta.FetchedAt, nil
+}
+
+// staleNote 是用了缓存时追加到 Summary 的说明。
+func staleNote(staleAt time.Time) string {
+	if staleAt.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("（拉取失败，用的是 %s 的缓存）", staleAt.Local().Format("01-02 15:04"))
+}
+
+// RefreshableSubscriptions 返回当前源里配置了 refresh_interval 的订阅；
+// 非订阅类源返回 nil。
+func RefreshableSubscriptions(src config.SourceConfig) []config.SubscriptionSource {
+	var subs []config.SubscriptionSource
+	switch src.Type {
+	case config.SourceTypeSubscription:
+		subs = []config.SubscriptionSource{src.Subscription}
+	case config.SourceTypeMulti:
+		subs = src.Subscriptions
+	}
+	var out []config.SubscriptionSource
+	for _, s := range subs {
+		if s.RefreshEvery() > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// RefreshSubscription 按名字重新拉当前源里的一份订阅（带条件请求），更新缓存和
+// userinfo，返回节点集合是否变了。proxyURL 为空时和 Materialize 一样回退到
+// 上游代理。拉取失败时缓存保持原样，changed=false。
+func RefreshSubscription(ctx context.Context, src config.SourceConfig, workDir, name, proxyURL string) (changed bool, err error) {
+	index := -1
+	var sub config.SubscriptionSource
+	switch src.Type {
+	case config.SourceTypeSubscription:
+		if src.Subscription.Name != name {
+			return false, fmt.Errorf("当前源里没有订阅 %q", name)
+		}
+		sub = src.Subscription
+	case config.SourceTypeMulti:
+		for i, s := range src.Subscriptions {
+			if s.Name == name {
+				index, sub = i, s
+				break
+			}
+		}
+		if index < 0 {
+			return false, fmt.Errorf("当前源里没有订阅 %q", name)
+		}
+	default:
+		return false, fmt.Errorf("当前源不是订阅类型: %s", src.Type)
+	}
+	if proxyURL == "" {
+		proxyURL = firstUpstreamProxyURL(src)
+	}
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return false, err
+	}
+
+	cachePath := subscriptionCachePath(workDir, index)
+	before, _ := os.ReadFile(cachePath)
+	data, info, err := fetchSubscription(ctx, sub.URL, proxyURL, cachePath)
+	if err != nil {
+		return false, fmt.Errorf("刷新订阅 %s: %w", name, err)
+	}
+	if info != nil {
+		info.Name = sub.Name
+		_ = upsertSubscriptionInfo(workDir, *info)
+	}
+	return nodeFingerprint(before) != nodeFingerprint(data), nil
+}
+
+// upsertSubscriptionInfo 按 Name 替换 / 追加 subscription-info.json 里的一条。
+func upsertSubscriptionInfo(workDir string, info SubscriptionInfo) error {
+	infos, err := LoadSubscriptionInfo(workDir)
+	if err != nil {
+		infos = nil
+	}
+	for i := range infos {
+		if infos[i].Name == info.Name {
+			infos[i] = info
+			return SaveSubscriptionInfo(workDir, infos)
+		}
+	}
+	return SaveSubscriptionInfo(workDir, append(infos, info))
+}
+
+// nodeFingerprint 对订阅里的 proxies 求一个与顺序无关的摘要。只看节点本身：
+// 机场常在注释 / 分组 / 规则里塞更新时间戳，这些变了不值得重启 mihomo。
+func nodeFingerprint(data []byte) string {
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if len(data) == 0 || yaml.Unmarshal(data, &doc) != nil {
+		return ""
+	}
+	items := make([]string, 0, len(doc.Proxies))
+	for _, p := range doc.Proxies {
+		b, err := yaml.Marshal(&p)
+		if err != nil {
+			continue
+		}
+		items = append(items, string(b))
+	}
+	sort.Strings(items)
+	h := sha256.New()
+	for _, it := range items {
+		h.Write([]byte(it))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// countProxies 返回订阅 yaml 里 proxies 的条数；解析失败按 0 算。
+func countProxies(data []byte) int {
+	var doc struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if yaml.Unmarshal(data, &doc) != nil {
+		return 0
+	}
+	return len(doc.Proxies)
+}
@@ -0,0 +1,186 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// etagServer 模拟支持 ETag 的机场：body 可随时替换，If-None-Match 命中时回 304。
+type etagServer struct {
+	mu     sync.Mutex
+	body   string
+	etag   string
+	full   int // 回完整 body 的次数
+	notMod int // 回 304 的次数
+	srv    *httptest.Server
+}
+
+func newETagServer(t *testing.T, body, etag string) *etagServer {
+	t.Helper()
+	s := &etagServer{body: body, etag: etag}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.notMod++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full++
+		w.Header().Set("ETag", s.etag)
+		_, _ = io.WriteString(w, s.body)
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *etagServer) set(body, etag string) {
+	s.mu.Lock()
+	s.body, s.etag = body, etag
+	s.mu.Unlock()
+}
+
+func TestFetchSubscriptionUsesETag(t *testing.T) {
+	srv := newETagServer(t, multiSubA, `"v1"`)
+	cachePath := filepath.Join(t.TempDir(), "subscription.yaml")
+
+	first, _, err := fetchSubscription(context.Background(), srv.srv.URL, "", cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := fetchSubscription(context.Background(), srv.srv.URL, "", cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.full != 1 || srv.notMod != 1 {
+		t.Fatalf("expected 1 full + 1 not-modified, got full=%d notMod=%d", srv.full, srv.notMod)
+	}
+	if string(first) != string(second) {
+		t.Fatalf("304 should return cached body")
+	}
+
+	// 换了订阅链接：旧缓存不算数，必须无条件请求
+	other := newETagServer(t, multiSubA, `"v1"`)
+	if _, _, err := fetchSubscription(context.Background(), other.srv.URL, "", cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if other.full != 1 || other.notMod != 0 {
+		t.Fatalf("url change should bypass cache, got full=%d notMod=%d", other.full, other.notMod)
+	}
+}
+
+func TestFetchSubscriptionKeepsLastGoodOnEmptyBody(t *testing.T) {
+	srv := newETagServer(t, multiSubA, `"v1"`)
+	cachePath := filepath.Join(t.TempDir(), "subscription.yaml")
+	if _, _, err := fetchSubscription(context.Background(), srv.srv.URL, "", cachePath); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.set("proxies: []\n", `"v2"`)
+	if _, _, err := fetchSubscription(context.Background(), srv.srv.URL, "", cachePath); err == nil {
+		t.Fatal("empty subscription should be rejected")
+	}
+	kept, _ := os.ReadFile(cachePath)
+	if !strings.Contains(string(kept), "HK 01") {
+		t.Fatalf("cache should still hold last good copy, got %q", kept)
+	}
+}
+
+// 机场连不上时渲染退回缓存，不至于整个 Start / Reload 失败；机场明确回了 4xx 照常报错。
+func TestMaterializeFallsBackToCacheWhenUnreachable(t *testing.T) {
+	srv := newETagServer(t, multiSubA, `"v1"`)
+	dir := t.TempDir()
+	src := config.SourceConfig{
+		Type:         config.SourceTypeSubscription,
+		Subscription: config.SubscriptionSource{Name: "airport", URL: srv.srv.URL},
+	}
+	if _, err := MaterializeWithOptions(context.Background(), src, dir, MaterializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.srv.Close()
+	frag, err := MaterializeWithOptions(context.Background(), src, dir, MaterializeOptions{})
+	if err != nil {
+		t.Fatalf("unreachable airport should fall back to cache: %v", err)
+	}
+	if !strings.Contains(frag.YAML, "HK 01") || !strings.Contains(frag.Summary, "缓存") {
+		t.Fatalf("expected cached nodes and a stale note, got summary %q", frag.Summary)
+	}
+
+	// 多订阅：一份连不上也走自己的缓存。
+	multi := config.SourceConfig{Type: config.SourceTypeMulti, Subscriptions: []config.SubscriptionSource{src.Subscription}}
+	if _, err := MaterializeWithOptions(context.Background(), multi, dir, MaterializeOptions{}); err == nil {
+		t.Fatal("multi without a cache of its own should still fail")
+	}
+
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "token revoked", http.StatusForbidden)
+	}))
+	t.Cleanup(gone.Close)
+	cachePath := filepath.Join(dir, "gone.yaml")
+	saveSubscriptionCache(cachePath, []byte(multiSubA), subscriptionCacheMeta{URL: gone.URL})
+	if _, _, _, err := fetchSubscriptionOrCache(context.Background(), gone.URL, "", cachePath); err == nil {
+		t.Fatal("4xx is an answer from the airport, should not fall back to cache")
+	}
+}
+
+func TestRefreshSubscriptionReportsNodeChanges(t *testing.T) {
+	srv := newETagServer(t, multiSubA, `"v1"`)
+	dir := t.TempDir()
+	src := config.SourceConfig{
+		Type:         config.SourceTypeSubscription,
+		Subscription: config.SubscriptionSource{Name: "airport", URL: srv.srv.URL, RefreshInterval: "1h"},
+	}
+	if _, err := MaterializeWithOptions(context.Background(), src, dir, MaterializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := RefreshSubscription(context.Background(), src, dir, "airport", "")
+	if err != nil || changed {
+		t.Fatalf("304 should report unchanged, changed=%v err=%v", changed, err)
+	}
+
+	// 只改了分组 / 规则：节点没变，不该触发重载
+	srv.set(multiSubA+"\n# updated 2026-10-18\n", `"v2"`)
+	changed, err = RefreshSubscription(context.Background(), src, dir, "airport", "")
+	if err != nil || changed {
+		t.Fatalf("same node set should report unchanged, changed=%v err=%v", changed, err)
+	}
+
+	srv.set(multiSubB, `"v3"`)
+	changed, err = RefreshSubscription(context.Background(), src, dir, "airport", "")
+	if err != nil || !changed {
+		t.Fatalf("new node set should report changed, changed=%v err=%v", changed, err)
+	}
+
+	if _, err := RefreshSubscription(context.Background(), src, dir, "nope", ""); err == nil {
+		t.Fatal("unknown subscription name should error")
+	}
+}
+
+func TestRefreshableSubscriptions(t *testing.T) {
+	src := config.SourceConfig{
+		Type: config.SourceTypeMulti,
+		Subscriptions: []config.SubscriptionSource{
+			{Name: "a", URL: "http://a", RefreshInterval: "6h"},
+			{Name: "b", URL: "http://b"},
+		},
+	}
+	got := RefreshableSubscriptions(src)
+	if len(got) != 1 || got[0].Name != "a" {
+		t.Fatalf("unexpected: %+v", got)
+	}
+	src.Type = config.SourceTypeFile
+	if got := RefreshableSubscriptions(src); got != nil {
+		t.Fatalf("non-subscription source should have nothing to refresh: %+v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- 按地区自动分组 ---
+//
+// traffic.region_groups 开启后，把节点按地区归成 url-test 组（「🇭🇰 香港」「🇯🇵 日本」
+// 「🇺🇸 美国」…），组名固定，规则里就能直接写
+// `traffic.extras.groups: [{target: "🇺🇸 美国", rules: [DOMAIN-SUFFIX,openai.com]}]`
+// 把 OpenAI 钉到美国节点，不用手改订阅 YAML。
+//
+// 归类顺序：先认节点名里的国旗 emoji，认不出（没国旗 / 机场拿 🇨🇳 标台湾这类）
+// 再按关键词表匹配。都认不出的节点不进任何地区组，仍留在 Proxy / Auto 里。
+
+// Region 是一个地区分组的定义。
+type Region struct {
+	Flag     string // 国旗 emoji，同时是组名前缀
+	Name     string // 中文名，组名 = Flag + " " + Name
+	keywords *regexp.Regexp
+}
+
+// GroupName 返回地区组名，例「🇭🇰 香港」。
+func (r Region) GroupName() string { return r.Flag + " " + r.Name }
+
+// asciiWord 拼一个「前后不是字母」的英文关键词匹配，避免 US 误中 RUS / AUS。
+// 注意别收 GB 这类和流量单位撞车的缩写（「剩余 10 GB」会被认成英国）。
+func asciiWord(words string) string {
+	return `(?i)(^|[^a-z])(` + words + `)([^a-z]|$)`
+}
+
+// Regions 是内置地区表，顺序即生成的组顺序（也是 Proxy 组里的追加顺序）。
+var Regions = []Region{
+	{Flag: "🇭🇰", Name: "香港", keywords: regexp.MustCompile(`香港|港|` + asciiWord(`hk|hong ?kong`))},
+	{Flag: "🇹🇼", Name: "台湾", keywords: regexp.MustCompile(`台湾|台灣|臺灣|台北|新北|彰化|` + asciiWord(`tw|taiwan`))},
+	{Flag: "🇯🇵", Name: "日本", keywords: regexp.MustCompile(`日本|东京|東京|大阪|埼玉|` + asciiWord(`jp|japan|tokyo|osaka`))},
+	{Flag: "🇸🇬", Name: "新加坡", keywords: regexp.MustCompile(`新加坡|狮城|獅城|` + asciiWord(`sg|singapore`))},
+	{Flag: "🇺🇸", Name: "美国", keywords: regexp.MustCompile(`美国|美國|洛杉矶|圣何塞|硅谷|西雅图|纽约|芝加哥|达拉斯|` + asciiWord(`us|usa|united states|america|los angeles|san jose|seattle`))},
+	{Flag: "🇰🇷", Name: "韩国", keywords: regexp.MustCompile(`韩国|韓國|首尔|首爾|春川|` + asciiWord(`kr|korea|seoul`))},
+	{Flag: "🇬🇧", Name: "英国", keywords: regexp.MustCompile(`英国|英國|伦敦|倫敦|` + asciiWord(`uk|britain|united kingdom|london`))},
+	{Flag: "🇩🇪", Name: "德国", keywords: regexp.MustCompile(`德国|德國|法兰克福|` + asciiWord(`de|germany|frankfurt`))},
+	{Flag: "🇫🇷", Name: "法国", keywords: regexp.MustCompile(`法国|法國|巴黎|` + asciiWord(`fr|france|paris`))},
+	{Flag: "🇳🇱", Name: "荷兰", keywords: regexp.MustCompile(`荷兰|荷蘭|阿姆斯特丹|` + asciiWord(`nl|netherlands|amsterdam`))},
+	{Flag: "🇨🇦", Name: "加拿大", keywords: regexp.MustCompile(`加拿大|多伦多|温哥华|` + asciiWord(`ca|canada|toronto|vancouver`))},
+	{Flag: "🇦🇺", Name: "澳大利亚", keywords: regexp.MustCompile(`澳大利亚|澳洲|悉尼|墨尔本|` + asciiWord(`au|australia|sydney`))},
+	{Flag: "🇮🇳", Name: "印度", keywords: regexp.MustCompile(`印度|孟买|` + asciiWord(`india|mumbai`))},
+	{Flag: "🇹🇷", Name: "土耳其", keywords: regexp.MustCompile(`土耳其|伊斯坦布尔|` + asciiWord(`tr|turkey|istanbul`))},
+	{Flag: "🇷🇺", Name: "俄罗斯", keywords: regexp.MustCompile(`俄罗斯|俄羅斯|莫斯科|` + asciiWord(`ru|russia|moscow`))},
+}
+
+// ExtractFlag 从节点名里扫一段 2 位 regional indicator（国旗 emoji）。没找到
+// 返回空串。机场节点名基本都带 🇭🇰/🇯🇵 前缀；也兼容中间出现的形式。
+func ExtractFlag(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes)-1; i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			return string(runes[i : i+2])
+		}
+	}
+	return ""
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// RegionOf 返回节点名所属地区在 Regions 里的下标；认不出返回 -1。
+func RegionOf(name string) int {
+	if flag := ExtractFlag(name); flag != "" {
+		for i, r := range Regions {
+			if r.Flag == flag {
+				return i
+			}
+		}
+	}
+	for i, r := range Regions {
+		if r.keywords.MatchString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendRegionGroups 按地区给 doc 追加 url-test 组，返回这次涉及的地区组名
+// （含订阅里已有同名组的 —— 那种情况沿用用户的组，不重复追加）。
+func appendRegionGroups(doc *struct {
+	Proxies     []yaml.Node `yaml:"proxies"`
+	ProxyGroups []yaml.Node `yaml:"proxy-groups"`
+	Rules       []string    `yaml:"rules"`
+}) ([]string, error) {
+	members := make([][]string, len(Regions))
+	for _, name := range proxyNamesFromNodes(doc.Proxies) {
+		if i := RegionOf(name); i >= 0 {
+			members[i] = append(members[i], name)
+		}
+	}
+	existing := map[string]bool{}
+	for _, g := range doc.ProxyGroups {
+		if n := groupNameFromNode(g); n != "" {
+			existing[n] = true
+		}
+	}
+	var names []string
+	for i, r := range Regions {
+		if len(members[i]) == 0 {
+			continue
+		}
+		name := r.GroupName()
+		names = append(names, name)
+		if existing[name] {
+			continue
+		}
+		node, err := buildAutoOrFallbackNode(name, "url-test", members[i])
+		if err != nil {
+			return nil, fmt.Errorf("构造地区组 %s: %w", name, err)
+		}
+		doc.ProxyGroups = append(doc.ProxyGroups, *node)
+		existing[name] = true
+	}
+	return names, nil
+}
+
+// addRegionGroupsToProxy 把地区组名追加到 Proxy select 组尾部，让用户在 Proxy 里
+// 也能直接选「🇯🇵 日本」。规则同 augmentProxyGroupOptions：只动 select 类型的 Proxy。
+func addRegionGroupsToProxy(groups []yaml.Node, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	for i := range groups {
+		if groupNameFromNode(groups[i]) != "Proxy" {
+			continue
+		}
+		if groupTypeFromNode(groups[i]) == "select" {
+			appendProxyOptions(&groups[i], names)
+		}
+		return
+	}
+}
@@ -0,0 +1,93 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestRegionOf(t *testing.T) {
+	cases := []struct {
+		name string
+		want string // 期望的组名；空 = 认不出
+	}{
+		{"🇭🇰 香港 01", "🇭🇰 香港"},
+		{"🇯🇵Tokyo-02 | 2x", "🇯🇵 日本"},
+		{"🇨🇳 台湾 家宽", "🇹🇼 台湾"}, // 🇨🇳 不在表里，回退到关键词
+		{"US-LosAngeles 03", "🇺🇸 美国"},
+		{"美国 圣何塞", "🇺🇸 美国"},
+		{"RUS Moscow", "🇷🇺 俄罗斯"}, // RUS 不应误中 US
+		{"SG01", "🇸🇬 新加坡"},
+		{"剩余流量：10 GB", ""},
+		{"Plus 专线", ""},
+	}
+	for _, tc := range cases {
+		got := ""
+		if i := RegionOf(tc.name); i >= 0 {
+			got = Regions[i].GroupName()
+		}
+		if got != tc.want {
+			t.Errorf("RegionOf(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+const yamlRegionNodes = `proxies:
+  - {name: "🇭🇰 香港 01", type: http, server: 1.1.1.1, port: 80}
+  - {name: "HK 02", type: http, server: 1.1.1.2, port: 80}
+  - {name: "🇺🇸 美国 01", type: http, server: 1.1.1.3, port: 80}
+  - {name: "自建 01", type: http, server: 1.1.1.4, port: 80}
+`
+
+func TestInlineUserYAML_RegionGroups(t *testing.T) {
+	frag, err := inlineUserYAML([]byte(yamlRegionNodes), config.NodeFilter{}, groupOptions{auto: true, region: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		ProxyGroups []struct {
+			Name    string   `yaml:"name"`
+			Type    string   `yaml:"type"`
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal([]byte(frag.YAML), &out); err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string][]string{}
+	types := map[string]string{}
+	for _, g := range out.ProxyGroups {
+		groups[g.Name] = g.Proxies
+		types[g.Name] = g.Type
+	}
+	// 地区组在 Auto 之后加，不应挤掉 Auto
+	if types["Auto"] != "url-test" {
+		t.Fatalf("Auto group missing:\n%s", frag.YAML)
+	}
+	if got := strings.Join(groups["🇭🇰 香港"], "|"); got != "🇭🇰 香港 01|HK 02" || types["🇭🇰 香港"] != "url-test" {
+		t.Fatalf("香港 group = %v (%s)", groups["🇭🇰 香港"], types["🇭🇰 香港"])
+	}
+	if got := strings.Join(groups["🇺🇸 美国"], "|"); got != "🇺🇸 美国 01" {
+		t.Fatalf("美国 group = %v", groups["🇺🇸 美国"])
+	}
+	if _, ok := groups["🇯🇵 日本"]; ok {
+		t.Fatal("region without nodes should not get a group")
+	}
+	proxy := strings.Join(groups["Proxy"], "|")
+	if !strings.Contains(proxy, "🇭🇰 香港") || !strings.Contains(proxy, "🇺🇸 美国") {
+		t.Fatalf("Proxy should offer region groups, got %v", groups["Proxy"])
+	}
+}
+
+func TestInlineUserYAML_RegionGroupsOff(t *testing.T) {
+	frag, err := inlineUserYAML([]byte(yamlRegionNodes), config.NodeFilter{}, groupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(frag.YAML, "🇭🇰 香港\n") || strings.Contains(frag.YAML, "name: 🇺🇸 美国") {
+		t.Fatalf("region groups should not appear when disabled:\n%s", frag.YAML)
+	}
+}
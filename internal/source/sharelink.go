@@ -0,0 +1,482 @@
+package source
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- share links: V2Ray 风格订阅（base64 包着一行一个 URI）---
+//
+// 很多机场默认返回的不是 Clash YAML，而是 base64(ss://…\nvmess://…\n…)。
+// Clash Verge / v2rayN 都能直接吃，我们以前会报「不是 YAML」。这里把常见的
+// 五种 URI 转成 mihomo 的 proxy map，再包成 `proxies:` YAML 交给后面的
+// inlineUserYAML —— 对下游来说和普通 Clash 订阅没有区别。
+//
+// 认不出的行（注释、过期提示、ssr:// 等不支持的协议）直接跳过；一条都没转成
+// 才算失败，交回调用方按原文处理。
+
+// convertShareLinks 尝试把 text（明文或 base64 的 URI 列表）转成 Clash YAML。
+// ok=false 表示这不是一个分享链接订阅。
+func convertShareLinks(text string) (string, bool) {
+	body := text
+	if !strings.Contains(body, "://") {
+		decoded, err := decodeBase64Loose(compactWhitespace(text))
+		if err != nil {
+			return "", false
+		}
+		body = string(decoded)
+	}
+	proxies := parseShareLinks(body)
+	if len(proxies) == 0 {
+		return "", false
+	}
+	out, err := yaml.Marshal(map[string]interface{}{"proxies": proxies})
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// parseShareLinks 逐行解析 URI，返回 mihomo proxy map 列表。重名节点按
+// uniqueName 的规则加序号，避免 mihomo 报 duplicate。
+func parseShareLinks(body string) []map[string]interface{} {
+	var out []map[string]interface{}
+	taken := map[string]bool{}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		p, err := parseShareLink(line)
+		if err != nil {
+			continue
+		}
+		name := uniqueName(p["name"].(string), taken)
+		taken[name] = true
+		p["name"] = name
+		out = append(out, p)
+	}
+	return out
+}
+
+// parseShareLink 按 scheme 分发单条 URI。
+func parseShareLink(link string) (map[string]interface{}, error) {
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok {
+		return nil, fmt.Errorf("不是 URI: %q", link)
+	}
+	switch strings.ToLower(scheme) {
+	case "ss":
+		return parseSS(link)
+	case "vmess":
+		return parseVMess(link)
+	case "trojan":
+		return parseTrojan(link)
+	case "vless":
+		return parseVLESS(link)
+	case "hysteria2", "hy2":
+		return parseHysteria2(link)
+	}
+	return nil, fmt.Errorf("不支持的协议: %s", scheme)
+}
+
+// parseSS 支持两种写法：
+//   - SIP002: ss://base64(method:password)@host:port/?plugin=…#name
+//     （2022 系列 cipher 常见 userinfo 不编码，直接 method:password）
+//   - 旧式:   ss://base64(method:password@host:port)#name
+func parseSS(link string) (map[string]interface{}, error) {
+	rest := link[len("ss://"):]
+	name := ""
+	if i := strings.Index(rest, "#"); i >= 0 {
+		name, _ = url.PathUnescape(rest[i+1:])
+		rest = rest[:i]
+	}
+	if !strings.Contains(rest, "@") {
+		decoded, err := decodeBase64Loose(rest)
+		if err != nil {
+			return nil, fmt.Errorf("ss: 解不开 base64: %w", err)
+		}
+		rest = string(decoded)
+	}
+	// 按最后一个 @ 手动切：base64 的 userinfo 里可能有 '/'，交给 url.Parse 会被当成 path。
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("ss: 缺少 method:password")
+	}
+	userinfo, hostPart := rest[:at], rest[at+1:]
+	if unescaped, err := url.PathUnescape(userinfo); err == nil && strings.Contains(unescaped, ":") {
+		userinfo = unescaped
+	} else if decoded, err := decodeBase64Loose(userinfo); err == nil {
+		userinfo = string(decoded)
+	}
+	method, password, ok := strings.Cut(userinfo, ":")
+	if !ok {
+		return nil, fmt.Errorf("ss: userinfo 应为 method:password")
+	}
+	u, err := url.Parse("ss://" + hostPart)
+	if err != nil {
+		return nil, fmt.Errorf("ss: %w", err)
+	}
+	server, port, err := hostPort(u)
+	if err != nil {
+		return nil, fmt.Errorf("ss: %w", err)
+	}
+	p := newProxyMap(name, "ss", server, port)
+	p["cipher"] = method
+	p["password"] = password
+	p["udp"] = true
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		applySSPlugin(p, plugin)
+	}
+	return p, nil
+}
+
+// applySSPlugin 把 SIP003 plugin 参数（obfs-local;obfs=http;obfs-host=…）翻成
+// mihomo 的 plugin / plugin-opts。只认 obfs 和 v2ray-plugin 两种常见插件。
+func applySSPlugin(p map[string]interface{}, plugin string) {
+	parts := strings.Split(plugin, ";")
+	opts := map[string]string{}
+	for _, kv := range parts[1:] {
+		k, v, _ := strings.Cut(kv, "=")
+		opts[k] = v
+	}
+	switch parts[0] {
+	case "obfs-local", "simple-obfs":
+		p["plugin"] = "obfs"
+		po := map[string]interface{}{"mode": opts["obfs"]}
+		if h := opts["obfs-host"]; h != "" {
+			po["host"] = h
+		}
+		p["plugin-opts"] = po
+	case "v2ray-plugin":
+		p["plugin"] = "v2ray-plugin"
+		po := map[string]interface{}{"mode": firstNonEmptyStr(opts["mode"], "websocket")}
+		if _, ok := opts["tls"]; ok {
+			po["tls"] = true
+		}
+		if h := opts["host"]; h != "" {
+			po["host"] = h
+		}
+		if path := opts["path"]; path != "" {
+			po["path"] = path
+		}
+		p["plugin-opts"] = po
+	}
+}
+
+// vmessJSON 是 v2rayN 分享格式（vmess://base64(json)）的字段。port / aid 有的
+// 机场给数字有的给字符串，还有不少导出写成 "aid":""，用 looseNumber 兼容。
+type vmessJSON struct {
+	PS   string      `json:"ps"`
+	Add  string      `json:"add"`
+	Port looseNumber `json:"port"`
+	ID   string      `json:"id"`
+	Aid  looseNumber `json:"aid"`
+	Scy  string      `json:"scy"`
+	Net  string      `json:"net"`
+	Host string      `json:"host"`
+	Path string      `json:"path"`
+	TLS  string      `json:"tls"`
+	SNI  string      `json:"sni"`
+	ALPN string      `json:"alpn"`
+	FP   string      `json:"fp"`
+}
+
+// looseNumber 接受 JSON 数字或字符串（含空串），原样留下文本，由调用方决定怎么解释。
+// json.Number 碰到 "" 会让整个 Unmarshal 失败，节点就丢了。
+type looseNumber string
+
+func (n *looseNumber) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*n = looseNumber(strings.TrimSpace(s))
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return err
+	}
+	*n = looseNumber(num)
+	return nil
+}
+
+func parseVMess(link string) (map[string]interface{}, error) {
+	raw, err := decodeBase64Loose(link[len("vmess://"):])
+	if err != nil {
+		return nil, fmt.Errorf("vmess: 解不开 base64: %w", err)
+	}
+	var v vmessJSON
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("vmess: %w", err)
+	}
+	port, err := strconv.Atoi(string(v.Port))
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("vmess: 端口非法 %q", v.Port)
+	}
+	if v.Add == "" || v.ID == "" {
+		return nil, fmt.Errorf("vmess: 缺少 add / id")
+	}
+	p := newProxyMap(v.PS, "vmess", v.Add, port)
+	p["uuid"] = v.ID
+	aid, _ := strconv.Atoi(string(v.Aid)) // 空串 / 缺省当 0
+	p["alterId"] = aid
+	p["cipher"] = firstNonEmptyStr(v.Scy, "auto")
+	p["udp"] = true
+	if v.TLS == "tls" {
+		p["tls"] = true
+		if sni := firstNonEmptyStr(v.SNI, v.Host); sni != "" {
+			p["servername"] = sni
+		}
+		if v.ALPN != "" {
+			p["alpn"] = strings.Split(v.ALPN, ",")
+		}
+		if v.FP != "" {
+			p["client-fingerprint"] = v.FP
+		}
+	}
+	// v2rayN 格式里 grpc 的 serviceName 也放在 path 字段。
+	applyTransport(p, v.Net, v.Path, v.Host, v.Path)
+	return p, nil
+}
+
+// parseTrojan: trojan://password@host:port?sni=…&type=ws&path=…&allowInsecure=1#name
+func parseTrojan(link string) (map[string]interface{}, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("trojan: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("trojan: 缺少 password")
+	}
+	server, port, err := hostPort(u)
+	if err != nil {
+		return nil, fmt.Errorf("trojan: %w", err)
+	}
+	q := u.Query()
+	p := newProxyMap(u.Fragment, "trojan", server, port)
+	p["password"] = u.User.Username()
+	p["udp"] = true
+	if sni := firstNonEmptyStr(q.Get("sni"), q.Get("peer")); sni != "" {
+		p["sni"] = sni
+	}
+	applyCommonTLSParams(p, q)
+	applyTransport(p, q.Get("type"), q.Get("path"), q.Get("host"), q.Get("serviceName"))
+	return p, nil
+}
+
+// parseVLESS: vless://uuid@host:port?security=tls|reality&sni=…&flow=…&pbk=…&sid=…&type=ws#name
+func parseVLESS(link string) (map[string]interface{}, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("vless: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("vless: 缺少 uuid")
+	}
+	server, port, err := hostPort(u)
+	if err != nil {
+		return nil, fmt.Errorf("vless: %w", err)
+	}
+	q := u.Query()
+	p := newProxyMap(u.Fragment, "vless", server, port)
+	p["uuid"] = u.User.Username()
+	p["udp"] = true
+	if flow := q.Get("flow"); flow != "" {
+		p["flow"] = flow
+	}
+	switch q.Get("security") {
+	case "tls", "reality":
+		p["tls"] = true
+		if sni := q.Get("sni"); sni != "" {
+			p["servername"] = sni
+		}
+		if q.Get("security") == "reality" {
+			ro := map[string]interface{}{"public-key": q.Get("pbk")}
+			if sid := q.Get("sid"); sid != "" {
+				ro["short-id"] = sid
+			}
+			p["reality-opts"] = ro
+		}
+	}
+	applyCommonTLSParams(p, q)
+	applyTransport(p, q.Get("type"), q.Get("path"), q.Get("host"), q.Get("serviceName"))
+	return p, nil
+}
+
+// parseHysteria2: hysteria2://password@host:port?sni=…&insecure=1&obfs=salamander&obfs-password=…#name
+// hy2:// 是同一格式的简写。
+func parseHysteria2(link string) (map[string]interface{}, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2: %w", err)
+	}
+	server, port, err := hostPort(u)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2: %w", err)
+	}
+	password := ""
+	if u.User != nil {
+		// Username / Password 是解码后的值；User.String() 会保留 %40 这类转义。
+		password = u.User.Username()
+		if pw, ok := u.User.Password(); ok {
+			// hysteria2 的 auth 允许 user:pass 形式，整体就是密码
+			password += ":" + pw
+		}
+	}
+	if password == "" {
+		return nil, fmt.Errorf("hysteria2: 缺少 password")
+	}
+	q := u.Query()
+	p := newProxyMap(u.Fragment, "hysteria2", server, port)
+	p["password"] = password
+	if sni := q.Get("sni"); sni != "" {
+		p["sni"] = sni
+	}
+	if q.Get("insecure") == "1" {
+		p["skip-cert-verify"] = true
+	}
+	if obfs := q.Get("obfs"); obfs != "" {
+		p["obfs"] = obfs
+		if pw := q.Get("obfs-password"); pw != "" {
+			p["obfs-password"] = pw
+		}
+	}
+	if up := q.Get("up"); up != "" {
+		p["up"] = up
+	}
+	if down := q.Get("down"); down != "" {
+		p["down"] = down
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		p["alpn"] = strings.Split(alpn, ",")
+	}
+	return p, nil
+}
+
+// applyCommonTLSParams 处理 trojan / vless 共有的 allowInsecure / alpn / fp。
+func applyCommonTLSParams(p map[string]interface{}, q url.Values) {
+	if q.Get("allowInsecure") == "1" || q.Get("insecure") == "1" {
+		p["skip-cert-verify"] = true
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		p["alpn"] = strings.Split(alpn, ",")
+	}
+	if fp := q.Get("fp"); fp != "" {
+		p["client-fingerprint"] = fp
+	}
+}
+
+// applyTransport 把 network=ws/grpc/h2/http 及其参数翻成 mihomo 的 *-opts。
+// tcp / 空 = 默认，不写 network。
+func applyTransport(p map[string]interface{}, network, path, host, serviceName string) {
+	switch network {
+	case "ws":
+		p["network"] = "ws"
+		wo := map[string]interface{}{}
+		if path != "" {
+			wo["path"] = path
+		}
+		if host != "" {
+			wo["headers"] = map[string]interface{}{"Host": host}
+		}
+		if len(wo) > 0 {
+			p["ws-opts"] = wo
+		}
+	case "grpc":
+		p["network"] = "grpc"
+		if serviceName != "" {
+			p["grpc-opts"] = map[string]interface{}{"grpc-service-name": serviceName}
+		}
+	case "h2":
+		p["network"] = "h2"
+		ho := map[string]interface{}{}
+		if host != "" {
+			ho["host"] = strings.Split(host, ",")
+		}
+		if path != "" {
+			ho["path"] = path
+		}
+		if len(ho) > 0 {
+			p["h2-opts"] = ho
+		}
+	case "http":
+		p["network"] = "http"
+		ho := map[string]interface{}{}
+		if path != "" {
+			ho["path"] = []string{path}
+		}
+		if host != "" {
+			ho["headers"] = map[string]interface{}{"Host": []string{host}}
+		}
+		if len(ho) > 0 {
+			p["http-opts"] = ho
+		}
+	}
+}
+
+// newProxyMap 拼 proxy map 的公共字段。name 为空时用 server:port 兜底。
+func newProxyMap(name, typ, server string, port int) map[string]interface{} {
+	if strings.TrimSpace(name) == "" {
+		name = net.JoinHostPort(server, strconv.Itoa(port))
+	}
+	return map[string]interface{}{
+		"name":   name,
+		"type":   typ,
+		"server": server,
+		"port":   port,
+	}
+}
+
+func hostPort(u *url.URL) (string, int, error) {
+	host := u.Hostname()
+	if host == "" {
+		return "", 0, fmt.Errorf("缺少 host")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("端口非法 %q", u.Port())
+	}
+	return host, port, nil
+}
+
+// decodeBase64Loose 依次试标准 / 无 padding / URL-safe 三种 base64 变体——
+// 机场各用各的，连同一家不同接口都可能不一样。
+func decodeBase64Loose(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	var lastErr error
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		b, err := enc.DecodeString(s)
+		if err == nil {
+			return b, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func compactWhitespace(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\r', '\n', '\t', ' ':
+			return -1
+		default:
+			return r
+		}
+	}, text)
+}
+
+func firstNonEmptyStr(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
@@ -1,5 +1,5 @@
 // Package source implements the "extension" feature: turning a user's proxy
-// source (local port / subscription / file / single remote / none) into the
+// source (local port / subscription / multi / file / single remote / none) into the
 // mihomo YAML fragment that defines `proxies:`, `proxy-providers:` and
 // `proxy-groups:` — keyed as the `Proxy` group so traffic rules can target it.
 package source
@@ -13,7 +13,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +37,9 @@ type MaterializeOptions struct {
 	// "Auto" (url-test) + "Fallback" (fallback)，引用订阅里全部节点。
 	// 已有对应类型组时不重复追加，不改用户已有组的内容。
 	AutoGroups bool
+	// RegionGroups 是 traffic.region_groups 的旁路：按节点名的国旗 / 关键词生成
+	// 「🇭🇰 香港」这类地区 url-test 组（见 region.go）。对 subscription / multi / file 生效。
+	RegionGroups bool
 }
 
 // Materialize produces the Fragment for this source config.
@@ -46,6 +48,16 @@ func Materialize(ctx context.Context, src config.SourceConfig, workDir string) (
 	return MaterializeWithOptions(ctx, src, workDir, MaterializeOptions{})
 }
 
+// groupOptions 是 inline 订阅 / 文件时要补哪些策略组，从 MaterializeOptions 摘出来。
+type groupOptions struct {
+	auto   bool // Auto / Fallback
+	region bool // 地区 url-test 组
+}
+
+func (o MaterializeOptions) groupOptions() groupOptions {
+	return groupOptions{auto: o.AutoGroups, region: o.RegionGroups}
+}
+
 // MaterializeWithOptions is the runtime-aware variant used by engine reload paths.
 func MaterializeWithOptions(ctx context.Context, src config.SourceConfig, workDir string, opts MaterializeOptions) (Fragment, error) {
 	switch src.Type {
@@ -56,9 +68,15 @@ func MaterializeWithOptions(ctx context.Context, src config.SourceConfig, workDi
 		if proxyURL == "" {
 			proxyURL = firstUpstreamProxyURL(src)
 		}
-		return materializeSubscription(ctx, src.Subscription, workDir, proxyURL, opts.AutoGroups)
+		return materializeSubscription(ctx, src.Subscription, workDir, proxyURL, opts.groupOptions())
+	case config.SourceTypeMulti:
+		proxyURL := opts.SubscriptionProxyURL
+		if proxyURL == "" {
+			proxyURL = firstUpstreamProxyURL(src)
+		}
+		return materializeMulti(ctx, src.Subscriptions, workDir, proxyURL, opts.groupOptions())
 	case config.SourceTypeFile:
-		return materializeFile(src.File, workDir, opts.AutoGroups)
+		return materializeFile(src.File, workDir, opts.groupOptions())
 	case config.SourceTypeRemote:
 		return materializeRemote(src.Remote), nil
 	case config.SourceTypeNone:
@@ -141,41 +159,91 @@ func singleProxyFragment(proxyYaml string) string {
 //
 // 把订阅 yaml 下载到 workdir 做备份（方便调试 / 下次启动离线用），
 // 但真正给 mihomo 的是 inline 的 proxies + proxy-groups + rules。
-func materializeSubscription(ctx context.Context, s config.SubscriptionSource, workDir string, proxyURL string, autoGroups bool) (Fragment, error) {
+func materializeSubscription(ctx context.Context, s config.SubscriptionSource, workDir string, proxyURL string, groups groupOptions) (Fragment, error) {
 	if err := os.MkdirAll(workDir, 0o755); err != nil {
 		return Fragment{}, err
 	}
-	dst := filepath.Join(workDir, "subscription.yaml")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	normalized, info, staleAt, err := fetchSubscriptionOrCache(ctx, s.URL, proxyURL, subscriptionCachePath(workDir, -1))
 	if err != nil {
-		return Fragment{}, fmt.Errorf("build request: %w", err)
+		return Fragment{}, err
+	}
+	var infos []SubscriptionInfo
+	if info != nil {
+		info.Name = s.Name
+		infos = append(infos, *info)
+	}
+	_ = SaveSubscriptionInfo(workDir, infos)
+
+	frag, err := inlineUserYAML(normalized, s.Filter, groups)
+	if err != nil {
+		return Fragment{}, fmt.Errorf("解析订阅 yaml: %w", err)
+	}
+	frag.Summary = fmt.Sprintf("订阅 · %s", s.Name) + staleNote(staleAt)
+	return frag, nil
+}
+
+// fetchSubscription 拉一份订阅并做 normalizeSubscriptionContent。单订阅和多订阅共用。
+// 响应带 subscription-userinfo 头时一并解析返回，没有则 info 为 nil。
+//
+// cachePath 是这份订阅「上次成功内容」的落盘位置（subscription.yaml 等）：
+//   - 上次的 ETag / Last-Modified 记在旁边的 .meta.json，这次带上做条件请求，
+//     机场回 304 就直接用缓存，省掉整份下载；
+//   - 拉到新内容才覆盖缓存；新内容一个节点都没有而缓存里有时拒绝覆盖并报错 ——
+//     机场偶尔回一个「空订阅」，不能让它把上次可用的节点冲掉。
+func fetchSubscription(ctx context.Context, rawURL string, proxyURL string, cachePath string) ([]byte, *SubscriptionInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build request: %w", err)
 	}
 	req.Header.Set("User-Agent", "clash-meta/1.18")
+	meta, cached := loadSubscriptionCache(cachePath, rawURL)
+	if cached != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
 	client := newSubscriptionClient(proxyURL)
 	resp, err := client.Do(req)
 	if err != nil {
-		return Fragment{}, fmt.Errorf("fetch subscription: %w", err)
+		return nil, nil, unreachableError{fmt.Errorf("fetch subscription: %w", err)}
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, meta.Info, nil
+	}
+	if resp.StatusCode >= 500 {
+		return nil, nil, unreachableError{fmt.Errorf("subscription HTTP %d", resp.StatusCode)}
+	}
 	if resp.StatusCode >= 400 {
-		return Fragment{}, fmt.Errorf("subscription HTTP %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("subscription HTTP %d", resp.StatusCode)
 	}
 	data, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
 	if err != nil {
-		return Fragment{}, fmt.Errorf("read subscription: %w", err)
+		return nil, nil, fmt.Errorf("read subscription: %w", err)
 	}
 	normalized, err := normalizeSubscriptionContent(data)
 	if err != nil {
-		return Fragment{}, fmt.Errorf("normalize subscription: %w", err)
-	}
-	_ = os.WriteFile(dst, normalized, 0o600)
-
-	frag, err := inlineUserYAML(normalized, autoGroups)
-	if err != nil {
-		return Fragment{}, fmt.Errorf("解析订阅 yaml: %w", err)
-	}
-	frag.Summary = fmt.Sprintf("订阅 · %s", s.Name)
-	return frag, nil
+		return nil, nil, fmt.Errorf("normalize subscription: %w", err)
+	}
+	if cached != nil && countProxies(normalized) == 0 && countProxies(cached) > 0 {
+		return nil, nil, fmt.Errorf("订阅返回了空节点列表，保留上次可用的内容")
+	}
+	var info *SubscriptionInfo
+	if parsed, ok := ParseSubscriptionUserinfo(resp.Header.Get("subscription-userinfo")); ok {
+		parsed.UpdatedAt = time.Now()
+		info = &parsed
+	}
+	saveSubscriptionCache(cachePath, normalized, subscriptionCacheMeta{
+		URL:          rawURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Info:         info,
+		FetchedAt:    time.Now(),
+	})
+	return normalized, info, nil
 }
 
 // --- file: load local Clash/mihomo YAML ---
@@ -184,7 +252,7 @@ func materializeSubscription(ctx context.Context, s config.SubscriptionSource, w
 // 用户 yaml 里自己的 proxy-groups 和 rules 会被整体扔掉。所以这里改成
 // inline：读 yaml → 提 proxies + proxy-groups + rules → 直接嵌进最终
 // mihomo config.yaml。script enhancer 和「切换节点」菜单都能看到完整内容。
-func materializeFile(f config.FileSource, workDir string, autoGroups bool) (Fragment, error) {
+func materializeFile(f config.FileSource, workDir string, groups groupOptions) (Fragment, error) {
 	info, err := os.Stat(f.Path)
 	if err != nil {
 		return Fragment{}, fmt.Errorf("本地配置文件 %s: %w", f.Path, err)
@@ -200,7 +268,7 @@ func materializeFile(f config.FileSource, workDir string, autoGroups bool) (Frag
 	if err != nil {
 		return Fragment{}, fmt.Errorf("标准化 %s 失败: %w", f.Path, err)
 	}
-	frag, err := inlineUserYAML(normalized, autoGroups)
+	frag, err := inlineUserYAML(normalized, f.Filter, groups)
 	if err != nil {
 		return Fragment{}, fmt.Errorf("解析 %s: %w", f.Path, err)
 	}
@@ -214,7 +282,7 @@ func materializeFile(f config.FileSource, workDir string, autoGroups bool) (Frag
 //
 // 如果用户 yaml 里没有「Proxy」组，补一个 select 组指向 DIRECT，让 base rules
 // 里的 `MATCH,Proxy`（rule 模式兜底）不会挂。
-func inlineUserYAML(data []byte, autoGroups bool) (Fragment, error) {
+func inlineUserYAML(data []byte, filter config.NodeFilter, groups groupOptions) (Fragment, error) {
 	var doc struct {
 		Proxies     []yaml.Node `yaml:"proxies"`
 		ProxyGroups []yaml.Node `yaml:"proxy-groups"`
@@ -224,6 +292,16 @@ func inlineUserYAML(data []byte, autoGroups bool) (Fragment, error) {
 		return Fragment{}, err
 	}
 
+	// 先过滤 / 改名节点，再同步订阅自带分组和规则的引用 —— 后面补 Proxy / Auto /
+	// Fallback 组时看到的就已经是干净的节点列表。
+	kept, renames, err := filterNodes(doc.Proxies, filter)
+	if err != nil {
+		return Fragment{}, err
+	}
+	doc.Proxies = kept
+	applyRenamesToGroups(doc.ProxyGroups, renames)
+	doc.Rules = applyRenamesToRules(doc.Rules, renames)
+
 	// 检查用户是否有 Proxy 组
 	hasProxyGroup := false
 	for _, g := range doc.ProxyGroups {
@@ -261,7 +339,7 @@ proxies:
 	// 这两个策略。只在用户订阅**按类型**没有对应组时才追加，已有则不动。
 	// 追加的组引用 proxies 里所有节点名，给只会"直选节点"的订阅用户一个
 	// 自动切换兜底。用户没开这个开关时完全沿用订阅原状。
-	if autoGroups {
+	if groups.auto {
 		if err := appendAutoFallbackGroups(&doc); err != nil {
 			return Fragment{}, err
 		}
@@ -274,6 +352,16 @@ proxies:
 		augmentProxyGroupOptions(&doc)
 	}
 
+	// 地区组放在 Auto / Fallback 之后追加：appendAutoFallbackGroups 按「已有
+	// url-test 组就不加 Auto」判断，先加地区组会把 Auto 挤掉。
+	if groups.region {
+		names, err := appendRegionGroups(&doc)
+		if err != nil {
+			return Fragment{}, err
+		}
+		addRegionGroupsToProxy(doc.ProxyGroups, names)
+	}
+
 	extract := map[string]interface{}{}
 	if len(doc.Proxies) > 0 {
 		extract["proxies"] = doc.Proxies
@@ -492,20 +580,18 @@ func normalizeSubscriptionContent(data []byte) ([]byte, error) {
 	// 时才替换，避免误伤正常明文 YAML。
 	if maybe, ok := tryDecodeBase64YAML(text); ok {
 		text = maybe
+	} else if !looksLikeYAML(text) {
+		// 4) 再不是 YAML，就按 V2Ray 风格的分享链接列表（明文或 base64）试一次。
+		if converted, ok := convertShareLinks(text); ok {
+			text = converted
+		}
 	}
 
 	return []byte(text), nil
 }
 
 func tryDecodeBase64YAML(text string) (string, bool) {
-	compact := strings.Map(func(r rune) rune {
-		switch r {
-		case '\r', '\n', '\t', ' ':
-			return -1
-		default:
-			return r
-		}
-	}, text)
+	compact := compactWhitespace(text)
 	if compact == "" {
 		return "", false
 	}
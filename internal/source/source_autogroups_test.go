@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
 )
 
 // 三个节点 + 一个 select 类型的 Proxy 组。订阅里既没 url-test 也没 fallback。
@@ -32,7 +34,7 @@ rules:
 `
 
 func TestInlineUserYAML_AutoGroupsOff(t *testing.T) {
-	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), false)
+	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), config.NodeFilter{}, groupOptions{})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -45,7 +47,7 @@ func TestInlineUserYAML_AutoGroupsOff(t *testing.T) {
 }
 
 func TestInlineUserYAML_AutoGroupsAppendsBoth(t *testing.T) {
-	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), true)
+	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -97,7 +99,7 @@ proxy-groups:
     type: select
     proxies: ["🚀 自动", n1]
 `
-	frag, err := inlineUserYAML([]byte(yamlHasUrlTest), true)
+	frag, err := inlineUserYAML([]byte(yamlHasUrlTest), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -136,7 +138,7 @@ proxy-groups:
     type: select
     proxies: [AutoX, FbX, n1]
 `
-	frag, err := inlineUserYAML([]byte(yamlHasBoth), true)
+	frag, err := inlineUserYAML([]byte(yamlHasBoth), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -168,7 +170,7 @@ proxy-groups:
     type: select
     proxies: [Auto, n1]
 `
-	frag, err := inlineUserYAML([]byte(yamlAutoIsSelect), true)
+	frag, err := inlineUserYAML([]byte(yamlAutoIsSelect), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -220,7 +222,7 @@ proxy-groups:
 rules:
   - MATCH,Proxy
 `
-	frag, err := inlineUserYAML([]byte(yamlExistingSmartGroups), true)
+	frag, err := inlineUserYAML([]byte(yamlExistingSmartGroups), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -252,7 +254,7 @@ proxy-groups:
     type: select
     proxies: [n1]
 `
-	frag, err := inlineUserYAML([]byte(yamlNoProxyGroup), true)
+	frag, err := inlineUserYAML([]byte(yamlNoProxyGroup), config.NodeFilter{}, groupOptions{auto: true})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -267,7 +269,7 @@ proxy-groups:
 
 // auto_groups=false 时绝对不能改 Proxy 组的 proxies 列表 (零侵入升级)
 func TestInlineUserYAML_AutoGroupsOff_ProxyGroupUntouched(t *testing.T) {
-	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), false)
+	frag, err := inlineUserYAML([]byte(yamlThreeProxiesOneSelectGroup), config.NodeFilter{}, groupOptions{})
 	if err != nil {
 		t.Fatalf("inline: %v", err)
 	}
@@ -28,7 +28,8 @@ type TestOptions struct {
 // Test 探测当前源是否可达。每种 type 做不同的检查：
 //   - external / remote: TCP dial server:port
 //   - subscription: HTTP GET url（状态码 < 400）
-//   - file: 读文件 + 粗看像 Clash YAML（有 proxies / proxy-providers）
+//   - multi: 逐份 HTTP GET，至少一份可达即算通（合并源里一家挂了不影响整体）
+//   - file: 读文件 + 粗看像 Clash YAML（有 proxies / proxy-providers，或是分享链接列表）
 //   - none: 直连不用测
 //
 // 失败返回中文 error；成功返回 nil。超时走 ctx。
@@ -55,6 +56,12 @@ func TestWithOptions(ctx context.Context, src config.SourceConfig, opts TestOpti
 			proxyURL = firstUpstreamProxyURL(src)
 		}
 		return testURL(ctx, src.Subscription.URL, proxyURL)
+	case config.SourceTypeMulti:
+		proxyURL := opts.SubscriptionProxyURL
+		if proxyURL == "" {
+			proxyURL = firstUpstreamProxyURL(src)
+		}
+		return testMulti(ctx, src.Subscriptions, proxyURL)
 	case config.SourceTypeFile:
 		return testFile(src.File.Path)
 	case config.SourceTypeNone, "":
@@ -134,6 +141,21 @@ func testURL(ctx context.Context, url string, proxyURL string) error {
 	return nil
 }
 
+func testMulti(ctx context.Context, subs []config.SubscriptionSource, proxyURL string) error {
+	if len(subs) == 0 {
+		return fmt.Errorf("多订阅源没有配置任何订阅")
+	}
+	var errs []string
+	for _, s := range subs {
+		err := testURL(ctx, s.URL, proxyURL)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", s.Name, err))
+	}
+	return fmt.Errorf("所有订阅都不可达 → %s", strings.Join(errs, "; "))
+}
+
 func testFile(path string) error {
 	if path == "" {
 		return fmt.Errorf("文件路径为空")
@@ -149,9 +171,13 @@ func testFile(path string) error {
 	if err != nil {
 		return fmt.Errorf("读不了文件 → %w", err)
 	}
+	// 先走一遍 normalize，分享链接列表（ss:// / vmess:// …）转换后也算合法。
+	if normalized, err := normalizeSubscriptionContent(data); err == nil {
+		data = normalized
+	}
 	s := string(data)
 	if !strings.Contains(s, "proxies") && !strings.Contains(s, "proxy-providers") {
-		return fmt.Errorf("文件里没找到 proxies / proxy-providers，看起来不是 Clash/mihomo YAML")
+		return fmt.Errorf("文件里没找到 proxies / proxy-providers，也不是分享链接列表，看起来不是 Clash/mihomo YAML")
 	}
 	return nil
 }
@@ -0,0 +1,196 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/stats"
+)
+
+// --- subscription-userinfo: 机场流量 / 到期信息 ---
+//
+// 大多数机场在订阅响应里带一个头：
+//
+//	subscription-userinfo: upload=123; download=456; total=1073741824; expire=1767225600
+//
+// 单位是字节 / unix 秒；total=0 表示不限量，expire 缺失或 0 表示不过期。
+// 拉订阅时解析出来落盘到 workDir/subscription-info.json（和 subscription.yaml
+// 放一起），status / 控制台 / supervisor 都只读这个文件，不会为了看余量再去请求机场。
+
+// SubscriptionInfoFile 是 workDir 下保存流量信息的文件名。
+const SubscriptionInfoFile = "subscription-info.json"
+
+// QuotaWarnPercent 是流量告警阈值：已用 ≥ 90% 时 supervisor 告警。
+const QuotaWarnPercent = 90
+
+// SubscriptionInfo 是一份订阅的流量 / 到期信息。Name 是订阅名（多订阅时区分各家）。
+type SubscriptionInfo struct {
+	Name      string    `json:"name"`
+	Upload    int64     `json:"upload"`
+	Download  int64     `json:"download"`
+	Total     int64     `json:"total"`
+	Expire    time.Time `json:"expire"` // 零值 = 不过期（omitempty 对 time.Time 不起作用，别加）
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Used 返回已用流量（上行 + 下行）。
+func (i SubscriptionInfo) Used() int64 { return i.Upload + i.Download }
+
+// UsedPercent 返回已用百分比；total=0（不限量）时返回 -1。
+func (i SubscriptionInfo) UsedPercent() float64 {
+	if i.Total <= 0 {
+		return -1
+	}
+	return float64(i.Used()) * 100 / float64(i.Total)
+}
+
+// DaysLeft 返回距到期还剩几天（向上取整，已过期为负）；不过期时 ok=false。
+func (i SubscriptionInfo) DaysLeft(now time.Time) (days int, ok bool) {
+	if i.Expire.IsZero() {
+		return 0, false
+	}
+	return int(math.Ceil(i.Expire.Sub(now).Hours() / 24)), true
+}
+
+// Summary 拼一行给人看的描述，例：「已用 45.2 GB / 100 GB（45%）· 2026-12-01 到期（剩 44 天）」。
+func (i SubscriptionInfo) Summary(now time.Time) string {
+	var parts []string
+	if i.Total > 0 {
+		parts = append(parts, fmt.Sprintf("已用 %s / %s（%.0f%%）",
+			stats.FormatBytes(i.Used()), stats.FormatBytes(i.Total), i.UsedPercent()))
+	} else {
+		parts = append(parts, fmt.Sprintf("已用 %s（不限量）", stats.FormatBytes(i.Used())))
+	}
+	if days, ok := i.DaysLeft(now); ok {
+		date := i.Expire.Local().Format("2006-01-02")
+		if days <= 0 {
+			parts = append(parts, date+" 已到期")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s 到期（剩 %d 天）", date, days))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Warnings 返回需要提醒用户的问题：流量已用 ≥ QuotaWarnPercent，或到期在 warnDays 天内。
+// warnDays <= 0 时不检查到期。
+func (i SubscriptionInfo) Warnings(now time.Time, warnDays int) []string {
+	label := "订阅"
+	if i.Name != "" {
+		label = "订阅 " + i.Name
+	}
+	var out []string
+	if p := i.UsedPercent(); p >= QuotaWarnPercent {
+		left := i.Total - i.Used()
+		if left < 0 {
+			left = 0
+		}
+		out = append(out, fmt.Sprintf("%s 流量已用 %.0f%%（剩 %s）", label, p, stats.FormatBytes(left)))
+	}
+	if w, ok := i.ExpiryWarning(now, warnDays); ok {
+		out = append(out, w)
+	}
+	return out
+}
+
+// ExpiryWarning 返回到期告警（已到期或剩余天数 ≤ warnDays）；不用告警时 ok=false。
+func (i SubscriptionInfo) ExpiryWarning(now time.Time, warnDays int) (string, bool) {
+	days, ok := i.DaysLeft(now)
+	if !ok || warnDays <= 0 {
+		return "", false
+	}
+	label := "订阅"
+	if i.Name != "" {
+		label = "订阅 " + i.Name
+	}
+	switch {
+	case days <= 0:
+		return fmt.Sprintf("%s 已于 %s 到期", label, i.Expire.Local().Format("2006-01-02")), true
+	case days <= warnDays:
+		return fmt.Sprintf("%s 将于 %s 到期（剩 %d 天）", label, i.Expire.Local().Format("2006-01-02"), days), true
+	}
+	return "", false
+}
+
+// ParseSubscriptionUserinfo 解析 subscription-userinfo 头。字段之间用 ; 分隔，
+// 顺序不定、大小写不敏感，未知字段忽略；一个已知字段都没有时 ok=false。
+// 个别机场会把数字写成 1.5e+11 这种浮点形式，也一并兼容。
+func ParseSubscriptionUserinfo(header string) (info SubscriptionInfo, ok bool) {
+	for _, part := range strings.Split(header, ";") {
+		key, val, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		n, err := parseUserinfoNumber(strings.TrimSpace(val))
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			info.Upload = n
+		case "download":
+			info.Download = n
+		case "total":
+			info.Total = n
+		case "expire":
+			if n > 0 {
+				info.Expire = time.Unix(n, 0).UTC()
+			}
+		default:
+			continue
+		}
+		ok = true
+	}
+	return info, ok
+}
+
+func parseUserinfoNumber(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(f), nil
+}
+
+// SaveSubscriptionInfo 把流量信息写到 workDir/subscription-info.json。
+// infos 为空时删掉旧文件 —— 换了个不带这个头的订阅，不该继续显示上一家的余量。
+func SaveSubscriptionInfo(workDir string, infos []SubscriptionInfo) error {
+	path := filepath.Join(workDir, SubscriptionInfoFile)
+	if len(infos) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// LoadSubscriptionInfo 读 workDir/subscription-info.json；文件不存在返回 nil, nil。
+func LoadSubscriptionInfo(workDir string) ([]SubscriptionInfo, error) {
+	b, err := os.ReadFile(filepath.Join(workDir, SubscriptionInfoFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var infos []SubscriptionInfo
+	if err := json.Unmarshal(b, &infos); err != nil {
+		return nil, fmt.Errorf("解析 %s: %w", SubscriptionInfoFile, err)
+	}
+	return infos, nil
+}
@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestParseSubscriptionUserinfo(t *testing.T) {
+	info, ok := ParseSubscriptionUserinfo("upload=1024; download=2048;total=10240; expire=1767225600")
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	if info.Upload != 1024 || info.Download != 2048 || info.Total != 10240 {
+		t.Fatalf("unexpected bytes: %+v", info)
+	}
+	if !info.Expire.Equal(time.Unix(1767225600, 0)) {
+		t.Fatalf("unexpected expire: %v", info.Expire)
+	}
+
+	// 浮点写法、大小写、未知字段、expire=0 都要兼容
+	info, ok = ParseSubscriptionUserinfo("Upload=1.5e+3; DOWNLOAD=0; total=0; expire=0; foo=bar")
+	if !ok || info.Upload != 1500 || !info.Expire.IsZero() {
+		t.Fatalf("unexpected: ok=%v %+v", ok, info)
+	}
+
+	if _, ok := ParseSubscriptionUserinfo(""); ok {
+		t.Fatal("empty header should not be ok")
+	}
+	if _, ok := ParseSubscriptionUserinfo("foo=1; bar"); ok {
+		t.Fatal("header without known fields should not be ok")
+	}
+}
+
+func TestSubscriptionInfoWarnings(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	gb := int64(1 << 30)
+
+	healthy := SubscriptionInfo{Name: "a", Download: 10 * gb, Total: 100 * gb, Expire: now.Add(30 * 24 * time.Hour)}
+	if w := healthy.Warnings(now, 7); len(w) != 0 {
+		t.Fatalf("expected no warnings, got %v", w)
+	}
+	if s := healthy.Summary(now); !strings.Contains(s, "10 GB / 100 GB（10%）") || !strings.Contains(s, "剩 30 天") {
+		t.Fatalf("unexpected summary: %q", s)
+	}
+
+	nearlyFull := SubscriptionInfo{Name: "a", Upload: 5 * gb, Download: 87 * gb, Total: 100 * gb}
+	if w := nearlyFull.Warnings(now, 7); len(w) != 1 || !strings.Contains(w[0], "92%") || !strings.Contains(w[0], "8 GB") {
+		t.Fatalf("expected quota warning, got %v", w)
+	}
+
+	expiring := SubscriptionInfo{Name: "a", Expire: now.Add(3*24*time.Hour - time.Hour)}
+	if w := expiring.Warnings(now, 7); len(w) != 1 || !strings.Contains(w[0], "剩 3 天") {
+		t.Fatalf("expected expiry warning, got %v", w)
+	}
+	if w := expiring.Warnings(now, 2); len(w) != 0 {
+		t.Fatalf("expiry outside window should not warn, got %v", w)
+	}
+	if w := expiring.Warnings(now, -1); len(w) != 0 {
+		t.Fatalf("negative warn days disables expiry warning, got %v", w)
+	}
+
+	expired := SubscriptionInfo{Name: "a", Expire: now.Add(-time.Hour)}
+	if w := expired.Warnings(now, 7); len(w) != 1 || !strings.Contains(w[0], "已于") {
+		t.Fatalf("expected expired warning, got %v", w)
+	}
+
+	unlimited := SubscriptionInfo{Download: 500 * gb}
+	if unlimited.UsedPercent() != -1 || len(unlimited.Warnings(now, 7)) != 0 {
+		t.Fatalf("unlimited plan should never warn on quota")
+	}
+}
+
+func TestMaterializeSubscriptionSavesUserinfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Subscription-Userinfo", "upload=1; download=2; total=100; expire=1767225600")
+		_, _ = io.WriteString(w, multiSubA)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	src := config.SourceConfig{
+		Type:         config.SourceTypeSubscription,
+		Subscription: config.SubscriptionSource{Name: "airport", URL: srv.URL},
+	}
+	if _, err := MaterializeWithOptions(context.Background(), src, dir, MaterializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	infos, err := LoadSubscriptionInfo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "airport" || infos[0].Total != 100 || infos[0].UpdatedAt.IsZero() {
+		t.Fatalf("unexpected infos: %+v", infos)
+	}
+
+	// 换成不带这个头的订阅：旧信息要清掉
+	plain := subscriptionServer(t, multiSubA)
+	src.Subscription.URL = plain.URL
+	if _, err := MaterializeWithOptions(context.Background(), src, dir, MaterializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, SubscriptionInfoFile)); !os.IsNotExist(err) {
+		t.Fatalf("stale info file should be removed, stat err=%v", err)
+	}
+}
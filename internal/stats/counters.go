@@ -0,0 +1,68 @@
+package stats
+
+import "sync"
+
+// Counters 是进程内按设备 / 按策略组累加的字节计数，给 /metrics 用。
+//
+// 和 Store 分开：Store 是跨进程共享、按小时落盘的账本；这里是本进程启动以来
+// 单调递增的计数器（Prometheus 自己处理进程重启后的归零），不落盘。
+type Counters struct {
+	mu     sync.Mutex
+	last   map[string]Usage
+	device map[string]Usage
+	group  map[string]Usage
+}
+
+// NewCounters 返回一组空计数器。
+func NewCounters() *Counters {
+	return &Counters{
+		last:   map[string]Usage{},
+		device: map[string]Usage{},
+		group:  map[string]Usage{},
+	}
+}
+
+// Observe 记一次采样：每条连接和上次的差值分别加到来源设备和策略组上。
+// 第一次见到的连接从 0 算起。
+func (c *Counters) Observe(samples []Sample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := make(map[string]Usage, len(samples))
+	for _, s := range samples {
+		cur := Usage{Up: s.Upload, Down: s.Download}
+		next[s.ID] = cur
+		d := cur
+		if p, ok := c.last[s.ID]; ok && p.Up <= cur.Up && p.Down <= cur.Down {
+			d = Usage{Up: cur.Up - p.Up, Down: cur.Down - p.Down}
+		}
+		if d.Total() == 0 {
+			continue
+		}
+		if !SkipSource(s.SourceIP) {
+			u := c.device[s.SourceIP]
+			u.add(d)
+			c.device[s.SourceIP] = u
+		}
+		if s.Group != "" {
+			u := c.group[s.Group]
+			u.add(d)
+			c.group[s.Group] = u
+		}
+	}
+	c.last = next
+}
+
+// Snapshot 返回当前计数的拷贝。
+func (c *Counters) Snapshot() (devices, groups map[string]Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	devices = make(map[string]Usage, len(c.device))
+	for k, v := range c.device {
+		devices[k] = v
+	}
+	groups = make(map[string]Usage, len(c.group))
+	for k, v := range c.group {
+		groups[k] = v
+	}
+	return devices, groups
+}
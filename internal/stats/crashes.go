@@ -0,0 +1,27 @@
+package stats
+
+import "time"
+
+const engineCrashesFile = "engine-crashes.jsonl"
+
+// EngineCrash 是 mihomo 的一次意外退出，以及 watchdog 怎么把它拉起来的。
+type EngineCrash struct {
+	At time.Time `json:"at"`
+	// LogTail 是崩溃时 mihomo.log 的末尾几行。重启会清空日志，不存下来就没了。
+	LogTail   string `json:"log_tail,omitempty"`
+	Attempts  int    `json:"attempts"` // 重启试了几次
+	Restarted bool   `json:"restarted"`
+	// LastGood 表示短时间内崩得太多，已经退回 last-known-good 的 config.yaml。
+	LastGood bool   `json:"last_good,omitempty"`
+	Error    string `json:"error,omitempty"` // 最后一次重启失败的原因
+}
+
+// AppendEngineCrash 往账本目录的 engine-crashes.jsonl 追加一次崩溃。
+func (s *Store) AppendEngineCrash(c EngineCrash) error {
+	return s.appendLine(engineCrashesFile, c)
+}
+
+// EngineCrashes 返回最近 limit 次崩溃（旧的在前）；limit <= 0 表示全部。
+func (s *Store) EngineCrashes(limit int) ([]EngineCrash, error) {
+	return readLines[EngineCrash](s, engineCrashesFile, limit)
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// 节点测速历史：每次 `gateway node test` 记一行，按天一个 delays-2006-01-02.jsonl，
+// 和流量账本一起按 Retention 清理。
+
+const (
+	delayFilePrefix = "delays-"
+	delayFileExt    = ".jsonl"
+)
+
+// DelayRun 是一次测速：Delays 是节点 → 延迟 ms，0 = 超时或不通。
+type DelayRun struct {
+	At     time.Time      `json:"at"`
+	Group  string         `json:"group,omitempty"` // 测的是整组时的组名
+	URL    string         `json:"url"`
+	Delays map[string]int `json:"delays"`
+}
+
+// DelayStats 是一个节点在一段时间内的测速统计。延迟只算测通的次数。
+type DelayStats struct {
+	Tests       int     `json:"tests"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"` // 0-1
+	MedianMs    int     `json:"median_ms"`
+	P95Ms       int     `json:"p95_ms"`
+	MinMs       int     `json:"min_ms"`
+	MaxMs       int     `json:"max_ms"`
+}
+
+// DayDelay 是某一天的统计。
+type DayDelay struct {
+	Date string `json:"date"`
+	DelayStats
+}
+
+// NodeHistory 是一个节点的测速历史汇总，Days 按日期升序。
+type NodeHistory struct {
+	Node   string     `json:"node"`
+	Since  time.Time  `json:"since"`
+	Total  DelayStats `json:"total"`
+	Days   []DayDelay `json:"days,omitempty"`
+	LastAt time.Time  `json:"last_at,omitempty"`
+}
+
+// AppendDelayRun 把一次测速追加进 At 当天的文件。
+func (s *Store) AppendDelayRun(r DelayRun) error {
+	return s.appendLine(delayDayName(r.At), r)
+}
+
+// DelayRuns 返回 [since, until) 之间的测速记录，按时间升序。
+func (s *Store) DelayRuns(since, until time.Time) ([]DelayRun, error) {
+	var out []DelayRun
+	for d := startOfDay(since); d.Before(until); d = d.AddDate(0, 0, 1) {
+		runs, err := readLines[DelayRun](s, delayDayName(d), 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range runs {
+			if !r.At.Before(since) && r.At.Before(until) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+// SummarizeNode 从测速记录里挑出 node 的结果做统计。
+func SummarizeNode(runs []DelayRun, node string, since time.Time) NodeHistory {
+	h := NodeHistory{Node: node, Since: since}
+	var all []int
+	allFail := 0
+	byDay := map[string][]int{}
+	failDay := map[string]int{}
+	var dates []string
+	for _, r := range runs {
+		d, ok := r.Delays[node]
+		if !ok {
+			continue
+		}
+		date := r.At.Format(dayLayout)
+		if _, seen := byDay[date]; !seen {
+			byDay[date] = nil
+			dates = append(dates, date)
+		}
+		if d > 0 {
+			all = append(all, d)
+			byDay[date] = append(byDay[date], d)
+		} else {
+			allFail++
+			failDay[date]++
+		}
+		h.LastAt = r.At
+	}
+	h.Total = delayStats(all, allFail)
+	sort.Strings(dates)
+	for _, date := range dates {
+		h.Days = append(h.Days, DayDelay{Date: date, DelayStats: delayStats(byDay[date], failDay[date])})
+	}
+	return h
+}
+
+func delayStats(ok []int, failures int) DelayStats {
+	st := DelayStats{Tests: len(ok) + failures, Failures: failures}
+	if st.Tests > 0 {
+		st.FailureRate = float64(failures) / float64(st.Tests)
+	}
+	if len(ok) == 0 {
+		return st
+	}
+	sorted := append([]int(nil), ok...)
+	sort.Ints(sorted)
+	st.MinMs, st.MaxMs = sorted[0], sorted[len(sorted)-1]
+	st.MedianMs = percentile(sorted, 50)
+	st.P95Ms = percentile(sorted, 95)
+	return st
+}
+
+// percentile 用最近秩法取第 p 百分位，sorted 必须升序且非空。
+func percentile(sorted []int, p int) int {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 × n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func delayDayName(t time.Time) string {
+	return delayFilePrefix + t.Format(dayLayout) + delayFileExt
+}
+
+// delayFileDate 从 delays-2006-01-02.jsonl 里取日期部分；不是测速文件时 ok=false。
+func delayFileDate(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, delayFilePrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(rest, delayFileExt)
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayRunsAndSummary(t *testing.T) {
+	s := Open(t.TempDir())
+	day1 := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	runs := []DelayRun{
+		{At: day1, Group: "Proxy", Delays: map[string]int{"HK": 100, "JP": 0}},
+		{At: day1.Add(time.Hour), Group: "Proxy", Delays: map[string]int{"HK": 300, "JP": 90}},
+		{At: day2, Delays: map[string]int{"HK": 0}},
+		{At: day2.Add(time.Hour), Delays: map[string]int{"HK": 200}},
+		{At: day2.Add(2 * time.Hour), Delays: map[string]int{"HK": 120}},
+	}
+	for _, r := range runs {
+		if err := s.AppendDelayRun(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.DelayRuns(day1.Add(30*time.Minute), day2.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("runs since 09:30 = %d, want 4", len(got))
+	}
+
+	h := SummarizeNode(got, "HK", day1)
+	tot := h.Total
+	if tot.Tests != 4 || tot.Failures != 1 || tot.FailureRate != 0.25 {
+		t.Errorf("total = %+v", tot)
+	}
+	// 测通的 120 / 200 / 300：中位 200，p95 取最大值。
+	if tot.MedianMs != 200 || tot.P95Ms != 300 || tot.MinMs != 120 || tot.MaxMs != 300 {
+		t.Errorf("percentiles = %+v", tot)
+	}
+	if len(h.Days) != 2 || h.Days[0].Date != "2026-10-16" || h.Days[1].Tests != 3 || h.Days[1].Failures != 1 {
+		t.Errorf("days = %+v", h.Days)
+	}
+	if !h.LastAt.Equal(day2.Add(2 * time.Hour)) {
+		t.Errorf("last = %v", h.LastAt)
+	}
+	if none := SummarizeNode(got, "US", day1); none.Total.Tests != 0 || none.Days != nil {
+		t.Errorf("unknown node = %+v", none)
+	}
+}
+
+func TestPruneDropsOldDelayFiles(t *testing.T) {
+	s := Open(t.TempDir())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	old := now.Add(-Retention - 48*time.Hour)
+	for _, at := range []time.Time{old, now} {
+		if err := s.AppendDelayRun(DelayRun{At: at, Delays: map[string]int{"HK": 100}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Prune(now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.DelayRuns(old.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !got[0].At.Equal(now) {
+		t.Fatalf("old delay file not pruned: %+v", got)
+	}
+}
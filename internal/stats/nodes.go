@@ -0,0 +1,24 @@
+package stats
+
+import "time"
+
+const nodeSwitchesFile = "node-switches.jsonl"
+
+// NodeSwitch 是自动选节点做的一次切换。
+type NodeSwitch struct {
+	At     time.Time `json:"at"`
+	Group  string    `json:"group"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+// AppendNodeSwitch 往账本目录的 node-switches.jsonl 追加一次切换。
+func (s *Store) AppendNodeSwitch(e NodeSwitch) error {
+	return s.appendLine(nodeSwitchesFile, e)
+}
+
+// NodeSwitches 返回最近 limit 次切换（旧的在前）；limit <= 0 表示全部。
+func (s *Store) NodeSwitches(limit int) ([]NodeSwitch, error) {
+	return readLines[NodeSwitch](s, nodeSwitchesFile, limit)
+}
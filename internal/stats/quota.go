@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// QuotaBlocksFile 是超额设备的封禁表，放在 mihomo 工作目录：渲染 config.yaml 时
+// engine 直接读它，不管是哪条路径触发的重载（菜单、定时策略、订阅刷新）都会带上。
+const QuotaBlocksFile = "quota-blocks.json"
+
+const quotaEventsFile = "quota-events.jsonl"
+
+// QuotaBlock 是一台超额设备的临时策略，Until（周期结束）之后自动失效。
+type QuotaBlock struct {
+	IP     string    `json:"ip"`
+	Device string    `json:"device"` // 配置里写的 device（IP 或名字）
+	Target string    `json:"target"` // reject | direct
+	Period string    `json:"period"`
+	Limit  int64     `json:"limit"`
+	Used   int64     `json:"used"`
+	At     time.Time `json:"at"`
+	Until  time.Time `json:"until"`
+}
+
+// ActiveAt 报告封禁在 t 时是否还有效。
+func (b QuotaBlock) ActiveAt(t time.Time) bool {
+	return t.Before(b.Until)
+}
+
+// LoadQuotaBlocks 读 workDir/quota-blocks.json；文件不存在返回 nil, nil。
+func LoadQuotaBlocks(workDir string) ([]QuotaBlock, error) {
+	b, err := os.ReadFile(filepath.Join(workDir, QuotaBlocksFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var blocks []QuotaBlock
+	if err := json.Unmarshal(b, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// SaveQuotaBlocks 覆盖写封禁表；为空时删掉文件。
+func SaveQuotaBlocks(workDir string, blocks []QuotaBlock) error {
+	path := filepath.Join(workDir, QuotaBlocksFile)
+	if len(blocks) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(blocks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// QuotaEvent 是额度相关的一次事件：超额封禁（exceeded）或到期解除（reset）。
+type QuotaEvent struct {
+	At     time.Time `json:"at"`
+	Kind   string    `json:"kind"` // exceeded | reset
+	IP     string    `json:"ip"`
+	Device string    `json:"device"`
+	Target string    `json:"target,omitempty"`
+	Period string    `json:"period"`
+	Limit  int64     `json:"limit"`
+	Used   int64     `json:"used,omitempty"`
+}
+
+// 额度事件类型。
+const (
+	QuotaExceeded = "exceeded"
+	QuotaReset    = "reset"
+)
+
+// AppendQuotaEvent 往账本目录的 quota-events.jsonl 追加一行。
+func (s *Store) AppendQuotaEvent(e QuotaEvent) error {
+	return s.appendLine(quotaEventsFile, e)
+}
+
+// QuotaEvents 返回最近 limit 条额度事件（旧的在前）；limit <= 0 表示全部。
+// 坏行跳过。
+func (s *Store) QuotaEvents(limit int) ([]QuotaEvent, error) {
+	return readLines[QuotaEvent](s, quotaEventsFile, limit)
+}
@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// seenFile 记着见过的 LAN 设备（IP → 第一次出现的时间），「新设备」通知靠它判断。
+const seenFile = "devices-seen.json"
+
+// seenSeedDays 是名单第一次建立时，从流量账本里回溯多少天的设备当作「见过」。
+const seenSeedDays = 30
+
+// MarkSeen 把这次采样里的设备记进名单，返回以前没见过的（按 IP 排序）。
+// 名单文件还不存在时（刚升级上来）先用最近 30 天账本里出现过的设备建名单，
+// 这一轮也不返回任何设备 —— 不然家里已有的每台设备都会被当成「新设备」报一遍。
+func (s *Store) MarkSeen(ips []string, now time.Time) ([]string, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	_, statErr := os.Stat(filepath.Join(s.dir, seenFile))
+	first := errors.Is(statErr, os.ErrNotExist)
+	seen := map[string]time.Time{}
+	if err := s.readJSON(seenFile, &seen); err != nil {
+		return nil, err
+	}
+	if first {
+		for d := startOfDay(now).AddDate(0, 0, -seenSeedDays); !d.After(now); d = d.AddDate(0, 0, 1) {
+			day, err := s.loadDay(d)
+			if err != nil {
+				continue
+			}
+			for _, bucket := range day.Hours {
+				for ip := range bucket {
+					if _, ok := seen[ip]; !ok {
+						seen[ip] = d
+					}
+				}
+			}
+		}
+	}
+	var fresh []string
+	for _, ip := range ips {
+		if SkipSource(ip) {
+			continue
+		}
+		if _, ok := seen[ip]; ok {
+			continue
+		}
+		seen[ip] = now
+		if !first {
+			fresh = append(fresh, ip)
+		}
+	}
+	if len(fresh) == 0 && !first {
+		return nil, nil
+	}
+	sort.Strings(fresh)
+	return fresh, s.writeJSON(seenFile, seen)
+}
@@ -0,0 +1,392 @@
+// Package stats 记录 LAN 设备的累计流量。
+//
+// mihomo 的 /connections 只给「当前活跃连接」的累计字节，连接一关就没了；仪表盘
+// 的设备速率也只活在内存里，关掉菜单就清零。这里把两次采样之间每条连接的增量
+// 按来源 IP 累加进小时桶，按天落盘（每天一个 JSON 文件），重启 gateway 不丢。
+//
+// 上一次采样看到的连接计数（基线）也落盘：菜单和 `start --foreground` 同时在跑
+// 时两个进程共用一份基线，同一段流量不会被记两遍。读基线 → 记账 → 写基线这一
+// 整段拿着目录里 stats.lock 的文件锁（flock / LockFileEx），两个进程的采样排队
+// 进行，不会交错着各算一遍；拿到锁时基线已经比自己的采样新，就丢掉这次。连接
+// 在两次采样之间关闭的话，最后那一小段会漏记 —— 采样间隔 10 秒，量级可以忽略。
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retention 是按天文件保留多久；月度配额只看当月，三个月足够 `--since 90d`。
+const Retention = 92 * 24 * time.Hour
+
+const (
+	stateFile  = "state.json"
+	lockName   = "stats.lock"
+	dayLayout  = "2006-01-02"
+	dayFileExt = ".json"
+)
+
+// Sample 是一次采样里的一条连接。Group 是连接命中的策略组（mihomo chains 的
+// 最后一项，如 Proxy / DIRECT），只给 Counters 用，账本不记。
+type Sample struct {
+	ID       string
+	SourceIP string
+	Group    string
+	Upload   int64
+	Download int64
+}
+
+// Usage 是一段时间内的上下行字节数。
+type Usage struct {
+	Up   int64 `json:"up"`
+	Down int64 `json:"down"`
+}
+
+// Total 返回上下行之和。
+func (u Usage) Total() int64 { return u.Up + u.Down }
+
+func (u *Usage) add(o Usage) {
+	u.Up += o.Up
+	u.Down += o.Down
+}
+
+// DayUsage 是某一天的用量。
+type DayUsage struct {
+	Date string `json:"date"` // 2006-01-02，本地时区
+	Usage
+}
+
+// DeviceUsage 是一台设备在查询区间内的用量，Days 按日期升序。
+type DeviceUsage struct {
+	IP    string     `json:"ip"`
+	Name  string     `json:"name,omitempty"`
+	Total Usage      `json:"total"`
+	Days  []DayUsage `json:"days,omitempty"`
+}
+
+// dayFile 是一天的数据：小时（本地 0-23）→ IP → 用量。
+type dayFile struct {
+	Date  string                      `json:"date"`
+	Hours map[string]map[string]Usage `json:"hours"`
+}
+
+// baseline 是上一次采样看到的每条连接的计数。
+type baseline struct {
+	At    time.Time        `json:"at"`
+	Conns map[string]Usage `json:"conns"`
+}
+
+// Store 是落在 dir 下的流量账本。方法可以并发调用；先读后写的（Record、
+// MarkSeen）另外拿跨进程的文件锁。
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// Open 返回 dir 下的账本；目录在第一次写入时才创建。
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Record 把一次采样和上一次的差值记进 now 所在的小时桶，返回这次每台设备的增量。
+// 新出现的连接从 0 算起；计数变小（mihomo 重启后 ID 撞了）按新连接处理。
+func (s *Store) Record(samples []Sample, now time.Time) (map[string]Usage, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	prev, err := s.loadBaseline()
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(prev.At) {
+		// 另一个进程已经记了一次更晚的采样，这份是旧的：计数比基线小，会被当成
+		// 新连接整条再记一遍。直接丢掉，下一轮接着记。
+		return map[string]Usage{}, nil
+	}
+	next := baseline{At: now, Conns: make(map[string]Usage, len(samples))}
+	delta := map[string]Usage{}
+	for _, c := range samples {
+		cur := Usage{Up: c.Upload, Down: c.Download}
+		next.Conns[c.ID] = cur
+		if SkipSource(c.SourceIP) {
+			continue
+		}
+		d := cur
+		if p, ok := prev.Conns[c.ID]; ok && p.Up <= cur.Up && p.Down <= cur.Down {
+			d = Usage{Up: cur.Up - p.Up, Down: cur.Down - p.Down}
+		}
+		if d.Total() == 0 {
+			continue
+		}
+		u := delta[c.SourceIP]
+		u.add(d)
+		delta[c.SourceIP] = u
+	}
+
+	if len(delta) > 0 {
+		day, err := s.loadDay(now)
+		if err != nil {
+			return nil, err
+		}
+		hour := strconv.Itoa(now.Hour())
+		bucket := day.Hours[hour]
+		if bucket == nil {
+			bucket = map[string]Usage{}
+			day.Hours[hour] = bucket
+		}
+		for ip, d := range delta {
+			u := bucket[ip]
+			u.add(d)
+			bucket[ip] = u
+		}
+		if err := s.writeJSON(dayName(now), day); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.writeJSON(stateFile, next); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// Usage 返回 [since, until) 之间每台设备的用量，按总量降序。小时桶只要开始时间
+// 落在区间里就整桶算进去。
+func (s *Store) Usage(since, until time.Time) ([]DeviceUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 按本地整点取整（Truncate 按 UTC 算，+05:30 这类时区会错半小时）。
+	from := time.Date(since.Year(), since.Month(), since.Day(), since.Hour(), 0, 0, 0, since.Location())
+	byIP := map[string]*DeviceUsage{}
+	for d := startOfDay(since); d.Before(until); d = d.AddDate(0, 0, 1) {
+		day, err := s.loadDay(d)
+		if err != nil {
+			return nil, err
+		}
+		perDay := map[string]Usage{}
+		for h, bucket := range day.Hours {
+			hour, err := strconv.Atoi(h)
+			if err != nil {
+				continue
+			}
+			at := time.Date(d.Year(), d.Month(), d.Day(), hour, 0, 0, 0, d.Location())
+			if at.Before(from) || !at.Before(until) {
+				continue
+			}
+			for ip, u := range bucket {
+				p := perDay[ip]
+				p.add(u)
+				perDay[ip] = p
+			}
+		}
+		for ip, u := range perDay {
+			dev := byIP[ip]
+			if dev == nil {
+				dev = &DeviceUsage{IP: ip}
+				byIP[ip] = dev
+			}
+			dev.Total.add(u)
+			dev.Days = append(dev.Days, DayUsage{Date: d.Format(dayLayout), Usage: u})
+		}
+	}
+
+	out := make([]DeviceUsage, 0, len(byIP))
+	for _, dev := range byIP {
+		out = append(out, *dev)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total.Total() != out[j].Total.Total() {
+			return out[i].Total.Total() > out[j].Total.Total()
+		}
+		return out[i].IP < out[j].IP
+	})
+	return out, nil
+}
+
+// Prune 删掉 now - Retention 之前的按天文件（流量账本和测速历史）。
+func (s *Store) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cutoff := startOfDay(now.Add(-Retention))
+	for _, e := range entries {
+		name, ok := delayFileDate(e.Name())
+		if !ok {
+			name, ok = strings.CutSuffix(e.Name(), dayFileExt)
+		}
+		if !ok {
+			continue
+		}
+		d, err := time.ParseInLocation(dayLayout, name, now.Location())
+		if err != nil || !d.Before(cutoff) {
+			continue
+		}
+		_ = os.Remove(filepath.Join(s.dir, e.Name()))
+	}
+	return nil
+}
+
+// lock 拿进程内的 mu 加上 dir/stats.lock 的文件锁，返回解锁函数。菜单和后台服务
+// 两个进程都会采样，只靠 mu 挡不住对方在自己读写之间插进来。
+func (s *Store) lock() (func(), error) {
+	s.mu.Lock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, lockName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		s.mu.Unlock()
+		return nil, fmt.Errorf("锁定 %s: %w", lockName, err)
+	}
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *Store) loadBaseline() (baseline, error) {
+	var b baseline
+	if err := s.readJSON(stateFile, &b); err != nil {
+		return baseline{}, err
+	}
+	return b, nil
+}
+
+func (s *Store) loadDay(t time.Time) (dayFile, error) {
+	var d dayFile
+	if err := s.readJSON(dayName(t), &d); err != nil {
+		return dayFile{}, err
+	}
+	if d.Date == "" {
+		d.Date = t.Format(dayLayout)
+	}
+	if d.Hours == nil {
+		d.Hours = map[string]map[string]Usage{}
+	}
+	return d, nil
+}
+
+// readJSON 读 dir/name；文件不存在时 v 保持零值、不报错。
+func (s *Store) readJSON(name string, v any) error {
+	b, err := os.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("解析 %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeJSON 先写临时文件再 rename，进程中途被杀也不会留下半个文件。
+func (s *Store) writeJSON(name string, v any) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func dayName(t time.Time) string {
+	return t.Format(dayLayout) + dayFileExt
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// fakeIPNet 是 TUN fake-ip 段：本机 TUN 出来的连接源地址落在这里，不是 LAN 设备。
+var _, fakeIPNet, _ = net.ParseCIDR("198.18.0.0/15")
+
+// SkipSource 报告这个来源 IP 是否不算 LAN 设备（空、回环、TUN fake-ip 段）。
+func SkipSource(ip string) bool {
+	p := net.ParseIP(ip)
+	if p == nil {
+		return true
+	}
+	return p.IsLoopback() || fakeIPNet.Contains(p)
+}
+
+// ParseSince 把 `--since` 的写法换成起始时间：7d / 12h / 90m（相对 now）、
+// today / month（本地今天 0 点 / 本月 1 号 0 点）、2006-01-02（那天 0 点）。
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	switch s {
+	case "today":
+		return startOfDay(now), nil
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	}
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err == nil && days > 0 {
+			return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(dayLayout, s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("看不懂 %q（例 7d / 12h / today / month / 2006-01-02）", s)
+}
+
+// FormatBytes 用 1024 进制把字节数格式化成 B / KB / MB / GB / TB，整数不带小数
+// （「100 GB」而不是「100.0 GB」）。CLI、菜单、订阅余量都用这一个，同一个数字
+// 在哪儿看都长一样。
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	v := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		v /= unit
+		if v < unit || suffix == "TB" {
+			if v == math.Trunc(v) {
+				return fmt.Sprintf("%.0f %s", v, suffix)
+			}
+			return fmt.Sprintf("%.1f %s", v, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecordAttributesDeltasBySource(t *testing.T) {
+	s := Open(t.TempDir())
+	t0 := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+
+	d, err := s.Record([]Sample{
+		{ID: "a", SourceIP: "192.168.1.23", Upload: 100, Download: 1000},
+		{ID: "b", SourceIP: "127.0.0.1", Upload: 5, Download: 5},
+		{ID: "c", SourceIP: "198.18.0.1", Upload: 5, Download: 5},
+	}, t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d["192.168.1.23"] != (Usage{Up: 100, Down: 1000}) || len(d) != 1 {
+		t.Fatalf("first sample delta = %+v", d)
+	}
+
+	// 第二次：a 继续涨，新连接 d 从 0 算起，a 消失前的部分已计过。
+	d, err = s.Record([]Sample{
+		{ID: "a", SourceIP: "192.168.1.23", Upload: 150, Download: 3000},
+		{ID: "d", SourceIP: "192.168.1.50", Upload: 10, Download: 20},
+	}, t0.Add(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d["192.168.1.23"] != (Usage{Up: 50, Down: 2000}) || d["192.168.1.50"] != (Usage{Up: 10, Down: 20}) {
+		t.Fatalf("second sample delta = %+v", d)
+	}
+
+	// 计数变小：mihomo 重启后同 ID 的新连接，整段计入。
+	if _, err := s.Record([]Sample{{ID: "a", SourceIP: "192.168.1.23", Upload: 1, Download: 1}}, t0.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个 Store 打开同一目录（重启后），数据还在。
+	list, err := Open(s.dir).Usage(t0.Add(-time.Hour), t0.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].IP != "192.168.1.23" {
+		t.Fatalf("usage = %+v", list)
+	}
+	if list[0].Total != (Usage{Up: 151, Down: 3001}) {
+		t.Fatalf("total = %+v", list[0].Total)
+	}
+	if len(list[0].Days) != 1 || list[0].Days[0].Date != "2026-10-18" {
+		t.Fatalf("days = %+v", list[0].Days)
+	}
+
+	// 区间只覆盖 11 点之后：只剩重启后那 2 字节。
+	list, err = s.Usage(t0.Add(90*time.Minute), t0.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Total != (Usage{Up: 1, Down: 1}) {
+		t.Fatalf("windowed usage = %+v", list)
+	}
+}
+
+func TestUsageSpansDays(t *testing.T) {
+	s := Open(t.TempDir())
+	day1 := time.Date(2026, 10, 17, 23, 0, 0, 0, time.Local)
+	day2 := time.Date(2026, 10, 18, 1, 0, 0, 0, time.Local)
+	if _, err := s.Record([]Sample{{ID: "x", SourceIP: "192.168.1.9", Download: 100}}, day1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Record([]Sample{{ID: "y", SourceIP: "192.168.1.9", Download: 50}}, day2); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Usage(day1.Add(-time.Hour), day2.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Total.Down != 150 || len(list[0].Days) != 2 {
+		t.Fatalf("usage = %+v", list)
+	}
+	if list[0].Days[0].Date != "2026-10-17" || list[0].Days[1].Down != 50 {
+		t.Fatalf("days = %+v", list[0].Days)
+	}
+}
+
+func TestPruneDropsOldDays(t *testing.T) {
+	s := Open(t.TempDir())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	old := now.Add(-Retention - 48*time.Hour)
+	if _, err := s.Record([]Sample{{ID: "x", SourceIP: "192.168.1.9", Download: 100}}, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Record([]Sample{{ID: "y", SourceIP: "192.168.1.9", Download: 1}}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Prune(now); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.Usage(old.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Total.Down != 1 {
+		t.Fatalf("old day not pruned: %+v", list)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 4, 0, 0, time.Local)
+	cases := map[string]time.Time{
+		"7d":         now.Add(-7 * 24 * time.Hour),
+		"12h":        now.Add(-12 * time.Hour),
+		"today":      time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local),
+		"month":      time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local),
+		"2026-10-01": time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local),
+	}
+	for in, want := range cases {
+		got, err := ParseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "0d", "-3d", "week"} {
+		if _, err := ParseSince(bad, now); err == nil {
+			t.Errorf("ParseSince(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCountersAccumulate(t *testing.T) {
+	c := NewCounters()
+	c.Observe([]Sample{
+		{ID: "a", SourceIP: "192.168.1.23", Group: "Proxy", Upload: 10, Download: 100},
+		{ID: "b", SourceIP: "127.0.0.1", Group: "DIRECT", Upload: 1, Download: 1},
+	})
+	c.Observe([]Sample{
+		{ID: "a", SourceIP: "192.168.1.23", Group: "Proxy", Upload: 15, Download: 300},
+		{ID: "c", SourceIP: "192.168.1.23", Group: "DIRECT", Upload: 0, Download: 50},
+	})
+	devices, groups := c.Snapshot()
+	if devices["192.168.1.23"] != (Usage{Up: 15, Down: 350}) || len(devices) != 1 {
+		t.Fatalf("devices = %+v", devices)
+	}
+	if groups["Proxy"] != (Usage{Up: 15, Down: 300}) || groups["DIRECT"] != (Usage{Up: 1, Down: 51}) {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
+
+func TestMarkSeenBaselinesThenReportsNewDevices(t *testing.T) {
+	s := Open(t.TempDir())
+	now := time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)
+	fresh, err := s.MarkSeen([]string{"192.168.1.10", "192.168.1.11"}, now)
+	if err != nil || len(fresh) != 0 {
+		t.Fatalf("first run should only baseline: %v %v", fresh, err)
+	}
+	fresh, err = s.MarkSeen([]string{"192.168.1.12", "192.168.1.10", "127.0.0.1", "192.168.1.12"}, now.Add(time.Minute))
+	if err != nil || len(fresh) != 1 || fresh[0] != "192.168.1.12" {
+		t.Fatalf("want only 192.168.1.12, got %v %v", fresh, err)
+	}
+	if fresh, _ = s.MarkSeen([]string{"192.168.1.12"}, now.Add(2*time.Minute)); len(fresh) != 0 {
+		t.Fatalf("device reported twice: %v", fresh)
+	}
+}
+
+// 两个 Store 指向同一目录，模拟菜单和后台服务各自采样、各自记账：同一条连接
+// 不管谁采到，总账都不会超过它实际的计数。
+func TestRecordSharedAcrossStores(t *testing.T) {
+	dir := t.TempDir()
+	stores := []*Store{Open(dir), Open(dir)}
+	base := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+	const rounds = 50
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		counter int64
+		tick    time.Duration
+	)
+	for _, st := range stores {
+		wg.Add(1)
+		go func(st *Store) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				mu.Lock()
+				counter += 100
+				tick += time.Millisecond
+				cur, at := counter, base.Add(tick)
+				mu.Unlock()
+				// 采样之后、记账之前另一个进程随时可能插进来。
+				if _, err := st.Record([]Sample{{ID: "c1", SourceIP: "192.168.1.23", Download: cur}}, at); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(st)
+	}
+	wg.Wait()
+	list, err := stores[0].Usage(base.Add(-time.Hour), base.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Total.Down == 0 || list[0].Total.Down > counter {
+		t.Fatalf("usage = %+v, connection counter = %d", list, counter)
+	}
+}
@@ -0,0 +1,46 @@
+package traffic
+
+import (
+	"net"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// deviceRules 把设备策略翻成 SRC-IP-CIDR 规则行（不带 "  - " 前缀）。
+// 单个 IP 补成 /32（IPv6 /128），CIDR 原样；没 IP 的条目（MAC 没解析出来）跳过。
+// target 走和 rule-provider 一样的映射：direct / proxy / reject 或策略组名。
+//
+// 注意 mihomo 只在 mode=rule 下看规则；global / direct 模式里设备策略不生效。
+func deviceRules(list []config.DevicePolicy) []string {
+	out := make([]string, 0, len(list))
+	for _, p := range list {
+		cidr := deviceCIDR(p.IP)
+		if cidr == "" {
+			continue
+		}
+		out = append(out, "SRC-IP-CIDR,"+cidr+","+ProviderTarget(p.Target))
+	}
+	return out
+}
+
+func deviceCIDR(ip string) string {
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return ""
+	}
+	if strings.Contains(ip, "/") {
+		if _, n, err := net.ParseCIDR(ip); err == nil {
+			return n.String()
+		}
+		return ""
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if parsed.To4() != nil {
+		return parsed.String() + "/32"
+	}
+	return parsed.String() + "/128"
+}
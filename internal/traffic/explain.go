@@ -0,0 +1,168 @@
+package traffic
+
+import (
+	"net"
+	"strings"
+)
+
+// --- 规则命中解释 ---
+//
+// 「这个网站为什么慢 / 打不开」最常见的答案是被哪条规则截了：广告表、自定义、
+// china_direct 还是最后的 MATCH。Explain 按 mihomo 的顺序把 Rules 走一遍，找第一条
+// 命中的规则。只模拟能离线判断的类型；PROCESS-NAME / GEOSITE / RULE-SET 这类
+// 需要运行时信息的规则跳过，并在结果里列出来，方便用户知道判断可能不完整。
+
+// Query 是一次命中查询。Host 填域名或 IP；SrcIP 是发起连接的 LAN 设备（可空）。
+type Query struct {
+	Host  string
+	SrcIP string
+	// Resolve 把域名解析成 IP，遇到不带 no-resolve 的 IP 类规则时才调用一次
+	// （和 mihomo 一样懒解析）。nil = 不解析，域名永远不命中 IP 规则。
+	Resolve func(host string) []net.IP
+	// Country 返回 IP 的 ISO 国家码（GEOIP 规则用）；nil = GEOIP 只认 LAN。
+	Country func(ip net.IP) string
+}
+
+// Explanation 是 Explain 的结果。
+type Explanation struct {
+	Rule    Rule     `json:"rule"`
+	Index   int      `json:"index"` // 在规则列表里的下标（从 0 开始）
+	Verdict string   `json:"verdict"`
+	IPs     []string `json:"ips,omitempty"`     // 判断过程中解析出的 IP
+	Skipped []Rule   `json:"skipped,omitempty"` // 命中前跳过的、无法离线判断的规则
+}
+
+// Explain 返回 q 命中的第一条规则；一条都没命中（direct 模式没有 MATCH）时 ok=false，
+// 此时 mihomo 按 mode 走默认出口。
+func Explain(rules []Rule, q Query) (ex Explanation, ok bool) {
+	host := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(q.Host), "."))
+	var (
+		dstIPs   []net.IP
+		resolved bool
+	)
+	if ip := net.ParseIP(host); ip != nil {
+		dstIPs, resolved = []net.IP{ip}, true
+		host = ""
+	}
+	srcIP := net.ParseIP(strings.TrimSpace(q.SrcIP))
+	ips := func(noResolve bool) []net.IP {
+		if !resolved && !noResolve && host != "" && q.Resolve != nil {
+			dstIPs, resolved = q.Resolve(host), true
+			for _, ip := range dstIPs {
+				ex.IPs = append(ex.IPs, ip.String())
+			}
+		}
+		return dstIPs
+	}
+
+	for i, r := range rules {
+		kind, matcher, verdict, noResolve := splitRule(r.Line)
+		var hit bool
+		switch kind {
+		case "MATCH":
+			hit = true
+		case "DOMAIN":
+			hit = host != "" && host == strings.ToLower(matcher)
+		case "DOMAIN-SUFFIX":
+			m := strings.ToLower(matcher)
+			hit = host != "" && (host == m || strings.HasSuffix(host, "."+m))
+		case "DOMAIN-KEYWORD":
+			hit = host != "" && strings.Contains(host, strings.ToLower(matcher))
+		case "IP-CIDR", "IP-CIDR6":
+			hit = anyInCIDR(ips(noResolve), matcher)
+		case "SRC-IP-CIDR":
+			hit = srcIP != nil && anyInCIDR([]net.IP{srcIP}, matcher)
+		case "GEOIP":
+			hit = anyInCountry(ips(noResolve), matcher, q.Country)
+		default:
+			ex.Skipped = append(ex.Skipped, r)
+			continue
+		}
+		if hit {
+			ex.Rule, ex.Index, ex.Verdict = r, i, verdict
+			return ex, true
+		}
+	}
+	return ex, false
+}
+
+// splitRule 拆出 TYPE / 匹配值 / 目标 / 是否 no-resolve。MATCH 只有两段：MATCH,目标。
+func splitRule(line string) (kind, matcher, verdict string, noResolve bool) {
+	parts := strings.Split(line, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	kind = strings.ToUpper(parts[0])
+	if kind == "MATCH" {
+		if len(parts) > 1 {
+			verdict = parts[1]
+		}
+		return kind, "", verdict, false
+	}
+	if len(parts) > 1 {
+		matcher = parts[1]
+	}
+	if len(parts) > 2 {
+		verdict = parts[2]
+	}
+	for _, p := range parts[3:] {
+		if p == "no-resolve" {
+			noResolve = true
+		}
+	}
+	return kind, matcher, verdict, noResolve
+}
+
+func anyInCIDR(ips []net.IP, cidr string) bool {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyInCountry 判断 GEOIP。LAN 是 mihomo 的特殊码：私有 / 回环 / 链路本地地址。
+func anyInCountry(ips []net.IP, code string, country func(net.IP) string) bool {
+	for _, ip := range ips {
+		if strings.EqualFold(code, "LAN") {
+			if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				return true
+			}
+			continue
+		}
+		if country != nil && strings.EqualFold(country(ip), code) {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceLabel 把 Rule.Source 翻成给人看的说明。
+func SourceLabel(source string) string {
+	switch source {
+	case "adblock":
+		return "广告拦截"
+	case "device":
+		return "设备策略"
+	case "extras.reject":
+		return "自定义规则 · 拒绝"
+	case "extras.direct":
+		return "自定义规则 · 直连"
+	case "extras.proxy":
+		return "自定义规则 · 代理"
+	case "match":
+		return "兜底 MATCH"
+	}
+	if g, ok := strings.CutPrefix(source, "extras.group:"); ok {
+		return "自定义规则 · 策略组 " + g
+	}
+	if p, ok := strings.CutPrefix(source, "rule-provider:"); ok {
+		return "外部规则集 " + p
+	}
+	return "内置规则集 " + source
+}
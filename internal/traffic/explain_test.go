@@ -0,0 +1,76 @@
+package traffic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestExplainWalksRenderedRules(t *testing.T) {
+	cfg := config.Default().Traffic
+	cfg.Extras.Proxy = []string{"DOMAIN-SUFFIX,baidu.com"}
+	rules := Rules(cfg, []config.DevicePolicy{{IP: "192.168.1.50", Target: "reject"}})
+
+	cases := []struct {
+		q       Query
+		source  string
+		verdict string
+	}{
+		{Query{Host: "stats.doubleclick.net"}, "adblock", "REJECT"},
+		{Query{Host: "www.google.com", SrcIP: "192.168.1.50"}, "device", "REJECT"},
+		{Query{Host: "map.baidu.com"}, "extras.proxy", "Proxy"}, // 自定义压过 china_direct
+		{Query{Host: "weibo.com"}, "china_direct", "DIRECT"},
+		{Query{Host: "192.168.1.1"}, "lan_direct", "DIRECT"},
+		{Query{Host: "example.org"}, "match", "Proxy"},
+	}
+	for _, tc := range cases {
+		ex, ok := Explain(rules, tc.q)
+		if !ok || ex.Rule.Source != tc.source || ex.Verdict != tc.verdict {
+			t.Errorf("%+v: got %s %q → %s (ok=%v), want %s → %s", tc.q, ex.Rule.Source, ex.Rule.Line, ex.Verdict, ok, tc.source, tc.verdict)
+		}
+	}
+}
+
+func TestExplainResolvesLazilyForIPRules(t *testing.T) {
+	rules := []Rule{
+		{Line: "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", Source: "lan_direct"},
+		{Line: "PROCESS-NAME,curl,REJECT", Source: "extras.reject"},
+		{Line: "GEOIP,CN,DIRECT", Source: "china_direct"},
+		{Line: "MATCH,Proxy", Source: "match"},
+	}
+	calls := 0
+	q := Query{
+		Host: "cn.example",
+		Resolve: func(string) []net.IP {
+			calls++
+			return []net.IP{net.ParseIP("1.2.4.8")}
+		},
+		Country: func(ip net.IP) string {
+			if ip.String() == "1.2.4.8" {
+				return "CN"
+			}
+			return ""
+		},
+	}
+	ex, ok := Explain(rules, q)
+	if !ok || ex.Rule.Source != "china_direct" || ex.Verdict != "DIRECT" {
+		t.Fatalf("got %+v", ex)
+	}
+	if calls != 1 || len(ex.IPs) != 1 {
+		t.Fatalf("resolve should run once for the GEOIP rule, calls=%d ips=%v", calls, ex.IPs)
+	}
+	if len(ex.Skipped) != 1 || ex.Skipped[0].Line != "PROCESS-NAME,curl,REJECT" {
+		t.Fatalf("skipped = %+v", ex.Skipped)
+	}
+
+	// 没有 Resolve：域名不会命中 IP 规则，落到 MATCH
+	q.Resolve = nil
+	if ex, _ := Explain(rules, q); ex.Rule.Source != "match" {
+		t.Fatalf("without resolver got %+v", ex)
+	}
+	// direct 模式没有 MATCH：一条都不中
+	if _, ok := Explain(rules[:1], Query{Host: "example.org"}); ok {
+		t.Fatal("expected no match")
+	}
+}
@@ -0,0 +1,371 @@
+package traffic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// --- 从 iOS 客户端导入规则 ---
+//
+// Surge / Shadowrocket 的 [Rule] 段和 mihomo 语法几乎一样（TYPE,值,策略[,选项]），
+// 主要差在几个类型名（DEST-PORT / SRC-IP）和策略名；Quantumult X 的 [filter_local]
+// 用小写类型（host-suffix, google.com, proxy）。这里把能一一对应的转过来、按策略分进
+// ExtraRules 的 direct / proxy / reject / groups，转不了的（USER-AGENT、URL-REGEX、
+// 外部 RULE-SET、FINAL…）原样记进报告，不猜。
+//
+// 分桶会丢掉跨策略的先后：渲染时总是 reject → direct → proxy → groups。原配置里
+// 后面的规则要是被提到了一条和它范围重叠的规则前面（先 DIRECT ads.x.com、后
+// REJECT x.com，导入后 ads.x.com 会被拒），命中结果就变了 —— 这种后来者不导入，
+// 记进报告让用户自己处理。
+
+// 导入格式。
+const (
+	ImportSurge        = "surge"
+	ImportQuantumultX  = "qx"
+	ImportShadowrocket = "shadowrocket"
+)
+
+// ImportedRule 是转换好的一条规则。Bucket 是 direct / proxy / reject / group，
+// group 时 Target 是策略组名。
+type ImportedRule struct {
+	Bucket string `json:"bucket"`
+	Target string `json:"target,omitempty"`
+	Rule   string `json:"rule"`
+	Line   int    `json:"line"` // 来源文件里的行号
+}
+
+// ImportSkip 是没能转换的一行。
+type ImportSkip struct {
+	Line   int    `json:"line"`
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult 是一次导入的解析结果。
+type ImportResult struct {
+	Rules   []ImportedRule `json:"rules"`
+	Skipped []ImportSkip   `json:"skipped,omitempty"`
+}
+
+// ParseImport 解析 Surge / Shadowrocket 的 [Rule] 段或 Quantumult X 的 [filter_local] 段。
+// 文件里没有任何 [段] 标题时，整个文件都当规则列表（很多人只导出了规则那一段）。
+func ParseImport(data []byte, format string) (ImportResult, error) {
+	var section string
+	var convert func(string) (ImportedRule, string)
+	switch format {
+	case ImportSurge, ImportShadowrocket:
+		section, convert = "rule", convertSurgeLine
+	case ImportQuantumultX:
+		section, convert = "filter_local", convertQXLine
+	default:
+		return ImportResult{}, fmt.Errorf("不支持的格式 %q（surge / qx / shadowrocket）", format)
+	}
+
+	hasSections := bytes.Contains(data, []byte("\n[")) || bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))
+	in := !hasSections
+	var res ImportResult
+	texts := map[int]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			in = strings.EqualFold(strings.Trim(line, "[]"), section)
+			continue
+		}
+		if !in || line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		r, reason := convert(line)
+		if reason == "" {
+			if err := ValidateRule(r.Rule); err != nil {
+				reason = err.Error()
+			}
+		}
+		if reason != "" {
+			res.Skipped = append(res.Skipped, ImportSkip{Line: n, Text: line, Reason: reason})
+			continue
+		}
+		r.Line = n
+		texts[n] = line
+		res.Rules = append(res.Rules, r)
+	}
+	if err := sc.Err(); err != nil {
+		return res, err
+	}
+	var reordered []ImportSkip
+	res.Rules, reordered = dropReordered(res.Rules, texts)
+	if len(reordered) > 0 {
+		res.Skipped = append(res.Skipped, reordered...)
+		slices.SortFunc(res.Skipped, func(a, b ImportSkip) int { return a.Line - b.Line })
+	}
+	return res, nil
+}
+
+// bucketLabel 是报告里分桶的叫法。
+func bucketLabel(r ImportedRule) string {
+	if r.Bucket == "group" {
+		return "group:" + r.Target
+	}
+	return r.Bucket
+}
+
+// dropReordered 找出分桶后会被提到前面、和原本排在它前面的规则范围重叠的规则
+// （重复、包含或被包含），把它们挪进报告。分桶的渲染顺序：reject → direct →
+// proxy → 各策略组按第一次出现的先后。同一个桶里的先后不变，不用查。
+func dropReordered(rules []ImportedRule, texts map[int]string) (kept []ImportedRule, skipped []ImportSkip) {
+	rank := map[string]int{"reject": 0, "direct": 1, "proxy": 2}
+	for _, r := range rules {
+		if _, ok := rank[bucketLabel(r)]; !ok {
+			rank[bucketLabel(r)] = len(rank)
+		}
+	}
+	parsed := map[int]parsedRule{}
+	for _, r := range rules {
+		parsed[r.Line], _ = parseRule(r.Rule)
+	}
+	for _, r := range rules {
+		p := parsed[r.Line]
+		clash := -1
+		for i, prev := range kept {
+			if rank[bucketLabel(r)] >= rank[bucketLabel(prev)] {
+				continue
+			}
+			pp := parsed[prev.Line]
+			if sameRule(pp, p) || covers(pp, p) || covers(p, pp) {
+				clash = i
+				break
+			}
+		}
+		if clash < 0 {
+			kept = append(kept, r)
+			continue
+		}
+		prev := kept[clash]
+		skipped = append(skipped, ImportSkip{Line: r.Line, Text: texts[r.Line], Reason: fmt.Sprintf(
+			"原来排在第 %d 行（%s）后面，导入后 %s 先于 %s 匹配，两条范围重叠、结果会变；请手动调整",
+			prev.Line, texts[prev.Line], bucketLabel(r), bucketLabel(prev))})
+	}
+	return kept, skipped
+}
+
+// surgeKinds 是 Surge / Shadowrocket 类型名 → mihomo 类型名；不在表里的类型不支持。
+var surgeKinds = map[string]string{
+	"DOMAIN":         "DOMAIN",
+	"DOMAIN-SUFFIX":  "DOMAIN-SUFFIX",
+	"DOMAIN-KEYWORD": "DOMAIN-KEYWORD",
+	"IP-CIDR":        "IP-CIDR",
+	"IP-CIDR6":       "IP-CIDR6",
+	"IP-ASN":         "IP-ASN",
+	"GEOIP":          "GEOIP",
+	"PROCESS-NAME":   "PROCESS-NAME",
+	"DEST-PORT":      "DST-PORT",
+	"DST-PORT":       "DST-PORT",
+	"SRC-PORT":       "SRC-PORT",
+	"IN-PORT":        "IN-PORT",
+	"SRC-IP":         "SRC-IP-CIDR",
+	"SRC-IP-CIDR":    "SRC-IP-CIDR",
+	"AND":            "AND",
+	"OR":             "OR",
+	"NOT":            "NOT",
+}
+
+// convertSurgeLine 转一行 Surge / Shadowrocket 规则；失败时 reason 非空。
+func convertSurgeLine(line string) (ImportedRule, string) {
+	line = stripTrailingComment(line)
+	kind, rest, _ := strings.Cut(line, ",")
+	kind = strings.ToUpper(strings.TrimSpace(kind))
+	switch kind {
+	case "FINAL", "MATCH":
+		return ImportedRule{}, "兜底规则不导入，由 traffic.mode 决定"
+	case "RULE-SET", "DOMAIN-SET":
+		return ImportedRule{}, "外部规则集请改用 traffic.rule_providers"
+	}
+	mk, ok := surgeKinds[kind]
+	if !ok {
+		return ImportedRule{}, fmt.Sprintf("mihomo 没有对应的 %s 规则", kind)
+	}
+
+	var payload string
+	var opts []string
+	if mk == "AND" || mk == "OR" || mk == "NOT" {
+		// 逻辑规则的参数自带逗号：策略在最后一个 ')' 之后。
+		end := strings.LastIndex(rest, ")")
+		if end < 0 {
+			return ImportedRule{}, "逻辑规则括号不完整"
+		}
+		payload = strings.TrimSpace(rest[:end+1])
+		opts = splitFields(strings.TrimPrefix(strings.TrimSpace(rest[end+1:]), ","))
+	} else {
+		fields := splitFields(rest)
+		if len(fields) > 0 {
+			payload, opts = fields[0], fields[1:]
+		}
+	}
+	if len(opts) == 0 || opts[0] == "" {
+		return ImportedRule{}, "缺少策略"
+	}
+	if mk == "SRC-IP-CIDR" && !strings.Contains(payload, "/") {
+		payload = hostCIDR(payload)
+	}
+	return buildImported(mk, payload, opts[0], opts[1:]), ""
+}
+
+// qxKinds 是 Quantumult X 的类型名 → mihomo 类型名。
+var qxKinds = map[string]string{
+	"host":         "DOMAIN",
+	"host-suffix":  "DOMAIN-SUFFIX",
+	"host-keyword": "DOMAIN-KEYWORD",
+	"ip-cidr":      "IP-CIDR",
+	"ip6-cidr":     "IP-CIDR6",
+	"ip-asn":       "IP-ASN",
+	"geoip":        "GEOIP",
+}
+
+// convertQXLine 转一行 Quantumult X filter_local 规则。
+func convertQXLine(line string) (ImportedRule, string) {
+	fields := splitFields(stripTrailingComment(line))
+	kind := strings.ToLower(fields[0])
+	if kind == "final" {
+		return ImportedRule{}, "兜底规则不导入，由 traffic.mode 决定"
+	}
+	mk, ok := qxKinds[kind]
+	if !ok {
+		return ImportedRule{}, fmt.Sprintf("mihomo 没有对应的 %s 规则", kind)
+	}
+	if len(fields) < 3 || fields[2] == "" {
+		return ImportedRule{}, "缺少策略"
+	}
+	return buildImported(mk, fields[1], fields[2], fields[3:]), ""
+}
+
+// buildImported 拼 mihomo 规则体并按策略分桶。选项里只保留 no-resolve，
+// extended-matching / dns-failed 这类客户端私有选项丢掉。
+func buildImported(kind, payload, policy string, opts []string) ImportedRule {
+	rule := kind + "," + payload
+	for _, o := range opts {
+		if strings.EqualFold(o, "no-resolve") {
+			rule += ",no-resolve"
+			break
+		}
+	}
+	r := ImportedRule{Rule: rule}
+	switch p := strings.ToLower(policy); {
+	case p == "direct":
+		r.Bucket = "direct"
+	case strings.HasPrefix(p, "reject"): // reject / reject-tinygif / reject-200 / reject-img …
+		r.Bucket = "reject"
+	case p == "proxy":
+		r.Bucket = "proxy"
+	default:
+		r.Bucket, r.Target = "group", policy
+	}
+	return r
+}
+
+func splitFields(s string) []string {
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+// stripTrailingComment 去掉行尾 " // 注释"（Surge 配置里常见）。
+func stripTrailingComment(line string) string {
+	if i := strings.Index(line, " //"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+func hostCIDR(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if parsed.To4() != nil {
+		return parsed.String() + "/32"
+	}
+	return parsed.String() + "/128"
+}
+
+// DropUnknownTargets 把指向 targets 里没有的策略组的规则挪进报告：Surge 的策略名
+// （「🇺🇸 美国」之类）在 mihomo 里未必有同名组，写进去 mihomo 会拒绝启动。
+// targets 为 nil（不知道有哪些组）时原样返回。
+func DropUnknownTargets(res ImportResult, targets []string) ImportResult {
+	if targets == nil {
+		return res
+	}
+	out := ImportResult{Skipped: append([]ImportSkip(nil), res.Skipped...)}
+	for _, r := range res.Rules {
+		if r.Bucket != "group" || TargetKnown(targets, r.Target) {
+			out.Rules = append(out.Rules, r)
+			continue
+		}
+		out.Skipped = append(out.Skipped, ImportSkip{
+			Line: r.Line, Text: r.Rule + "," + r.Target,
+			Reason: fmt.Sprintf("策略组 %q 在当前配置里不存在，写进去 mihomo 会拒绝启动；先建好同名组再导入", r.Target),
+		})
+	}
+	slices.SortFunc(out.Skipped, func(a, b ImportSkip) int { return a.Line - b.Line })
+	return out
+}
+
+// MergeImport 把导入的规则追加进 ex，已经存在的（同桶同规则）跳过。返回合并后的
+// ExtraRules 和真正新增的条目（dry-run 展示的就是它）。ex 本身不被修改。
+func MergeImport(ex config.ExtraRules, rules []ImportedRule) (config.ExtraRules, []ImportedRule) {
+	out := config.ExtraRules{
+		Direct: append([]string(nil), ex.Direct...),
+		Proxy:  append([]string(nil), ex.Proxy...),
+		Reject: append([]string(nil), ex.Reject...),
+	}
+	for _, g := range ex.Groups {
+		out.Groups = append(out.Groups, config.TargetedRules{Target: g.Target, Rules: append([]string(nil), g.Rules...)})
+	}
+	contains := func(list []string, r string) bool {
+		for _, x := range list {
+			if strings.EqualFold(x, r) {
+				return true
+			}
+		}
+		return false
+	}
+	var added []ImportedRule
+	for _, r := range rules {
+		var list *[]string
+		switch r.Bucket {
+		case "direct":
+			list = &out.Direct
+		case "proxy":
+			list = &out.Proxy
+		case "reject":
+			list = &out.Reject
+		default:
+			idx := -1
+			for i := range out.Groups {
+				if out.Groups[i].Target == r.Target {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				out.Groups = append(out.Groups, config.TargetedRules{Target: r.Target})
+				idx = len(out.Groups) - 1
+			}
+			list = &out.Groups[idx].Rules
+		}
+		if contains(*list, r.Rule) {
+			continue
+		}
+		*list = append(*list, r.Rule)
+		added = append(added, r)
+	}
+	return out, added
+}
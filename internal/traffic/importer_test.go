@@ -0,0 +1,169 @@
+package traffic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+const surgeConf = `[General]
+loglevel = notify
+
+[Rule]
+# 广告
+DOMAIN-SUFFIX,doubleclick.net,REJECT-TINYGIF
+DOMAIN-KEYWORD,openai,🇺🇸 美国
+DOMAIN,chat.example.com,Proxy,extended-matching
+IP-CIDR,10.0.0.0/8,DIRECT,no-resolve
+DEST-PORT,6881-6889,DIRECT
+SRC-IP,192.168.1.50,REJECT
+AND,((DOMAIN-SUFFIX,qq.com),(NETWORK,UDP)),DIRECT
+USER-AGENT,Instagram*,Proxy
+RULE-SET,https://example.com/list.txt,Proxy
+DOMAIN-SUFIX,typo.com,Proxy
+FINAL,Proxy,dns-failed
+
+[URL Rewrite]
+^http://example.com - reject
+`
+
+func TestParseImportSurge(t *testing.T) {
+	res, err := ParseImport([]byte(surgeConf), ImportSurge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ImportedRule{
+		{Bucket: "reject", Rule: "DOMAIN-SUFFIX,doubleclick.net", Line: 6},
+		{Bucket: "group", Target: "🇺🇸 美国", Rule: "DOMAIN-KEYWORD,openai", Line: 7},
+		{Bucket: "proxy", Rule: "DOMAIN,chat.example.com", Line: 8},
+		{Bucket: "direct", Rule: "IP-CIDR,10.0.0.0/8,no-resolve", Line: 9},
+		{Bucket: "direct", Rule: "DST-PORT,6881-6889", Line: 10},
+		{Bucket: "reject", Rule: "SRC-IP-CIDR,192.168.1.50/32", Line: 11},
+		{Bucket: "direct", Rule: "AND,((DOMAIN-SUFFIX,qq.com),(NETWORK,UDP))", Line: 12},
+	}
+	if len(res.Rules) != len(want) {
+		t.Fatalf("rules = %+v", res.Rules)
+	}
+	for i, w := range want {
+		if res.Rules[i] != w {
+			t.Errorf("rule %d = %+v, want %+v", i, res.Rules[i], w)
+		}
+	}
+	// USER-AGENT / RULE-SET / 拼错 / FINAL 进报告，[URL Rewrite] 段不看
+	if len(res.Skipped) != 4 {
+		t.Fatalf("skipped = %+v", res.Skipped)
+	}
+	if res.Skipped[0].Line != 13 || !strings.Contains(res.Skipped[0].Reason, "USER-AGENT") {
+		t.Fatalf("first skipped = %+v", res.Skipped[0])
+	}
+}
+
+// 分桶后 reject 总在 direct 前面：原来被前面的 DIRECT 例外保护的域名不能被后面的 REJECT 吞掉。
+func TestParseImportDropsReorderedRules(t *testing.T) {
+	conf := `DOMAIN,ads.x.com,DIRECT
+DOMAIN-SUFFIX,x.com,REJECT
+DOMAIN-SUFFIX,y.com,Proxy
+DOMAIN,a.y.com,DIRECT
+DOMAIN-SUFFIX,z.com,REJECT
+DOMAIN,b.z.com,DIRECT
+DOMAIN-SUFFIX,other.com,REJECT
+`
+	res, err := ParseImport([]byte(conf), ImportSurge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(res.Rules))
+	for _, r := range res.Rules {
+		got = append(got, r.Bucket+" "+r.Rule)
+	}
+	// x.com 会被提到 ads.x.com 前面、a.y.com 会被提到 y.com 前面：都不导入。
+	// z.com 本来就在 b.z.com 前面，顺序没变；other.com 和谁都不重叠。
+	want := "direct DOMAIN,ads.x.com|proxy DOMAIN-SUFFIX,y.com|reject DOMAIN-SUFFIX,z.com|direct DOMAIN,b.z.com|reject DOMAIN-SUFFIX,other.com"
+	if strings.Join(got, "|") != want {
+		t.Fatalf("rules = %q", got)
+	}
+	if len(res.Skipped) != 2 || res.Skipped[0].Line != 2 || res.Skipped[1].Line != 4 {
+		t.Fatalf("skipped = %+v", res.Skipped)
+	}
+	if !strings.Contains(res.Skipped[0].Reason, "第 1 行") {
+		t.Fatalf("reason should point at the shadowed line: %q", res.Skipped[0].Reason)
+	}
+}
+
+func TestParseImportQuantumultX(t *testing.T) {
+	conf := `[filter_remote]
+https://example.com/remote.list, tag=remote, enabled=true
+
+[filter_local]
+host-suffix, google.com, proxy
+host, ads.example.com, reject-200
+ip-cidr, 192.168.0.0/16, direct, no-resolve
+geoip, cn, direct
+host-wildcard, *.apple.com, direct
+final, proxy
+`
+	res, err := ParseImport([]byte(conf), ImportQuantumultX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(res.Rules))
+	for _, r := range res.Rules {
+		got = append(got, r.Bucket+" "+r.Rule)
+	}
+	want := "proxy DOMAIN-SUFFIX,google.com|reject DOMAIN,ads.example.com|direct IP-CIDR,192.168.0.0/16,no-resolve|direct GEOIP,cn"
+	if strings.Join(got, "|") != want {
+		t.Fatalf("rules = %q", got)
+	}
+	if len(res.Skipped) != 2 {
+		t.Fatalf("skipped = %+v", res.Skipped)
+	}
+	if _, err := ParseImport([]byte(conf), "clash"); err == nil {
+		t.Fatal("unknown format should error")
+	}
+}
+
+func TestMergeImportSkipsExisting(t *testing.T) {
+	ex := config.ExtraRules{
+		Direct: []string{"DOMAIN-SUFFIX,example.cn"},
+		Groups: []config.TargetedRules{{Target: "🇺🇸 美国", Rules: []string{"DOMAIN-KEYWORD,openai"}}},
+	}
+	merged, added := MergeImport(ex, []ImportedRule{
+		{Bucket: "direct", Rule: "domain-suffix,example.cn"},
+		{Bucket: "group", Target: "🇺🇸 美国", Rule: "DOMAIN-KEYWORD,openai"},
+		{Bucket: "group", Target: "🇺🇸 美国", Rule: "DOMAIN-SUFFIX,claude.ai"},
+		{Bucket: "group", Target: "Netflix", Rule: "DOMAIN-SUFFIX,netflix.com"},
+		{Bucket: "reject", Rule: "DOMAIN-SUFFIX,ads.com"},
+	})
+	if len(added) != 3 {
+		t.Fatalf("added = %+v", added)
+	}
+	if len(merged.Groups) != 2 || len(merged.Groups[0].Rules) != 2 || merged.Groups[1].Target != "Netflix" {
+		t.Fatalf("groups = %+v", merged.Groups)
+	}
+	if len(ex.Groups[0].Rules) != 1 {
+		t.Fatal("MergeImport must not modify its input")
+	}
+}
+
+func TestDropUnknownTargets(t *testing.T) {
+	res := ImportResult{Rules: []ImportedRule{
+		{Bucket: "group", Target: "🇺🇸 美国", Rule: "DOMAIN-KEYWORD,openai", Line: 3},
+		{Bucket: "group", Target: "Netflix", Rule: "DOMAIN-SUFFIX,netflix.com", Line: 5},
+		{Bucket: "proxy", Rule: "DOMAIN,chat.example.com", Line: 4},
+	}, Skipped: []ImportSkip{{Line: 9, Text: "USER-AGENT,x,Proxy"}}}
+
+	if got := DropUnknownTargets(res, nil); len(got.Rules) != 3 {
+		t.Fatalf("targets=nil should keep everything, got %+v", got.Rules)
+	}
+	got := DropUnknownTargets(res, append([]string{"Netflix"}, BuiltinTargets...))
+	if len(got.Rules) != 2 || got.Rules[0].Target != "Netflix" {
+		t.Fatalf("rules = %+v", got.Rules)
+	}
+	if len(got.Skipped) != 2 || got.Skipped[0].Line != 3 || !strings.Contains(got.Skipped[0].Reason, "🇺🇸 美国") {
+		t.Fatalf("skipped = %+v", got.Skipped)
+	}
+	if len(res.Skipped) != 1 {
+		t.Fatal("DropUnknownTargets must not modify its input")
+	}
+}
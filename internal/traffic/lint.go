@@ -0,0 +1,282 @@
+package traffic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// --- 自定义规则校验 ---
+//
+// 自定义规则（traffic.extras）是用户手打的字符串，以前原样写进 config.yaml，
+// 拼错一个字（DOMAIN-SUFIX）要等 mihomo 拒绝启动才发现。这里分两层：
+//   - ValidateRule：单条语法检查，加规则时直接拒绝；
+//   - Lint：整体检查，报重复、被前面规则挡住永远不会命中的、指向不存在策略组的。
+
+// Issue 是 Lint 发现的一个问题。
+type Issue struct {
+	Severity string `json:"severity"` // error | warn
+	Where    string `json:"where"`    // extras.direct[2] / extras.groups[🇺🇸 美国][0]
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+const (
+	SeverityError = "error"
+	SeverityWarn  = "warn"
+)
+
+// BuiltinTargets 是不依赖代理源、任何时候都存在的策略名。
+var BuiltinTargets = []string{"DIRECT", "REJECT", "REJECT-DROP", "PASS", ProxyTag}
+
+// ruleModifiers 是规则末尾可以跟的修饰。
+var ruleModifiers = map[string]bool{"no-resolve": true, "src": true}
+
+// parsedRule 是拆开的一条规则。verdict 只在用户自己写了 DIRECT / REJECT / Proxy 时非空。
+type parsedRule struct {
+	kind      string
+	payload   string
+	verdict   string
+	noResolve bool
+}
+
+// ValidateRule 检查一条自定义规则（不带去向，如 DOMAIN-SUFFIX,openai.com）能被 mihomo 接受。
+// 已知类型逐个校验参数；不认识的类型直接拒绝 —— 绝大多数是拼写错误。
+func ValidateRule(rule string) error {
+	_, err := parseRule(rule)
+	return err
+}
+
+func parseRule(rule string) (parsedRule, error) {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return parsedRule{}, errors.New("规则为空")
+	}
+	kind, rest, _ := strings.Cut(rule, ",")
+	kind = strings.ToUpper(strings.TrimSpace(kind))
+	// AND / OR / NOT 的参数里本身带逗号，只查括号配对。
+	switch kind {
+	case "AND", "OR", "NOT":
+		return parsedRule{kind: kind, payload: rest}, checkParens(rest)
+	case "MATCH":
+		return parsedRule{}, errors.New("自定义规则里不能用 MATCH（会吞掉后面所有规则）；想全部走代理请切 global 模式")
+	}
+
+	parts := strings.Split(rest, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	p := parsedRule{kind: kind, payload: parts[0]}
+	if p.payload == "" {
+		return p, fmt.Errorf("%s 缺少匹配内容（格式 %s,<值>）", kind, kind)
+	}
+	for i, extra := range parts[1:] {
+		switch {
+		case ruleModifiers[extra]:
+			p.noResolve = p.noResolve || extra == "no-resolve"
+		case i == 0 && isVerdict(extra):
+			p.verdict = extra
+		default:
+			return p, fmt.Errorf("%q 看不懂：自定义规则不用写去向，末尾只能跟 no-resolve / src", extra)
+		}
+	}
+	if err := checkPayload(kind, p.payload); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func isVerdict(s string) bool {
+	switch s {
+	case "DIRECT", "REJECT", "Proxy", "PROXY":
+		return true
+	}
+	return false
+}
+
+func checkPayload(kind, v string) error {
+	switch kind {
+	case "DOMAIN", "DOMAIN-SUFFIX", "DOMAIN-KEYWORD":
+		if strings.ContainsAny(v, " /:") {
+			return fmt.Errorf("%s 的值应该是纯域名，不带协议、路径和端口: %q", kind, v)
+		}
+	case "DOMAIN-REGEX", "PROCESS-NAME-REGEX", "PROCESS-PATH-REGEX":
+		if _, err := regexp.Compile(v); err != nil {
+			return fmt.Errorf("%s 正则写错了: %v", kind, err)
+		}
+	case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR", "IP-SUFFIX", "SRC-IP-SUFFIX":
+		if _, _, err := net.ParseCIDR(v); err != nil {
+			return fmt.Errorf("%s 的值不是合法网段（例 1.2.3.0/24）: %q", kind, v)
+		}
+	case "DST-PORT", "SRC-PORT", "IN-PORT":
+		return checkPorts(kind, v)
+	case "NETWORK":
+		if l := strings.ToLower(v); l != "tcp" && l != "udp" {
+			return fmt.Errorf("NETWORK 只能是 tcp / udp: %q", v)
+		}
+	case "IP-ASN", "SRC-IP-ASN", "UID", "DSCP":
+		if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return fmt.Errorf("%s 的值应该是数字: %q", kind, v)
+		}
+	case "GEOIP", "SRC-GEOIP", "GEOSITE", "RULE-SET", "PROCESS-NAME", "PROCESS-PATH",
+		"IN-TYPE", "IN-USER", "IN-NAME", "SUB-RULE":
+		// 值是自由文本（国家码 / 站点集 / 进程名…），非空即可。
+	default:
+		return fmt.Errorf("不认识的规则类型 %q（常用：DOMAIN-SUFFIX / DOMAIN / DOMAIN-KEYWORD / IP-CIDR / PROCESS-NAME）", kind)
+	}
+	return nil
+}
+
+// checkPorts 校验端口表达式：单个端口、a-b 区间，多段用 / 隔开（80/443/8000-9000）。
+func checkPorts(kind, v string) error {
+	for _, seg := range strings.Split(v, "/") {
+		lo, hi, isRange := strings.Cut(seg, "-")
+		a, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		b := a
+		var err2 error
+		if isRange {
+			b, err2 = strconv.Atoi(strings.TrimSpace(hi))
+		}
+		if err1 != nil || err2 != nil || a < 1 || b > 65535 || a > b {
+			return fmt.Errorf("%s 端口写错了: %q（1-65535，区间写 1000-2000，多个用 / 隔开）", kind, v)
+		}
+	}
+	return nil
+}
+
+func checkParens(s string) error {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return errors.New("逻辑规则括号不配对")
+			}
+		}
+	}
+	if depth != 0 || !strings.Contains(s, "(") {
+		return errors.New("逻辑规则括号不配对（格式 AND,((DOMAIN,a.com),(NETWORK,UDP))）")
+	}
+	return nil
+}
+
+// lintEntry 是展平后的一条自定义规则，顺序同渲染顺序。
+type lintEntry struct {
+	where  string
+	rule   string
+	parsed parsedRule
+	err    error
+}
+
+// Lint 按渲染顺序（reject → direct → proxy → groups）检查全部自定义规则。
+// targets 是当前已知的策略组名（含 BuiltinTargets）；传 nil 表示不知道有哪些组，跳过目标检查。
+func Lint(ex config.ExtraRules, targets []string) []Issue {
+	var entries []lintEntry
+	add := func(where string, list []string) {
+		for i, r := range list {
+			p, err := parseRule(r)
+			entries = append(entries, lintEntry{where: fmt.Sprintf("%s[%d]", where, i), rule: r, parsed: p, err: err})
+		}
+	}
+	var issues []Issue
+	add("extras.reject", ex.Reject)
+	add("extras.direct", ex.Direct)
+	add("extras.proxy", ex.Proxy)
+	for _, g := range ex.Groups {
+		target := strings.TrimSpace(g.Target)
+		where := "extras.groups[" + target + "]"
+		if target != "" && !TargetKnown(targets, target) {
+			issues = append(issues, Issue{
+				Severity: SeverityError, Where: where, Rule: target,
+				Message: fmt.Sprintf("策略组 %q 在当前配置里不存在，mihomo 会拒绝启动", target),
+			})
+		}
+		add(where, g.Rules)
+	}
+
+	for i, e := range entries {
+		if e.err != nil {
+			issues = append(issues, Issue{Severity: SeverityError, Where: e.where, Rule: e.rule, Message: e.err.Error()})
+			continue
+		}
+		for _, prev := range entries[:i] {
+			if prev.err != nil {
+				continue
+			}
+			if sameRule(prev.parsed, e.parsed) {
+				issues = append(issues, Issue{
+					Severity: SeverityWarn, Where: e.where, Rule: e.rule,
+					Message: fmt.Sprintf("和 %s 重复，这条不会生效", prev.where),
+				})
+				break
+			}
+			if covers(prev.parsed, e.parsed) {
+				issues = append(issues, Issue{
+					Severity: SeverityWarn, Where: e.where, Rule: e.rule,
+					Message: fmt.Sprintf("被前面的 %s（%s）挡住，永远不会命中", prev.where, prev.rule),
+				})
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// TargetKnown 报告策略组 target 在 targets（PolicyTargets 的结果）里存在。
+// targets 为 nil 表示不知道有哪些组，一律当存在。
+func TargetKnown(targets []string, target string) bool {
+	if targets == nil {
+		return true
+	}
+	target = strings.TrimSpace(target)
+	return slices.Contains(targets, target) || slices.Contains(targets, ProviderTarget(target))
+}
+
+func sameRule(a, b parsedRule) bool {
+	return a.kind == b.kind && strings.EqualFold(a.payload, b.payload) && a.noResolve == b.noResolve
+}
+
+// covers 报告 a 能匹配的集合是否包含 b 的 —— a 在前时 b 永远轮不到。
+// 只判断能静态确定的组合，拿不准就返回 false，宁可漏报不误报。
+func covers(a, b parsedRule) bool {
+	ap, bp := strings.ToLower(a.payload), strings.ToLower(b.payload)
+	switch a.kind {
+	case "DOMAIN-SUFFIX":
+		if b.kind == "DOMAIN" || b.kind == "DOMAIN-SUFFIX" {
+			return bp == ap || strings.HasSuffix(bp, "."+ap)
+		}
+	case "DOMAIN-KEYWORD":
+		switch b.kind {
+		case "DOMAIN", "DOMAIN-SUFFIX", "DOMAIN-KEYWORD":
+			return strings.Contains(bp, ap)
+		}
+	case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+		switch b.kind {
+		case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+		default:
+			return false
+		}
+		// 来源网段和目标网段互不相干；带 no-resolve 的不匹配域名连接，挡不住不带 no-resolve 的。
+		if (a.kind == "SRC-IP-CIDR") != (b.kind == "SRC-IP-CIDR") || (a.noResolve && !b.noResolve) {
+			return false
+		}
+		_, an, err1 := net.ParseCIDR(a.payload)
+		_, bn, err2 := net.ParseCIDR(b.payload)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		aOnes, _ := an.Mask.Size()
+		bOnes, _ := bn.Mask.Size()
+		return an.Contains(bn.IP) && aOnes <= bOnes && len(an.IP) == len(bn.IP)
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package traffic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+func TestValidateRule(t *testing.T) {
+	good := []string{
+		"DOMAIN-SUFFIX,openai.com",
+		"DOMAIN,chat.openai.com",
+		"IP-CIDR,1.2.3.0/24,no-resolve",
+		"IP-CIDR6,2001:db8::/32",
+		"DST-PORT,80/443/8000-9000",
+		"PROCESS-NAME,Cursor",
+		"GEOIP,CN",
+		"DOMAIN-SUFFIX,example.com,DIRECT",
+		"AND,((DOMAIN,baidu.com),(NETWORK,UDP))",
+	}
+	for _, r := range good {
+		if err := ValidateRule(r); err != nil {
+			t.Errorf("ValidateRule(%q) = %v, want nil", r, err)
+		}
+	}
+	bad := []string{
+		"",
+		"DOMAIN-SUFIX,x.com",          // 拼错类型
+		"DOMAIN-SUFFIX",               // 缺值
+		"DOMAIN,https://x.com/path",   // 带协议
+		"IP-CIDR,1.2.3.0/33",          // 网段错
+		"IP-CIDR,1.2.3.4",             // 缺掩码
+		"DST-PORT,0",                  // 端口越界
+		"DST-PORT,9000-8000",          // 区间倒置
+		"DOMAIN-SUFFIX,x.com,MyGroup", // 自定义规则不写去向
+		"MATCH,Proxy",
+		"AND,((DOMAIN,baidu.com)",
+	}
+	for _, r := range bad {
+		if err := ValidateRule(r); err == nil {
+			t.Errorf("ValidateRule(%q) = nil, want error", r)
+		}
+	}
+}
+
+func TestLintFindsDuplicatesShadowsAndTargets(t *testing.T) {
+	ex := config.ExtraRules{
+		Reject: []string{"DOMAIN-KEYWORD,ads"},
+		Direct: []string{
+			"DOMAIN-SUFFIX,example.com",
+			"DOMAIN,www.example.com", // 被 suffix 挡住
+			"IP-CIDR,10.0.0.0/8",
+			"IP-CIDR,10.1.0.0/16",   // 被大网段挡住
+			"DOMAIN-SUFIX,typo.com", // 语法错误
+		},
+		Proxy: []string{
+			"domain-suffix,EXAMPLE.com", // 和 direct[0] 重复（大小写不敏感）
+			"DOMAIN,cdn.ads.net",        // 被 reject 的关键字挡住
+			"IP-CIDR,10.2.0.0/16,no-resolve",
+			"DOMAIN-SUFFIX,openai.com",
+		},
+		Groups: []config.TargetedRules{{Target: "不存在的组", Rules: []string{"DOMAIN-SUFFIX,claude.ai"}}},
+	}
+	issues := Lint(ex, append([]string{"🇺🇸 美国"}, BuiltinTargets...))
+	got := map[string]string{}
+	for _, is := range issues {
+		got[is.Where] = is.Severity + " " + is.Message
+	}
+	want := map[string]string{
+		"extras.direct[1]":     "warn 被前面的 extras.direct[0]",
+		"extras.direct[3]":     "warn 被前面的 extras.direct[2]",
+		"extras.direct[4]":     "error 不认识的规则类型",
+		"extras.proxy[0]":      "warn 和 extras.direct[0] 重复",
+		"extras.proxy[1]":      "warn 被前面的 extras.reject[0]",
+		"extras.proxy[2]":      "warn 被前面的 extras.direct[2]",
+		"extras.groups[不存在的组]": "error 策略组",
+	}
+	for where, prefix := range want {
+		if !strings.HasPrefix(got[where], prefix) {
+			t.Errorf("%s: got %q, want prefix %q", where, got[where], prefix)
+		}
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("unexpected extra issues: %+v", issues)
+	}
+
+	// 不知道有哪些组时不报目标问题
+	for _, is := range Lint(ex, nil) {
+		if strings.HasPrefix(is.Where, "extras.groups[") && is.Rule == "不存在的组" {
+			t.Fatalf("targets=nil should skip target check, got %+v", is)
+		}
+	}
+}
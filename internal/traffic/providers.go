@@ -0,0 +1,84 @@
+package traffic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tght/lan-proxy-gateway/internal/config"
+)
+
+// ProviderDir 是 rule-provider 缓存文件在 mihomo 工作目录下的子目录。
+const ProviderDir = "rule-providers"
+
+// defaultProviderInterval 是 http 规则集的默认更新间隔（秒）。
+const defaultProviderInterval = 86400
+
+// ProviderPath 返回规则集缓存文件相对 mihomo 工作目录的路径，例 rule-providers/openai.yaml。
+// mihomo 只允许工作目录内的 path，所以 file 类型也要先拷进来再用这个路径。
+func ProviderPath(p config.RuleProvider) string {
+	ext := "yaml"
+	if p.Format == "text" {
+		ext = "txt"
+	}
+	return ProviderDir + "/" + p.Name + "." + ext
+}
+
+// ProviderTarget 把 rule-provider 的 target 翻成 mihomo 的策略名：
+// direct / proxy / reject（不分大小写）映射成 DIRECT / Proxy / REJECT，其余原样当组名。
+func ProviderTarget(target string) string {
+	t := strings.TrimSpace(target)
+	switch strings.ToLower(t) {
+	case "direct":
+		return "DIRECT"
+	case "proxy":
+		return ProxyTag
+	case "reject":
+		return "REJECT"
+	}
+	return t
+}
+
+// providerRules 返回每个规则集对应的 RULE-SET 规则行（不带 "  - " 前缀）。
+// ipcidr 规则集统一加 no-resolve：只匹配本来就是 IP 的连接，不为了查规则去解析域名。
+func providerRules(list []config.RuleProvider) []string {
+	out := make([]string, 0, len(list))
+	for _, p := range list {
+		line := "RULE-SET," + p.Name + "," + ProviderTarget(p.Target)
+		if p.Behavior == "ipcidr" {
+			line += ",no-resolve"
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// RenderProviders 返回 `rule-providers:` 顶层块；没声明任何规则集时返回空串。
+// file 类型也渲染成 type: file + 工作目录内的缓存路径，由 engine 负责把用户文件拷过去。
+func RenderProviders(t config.TrafficConfig) string {
+	if len(t.RuleProviders) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("rule-providers:\n")
+	for _, p := range t.RuleProviders {
+		format := p.Format
+		if format == "" {
+			format = "yaml"
+		}
+		fmt.Fprintf(&b, "  %s:\n", strconv.Quote(p.Name))
+		fmt.Fprintf(&b, "    type: %s\n", p.Type)
+		fmt.Fprintf(&b, "    behavior: %s\n", p.Behavior)
+		fmt.Fprintf(&b, "    format: %s\n", format)
+		if p.Type == config.RuleProviderHTTP {
+			interval := p.Interval
+			if interval == 0 {
+				interval = defaultProviderInterval
+			}
+			fmt.Fprintf(&b, "    url: %s\n", strconv.Quote(p.URL))
+			fmt.Fprintf(&b, "    interval: %d\n", interval)
+		}
+		fmt.Fprintf(&b, "    path: %s\n", strconv.Quote("./"+ProviderPath(p)))
+	}
+	return b.String()
+}
@@ -19,32 +19,62 @@ const ProxyTag = "Proxy"
 
 // Render returns the `rules:` YAML block (including the "rules:" header).
 // It's deterministic: no randomness, no time-based input.
-func Render(t config.TrafficConfig) string {
+//
+// devices are the per-device policies from gateway.device_policies, already
+// resolved to IPs by the caller (entries without an IP are skipped). They are
+// emitted right after adblock and before user extras: a device pinned to
+// DIRECT stays direct no matter what the extras say, but still loses ads.
+func Render(t config.TrafficConfig, devices []config.DevicePolicy) string {
 	var b strings.Builder
 	b.WriteString("rules:\n")
+	for _, r := range Rules(t, devices) {
+		b.WriteString("  - ")
+		b.WriteString(r.Line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Rule 是渲染出的一条规则，带上它从哪来（给 `gateway rule test` 解释命中用）。
+type Rule struct {
+	Line   string `json:"line"`   // 最终写进 config.yaml 的规则行（已带 verdict）
+	Source string `json:"source"` // adblock / device / extras.direct / rule-provider:openai / china_direct / match …
+}
 
-	emit := func(lines []string, verdict string) {
+// Rules 按 mihomo 的匹配顺序返回完整规则列表。Render 只是把它拼成 YAML。
+func Rules(t config.TrafficConfig, devices []config.DevicePolicy) []Rule {
+	var out []Rule
+	emit := func(lines []string, verdict, source string) {
 		for _, l := range lines {
-			b.WriteString("  - ")
-			b.WriteString(withVerdict(l, verdict))
-			b.WriteString("\n")
+			out = append(out, Rule{Line: withVerdict(l, verdict), Source: source})
+		}
+	}
+	// raw 用于目标已经拼在行里的规则：不走 withVerdict（组名不在它认识的裁决列表里会被再追加一次）。
+	raw := func(lines []string, source string) {
+		for _, l := range lines {
+			out = append(out, Rule{Line: l, Source: source})
 		}
 	}
 
 	// Adblock is orthogonal to mode: even "direct" mode still kills ads.
 	if t.Adblock {
-		emit(rulesets.Adblock(), "REJECT")
+		emit(rulesets.Adblock(), "REJECT", "adblock")
 	}
+	raw(deviceRules(devices), "device")
 	// User extras always come before built-ins so they can override.
-	emit(t.Extras.Reject, "REJECT")
-	emit(t.Extras.Direct, "DIRECT")
-	emit(t.Extras.Proxy, ProxyTag)
+	emit(t.Extras.Reject, "REJECT", "extras.reject")
+	emit(t.Extras.Direct, "DIRECT", "extras.direct")
+	emit(t.Extras.Proxy, ProxyTag, "extras.proxy")
 	for _, group := range t.Extras.Groups {
 		target := strings.TrimSpace(group.Target)
 		if target == "" {
 			continue
 		}
-		emit(group.Rules, target)
+		emit(group.Rules, target, "extras.group:"+target)
+	}
+	// 外部规则集（rule-providers）紧跟自定义规则：比用户手写的低、比内置规则集高。
+	for i, l := range providerRules(t.RuleProviders) {
+		raw([]string{l}, "rule-provider:"+t.RuleProviders[i].Name)
 	}
 
 	switch t.Mode {
@@ -53,31 +83,29 @@ func Render(t config.TrafficConfig) string {
 	case config.ModeGlobal:
 		// Still respect LAN direct so we don't route 192.168/16 through the proxy.
 		if t.Rulesets.LANDirect {
-			emit(rulesets.LANDirect(), "DIRECT")
+			emit(rulesets.LANDirect(), "DIRECT", "lan_direct")
 		}
 	case config.ModeRule:
 		fallthrough
 	default:
 		if t.Rulesets.LANDirect {
-			emit(rulesets.LANDirect(), "DIRECT")
+			emit(rulesets.LANDirect(), "DIRECT", "lan_direct")
 		}
 		if t.Rulesets.Nintendo {
-			emit(rulesets.Nintendo(), ProxyTag)
+			emit(rulesets.Nintendo(), ProxyTag, "nintendo")
 		}
 		if t.Rulesets.Apple {
-			emit(rulesets.Apple(), "DIRECT")
+			emit(rulesets.Apple(), "DIRECT", "apple")
 		}
 		if t.Rulesets.ChinaDirect {
-			emit(rulesets.ChinaDirect(), "DIRECT")
+			emit(rulesets.ChinaDirect(), "DIRECT", "china_direct")
 		}
 		if t.Rulesets.Global {
-			emit(rulesets.Global(), ProxyTag)
+			emit(rulesets.Global(), ProxyTag, "global")
 		}
-		b.WriteString("  - MATCH,")
-		b.WriteString(ProxyTag)
-		b.WriteString("\n")
+		raw([]string{"MATCH," + ProxyTag}, "match")
 	}
-	return b.String()
+	return out
 }
 
 // withVerdict returns a rule line guaranteed to have a verdict at the right spot.
@@ -9,7 +9,7 @@ import (
 
 func TestRenderRuleModeIncludesMatchProxy(t *testing.T) {
 	cfg := config.Default().Traffic
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	if !strings.Contains(out, "MATCH,Proxy") {
 		t.Fatalf("expected MATCH,Proxy at tail, got:\n%s", out)
 	}
@@ -20,7 +20,7 @@ func TestRenderRuleModeIncludesMatchProxy(t *testing.T) {
 
 func TestRenderGlobalRulesIncludeFastSpeedtestChain(t *testing.T) {
 	cfg := config.Default().Traffic
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	for _, want := range []string{
 		"DOMAIN-SUFFIX,fast.com,Proxy",
 		"DOMAIN-SUFFIX,api.fast.com,Proxy",
@@ -38,7 +38,7 @@ func TestRenderGlobalRulesIncludeFastSpeedtestChain(t *testing.T) {
 func TestRenderDirectModeSkipsProxyRules(t *testing.T) {
 	cfg := config.Default().Traffic
 	cfg.Mode = config.ModeDirect
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	if strings.Contains(out, "MATCH,Proxy") {
 		t.Fatalf("direct mode should not emit MATCH,Proxy")
 	}
@@ -51,7 +51,7 @@ func TestRenderDirectModeSkipsProxyRules(t *testing.T) {
 func TestRenderGlobalModeLANStillDirect(t *testing.T) {
 	cfg := config.Default().Traffic
 	cfg.Mode = config.ModeGlobal
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	if !strings.Contains(out, "IP-CIDR,192.168.0.0/16") {
 		t.Fatalf("LAN direct block missing in global mode:\n%s", out)
 	}
@@ -64,7 +64,7 @@ func TestRenderGlobalModeLANStillDirect(t *testing.T) {
 func TestRenderAdblockOff(t *testing.T) {
 	cfg := config.Default().Traffic
 	cfg.Adblock = false
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	if strings.Contains(out, "doubleclick") {
 		t.Fatalf("adblock disabled but doubleclick still present:\n%s", out)
 	}
@@ -72,7 +72,7 @@ func TestRenderAdblockOff(t *testing.T) {
 
 func TestNoResolveVerdictPlacement(t *testing.T) {
 	cfg := config.Default().Traffic
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	// Every IP-CIDR line must have "DIRECT,no-resolve" (not "no-resolve,DIRECT")
 	for _, line := range strings.Split(out, "\n") {
 		if !strings.Contains(line, "IP-CIDR") {
@@ -92,7 +92,7 @@ func TestRenderExtrasRespected(t *testing.T) {
 	cfg := config.Default().Traffic
 	cfg.Extras.Direct = []string{"DOMAIN-SUFFIX,corp.example.com"}
 	cfg.Extras.Proxy = []string{"DOMAIN-SUFFIX,foo.bar,Proxy"} // already has verdict
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	if !strings.Contains(out, "DOMAIN-SUFFIX,corp.example.com,DIRECT") {
 		t.Fatalf("extra direct not appended with DIRECT verdict:\n%s", out)
 	}
@@ -116,7 +116,7 @@ func TestRenderTargetedGroupExtras(t *testing.T) {
 			},
 		},
 	}
-	out := Render(cfg)
+	out := Render(cfg, nil)
 	for _, want := range []string{
 		"DOMAIN-SUFFIX,openai.com,🛬 AI落地节点",
 		"IP-CIDR,1.2.3.0/24,🛬 AI落地节点,no-resolve",
@@ -126,3 +126,72 @@ func TestRenderTargetedGroupExtras(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderRuleProviders(t *testing.T) {
+	cfg := config.Default().Traffic
+	cfg.Extras.Direct = []string{"DOMAIN-SUFFIX,example.cn"}
+	cfg.RuleProviders = []config.RuleProvider{
+		{Name: "openai", Type: config.RuleProviderHTTP, Behavior: "classical", URL: "https://example.com/openai.yaml", Target: "🇺🇸 美国"},
+		{Name: "cn-ip", Type: config.RuleProviderFile, Behavior: "ipcidr", Format: "text", Path: "/etc/cn.txt", Target: "direct"},
+	}
+	out := Render(cfg, nil)
+	for _, want := range []string{
+		"  - RULE-SET,openai,🇺🇸 美国\n",
+		"  - RULE-SET,cn-ip,DIRECT,no-resolve\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	// 自定义规则 > 外部规则集 > 内置规则集
+	extra := strings.Index(out, "example.cn")
+	set := strings.Index(out, "RULE-SET,openai")
+	builtin := strings.Index(out, "GEOIP,CN")
+	if !(extra < set && set < builtin) {
+		t.Fatalf("unexpected rule order (extra=%d set=%d builtin=%d):\n%s", extra, set, builtin, out)
+	}
+
+	block := RenderProviders(cfg)
+	for _, want := range []string{
+		"rule-providers:\n",
+		"  \"openai\":\n    type: http\n    behavior: classical\n    format: yaml\n    url: \"https://example.com/openai.yaml\"\n    interval: 86400\n    path: \"./rule-providers/openai.yaml\"\n",
+		"  \"cn-ip\":\n    type: file\n    behavior: ipcidr\n    format: text\n    path: \"./rule-providers/cn-ip.txt\"\n",
+	} {
+		if !strings.Contains(block, want) {
+			t.Fatalf("missing %q in:\n%s", want, block)
+		}
+	}
+	if RenderProviders(config.Default().Traffic) != "" {
+		t.Fatal("no providers should render nothing")
+	}
+}
+
+func TestRenderDevicePolicies(t *testing.T) {
+	cfg := config.Default().Traffic
+	cfg.Extras.Proxy = []string{"DOMAIN-SUFFIX,example.com"}
+	out := Render(cfg, []config.DevicePolicy{
+		{IP: "192.168.1.50", Target: "direct"},
+		{IP: "192.168.1.0/28", Target: "🇯🇵 日本"},
+		{IP: "fd00::1", Target: "reject"},
+		{MAC: "aa:bb:cc:dd:ee:ff", Target: "proxy"}, // MAC 没解析出 IP：跳过
+	})
+	for _, want := range []string{
+		"  - SRC-IP-CIDR,192.168.1.50/32,DIRECT\n",
+		"  - SRC-IP-CIDR,192.168.1.0/28,🇯🇵 日本\n",
+		"  - SRC-IP-CIDR,fd00::1/128,REJECT\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "SRC-IP-CIDR") != 3 {
+		t.Fatalf("policy without IP should be skipped:\n%s", out)
+	}
+	// 广告拦截 > 设备策略 > 自定义规则
+	adblock := strings.Index(out, ",REJECT\n")
+	device := strings.Index(out, "SRC-IP-CIDR")
+	extra := strings.Index(out, "example.com")
+	if !(adblock < device && device < extra) {
+		t.Fatalf("unexpected rule order (adblock=%d device=%d extra=%d):\n%s", adblock, device, extra, out)
+	}
+}